@@ -65,6 +65,14 @@ type Schema struct {
 	Items      *Schema           `yaml:"items,omitempty"`
 	Ref        string            `yaml:"$ref,omitempty"`
 	Required   []string          `yaml:"required,omitempty"`
+	MaxLength  *int              `yaml:"maxLength,omitempty"`
+	ReadOnly   bool              `yaml:"readOnly,omitempty"`
+	Enum       []interface{}     `yaml:"enum,omitempty"`
+
+	Minimum          *float64 `yaml:"minimum,omitempty"`
+	Maximum          *float64 `yaml:"maximum,omitempty"`
+	ExclusiveMinimum bool     `yaml:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum bool     `yaml:"exclusiveMaximum,omitempty"`
 }
 
 type Header struct {
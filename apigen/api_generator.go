@@ -4,6 +4,7 @@ import (
 	"db_meta/api"
 	"db_meta/dbstructs"
 	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -11,7 +12,7 @@ import (
 	"gopkg.in/yaml.v2"
 )
 
-func GenerateOpenAPI(tables []*dbstructs.TableMetadata, config *api.APIConfig) ([]byte, error) {
+func GenerateOpenAPI(tables []*dbstructs.TableMetadata, views []*dbstructs.ViewMetadata, types []*dbstructs.TypeMetadata, config *api.APIConfig) ([]byte, error) {
 	openAPI := api.OpenAPI{
 		OpenAPI: "3.0.0",
 		Info: api.Info{
@@ -24,11 +25,23 @@ func GenerateOpenAPI(tables []*dbstructs.TableMetadata, config *api.APIConfig) (
 			Schemas: make(map[string]api.Schema),
 		},
 	}
+
+	// User-defined types are reusable schemas the columns refer to
+	typeRefs := generateSchemasForTypes(&openAPI, types)
   
 	for _, table := range tables {
 		if config == nil || (*config)[strings.ToLower(table.TableName)] != nil {
 			generatePathsForTable(&openAPI, table, config)
-			generateSchemaForTable(&openAPI, table)
+			generateSchemaForTable(&openAPI, table, typeRefs)
+		}
+	}
+
+	// Views are read-only, they only get a list endpoint
+	for _, view := range views {
+		table := viewAsTable(view)
+		if config == nil || (*config)[strings.ToLower(table.TableName)] != nil {
+			generatePathsForView(&openAPI, table, config)
+			generateSchemaForTable(&openAPI, table, typeRefs)
 		}
 	}
 
@@ -132,12 +145,14 @@ func generatePathsForTable(openAPI *api.OpenAPI, table *dbstructs.TableMetadata,
 
 		// relations
 		for _, relation := range table.Relationships {
-			relatedPath := fmt.Sprintf("%s/{id}/%s", basePath, strings.ToLower(relation.RelatedTableName))
+			relatedTableName, joinDescription := describeRelation(table, relation)
+			relatedPath := fmt.Sprintf("%s/{id}/%s", basePath, strings.ToLower(relatedTableName))
 
 			//	relatedMethodConfig := getMethodConfig(tableConfig, relatedPath, "GET")
 			relatedOperation := &api.Operation{
-				Summary:     fmt.Sprintf("List %s for %s", relation.RelatedTableName, table.TableName),
-				OperationID: generateUniqueOperationID(table.TableName, "listRelated"+relation.RelatedTableName),
+				Summary:     fmt.Sprintf("List %s for %s", relatedTableName, table.TableName),
+				Description: joinDescription,
+				OperationID: generateUniqueOperationID(table.TableName, "listRelated"+relatedTableName),
 				Parameters:  append([]api.Parameter{idParam}, generateQueryParameters(table, config)...),
 				Responses:   generateStandardResponses(table, true, GETMethodConfig),
 			}
@@ -150,6 +165,62 @@ func generatePathsForTable(openAPI *api.OpenAPI, table *dbstructs.TableMetadata,
 	}
 }
 
+// describeRelation returns the table on the other end of the relation, seen from
+// table, and the join condition when the foreign key columns are known.
+func describeRelation(table *dbstructs.TableMetadata, relation *dbstructs.RelationshipMetadata) (string, string) {
+	relatedTableName := relation.RelatedTableName
+	if relation.RelatedTableName == table.TableName && relation.SourceTableName != "" {
+		relatedTableName = relation.SourceTableName
+	}
+	if len(relation.SourceColumns) == 0 || len(relation.SourceColumns) != len(relation.TargetColumns) {
+		return relatedTableName, ""
+	}
+
+	sourceTableName := relation.SourceTableName
+	if sourceTableName == "" {
+		sourceTableName = table.TableName
+	}
+	conditions := make([]string, len(relation.SourceColumns))
+	for i, column := range relation.SourceColumns {
+		conditions[i] = fmt.Sprintf("%s.%s = %s.%s", sourceTableName, column, relation.RelatedTableName, relation.TargetColumns[i])
+	}
+	return relatedTableName, "Joined on " + strings.Join(conditions, " AND ")
+}
+
+// viewAsTable lets views go through the table schema and parameters generators
+func viewAsTable(view *dbstructs.ViewMetadata) *dbstructs.TableMetadata {
+	return &dbstructs.TableMetadata{
+		Schema:    view.Schema,
+		TableName: view.ViewName,
+		Columns:   view.Columns,
+	}
+}
+
+func generatePathsForView(openAPI *api.OpenAPI, view *dbstructs.TableMetadata, config *api.APIConfig) {
+	basePath := fmt.Sprintf("/%s", strings.ToLower(view.TableName))
+	var viewConfig api.TableConfig
+	if config != nil {
+		viewConfig = (*config)[strings.ToLower(view.TableName)]
+	}
+	if config != nil && (viewConfig == nil || !viewConfig[basePath]["GET"].Included) {
+		return
+	}
+
+	GETMethodConfig := getMethodConfig(viewConfig, basePath, "GET")
+	getOperation := &api.Operation{
+		Summary:     "List " + view.TableName,
+		Description: "Read-only view",
+		OperationID: generateUniqueOperationID(view.TableName, "list"),
+		Parameters:  generateQueryParameters(view, config),
+		Responses:   generateStandardResponses(view, true, GETMethodConfig),
+	}
+	addRequestHeaders(getOperation, GETMethodConfig)
+
+	openAPI.Paths[basePath] = api.PathItem{
+		Get: getOperation,
+	}
+}
+
 func getMethodConfig(tableConfig api.TableConfig, path string, method string) api.MethodConfig {
 	if tableConfig != nil {
 		if pathConfig, ok := tableConfig[path]; ok {
@@ -196,7 +267,10 @@ func generateQueryParameters(table *dbstructs.TableMetadata, config *api.APIConf
 		},
 	}
 
-	tableConfig := (*config)[strings.ToLower(table.TableName)]
+	var tableConfig api.TableConfig
+	if config != nil {
+		tableConfig = (*config)[strings.ToLower(table.TableName)]
+	}
 	basePath := fmt.Sprintf("/%s", strings.ToLower(table.TableName))
 
 	for _, column := range table.Columns {
@@ -302,13 +376,16 @@ func generateStandardResponses(table *dbstructs.TableMetadata, isArray bool, met
 	return responses
 }
 
-func generateSchemaForTable(openAPI *api.OpenAPI, table *dbstructs.TableMetadata) {
+func generateSchemaForTable(openAPI *api.OpenAPI, table *dbstructs.TableMetadata, typeRefs map[string]string) {
 	properties := make(map[string]api.Schema)
 	required := []string{}
+	rules := checkConstraintRules(table.CheckConstraints)
 
 	for _, column := range table.Columns {
-		properties[column.ColumnName] = api.Schema{Type: mapSQLTypeToJSONType(column.DataType)}
-		if column.NotNull {
+		property := generateSchemaForColumn(column, rules[column.ColumnName], typeRefs)
+		properties[column.ColumnName] = property
+
+		if column.NotNull && column.Default == nil && !property.ReadOnly {
 			required = append(required, column.ColumnName)
 		}
 	}
@@ -320,6 +397,94 @@ func generateSchemaForTable(openAPI *api.OpenAPI, table *dbstructs.TableMetadata
 	}
 }
 
+func generateSchemaForColumn(column *dbstructs.Column, rule *columnRule, typeRefs map[string]string) api.Schema {
+	if ref, ok := typeRefs[column.UserType]; ok && column.UserType != "" {
+		return api.Schema{Ref: ref}
+	}
+
+	property := api.Schema{Type: mapSQLTypeToJSONType(column.DataType)}
+	if property.Type == "string" {
+		property.MaxLength = column.CharacterLength
+	}
+	if len(column.EnumValues) > 0 {
+		property.Enum = stringsAsValues(column.EnumValues)
+	}
+	applyColumnRule(&property, rule)
+	// Values assigned by the database are never sent by clients
+	property.ReadOnly = column.AutoIncrement || column.Generated != ""
+	return property
+}
+
+// generateSchemasForTypes adds a component schema per user-defined type and
+// returns their $ref by qualified type name.
+func generateSchemasForTypes(openAPI *api.OpenAPI, types []*dbstructs.TypeMetadata) map[string]string {
+	typeRefs := make(map[string]string)
+	for _, typ := range types {
+		typeRefs[typ.QualifiedName()] = "#/components/schemas/" + typ.TypeName
+	}
+
+	for _, typ := range types {
+		var schema api.Schema
+		switch typ.Kind {
+		case dbstructs.TypeKindEnum:
+			schema = api.Schema{Type: "string", Enum: stringsAsValues(typ.Values)}
+
+		case dbstructs.TypeKindDomain:
+			baseType, length := splitTypeLength(typ.BaseType)
+			schema = api.Schema{Type: mapSQLTypeToJSONType(baseType)}
+			if schema.Type == "string" {
+				schema.MaxLength = length
+			}
+			var checks []*dbstructs.CheckConstraint
+			for _, expression := range typ.Checks {
+				checks = append(checks, &dbstructs.CheckConstraint{Expression: expression})
+			}
+			// Domain checks name the checked value VALUE
+			for name, rule := range checkConstraintRules(checks) {
+				if strings.EqualFold(name, "VALUE") {
+					applyColumnRule(&schema, rule)
+				}
+			}
+
+		case dbstructs.TypeKindComposite:
+			schema = api.Schema{Type: "object", Properties: make(map[string]api.Schema)}
+			for _, attribute := range typ.Attributes {
+				schema.Properties[attribute.ColumnName] = generateSchemaForColumn(attribute, nil, typeRefs)
+				if attribute.NotNull {
+					schema.Required = append(schema.Required, attribute.ColumnName)
+				}
+			}
+
+		default:
+			continue
+		}
+		openAPI.Components.Schemas[typ.TypeName] = schema
+	}
+	return typeRefs
+}
+
+// splitTypeLength splits "varchar(20)" into "varchar" and 20
+func splitTypeLength(dataType string) (string, *int) {
+	open := strings.Index(dataType, "(")
+	if open < 0 || !strings.HasSuffix(dataType, ")") {
+		return dataType, nil
+	}
+	baseType := strings.TrimSpace(dataType[:open])
+	length, err := strconv.Atoi(dataType[open+1 : len(dataType)-1])
+	if err != nil {
+		return baseType, nil
+	}
+	return baseType, &length
+}
+
+func stringsAsValues(values []string) []interface{} {
+	enum := make([]interface{}, len(values))
+	for i, value := range values {
+		enum[i] = value
+	}
+	return enum
+}
+
 func generateExampleForTable(table *dbstructs.TableMetadata) map[string]interface{} {
 	example := make(map[string]interface{})
 	for _, column := range table.Columns {
@@ -0,0 +1,46 @@
+package apigen
+
+import (
+	"db_meta/api"
+	"db_meta/dbstructs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateSchemasForTypes(t *testing.T) {
+	types := []*dbstructs.TypeMetadata{
+		{Schema: "public", TypeName: "mood", Kind: dbstructs.TypeKindEnum, Values: []string{"sad", "happy"}},
+		{Schema: "public", TypeName: "us_zip", Kind: dbstructs.TypeKindDomain, BaseType: "character varying(10)", Checks: []string{"(length((VALUE)::text) <= 5)"}},
+		{Schema: "public", TypeName: "percentage", Kind: dbstructs.TypeKindDomain, BaseType: "integer", Checks: []string{"((VALUE >= 0) AND (VALUE <= 100))"}},
+		{Schema: "public", TypeName: "address", Kind: dbstructs.TypeKindComposite, Attributes: []*dbstructs.Column{
+			{ColumnName: "street", DataType: "text", NotNull: true},
+			{ColumnName: "zip", DataType: "character varying", UserType: "public.us_zip"},
+		}},
+	}
+	table := &dbstructs.TableMetadata{
+		TableName: "person",
+		Columns: []*dbstructs.Column{
+			{ColumnName: "mood", DataType: "USER-DEFINED", UserType: "public.mood"},
+			{ColumnName: "size", DataType: "enum", EnumValues: []string{"small", "large"}},
+			{ColumnName: "home", DataType: "USER-DEFINED", UserType: "public.address"},
+		},
+	}
+	openAPI := &api.OpenAPI{Components: api.Components{Schemas: make(map[string]api.Schema)}}
+
+	typeRefs := generateSchemasForTypes(openAPI, types)
+	generateSchemaForTable(openAPI, table, typeRefs)
+
+	schemas := openAPI.Components.Schemas
+	assert.Equal(t, []interface{}{"sad", "happy"}, schemas["mood"].Enum)
+	assert.Equal(t, 5, *schemas["us_zip"].MaxLength)
+	assert.Equal(t, "integer", schemas["percentage"].Type)
+	assert.Equal(t, 100.0, *schemas["percentage"].Maximum)
+	assert.Equal(t, []string{"street"}, schemas["address"].Required)
+	assert.Equal(t, "#/components/schemas/us_zip", schemas["address"].Properties["zip"].Ref)
+
+	properties := schemas["person"].Properties
+	assert.Equal(t, "#/components/schemas/mood", properties["mood"].Ref)
+	assert.Equal(t, []interface{}{"small", "large"}, properties["size"].Enum)
+	assert.Equal(t, "#/components/schemas/address", properties["home"].Ref)
+}
@@ -0,0 +1,475 @@
+package apigen
+
+import (
+	"db_meta/api"
+	"db_meta/dbstructs"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// columnRule is what the simple CHECK patterns tell about a column: a range
+// (col > 0, col BETWEEN 1 AND 5), a list of values (col IN ('a', 'b')) or a
+// length limit (length(col) <= 20).
+type columnRule struct {
+	Minimum          *float64
+	Maximum          *float64
+	ExclusiveMinimum bool
+	ExclusiveMaximum bool
+	Enum             []interface{}
+	MaxLength        *int
+}
+
+type tokenKind int
+
+const (
+	tokenIdentifier tokenKind = iota
+	tokenNumber
+	tokenString
+	tokenSymbol
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+func (t token) is(text string) bool {
+	return (t.kind == tokenSymbol || t.kind == tokenIdentifier) && strings.EqualFold(t.text, text)
+}
+
+func (t token) isValue() bool {
+	return t.kind == tokenNumber || t.kind == tokenString
+}
+
+// checkConstraintRules returns the rules found in the checks, by column name.
+// Expressions that do not match a known pattern are ignored.
+func checkConstraintRules(checks []*dbstructs.CheckConstraint) map[string]*columnRule {
+	rules := make(map[string]*columnRule)
+	ruleFor := func(column string) *columnRule {
+		if rules[column] == nil {
+			rules[column] = &columnRule{}
+		}
+		return rules[column]
+	}
+
+	for _, check := range checks {
+		for _, conjunct := range splitConjuncts(normalizeTokens(tokenizeCheck(check.Expression))) {
+			applyConjunct(conjunct, ruleFor)
+		}
+	}
+	return rules
+}
+
+// applyColumnRule sets the rule constraints on the schema of a column
+func applyColumnRule(property *api.Schema, rule *columnRule) {
+	if rule == nil {
+		return
+	}
+	if property.Type == "integer" || property.Type == "number" {
+		property.Minimum, property.ExclusiveMinimum = rule.Minimum, rule.ExclusiveMinimum
+		property.Maximum, property.ExclusiveMaximum = rule.Maximum, rule.ExclusiveMaximum
+	}
+	if property.Type == "string" && rule.MaxLength != nil && (property.MaxLength == nil || *rule.MaxLength < *property.MaxLength) {
+		property.MaxLength = rule.MaxLength
+	}
+	if rule.Enum != nil {
+		property.Enum = rule.Enum
+	}
+}
+
+func applyConjunct(tokens []token, ruleFor func(string) *columnRule) {
+	tokens = unwrap(tokens)
+	n := len(tokens)
+
+	switch {
+	// col IN (v1, v2)
+	case n >= 5 && tokens[0].kind == tokenIdentifier && tokens[1].is("IN") && tokens[2].is("(") && tokens[n-1].is(")"):
+		if values, ok := valueList(tokens[3 : n-1]); ok {
+			ruleFor(tokens[0].text).Enum = values
+		}
+
+	// col = ANY (ARRAY[v1, v2]), PostgreSQL rendering of IN lists
+	case n >= 4 && tokens[0].kind == tokenIdentifier && tokens[1].is("=") && tokens[2].is("ANY"):
+		array := unwrap(tokens[3:])
+		if len(array) < 4 || !array[0].is("ARRAY") || !array[1].is("[") || !array[len(array)-1].is("]") {
+			return
+		}
+		if values, ok := valueList(array[2 : len(array)-1]); ok {
+			ruleFor(tokens[0].text).Enum = values
+		}
+
+	// col BETWEEN low AND high
+	case n == 5 && tokens[0].kind == tokenIdentifier && tokens[1].is("BETWEEN") && tokens[3].is("AND"):
+		low, lowOK := numberValue(tokens[2])
+		high, highOK := numberValue(tokens[4])
+		if lowOK && highOK {
+			rule := ruleFor(tokens[0].text)
+			rule.raiseMinimum(low, false)
+			rule.lowerMaximum(high, false)
+		}
+
+	// col > value, value <= col
+	case n == 3 && isRangeOperator(tokens[1]):
+		applyComparison(tokens[0], tokens[1].text, tokens[2], ruleFor)
+
+	// length(col) <= value
+	case n == 6 && isLengthFunction(tokens[0]) && tokens[1].is("(") && tokens[2].kind == tokenIdentifier && tokens[3].is(")"):
+		if limit, ok := numberValue(tokens[5]); ok {
+			applyLengthLimit(tokens[2].text, tokens[4].text, limit, ruleFor)
+		}
+
+	// value >= length(col)
+	case n == 6 && isLengthFunction(tokens[2]) && tokens[3].is("(") && tokens[4].kind == tokenIdentifier && tokens[5].is(")"):
+		if limit, ok := numberValue(tokens[0]); ok {
+			applyLengthLimit(tokens[4].text, flipComparison(tokens[1].text), limit, ruleFor)
+		}
+
+	// col = v1 OR col = v2, SQL Server rendering of IN lists
+	default:
+		applyEqualities(tokens, ruleFor)
+	}
+}
+
+func applyComparison(left token, operator string, right token, ruleFor func(string) *columnRule) {
+	if left.kind != tokenIdentifier {
+		left, right = right, left
+		operator = flipComparison(operator)
+	}
+	value, ok := numberValue(right)
+	if left.kind != tokenIdentifier || !ok {
+		return
+	}
+
+	rule := ruleFor(left.text)
+	switch operator {
+	case ">":
+		rule.raiseMinimum(value, true)
+	case ">=":
+		rule.raiseMinimum(value, false)
+	case "<":
+		rule.lowerMaximum(value, true)
+	case "<=":
+		rule.lowerMaximum(value, false)
+	}
+}
+
+func applyLengthLimit(column, operator string, limit float64, ruleFor func(string) *columnRule) {
+	maxLength := int(limit)
+	switch operator {
+	case "<=":
+	case "<":
+		maxLength--
+	default:
+		return
+	}
+	rule := ruleFor(column)
+	if rule.MaxLength == nil || maxLength < *rule.MaxLength {
+		rule.MaxLength = &maxLength
+	}
+}
+
+func applyEqualities(tokens []token, ruleFor func(string) *columnRule) {
+	var column string
+	var values []interface{}
+	for _, part := range splitTopLevel(tokens, "OR") {
+		part = unwrap(part)
+		if len(part) != 3 || part[0].kind != tokenIdentifier || !part[1].is("=") || !part[2].isValue() {
+			return
+		}
+		if column != "" && !strings.EqualFold(column, part[0].text) {
+			return
+		}
+		column = part[0].text
+		values = append(values, literalValue(part[2]))
+	}
+	if column != "" {
+		ruleFor(column).Enum = values
+	}
+}
+
+func (rule *columnRule) raiseMinimum(value float64, exclusive bool) {
+	if rule.Minimum == nil || value > *rule.Minimum || (value == *rule.Minimum && exclusive) {
+		rule.Minimum, rule.ExclusiveMinimum = &value, exclusive
+	}
+}
+
+func (rule *columnRule) lowerMaximum(value float64, exclusive bool) {
+	if rule.Maximum == nil || value < *rule.Maximum || (value == *rule.Maximum && exclusive) {
+		rule.Maximum, rule.ExclusiveMaximum = &value, exclusive
+	}
+}
+
+func isRangeOperator(t token) bool {
+	return t.kind == tokenSymbol && (t.text == ">" || t.text == ">=" || t.text == "<" || t.text == "<=")
+}
+
+func flipComparison(operator string) string {
+	switch operator {
+	case ">":
+		return "<"
+	case ">=":
+		return "<="
+	case "<":
+		return ">"
+	case "<=":
+		return ">="
+	}
+	return operator
+}
+
+func isLengthFunction(t token) bool {
+	switch strings.ToLower(t.text) {
+	case "length", "char_length", "character_length", "len":
+		return t.kind == tokenIdentifier
+	}
+	return false
+}
+
+// valueList reads "v1, v2, ..." as literal values
+func valueList(tokens []token) ([]interface{}, bool) {
+	var values []interface{}
+	for i, t := range tokens {
+		if i%2 == 1 {
+			if !t.is(",") {
+				return nil, false
+			}
+			continue
+		}
+		if !t.isValue() {
+			return nil, false
+		}
+		values = append(values, literalValue(t))
+	}
+	return values, len(values) > 0 && len(tokens)%2 == 1
+}
+
+func literalValue(t token) interface{} {
+	if t.kind == tokenNumber {
+		if value, err := strconv.ParseInt(t.text, 10, 64); err == nil {
+			return value
+		}
+		if value, err := strconv.ParseFloat(t.text, 64); err == nil {
+			return value
+		}
+	}
+	return t.text
+}
+
+// numberValue also accepts quoted numbers, PostgreSQL renders -1 as '-1'::integer
+func numberValue(t token) (float64, bool) {
+	if !t.isValue() {
+		return 0, false
+	}
+	value, err := strconv.ParseFloat(t.text, 64)
+	return value, err == nil
+}
+
+// splitConjuncts splits tokens at the top level ANDs, except the ones of BETWEEN
+func splitConjuncts(tokens []token) [][]token {
+	var conjuncts [][]token
+	depth, from, between := 0, 0, false
+	for i, t := range tokens {
+		switch {
+		case t.is("(") || t.is("["):
+			depth++
+		case t.is(")") || t.is("]"):
+			depth--
+		case depth == 0 && t.kind == tokenIdentifier && t.is("BETWEEN"):
+			between = true
+		case depth == 0 && t.kind == tokenIdentifier && t.is("AND"):
+			if between {
+				between = false
+				continue
+			}
+			conjuncts = append(conjuncts, tokens[from:i])
+			from = i + 1
+		}
+	}
+	conjuncts = append(conjuncts, tokens[from:])
+
+	// (a > 0 AND b > 0) is split further once unwrapped
+	var flattened [][]token
+	for _, conjunct := range conjuncts {
+		unwrapped := unwrap(conjunct)
+		if len(unwrapped) < len(conjunct) && len(splitTopLevel(unwrapped, "AND")) > 1 {
+			flattened = append(flattened, splitConjuncts(unwrapped)...)
+		} else {
+			flattened = append(flattened, conjunct)
+		}
+	}
+	return flattened
+}
+
+func splitTopLevel(tokens []token, keyword string) [][]token {
+	var parts [][]token
+	depth, from := 0, 0
+	for i, t := range tokens {
+		switch {
+		case t.is("(") || t.is("["):
+			depth++
+		case t.is(")") || t.is("]"):
+			depth--
+		case depth == 0 && t.kind == tokenIdentifier && t.is(keyword):
+			parts = append(parts, tokens[from:i])
+			from = i + 1
+		}
+	}
+	return append(parts, tokens[from:])
+}
+
+// unwrap removes the parentheses enclosing the whole expression
+func unwrap(tokens []token) []token {
+	for len(tokens) >= 2 && tokens[0].is("(") && closingIndex(tokens, 0) == len(tokens)-1 {
+		tokens = tokens[1 : len(tokens)-1]
+	}
+	return tokens
+}
+
+func closingIndex(tokens []token, open int) int {
+	depth := 0
+	for i := open; i < len(tokens); i++ {
+		if tokens[i].is("(") {
+			depth++
+		} else if tokens[i].is(")") {
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// normalizeTokens drops what the databases add to the expressions they store:
+// casts (PostgreSQL) and parentheses around single values (SQL Server).
+func normalizeTokens(tokens []token) []token {
+	var withoutCasts []token
+	for i := 0; i < len(tokens); i++ {
+		if !tokens[i].is("::") {
+			withoutCasts = append(withoutCasts, tokens[i])
+			continue
+		}
+		i++ // type name
+		for i+1 < len(tokens) && tokens[i+1].kind == tokenIdentifier && isTypeNameContinuation(tokens[i+1].text) {
+			i++
+		}
+		if i+3 < len(tokens) && tokens[i+1].is("(") && tokens[i+2].kind == tokenNumber && tokens[i+3].is(")") {
+			i += 3
+		}
+		if i+2 < len(tokens) && tokens[i+1].is("[") && tokens[i+2].is("]") {
+			i += 2
+		}
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for i := 0; i+2 < len(withoutCasts); i++ {
+			if !withoutCasts[i].is("(") || !withoutCasts[i+2].is(")") || withoutCasts[i+1].kind == tokenSymbol {
+				continue
+			}
+			if i > 0 && withoutCasts[i-1].kind == tokenIdentifier && !isKeyword(withoutCasts[i-1].text) {
+				continue // function call
+			}
+			withoutCasts = append(withoutCasts[:i], append([]token{withoutCasts[i+1]}, withoutCasts[i+3:]...)...)
+			changed = true
+		}
+	}
+	return withoutCasts
+}
+
+func isTypeNameContinuation(word string) bool {
+	switch strings.ToLower(word) {
+	case "varying", "precision", "with", "without", "time", "zone":
+		return true
+	}
+	return false
+}
+
+func isKeyword(word string) bool {
+	switch strings.ToUpper(word) {
+	case "AND", "OR", "NOT", "BETWEEN":
+		return true
+	}
+	return false
+}
+
+// tokenizeCheck splits a CHECK expression into identifiers (unquoted), numbers,
+// string literals (unescaped) and symbols.
+func tokenizeCheck(expression string) []token {
+	var tokens []token
+	runes := []rune(expression)
+	for i := 0; i < len(runes); i++ {
+		char := runes[i]
+		switch {
+		case unicode.IsSpace(char):
+		case char == '\'':
+			var text strings.Builder
+			for i++; i < len(runes); i++ {
+				if runes[i] == '\'' {
+					if i+1 < len(runes) && runes[i+1] == '\'' {
+						i++
+					} else {
+						break
+					}
+				}
+				text.WriteRune(runes[i])
+			}
+			tokens = append(tokens, token{tokenString, text.String()})
+		case char == '"' || char == '`' || (char == '[' && isQuotingBracket(tokens, runes[i+1:])):
+			closing := char
+			if char == '[' {
+				closing = ']'
+			}
+			start := i + 1
+			for i++; i < len(runes) && runes[i] != closing; i++ {
+			}
+			tokens = append(tokens, token{tokenIdentifier, string(runes[start:i])})
+		case unicode.IsDigit(char) || (char == '-' && i+1 < len(runes) && unicode.IsDigit(runes[i+1]) && !endsOperand(tokens)):
+			start := i
+			for i+1 < len(runes) && (unicode.IsDigit(runes[i+1]) || runes[i+1] == '.') {
+				i++
+			}
+			tokens = append(tokens, token{tokenNumber, string(runes[start : i+1])})
+		case unicode.IsLetter(char) || char == '_':
+			start := i
+			for i+1 < len(runes) && (unicode.IsLetter(runes[i+1]) || unicode.IsDigit(runes[i+1]) || runes[i+1] == '_' || runes[i+1] == '$') {
+				i++
+			}
+			// charset introducers (_utf8mb4'a') and N'a' literals
+			if i+1 < len(runes) && runes[i+1] == '\'' && (char == '_' || (i == start && (char == 'N' || char == 'n'))) {
+				continue
+			}
+			tokens = append(tokens, token{tokenIdentifier, string(runes[start : i+1])})
+		case i+1 < len(runes) && isTwoCharSymbol(string(runes[i:i+2])):
+			tokens = append(tokens, token{tokenSymbol, string(runes[i : i+2])})
+			i++
+		default:
+			tokens = append(tokens, token{tokenSymbol, string(char)})
+		}
+	}
+	return tokens
+}
+
+// isQuotingBracket tells a SQL Server [identifier] from ARRAY[...] and type[]
+func isQuotingBracket(tokens []token, rest []rune) bool {
+	afterArray := len(tokens) > 0 && tokens[len(tokens)-1].is("ARRAY")
+	return !afterArray && len(rest) > 0 && rest[0] != ']'
+}
+
+func isTwoCharSymbol(symbol string) bool {
+	switch symbol {
+	case "::", ">=", "<=", "<>", "!=":
+		return true
+	}
+	return false
+}
+
+// endsOperand tells whether a '-' after tokens is a binary minus
+func endsOperand(tokens []token) bool {
+	if len(tokens) == 0 {
+		return false
+	}
+	last := tokens[len(tokens)-1]
+	return last.isValue() || last.is(")") || (last.kind == tokenIdentifier && !isKeyword(last.text))
+}
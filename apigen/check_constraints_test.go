@@ -0,0 +1,66 @@
+package apigen
+
+import (
+	"db_meta/api"
+	"db_meta/dbstructs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckConstraintRules(t *testing.T) {
+	checks := []*dbstructs.CheckConstraint{
+		{Name: "price_check", Expression: "(price > (0)::numeric)"},                                    // PostgreSQL
+		{Name: "quantity_check", Expression: "([quantity]>=(0) AND [quantity]<=(100))"},                // SQL Server
+		{Name: "status_check", Expression: "(status = ANY (ARRAY['draft'::text, 'published'::text]))"}, // PostgreSQL IN
+		{Name: "kind_check", Expression: "([kind]='b' OR [kind]='a')"},                                 // SQL Server IN
+		{Name: "rating_check", Expression: "(`rating` between 1 and 5)"},                               // MySQL
+		{Name: "code_check", Expression: "length(code) <= 8 AND code IN (_utf8mb4'A1', _utf8mb4'B2')"},
+		{Name: "size_check", Expression: "((size)::text = ANY ((ARRAY['S'::character varying, 'M'::character varying])::text[]))"},
+		{Name: "other_check", Expression: "price < discount * 2"},
+	}
+
+	rules := checkConstraintRules(checks)
+
+	assert.Equal(t, 0.0, *rules["price"].Minimum)
+	assert.True(t, rules["price"].ExclusiveMinimum)
+	assert.Nil(t, rules["price"].Maximum)
+
+	assert.Equal(t, 0.0, *rules["quantity"].Minimum)
+	assert.Equal(t, 100.0, *rules["quantity"].Maximum)
+	assert.False(t, rules["quantity"].ExclusiveMaximum)
+
+	assert.Equal(t, []interface{}{"draft", "published"}, rules["status"].Enum)
+	assert.Equal(t, []interface{}{"b", "a"}, rules["kind"].Enum)
+	assert.Equal(t, []interface{}{"S", "M"}, rules["size"].Enum)
+
+	assert.Equal(t, 1.0, *rules["rating"].Minimum)
+	assert.Equal(t, 5.0, *rules["rating"].Maximum)
+
+	assert.Equal(t, 8, *rules["code"].MaxLength)
+	assert.Equal(t, []interface{}{"A1", "B2"}, rules["code"].Enum)
+
+	assert.Nil(t, rules["discount"])
+}
+
+func TestGenerateSchemaForTable_checkConstraints(t *testing.T) {
+	length := 255
+	table := &dbstructs.TableMetadata{
+		TableName: "product",
+		Columns: []*dbstructs.Column{
+			{ColumnName: "price", DataType: "numeric", NotNull: true},
+			{ColumnName: "code", DataType: "varchar", CharacterLength: &length},
+		},
+		CheckConstraints: []*dbstructs.CheckConstraint{
+			{Name: "product_price_check", Columns: []string{"price"}, Expression: "price >= 1"},
+			{Name: "product_code_check", Columns: []string{"code"}, Expression: "char_length(code) < 11"},
+		},
+	}
+	openAPI := &api.OpenAPI{Components: api.Components{Schemas: make(map[string]api.Schema)}}
+
+	generateSchemaForTable(openAPI, table, nil)
+
+	properties := openAPI.Components.Schemas["product"].Properties
+	assert.Equal(t, 1.0, *properties["price"].Minimum)
+	assert.Equal(t, 10, *properties["code"].MaxLength)
+}
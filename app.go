@@ -1,23 +1,63 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"db_meta/api"
 	"db_meta/apigen"
 	"db_meta/databases"
+	"db_meta/databases/ddl"
+	"db_meta/databases/registry"
 	"db_meta/dbstructs"
+	"db_meta/integrity"
+	"db_meta/migration"
+	"db_meta/profiles"
+	"db_meta/schemadiff"
+	"db_meta/snapshot"
+	"db_meta/sqlgen"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
+	"strings"
+	"sync"
+
+	"github.com/wailsapp/wails/v2/pkg/runtime"
 )
 
+// metadataProgressEvent is emitted while ConfigureGorm loads the tables, with
+// the session ID, the number of tables loaded so far and their total.
+const metadataProgressEvent = "metadataProgress"
+
 // App struct
 type App struct {
-	ctx context.Context
+	ctx      context.Context
+	sessions *databases.SessionManager
+
+	loadMu      sync.Mutex
+	cancelLoads map[string]*load // the running ConfigureGorm, by session
+
+	profileStore *profiles.Store // saved connections
+
+	integrityConfigPath string // YAML configuration of the integrity rules
 }
 
 // NewApp creates a new App application struct
 func NewApp() *App {
-	return &App{}
+	path, err := profiles.DefaultPath()
+	if err != nil {
+		log.Println("app.go:[1] no config directory for the profiles:", err)
+	}
+	integrityConfigPath, err := integrity.DefaultConfigPath()
+	if err != nil {
+		log.Println("app.go:[11] no config directory for the integrity rules:", err)
+	}
+	return &App{
+		sessions:            databases.NewSessionManager(),
+		cancelLoads:         make(map[string]*load),
+		profileStore:        profiles.NewStore(path),
+		integrityConfigPath: integrityConfigPath,
+	}
 }
 
 // startup is called when the app starts. The context is saved
@@ -26,44 +66,426 @@ func (a *App) startup(ctx context.Context) {
 	a.ctx = ctx
 }
 
-func (a *App) ConfigureGorm(dbType, host, port, database, user, password string) (string, error) {
+// ConfigureGorm connects session sessionID to the database and loads its
+// metadata, replacing what the session held. schemas is a comma separated list
+// of schemas to load (connector default when empty), options the DSN, TLS and
+// other advanced settings. Progress is reported with metadataProgressEvent,
+// CancelConfigureGorm aborts it.
+func (a *App) ConfigureGorm(sessionID, dbType, host, port, database, user, password, schemas string, options registry.Options) (string, error) {
 	var tableMetadata []*dbstructs.TableMetadata
-	connector := databases.GetDatabaseManagerInstance()
+	ctx, current := a.startLoad(sessionID)
+	defer a.endLoad(sessionID, current)
+
+	connector := &databases.DatabaseManager{Schemas: splitList(schemas), Options: options}
+	connector.OnProgress = func(done, total int) {
+		a.emit(metadataProgressEvent, sessionID, done, total)
+	}
 
-	_, err := connector.Connect(dbType, host, port, database, user, password)
+	_, err := connector.Connect(ctx, dbType, host, port, database, user, password)
 	if err != nil {
 		log.Println("app.go:38", err)
 
 		return "", err
 	}
 
-	tableMetadata, err = connector.GetTableMetadata()
+	tableMetadata, err = connector.GetTableMetadata(ctx)
 	if err != nil {
 		log.Println("app.go:45 - Erreur lors de la récupération des métadonnées des tables :", err)
+		connector.Close()
 		return "", err
 	}
 
 	jsonData, err := json.Marshal(tableMetadata)
 	if err != nil {
 		log.Println("app.go:51", err)
+		connector.Close()
 		return "", err
 	}
 
+	if err := a.open(ctx, sessionID, current, connector); err != nil {
+		return "", err
+	}
 	return string(jsonData), nil
 }
 
-func (a *App) GetTablesList() []*dbstructs.TableMetadata {
-	tables := databases.GetDatabaseManagerInstance().GetTablesList()
-	return tables
+// CancelConfigureGorm aborts the running ConfigureGorm of sessionID, which then
+// returns context.Canceled.
+func (a *App) CancelConfigureGorm(sessionID string) {
+	a.loadMu.Lock()
+	defer a.loadMu.Unlock()
+	if current, ok := a.cancelLoads[sessionID]; ok {
+		current.cancel()
+	}
+}
+
+// load is a running ConfigureGorm, a later one of the session supersedes it.
+type load struct {
+	cancel context.CancelFunc
 }
 
-func (a *App) GraphTransform() (string, error) {
-	connector := databases.GetDatabaseManagerInstance()
-	response := &dbstructs.GraphResponse{
-		Edges: connector.Edges,
-		Nodes: connector.Nodes,
+func (a *App) startLoad(sessionID string) (context.Context, *load) {
+	parent := a.ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithCancel(parent)
+	current := &load{cancel: cancel}
+	a.loadMu.Lock()
+	// a new connection of the session supersedes the running one
+	if previous, ok := a.cancelLoads[sessionID]; ok {
+		previous.cancel()
 	}
-	jsonResponse, err := json.Marshal(response)
+	a.cancelLoads[sessionID] = current
+	a.loadMu.Unlock()
+	return ctx, current
+}
+
+// endLoad forgets current, unless a later load already took its place.
+func (a *App) endLoad(sessionID string, current *load) {
+	current.cancel()
+	a.loadMu.Lock()
+	if a.cancelLoads[sessionID] == current {
+		delete(a.cancelLoads, sessionID)
+	}
+	a.loadMu.Unlock()
+}
+
+// open gives connector to the session, unless current was cancelled or
+// superseded meanwhile: connector is then closed and context.Canceled returned.
+func (a *App) open(ctx context.Context, sessionID string, current *load, connector *databases.DatabaseManager) error {
+	a.loadMu.Lock()
+	defer a.loadMu.Unlock()
+	if a.cancelLoads[sessionID] != current || ctx.Err() != nil {
+		connector.Close()
+		return context.Canceled
+	}
+	a.sessions.Open(sessionID, connector)
+	return nil
+}
+
+// emit sends an event to the frontend, outside of Wails (tests) it does nothing.
+func (a *App) emit(eventName string, data ...interface{}) {
+	if a.ctx != nil {
+		runtime.EventsEmit(a.ctx, eventName, data...)
+	}
+}
+
+// GetConnectors returns the registered connectors and the fields of their
+// connection form.
+func (a *App) GetConnectors() []registry.Driver {
+	return registry.Drivers()
+}
+
+// UnlockProfiles opens the secrets of the saved profiles with the master
+// passphrase, the first one given becomes the master passphrase.
+func (a *App) UnlockProfiles(passphrase string) error {
+	return a.profileStore.Unlock(passphrase)
+}
+
+// LockProfiles forgets the master passphrase.
+func (a *App) LockProfiles() {
+	a.profileStore.Lock()
+}
+
+// ProfilesUnlocked tells whether the master passphrase was given.
+func (a *App) ProfilesUnlocked() bool {
+	return a.profileStore.Unlocked()
+}
+
+// ListProfiles returns the saved profiles, without their secrets.
+func (a *App) ListProfiles() ([]profiles.Profile, error) {
+	return a.profileStore.List()
+}
+
+// GetProfile returns the profile name with its secrets, the profiles must be
+// unlocked if it has some.
+func (a *App) GetProfile(name string) (profiles.Profile, error) {
+	return a.profileStore.Get(name)
+}
+
+// SaveProfile adds or replaces a profile, the profiles must be unlocked if it
+// has secrets.
+func (a *App) SaveProfile(profile profiles.Profile) error {
+	return a.profileStore.Save(profile)
+}
+
+// DeleteProfile removes the profile name.
+func (a *App) DeleteProfile(name string) error {
+	return a.profileStore.Delete(name)
+}
+
+// TestProfile connects to the database of the profile name, then disconnects
+// without loading the metadata.
+func (a *App) TestProfile(name string) error {
+	profile, err := a.profileStore.Get(name)
+	if err != nil {
+		return err
+	}
+	parent := a.ctx
+	if parent == nil {
+		parent = context.Background()
+	}
+	dbm := &databases.DatabaseManager{Schemas: splitList(profile.Schemas), Options: profile.Options}
+	if _, err := dbm.Connect(parent, profile.DBType, profile.Host, profile.Port, profile.Database, profile.User, profile.Password); err != nil {
+		return err
+	}
+	return dbm.Close()
+}
+
+// ConnectProfile is ConfigureGorm with the settings of the profile name.
+func (a *App) ConnectProfile(sessionID, name string) (string, error) {
+	profile, err := a.profileStore.Get(name)
+	if err != nil {
+		return "", err
+	}
+	return a.ConfigureGorm(sessionID, profile.DBType, profile.Host, profile.Port, profile.Database, profile.User, profile.Password, profile.Schemas, profile.Options)
+}
+
+// ExportProfiles returns the profiles as JSON, without their secrets.
+func (a *App) ExportProfiles() (string, error) {
+	data, err := a.profileStore.Export()
+	return string(data), err
+}
+
+// ImportProfiles adds the profiles of an ExportProfiles, replacing the ones of
+// the same name, and returns how many were imported.
+func (a *App) ImportProfiles(data string) (int, error) {
+	return a.profileStore.Import([]byte(data))
+}
+
+// ExportSnapshot returns the metadata of sessionID as a versioned JSON snapshot,
+// to be reopened with OpenSnapshot.
+func (a *App) ExportSnapshot(sessionID string) (string, error) {
+	var data bytes.Buffer
+	err := a.read(sessionID, func(dbm *databases.DatabaseManager) error {
+		return dbm.Snapshot().Write(&data)
+	})
+	if err != nil {
+		log.Println("app.go:[2]", err)
+		return "", err
+	}
+	return data.String(), nil
+}
+
+// OpenSnapshot makes a snapshot the metadata of session sessionID, without
+// connection: the graph, integrity and REST API pages work offline. It returns
+// the tables as ConfigureGorm does.
+func (a *App) OpenSnapshot(sessionID, data string) (string, error) {
+	s, err := snapshot.Read(strings.NewReader(data))
+	if err != nil {
+		log.Println("app.go:[3]", err)
+		return "", err
+	}
+	return a.openOffline(sessionID, databases.NewOfflineManager(s))
+}
+
+// OpenDDL makes the tables declared by a SQL script, written in the dialect of
+// the connector dialect, the metadata of session sessionID, as OpenSnapshot does.
+func (a *App) OpenDDL(sessionID, dialect, data string) (string, error) {
+	s, err := ddl.Parse(dialect, data)
+	if err != nil {
+		log.Println("app.go:[4]", err)
+		return "", err
+	}
+	return a.openOffline(sessionID, databases.NewOfflineManager(s))
+}
+
+// openOffline makes dbm the session sessionID, superseding its running
+// ConfigureGorm, and returns its tables as JSON.
+func (a *App) openOffline(sessionID string, dbm *databases.DatabaseManager) (string, error) {
+	ctx, current := a.startLoad(sessionID)
+	defer a.endLoad(sessionID, current)
+	jsonData, err := json.Marshal(dbm.Tables)
+	if err != nil {
+		return "", err
+	}
+	if err := a.open(ctx, sessionID, current, dbm); err != nil {
+		return "", err
+	}
+	return string(jsonData), nil
+}
+
+// CompareSessions compares the tables of two sessions, connected or opened from
+// a snapshot, and returns the schemadiff.Diff from the source to the target as
+// JSON.
+func (a *App) CompareSessions(sourceSessionID, targetSessionID string) (string, error) {
+	source, err := a.GetTablesList(sourceSessionID)
+	if err != nil {
+		log.Println("app.go:[5]", err)
+		return "", err
+	}
+	target, err := a.GetTablesList(targetSessionID)
+	if err != nil {
+		log.Println("app.go:[6]", err)
+		return "", err
+	}
+	jsonData, err := json.Marshal(schemadiff.Compare(source, target))
+	if err != nil {
+		return "", err
+	}
+	return string(jsonData), nil
+}
+
+// GenerateMigration writes the migration from the tables of the source session
+// to the ones of the target session in dialect, the connector of the source
+// session when empty. It returns the migration.Script as JSON, with the up and
+// down scripts as they can be run in upSql and downSql.
+func (a *App) GenerateMigration(sourceSessionID, targetSessionID, dialect string) (string, error) {
+	var source []*dbstructs.TableMetadata
+	err := a.read(sourceSessionID, func(dbm *databases.DatabaseManager) error {
+		source = dbm.Tables
+		if dialect == "" {
+			dialect = dbm.DBType
+		}
+		return nil
+	})
+	if err != nil {
+		log.Println("app.go:[7]", err)
+		return "", err
+	}
+	target, err := a.GetTablesList(targetSessionID)
+	if err != nil {
+		log.Println("app.go:[8]", err)
+		return "", err
+	}
+	script, err := migration.Generate(dialect, schemadiff.Compare(source, target))
+	if err != nil {
+		log.Println("app.go:[9]", err)
+		return "", err
+	}
+	jsonData, err := json.Marshal(struct {
+		*migration.Script
+		UpSQL   string `json:"upSql"`
+		DownSQL string `json:"downSql"`
+	}{script, script.UpSQL(), script.DownSQL()})
+	if err != nil {
+		return "", err
+	}
+	return string(jsonData), nil
+}
+
+// ExportDDL writes the DDL creating the tables of session sessionID in dialect,
+// the connector of the session when empty, translating their types from the
+// dialect of the session. It returns the sqlgen.DDL as JSON, with the script
+// in sql.
+func (a *App) ExportDDL(sessionID, dialect string) (string, error) {
+	var script *sqlgen.DDL
+	err := a.read(sessionID, func(dbm *databases.DatabaseManager) error {
+		if dialect == "" {
+			dialect = dbm.DBType
+		}
+		var err error
+		script, err = sqlgen.GenerateDDL(dbm.DBType, dialect, dbm.Tables, dbm.Types)
+		return err
+	})
+	if err != nil {
+		log.Println("app.go:[10]", err)
+		return "", err
+	}
+	jsonData, err := json.Marshal(struct {
+		*sqlgen.DDL
+		SQL string `json:"sql"`
+	}{script, script.SQL()})
+	if err != nil {
+		return "", err
+	}
+	return string(jsonData), nil
+}
+
+// ListSessions returns the IDs of the open sessions.
+func (a *App) ListSessions() []string {
+	return a.sessions.IDs()
+}
+
+// CloseSession closes the connection of sessionID and forgets its metadata.
+func (a *App) CloseSession(sessionID string) error {
+	return a.sessions.Close(sessionID)
+}
+
+// read calls fn with the manager of sessionID, under its read lock.
+func (a *App) read(sessionID string, fn func(dbm *databases.DatabaseManager) error) error {
+	session, err := a.sessions.Get(sessionID)
+	if err != nil {
+		return err
+	}
+	return session.Read(fn)
+}
+
+func (a *App) GetTablesList(sessionID string) ([]*dbstructs.TableMetadata, error) {
+	var tables []*dbstructs.TableMetadata
+	err := a.read(sessionID, func(dbm *databases.DatabaseManager) error {
+		tables = dbm.GetTablesList()
+		return nil
+	})
+	return tables, err
+}
+
+func (a *App) GetViewsList(sessionID string) ([]*dbstructs.ViewMetadata, error) {
+	var views []*dbstructs.ViewMetadata
+	err := a.read(sessionID, func(dbm *databases.DatabaseManager) error {
+		views = dbm.GetViewsList()
+		return nil
+	})
+	return views, err
+}
+
+func (a *App) GetTypesList(sessionID string) ([]*dbstructs.TypeMetadata, error) {
+	var types []*dbstructs.TypeMetadata
+	err := a.read(sessionID, func(dbm *databases.DatabaseManager) error {
+		types = dbm.GetTypesList()
+		return nil
+	})
+	return types, err
+}
+
+func (a *App) GetTriggersList(sessionID string) ([]*dbstructs.TriggerMetadata, error) {
+	var triggers []*dbstructs.TriggerMetadata
+	err := a.read(sessionID, func(dbm *databases.DatabaseManager) error {
+		triggers = dbm.GetTriggersList()
+		return nil
+	})
+	return triggers, err
+}
+
+func (a *App) GetRoutinesList(sessionID string) ([]*dbstructs.RoutineMetadata, error) {
+	var routines []*dbstructs.RoutineMetadata
+	err := a.read(sessionID, func(dbm *databases.DatabaseManager) error {
+		routines = dbm.GetRoutinesList()
+		return nil
+	})
+	return routines, err
+}
+
+func (a *App) GetSequencesList(sessionID string) ([]*dbstructs.SequenceMetadata, error) {
+	var sequences []*dbstructs.SequenceMetadata
+	err := a.read(sessionID, func(dbm *databases.DatabaseManager) error {
+		sequences = dbm.GetSequencesList()
+		return nil
+	})
+	return sequences, err
+}
+
+// GraphTransform returns the schema graph of sessionID, partitions are folded
+// into their parent node when collapsePartitions is set.
+func (a *App) GraphTransform(sessionID string, collapsePartitions bool) (string, error) {
+	session, err := a.sessions.Get(sessionID)
+	if err != nil {
+		return "", err
+	}
+	var jsonResponse []byte
+	err = session.Update(func(connector *databases.DatabaseManager) error {
+		if connector.CollapsePartitions != collapsePartitions {
+			connector.CollapsePartitions = collapsePartitions
+			connector.TransformToGraph()
+		}
+		response := &dbstructs.GraphResponse{
+			Edges: connector.Edges,
+			Nodes: connector.Nodes,
+		}
+		var err error
+		jsonResponse, err = json.Marshal(response)
+		return err
+	})
 	if err != nil {
 		return "", err
 	}
@@ -71,26 +493,156 @@ func (a *App) GraphTransform() (string, error) {
 	return string(jsonResponse), nil
 }
 
-func (a *App) PerformAllVerifications() (string, error) {
-	connector := databases.GetDatabaseManagerInstance()
-	var verifications *dbstructs.SchemaVerificationResults
+// PerformAllVerifications checks the schema of the session with the registered
+// integrity rules, configured by the YAML file at integrityConfigPath, read on
+// each run. It returns the report with the path of the configuration, each
+// finding with its fix in the dialect of the session.
+func (a *App) PerformAllVerifications(sessionID string) (string, error) {
 	var jsonResponse []byte
-	var err error
-	if verifications, err = connector.PerformAllVerifications(); err != nil {
+	err := a.read(sessionID, func(connector *databases.DatabaseManager) error {
+		report, err := a.verify(connector)
+		if err != nil {
+			return err
+		}
+		jsonResponse, err = json.Marshal(struct {
+			*integrity.Report
+			ConfigPath string `json:"configPath"`
+		}{report, a.integrityConfigPath})
+		return err
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(jsonResponse), nil
+}
+
+// PreviewFixes returns the script fixing the findings findingIDs of the
+// session, all of them when findingIDs is empty.
+func (a *App) PreviewFixes(sessionID string, findingIDs []string) (string, error) {
+	var script *integrity.FixScript
+	err := a.read(sessionID, func(connector *databases.DatabaseManager) error {
+		report, err := a.verify(connector)
+		if err != nil {
+			return err
+		}
+		script, err = report.Fixes(findingIDs)
+		return err
+	})
+	if err != nil {
+		log.Println("app.go:[14]", err)
+		return "", err
+	}
+	jsonData, err := json.Marshal(struct {
+		*integrity.FixScript
+		SQL string `json:"sql"`
+	}{script, script.SQL()})
+	if err != nil {
+		return "", err
+	}
+	return string(jsonData), nil
+}
+
+// ApplyFixes runs the fixes of the findings findingIDs in a transaction and
+// reloads the metadata of the session. A dry run rolls the transaction back,
+// checking that the statements go through without changing the database.
+func (a *App) ApplyFixes(sessionID string, findingIDs []string, dryRun bool) (string, error) {
+	if len(findingIDs) == 0 {
+		return "", errors.New("no finding selected")
+	}
+	session, err := a.sessions.Get(sessionID)
+	if err != nil {
 		return "", err
 	}
-	if jsonResponse, err = json.Marshal(verifications); err != nil {
+	// the reload is cancelled as ConfigureGorm is, its load is started out of
+	// Update as open locks the session under loadMu
+	ctx := context.Background()
+	if !dryRun {
+		var current *load
+		ctx, current = a.startLoad(sessionID)
+		defer a.endLoad(sessionID, current)
+	}
+	var dialect string
+	var statements []string
+	err = session.Update(func(connector *databases.DatabaseManager) error {
+		if connector.DB == nil {
+			return errors.New("no connection, the fixes can only be previewed")
+		}
+		report, err := a.verify(connector)
+		if err != nil {
+			return err
+		}
+		script, err := report.Fixes(findingIDs)
+		if err != nil {
+			return err
+		}
+		if len(script.Conflicts) > 0 {
+			return fmt.Errorf("several fixes rebuild or rename %s, apply them one at a time", strings.Join(script.Conflicts, ", "))
+		}
+		dialect, statements = connector.DBType, integrity.Transactional(script.Statements)
+		if err := integrity.Apply(connector.DB, connector.DBType, statements, dryRun); err != nil {
+			return err
+		}
+		if dryRun {
+			return nil
+		}
+		_, err = connector.GetTableMetadata(ctx)
+		return err
+	})
+	if err != nil {
+		log.Println("app.go:[15]", err)
 		return "", err
 	}
-	return string(jsonResponse), err
+	jsonData, err := json.Marshal(map[string]interface{}{
+		"dryRun":     dryRun,
+		"statements": statements,
+		"sql":        sqlgen.Script(dialect, statements),
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(jsonData), nil
+}
+
+// verify runs the integrity rules on the schema of connector.
+func (a *App) verify(connector *databases.DatabaseManager) (*integrity.Report, error) {
+	config, err := integrity.LoadConfig(a.integrityConfigPath)
+	if err != nil {
+		log.Println("app.go:[12]", err)
+		return nil, err
+	}
+	schema := &integrity.Schema{
+		Tables:  connector.Tables,
+		Graph:   &dbstructs.GraphResponse{Edges: connector.Edges, Nodes: connector.Nodes},
+		Dialect: connector.DBType,
+	}
+	report, err := integrity.Run(integrity.Rules(), schema, config)
+	if err != nil {
+		log.Println("app.go:[13]", err)
+		return nil, err
+	}
+	return report, nil
 }
 
-func (a *App) GenerateOpenApi(config *api.APIConfig) (string, error) {
-	tables := databases.GetDatabaseManagerInstance().GetTablesList()
+func (a *App) GenerateOpenApi(sessionID string, config *api.APIConfig) (string, error) {
 	var bytesArray []byte
-	var err error
-	if bytesArray, err = apigen.GenerateOpenAPI(tables, config); err != nil {
+	err := a.read(sessionID, func(connector *databases.DatabaseManager) error {
+		var err error
+		bytesArray, err = apigen.GenerateOpenAPI(connector.GetTablesList(), connector.GetViewsList(), connector.GetTypesList(), config)
+		return err
+	})
+	if err != nil {
 		return "", err
 	}
-	return string(bytesArray), err
+	return string(bytesArray), nil
+}
+
+// splitList splits a comma separated list, dropping blank items
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
 }
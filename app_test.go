@@ -1,13 +1,24 @@
 package main
 
 import (
+	"context"
 	"db_meta/databases"
+	"db_meta/databases/registry"
 	"db_meta/dbstructs"
+	"db_meta/integrity"
+	"db_meta/migration"
+	"db_meta/profiles"
+	"db_meta/schemadiff"
+	"db_meta/sqlgen"
 	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
@@ -20,14 +31,14 @@ type DatabaseConnectorMock struct {
 	GetTableMetadataFunc func(*gorm.DB) ([]*dbstructs.TableMetadata, error)
 }
 
-func (m *DatabaseConnectorMock) Connect(host, port, database, user, password string) (*gorm.DB, error) {
+func (m *DatabaseConnectorMock) Connect(ctx context.Context, host, port, database, user, password string) (*gorm.DB, error) {
 	if m.ConnectFunc != nil {
 		return m.ConnectFunc(host, port, database, user, password)
 	}
 	return nil, nil
 }
 
-func (m *DatabaseConnectorMock) GetTableMetadata(db *gorm.DB) ([]*dbstructs.TableMetadata, error) {
+func (m *DatabaseConnectorMock) GetTableMetadata(ctx context.Context, db *gorm.DB) ([]*dbstructs.TableMetadata, error) {
 	if m.GetTableMetadataFunc != nil {
 		return m.GetTableMetadataFunc(db)
 	}
@@ -47,20 +58,469 @@ func TestApp_GetTablesList(t *testing.T) {
 	}
 
 	mockDBManager := &databases.DatabaseManager{}
-	originalDBM := databases.GetDatabaseManagerInstance()
-	defer databases.SetInstance(originalDBM)
 	mockDBManager.DB = new(gorm.DB)
-	databases.SetInstance(mockDBManager)
 	mockDBManager.SetConnector(mockConnector)
 
-	_, err = mockDBManager.GetTableMetadata()
+	_, err = mockDBManager.GetTableMetadata(context.Background())
 	assert.NoError(t, err)
 
 	app := NewApp()
-	tables := app.GetTablesList()
+	app.sessions.Open("default", mockDBManager)
+	tables, err := app.GetTablesList("default")
+	assert.NoError(t, err)
 
 	assert.Len(t, tables, len(tablesMetadataMock))
 	assert.Equal(t, "table1", tables[0].TableName)
 	assert.Equal(t, "table2", tables[1].TableName)
 	assert.Equal(t, "table3", tables[2].TableName)
+
+	_, err = app.GetTablesList("production")
+	assert.ErrorIs(t, err, databases.ErrUnknownSession)
+}
+
+func TestApp_GetConnectors(t *testing.T) {
+	app := NewApp()
+	var names []string
+	for _, driver := range app.GetConnectors() {
+		if driver.Name != blockingDriver {
+			names = append(names, driver.Name)
+		}
+	}
+	assert.ElementsMatch(t, []string{"mysql", "postgres", "sqlite", "sqlserver"}, names)
+}
+
+// blockingDriver connects to an in-memory SQLite database, its metadata is a
+// table named after the host, loaded once blockingRelease[host] is closed.
+const blockingDriver = "blocking"
+
+var (
+	blockingStarted = make(chan string)
+	blockingRelease = map[string]chan struct{}{"first": make(chan struct{}), "second": make(chan struct{}), "third": make(chan struct{}), "fourth": make(chan struct{})}
+)
+
+func init() {
+	registry.Register(registry.Driver{Name: blockingDriver, Label: "Blocking", New: func() registry.Connector {
+		var host string
+		return &DatabaseConnectorMock{
+			ConnectFunc: func(h, _, _, _, _ string) (*gorm.DB, error) {
+				host = h
+				return gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+			},
+			// the load ignores its context, as a query answering right
+			// after the cancellation
+			GetTableMetadataFunc: func(*gorm.DB) ([]*dbstructs.TableMetadata, error) {
+				blockingStarted <- host
+				<-blockingRelease[host]
+				return []*dbstructs.TableMetadata{{TableName: host}}, nil
+			},
+		}
+	}})
+}
+
+func TestApp_overlappingLoads(t *testing.T) {
+	app := NewApp()
+	results := make(chan error)
+	load := func(host string) {
+		go func() {
+			_, err := app.ConfigureGorm("default", blockingDriver, host, "", "", "", "", "", registry.Options{})
+			results <- err
+		}()
+		assert.Equal(t, host, <-blockingStarted)
+	}
+
+	// the second load supersedes the first one, which ends without opening
+	// the session
+	load("first")
+	load("second")
+	close(blockingRelease["first"])
+	assert.ErrorIs(t, <-results, context.Canceled)
+	_, err := app.GetTablesList("default")
+	assert.ErrorIs(t, err, databases.ErrUnknownSession)
+
+	// the end of the first load left the second one cancellable
+	app.CancelConfigureGorm("default")
+	close(blockingRelease["second"])
+	assert.ErrorIs(t, <-results, context.Canceled)
+	_, err = app.GetTablesList("default")
+	assert.ErrorIs(t, err, databases.ErrUnknownSession)
+
+	load("third")
+	close(blockingRelease["third"])
+	assert.NoError(t, <-results)
+	tables, err := app.GetTablesList("default")
+	assert.NoError(t, err)
+	assert.Equal(t, "third", tables[0].TableName)
+
+	// a script opened meanwhile supersedes the load too
+	load("fourth")
+	_, err = app.OpenDDL("default", "sqlite", "CREATE TABLE script (id INTEGER PRIMARY KEY)")
+	assert.NoError(t, err)
+	close(blockingRelease["fourth"])
+	assert.ErrorIs(t, <-results, context.Canceled)
+	tables, err = app.GetTablesList("default")
+	assert.NoError(t, err)
+	assert.Equal(t, "script", tables[0].TableName)
+}
+
+// createShopDB creates a SQLite database in dir and returns its path.
+// shopDDL are the statements creating the database of createShopDB.
+var shopDDL = []string{
+	"CREATE TABLE customers (id INTEGER PRIMARY KEY, name TEXT NOT NULL UNIQUE)",
+	"CREATE TABLE orders (id INTEGER PRIMARY KEY, customer_id INT REFERENCES customers ON DELETE CASCADE, total REAL CHECK (total >= 0))",
+	"CREATE INDEX orders_customer ON orders (customer_id)",
+}
+
+func createShopDB(t *testing.T, dir string) string {
+	database := filepath.Join(dir, "shop.db")
+	db, err := gorm.Open(sqlite.Open(database), &gorm.Config{})
+	assert.NoError(t, err)
+	for _, statement := range shopDDL {
+		assert.NoError(t, db.Exec(statement).Error)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.Close()
+	return database
+}
+
+func TestApp_profiles(t *testing.T) {
+	dir := t.TempDir()
+	database := createShopDB(t, dir)
+
+	app := NewApp()
+	app.profileStore = profiles.NewStore(filepath.Join(dir, "profiles.json"))
+	assert.NoError(t, app.SaveProfile(profiles.Profile{Name: "shop", DBType: "sqlite", Database: database}))
+	list, err := app.ListProfiles()
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+
+	assert.NoError(t, app.TestProfile("shop"))
+	tables, err := app.ConnectProfile("default", "shop")
+	assert.NoError(t, err)
+	assert.Contains(t, tables, `"tableName":"customers"`)
+	assert.Equal(t, []string{"default"}, app.ListSessions())
+
+	assert.NoError(t, app.DeleteProfile("shop"))
+	_, err = app.ConnectProfile("default", "shop")
+	assert.ErrorIs(t, err, profiles.ErrUnknownProfile)
+}
+
+func TestApp_snapshots(t *testing.T) {
+	dir := t.TempDir()
+	database := createShopDB(t, dir)
+	app := NewApp()
+	_, err := app.ConfigureGorm("live", "sqlite", "", "", database, "", "", "", registry.Options{})
+	assert.NoError(t, err)
+	liveGraph, err := app.GraphTransform("live", false)
+	assert.NoError(t, err)
+
+	data, err := app.ExportSnapshot("live")
+	assert.NoError(t, err)
+	assert.Contains(t, data, `"dbType": "sqlite"`)
+	assert.Contains(t, data, `"serverVersion": "3.`)
+	assert.NoError(t, app.CloseSession("live"))
+	assert.NoError(t, os.Remove(database))
+
+	// the pages work from the snapshot alone
+	tables, err := app.OpenSnapshot("offline", data)
+	assert.NoError(t, err)
+	assert.Contains(t, tables, `"tableName":"orders"`)
+	graph, err := app.GraphTransform("offline", false)
+	assert.NoError(t, err)
+	assert.JSONEq(t, liveGraph, graph)
+	_, err = app.PerformAllVerifications("offline")
+	assert.NoError(t, err)
+	openAPI, err := app.GenerateOpenApi("offline", nil)
+	assert.NoError(t, err)
+	assert.Contains(t, openAPI, "/customers")
+
+	_, err = app.OpenSnapshot("broken", `{"tables": []}`)
+	assert.Error(t, err)
+	assert.NotContains(t, app.ListSessions(), "broken")
+}
+
+func TestApp_OpenDDL(t *testing.T) {
+	app := NewApp()
+	live, err := app.ConfigureGorm("live", "sqlite", "", "", createShopDB(t, t.TempDir()), "", "", "", registry.Options{})
+	assert.NoError(t, err)
+	liveGraph, err := app.GraphTransform("live", false)
+	assert.NoError(t, err)
+
+	// the script gives the metadata the connector reads from the database
+	tables, err := app.OpenDDL("file", "sqlite", strings.Join(shopDDL, ";\n")+";")
+	assert.NoError(t, err)
+	assert.JSONEq(t, live, tables)
+	graph, err := app.GraphTransform("file", false)
+	assert.NoError(t, err)
+	assert.JSONEq(t, liveGraph, graph)
+	_, err = app.PerformAllVerifications("file")
+	assert.NoError(t, err)
+
+	_, err = app.OpenDDL("broken", "sqlite", "CREATE TABLE t (name TEXT DEFAULT 'open)")
+	assert.ErrorContains(t, err, "unterminated string")
+	assert.NotContains(t, app.ListSessions(), "broken")
+}
+
+func TestApp_PerformAllVerifications(t *testing.T) {
+	dir := t.TempDir()
+	app := NewApp()
+	app.integrityConfigPath = filepath.Join(dir, "integrity.yaml")
+	_, err := app.ConfigureGorm("shop", "sqlite", "", "", createShopDB(t, dir), "", "", "", registry.Options{})
+	assert.NoError(t, err)
+
+	var report struct {
+		integrity.Report
+		ConfigPath string `json:"configPath"`
+	}
+	data, err := app.PerformAllVerifications("shop")
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal([]byte(data), &report))
+	assert.Equal(t, app.integrityConfigPath, report.ConfigPath)
+	assert.Len(t, report.Rules, len(integrity.Rules()))
+	assert.Equal(t, "sqlite", report.Dialect)
+	var total *integrity.Finding
+	for _, finding := range report.Findings {
+		if finding.ID == "nullable-column:orders:total" {
+			total = finding
+		}
+	}
+	if assert.NotNil(t, total) {
+		assert.Equal(t, integrity.SeverityInfo, total.Severity)
+		assert.Equal(t, "Column should be NOT NULL", total.Message)
+		assert.Equal(t, "orders", total.Fix.Rebuilds)
+	}
+
+	// the configuration is read on each run
+	assert.NoError(t, os.WriteFile(app.integrityConfigPath, []byte("rules:\n  nullable-column:\n    suppress:\n      - table: orders\n"), 0o600))
+	report.Findings = nil
+	data, err = app.PerformAllVerifications("shop")
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal([]byte(data), &report))
+	for _, finding := range report.Findings {
+		assert.False(t, finding.RuleID == integrity.RuleNullableColumn && finding.TableName == "orders", finding)
+	}
+	assert.NotZero(t, report.Suppressed)
+
+	assert.NoError(t, os.WriteFile(app.integrityConfigPath, []byte("rules:\n  no-such-rule: {}\n"), 0o600))
+	_, err = app.PerformAllVerifications("shop")
+	assert.EqualError(t, err, `unknown integrity rule "no-such-rule"`)
+}
+
+func TestApp_fixes(t *testing.T) {
+	dir := t.TempDir()
+	app := NewApp()
+	app.integrityConfigPath = filepath.Join(dir, "integrity.yaml")
+	database := createShopDB(t, dir)
+	_, err := app.ConfigureGorm("shop", "sqlite", "", "", database, "", "", "", registry.Options{})
+	assert.NoError(t, err)
+	notNull := func() bool {
+		var notNull bool
+		assert.NoError(t, app.read("shop", func(dbm *databases.DatabaseManager) error {
+			return dbm.DB.Raw(`SELECT "notnull" FROM pragma_table_info('orders') WHERE name = 'total'`).Scan(&notNull).Error
+		}))
+		return notNull
+	}
+
+	var script struct {
+		integrity.FixScript
+		SQL string `json:"sql"`
+	}
+	data, err := app.PreviewFixes("shop", nil)
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal([]byte(data), &script))
+	assert.Equal(t, []string{"orders"}, script.Conflicts)
+	assert.Contains(t, script.SQL, `"total" REAL NOT NULL`)
+
+	// both fixes rebuild orders
+	_, err = app.ApplyFixes("shop", []string{"nullable-column:orders:customer_id", "nullable-column:orders:total"}, false)
+	assert.EqualError(t, err, "several fixes rebuild or rename orders, apply them one at a time")
+	_, err = app.ApplyFixes("shop", nil, false)
+	assert.EqualError(t, err, "no finding selected")
+
+	data, err = app.ApplyFixes("shop", []string{"nullable-column:orders:total"}, true)
+	assert.NoError(t, err)
+	assert.Contains(t, data, `"dryRun":true`)
+	assert.False(t, notNull())
+
+	_, err = app.ApplyFixes("shop", []string{"nullable-column:orders:total"}, false)
+	assert.NoError(t, err)
+	assert.True(t, notNull())
+
+	// the metadata is reloaded, the finding is gone
+	data, err = app.PerformAllVerifications("shop")
+	assert.NoError(t, err)
+	assert.NotContains(t, data, "nullable-column:orders:total")
+	assert.Contains(t, data, "nullable-column:orders:customer_id")
+
+	// renames suggested by the naming rules
+	_, err = app.ApplyFixes("shop", []string{"naming-prefix:orders:customer_id:orders_customer", "naming-singular-table:orders"}, false)
+	assert.NoError(t, err)
+	tables, err := app.GetTablesList("shop")
+	assert.NoError(t, err)
+	var indexes []string
+	for _, table := range tables {
+		if table.TableName == "order" {
+			for _, index := range table.Indexes {
+				indexes = append(indexes, index.Name)
+			}
+		}
+	}
+	assert.Equal(t, []string{"ix_orders_customer_id"}, indexes)
+
+	_, err = app.OpenDDL("script", "sqlite", strings.Join(shopDDL, ";\n"))
+	assert.NoError(t, err)
+	_, err = app.PreviewFixes("script", []string{"nullable-column:orders:total"})
+	assert.NoError(t, err)
+	_, err = app.ApplyFixes("script", []string{"nullable-column:orders:total"}, true)
+	assert.EqualError(t, err, "no connection, the fixes can only be previewed")
+}
+
+func TestApp_CompareSessions(t *testing.T) {
+	app := NewApp()
+	_, err := app.ConfigureGorm("production", "sqlite", "", "", createShopDB(t, t.TempDir()), "", "", "", registry.Options{})
+	assert.NoError(t, err)
+	staging := append([]string{}, shopDDL...)
+	staging[1] = "CREATE TABLE orders (id INTEGER PRIMARY KEY, customer_id INT NOT NULL REFERENCES customers ON DELETE CASCADE, total REAL CHECK (total >= 0))"
+	staging = append(staging, "CREATE TABLE invoices (id INTEGER PRIMARY KEY)")
+	_, err = app.OpenDDL("staging", "sqlite", strings.Join(staging, ";\n"))
+	assert.NoError(t, err)
+
+	same, err := app.CompareSessions("production", "production")
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"addedTables":null,"removedTables":null,"changedTables":null,"renameCandidates":null}`, same)
+
+	data, err := app.CompareSessions("production", "staging")
+	assert.NoError(t, err)
+	var diff schemadiff.Diff
+	assert.NoError(t, json.Unmarshal([]byte(data), &diff))
+	if assert.Len(t, diff.AddedTables, 1) {
+		assert.Equal(t, "invoices", diff.AddedTables[0].TableName)
+	}
+	if assert.Len(t, diff.ChangedTables, 1) && assert.Len(t, diff.ChangedTables[0].ChangedColumns, 1) {
+		assert.Equal(t, "customer_id", diff.ChangedTables[0].ChangedColumns[0].ColumnName)
+		assert.Equal(t, []string{schemadiff.ChangeNullability}, diff.ChangedTables[0].ChangedColumns[0].Changes)
+	}
+
+	_, err = app.CompareSessions("production", "missing")
+	assert.Error(t, err)
+}
+
+func TestApp_GenerateMigration(t *testing.T) {
+	database := createShopDB(t, t.TempDir())
+	db, err := gorm.Open(sqlite.Open(database), &gorm.Config{})
+	assert.NoError(t, err)
+	assert.NoError(t, db.Exec("INSERT INTO customers (id, name) VALUES (1, 'Ada'); INSERT INTO orders (customer_id, total) VALUES (1, 12.5)").Error)
+
+	app := NewApp()
+	_, err = app.ConfigureGorm("production", "sqlite", "", "", database, "", "", "", registry.Options{})
+	assert.NoError(t, err)
+	_, err = app.OpenDDL("before", "sqlite", strings.Join(shopDDL, ";\n"))
+	assert.NoError(t, err)
+	_, err = app.OpenDDL("staging", "sqlite", strings.Join([]string{
+		"CREATE TABLE customers (id INTEGER PRIMARY KEY, name TEXT NOT NULL UNIQUE, email TEXT)",
+		"CREATE INDEX customers_email ON customers (email)",
+		"CREATE TABLE orders (id INTEGER PRIMARY KEY, customer_id INT NOT NULL REFERENCES customers ON DELETE CASCADE, total REAL CHECK (total >= 0))",
+		"CREATE INDEX orders_customer ON orders (customer_id)",
+		"CREATE TABLE invoices (id INTEGER PRIMARY KEY, order_id INT NOT NULL REFERENCES orders (id), amount REAL DEFAULT 0)",
+	}, ";\n"))
+	assert.NoError(t, err)
+
+	data, err := app.GenerateMigration("production", "staging", "")
+	assert.NoError(t, err)
+	var script struct {
+		migration.Script
+		UpSQL   string `json:"upSql"`
+		DownSQL string `json:"downSql"`
+	}
+	assert.NoError(t, json.Unmarshal([]byte(data), &script))
+	assert.Equal(t, "sqlite", script.Dialect)
+	assert.Contains(t, script.UpWarnings, "the column orders.customer_id becomes NOT NULL, the migration fails if it holds NULL values")
+	assert.Contains(t, script.DownWarnings, "the table invoices is dropped with its data")
+
+	// apply the scripts and read the database again
+	compare := func(target string) *schemadiff.Diff {
+		_, err := app.ConfigureGorm("production", "sqlite", "", "", database, "", "", "", registry.Options{})
+		assert.NoError(t, err)
+		data, err := app.CompareSessions("production", target)
+		assert.NoError(t, err)
+		var diff schemadiff.Diff
+		assert.NoError(t, json.Unmarshal([]byte(data), &diff))
+		return &diff
+	}
+	assert.NoError(t, db.Exec(script.UpSQL).Error)
+	assert.True(t, compare("staging").Empty(), script.UpSQL)
+	var count int64
+	assert.NoError(t, db.Raw("SELECT COUNT(*) FROM orders WHERE customer_id = 1 AND total = 12.5").Scan(&count).Error)
+	assert.Equal(t, int64(1), count)
+
+	assert.NoError(t, db.Exec(script.DownSQL).Error)
+	assert.True(t, compare("before").Empty(), script.DownSQL)
+
+	_, err = app.GenerateMigration("production", "staging", "oracle")
+	assert.Error(t, err)
+}
+
+func TestApp_ExportDDL(t *testing.T) {
+	dir := t.TempDir()
+	app := NewApp()
+	_, err := app.ConfigureGorm("production", "sqlite", "", "", createShopDB(t, dir), "", "", "", registry.Options{})
+	assert.NoError(t, err)
+	_, err = app.OpenDDL("postgres", "postgres", `
+		CREATE TABLE accounts (
+			id serial PRIMARY KEY,
+			email varchar(120) NOT NULL UNIQUE,
+			active boolean DEFAULT true,
+			balance numeric(12,2) CHECK (balance >= 0),
+			created_at timestamp DEFAULT now()
+		);
+		CREATE TABLE sessions (
+			id bigserial PRIMARY KEY,
+			account_id integer NOT NULL REFERENCES accounts (id) ON DELETE CASCADE,
+			token uuid DEFAULT gen_random_uuid()
+		);
+		CREATE INDEX sessions_account ON sessions (account_id);`)
+	assert.NoError(t, err)
+
+	// the DDL creates the schema again, in its dialect or another one
+	apply := func(sessionID, dialect string) *sqlgen.DDL {
+		data, err := app.ExportDDL(sessionID, dialect)
+		assert.NoError(t, err)
+		var ddl struct {
+			sqlgen.DDL
+			SQL string `json:"sql"`
+		}
+		assert.NoError(t, json.Unmarshal([]byte(data), &ddl))
+		database := filepath.Join(dir, sessionID+"-"+dialect+".db")
+		db, err := gorm.Open(sqlite.Open(database), &gorm.Config{})
+		assert.NoError(t, err)
+		assert.NoError(t, db.Exec(ddl.SQL).Error, ddl.SQL)
+		_, err = app.ConfigureGorm("copy", "sqlite", "", "", database, "", "", "", registry.Options{})
+		assert.NoError(t, err)
+		return &ddl.DDL
+	}
+	ddl := apply("production", "")
+	assert.Empty(t, ddl.Conversions)
+	diff, err := app.CompareSessions("production", "copy")
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"addedTables":null,"removedTables":null,"changedTables":null,"renameCandidates":null}`, diff)
+
+	ddl = apply("postgres", "sqlite")
+	assert.Contains(t, ddl.Statements[0], `"id" INTEGER NOT NULL PRIMARY KEY`)
+	assert.Contains(t, ddl.Statements[0], `"active" BOOLEAN DEFAULT 1`)
+	assert.Contains(t, ddl.Conversions, &sqlgen.Conversion{Table: "sessions", Object: "token", From: "gen_random_uuid()", Note: "SQLite has no UUID function, the default is dropped"})
+	tables, err := app.GetTablesList("copy")
+	assert.NoError(t, err)
+	assert.Len(t, tables, 2)
+
+	data, err := app.ExportDDL("production", "postgres")
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal([]byte(data), &ddl))
+	assert.Equal(t, `CREATE TABLE "orders" (
+  "id" bigint GENERATED BY DEFAULT AS IDENTITY,
+  "customer_id" bigint,
+  "total" double precision,
+  CONSTRAINT "orders_pkey" PRIMARY KEY ("id"),
+  CONSTRAINT "orders_total_check" CHECK (total >= 0)
+)`, ddl.Statements[2])
+	_, err = app.ExportDDL("production", "oracle")
+	assert.Error(t, err)
 }
@@ -1,12 +1,72 @@
 package databases
 
 import (
+	"context"
+	"db_meta/databases/registry"
 	"db_meta/dbstructs"
 
 	"gorm.io/gorm"
 )
 
-type DatabaseConnector interface {
-	Connect(string, string, string, string, string) (*gorm.DB, error)
-	GetTableMetadata(*gorm.DB) ([]*dbstructs.TableMetadata, error)
+// DatabaseConnector connects to a database and introspects it. Cancelling ctx
+// aborts the connection attempt or the running catalog queries. Connectors
+// register a constructor in the registry package.
+type DatabaseConnector = registry.Connector
+
+// TableBatchConnector is implemented by connectors able to list the tables first
+// and load their metadata batch by batch. The manager then loads the batches in
+// parallel and reports its progress.
+type TableBatchConnector interface {
+	GetTables(context.Context, *gorm.DB) ([]*dbstructs.TableMetadata, error)
+	LoadTableMetadata(context.Context, *gorm.DB, []*dbstructs.TableMetadata) error
+}
+
+// SchemaSelector is implemented by connectors able to introspect several schemas.
+type SchemaSelector interface {
+	SetSchemas([]string)
+}
+
+// OptionsSetter is implemented by connectors accepting a DSN, TLS settings and
+// the other advanced connection options.
+type OptionsSetter interface {
+	SetOptions(registry.Options)
+}
+
+// DialerSetter is implemented by connectors able to dial the database through
+// an SSH tunnel.
+type DialerSetter interface {
+	SetDialer(registry.DialFunc)
+}
+
+// ViewConnector is implemented by connectors able to introspect views.
+type ViewConnector interface {
+	GetViewMetadata(context.Context, *gorm.DB) ([]*dbstructs.ViewMetadata, error)
+}
+
+// TypeConnector is implemented by connectors able to introspect user-defined
+// types (enums, domains, composite types).
+type TypeConnector interface {
+	GetTypeMetadata(context.Context, *gorm.DB) ([]*dbstructs.TypeMetadata, error)
+}
+
+// TriggerConnector is implemented by connectors able to introspect triggers.
+type TriggerConnector interface {
+	GetTriggerMetadata(context.Context, *gorm.DB) ([]*dbstructs.TriggerMetadata, error)
+}
+
+// RoutineConnector is implemented by connectors able to introspect stored
+// procedures and functions.
+type RoutineConnector interface {
+	GetRoutineMetadata(context.Context, *gorm.DB) ([]*dbstructs.RoutineMetadata, error)
+}
+
+// SequenceConnector is implemented by connectors able to introspect sequences.
+type SequenceConnector interface {
+	GetSequenceMetadata(context.Context, *gorm.DB) ([]*dbstructs.SequenceMetadata, error)
+}
+
+// VersionConnector is implemented by connectors able to report the version of
+// the server, recorded in the snapshots.
+type VersionConnector interface {
+	GetServerVersion(context.Context, *gorm.DB) (string, error)
 }
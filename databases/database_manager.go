@@ -1,47 +1,53 @@
 package databases
 
 import (
-	mysqlConnector "db_meta/databases/mysql"
-	postgresConnector "db_meta/databases/postgres"
-	sqliteConnector "db_meta/databases/sqlite"
-	sqlserverConnector "db_meta/databases/sqlserver"
+	"context"
+	"database/sql"
+	"db_meta/databases/registry"
+	"db_meta/databases/sshtunnel"
 	"db_meta/dbstructs"
+	"db_meta/snapshot"
 	"errors"
+	"fmt"
 	"log"
 	"strconv"
+	"sync"
+	"time"
 
 	"gorm.io/gorm"
 )
 
-var (
-	instance *DatabaseManager
-	// once     sync.Once
+const (
+	tableBatchSize = 50 // tables loaded per LoadTableMetadata call
+	loadWorkers    = 4  // batches loaded in parallel
 )
 
+// DatabaseManager holds a connection and its loaded metadata, a Session guards
+// its concurrent use.
 type DatabaseManager struct {
 	connector DatabaseConnector
+	tunnel    *sshtunnel.Tunnel // open while connected through SSH
 	DB        *gorm.DB
+	DBType    string           // registry name of the connector
+	Schemas   []string         // schemas to load, connector default when empty
+	Options   registry.Options // DSN, TLS and other advanced connection settings
 	Tables    []*dbstructs.TableMetadata
+	Views     []*dbstructs.ViewMetadata
+	Types     []*dbstructs.TypeMetadata
+	Triggers  []*dbstructs.TriggerMetadata
+	Routines  []*dbstructs.RoutineMetadata
+	Sequences []*dbstructs.SequenceMetadata
 	Nodes     []*dbstructs.NodeElement
 	Edges     []*dbstructs.RelationshipEdge
-}
 
-// func GetDatabaseManagerInstance() *DatabaseManager {
-// 	once.Do(func() {
-// 		instance = &DatabaseManager{}
-// 	})
-// 	return instance
-// }
+	ServerVersion string    // reported by the server, if the connector can
+	CapturedAt    time.Time // when the metadata was loaded
 
-func GetDatabaseManagerInstance() *DatabaseManager {
-	if instance == nil {
-		instance = &DatabaseManager{}
-	}
-	return instance
-}
+	CollapsePartitions bool // fold partitions into their parent in the graph
 
-func SetInstance(mockInstance *DatabaseManager) {
-	instance = mockInstance
+	// OnProgress is called as tables are loaded, with the number of tables
+	// loaded so far out of total.
+	OnProgress func(done, total int)
 }
 
 func (dbm *DatabaseManager) SetConnector(con DatabaseConnector) {
@@ -52,42 +58,95 @@ func (dbm *DatabaseManager) GetTablesList() []*dbstructs.TableMetadata {
 	return dbm.Tables
 }
 
-// GetTablesListFunc is a function variable to get table metadata.
-var GetTablesListFunc = DefaultGetTablesList
+func (dbm *DatabaseManager) GetViewsList() []*dbstructs.ViewMetadata {
+	return dbm.Views
+}
+
+func (dbm *DatabaseManager) GetTypesList() []*dbstructs.TypeMetadata {
+	return dbm.Types
+}
+
+func (dbm *DatabaseManager) GetTriggersList() []*dbstructs.TriggerMetadata {
+	return dbm.Triggers
+}
+
+func (dbm *DatabaseManager) GetRoutinesList() []*dbstructs.RoutineMetadata {
+	return dbm.Routines
+}
+
+func (dbm *DatabaseManager) GetSequencesList() []*dbstructs.SequenceMetadata {
+	return dbm.Sequences
+}
 
-func DefaultGetTablesList() []*dbstructs.TableMetadata {
-	return GetDatabaseManagerInstance().GetTablesList()
+// Close closes the database connection and the SSH tunnel, if any.
+func (dbm *DatabaseManager) Close() error {
+	var err error
+	if dbm.DB != nil {
+		var sqlDB *sql.DB
+		if sqlDB, err = dbm.DB.DB(); err == nil {
+			err = sqlDB.Close()
+		}
+	}
+	if dbm.tunnel != nil {
+		if tunnelErr := dbm.tunnel.Close(); err == nil {
+			err = tunnelErr
+		}
+		dbm.tunnel = nil
+	}
+	return err
 }
 
-func (dbm *DatabaseManager) Connect(dbType, host, port, database, user, password string) (*gorm.DB, error) {
+// Connect opens the database with the connector registered as dbType,
+// cancelling ctx aborts the connection attempt.
+func (dbm *DatabaseManager) Connect(ctx context.Context, dbType, host, port, database, user, password string) (*gorm.DB, error) {
 	log.Println("Trying to connect to DB...")
-	switch dbType {
-	case "postgres":
-		dbm.SetConnector(&postgresConnector.PostgresConnector{})
-	case "mysql":
-		dbm.SetConnector(&mysqlConnector.MySQLConnector{})
-	case "sqlite":
-		dbm.SetConnector(&sqliteConnector.SQLiteConnector{})
-	case "sqlserver":
-		dbm.SetConnector(&sqlserverConnector.SQLServerConnector{})
-	default:
-		return nil, errors.New("unsopported database")
+	driver, ok := registry.Lookup(dbType)
+	if !ok {
+		return nil, fmt.Errorf("unsupported database %q", dbType)
 	}
+	dbm.SetConnector(driver.New())
+	dbm.DBType = dbType
 
 	if dbm.connector == nil {
 		return nil, errors.New("DB connector not initialized")
 	}
 
-	db, err := dbm.connector.Connect(host, port, database, user, password)
+	if selector, ok := dbm.connector.(SchemaSelector); ok {
+		selector.SetSchemas(dbm.Schemas)
+	}
+	if err := dbm.Options.Validate(); err != nil {
+		return nil, err
+	}
+	if setter, ok := dbm.connector.(OptionsSetter); ok {
+		setter.SetOptions(dbm.Options)
+	}
+	if dbm.Options.SSH.Enabled() {
+		setter, ok := dbm.connector.(DialerSetter)
+		if !ok {
+			return nil, fmt.Errorf("%s can't be reached through SSH", dbType)
+		}
+		tunnel, err := sshtunnel.Open(ctx, dbm.Options.SSH)
+		if err != nil {
+			log.Println("database_manager.go:[1]", err)
+			return nil, err
+		}
+		dbm.tunnel = tunnel
+		setter.SetDialer(tunnel.DialContext)
+	}
+
+	db, err := dbm.connector.Connect(ctx, host, port, database, user, password)
 	if err != nil {
 		log.Println("database_manager.go:[1]", err)
+		dbm.Close()
 		return nil, err
 	}
 	dbm.DB = db
 	return dbm.DB, nil
 }
 
-func (dbm *DatabaseManager) GetTableMetadata() ([]*dbstructs.TableMetadata, error) {
+// GetTableMetadata loads the metadata of the connected database, cancelling ctx
+// stops the loading.
+func (dbm *DatabaseManager) GetTableMetadata(ctx context.Context) ([]*dbstructs.TableMetadata, error) {
 	if dbm.DB == nil {
 		return nil, errors.New("DB not connected")
 	}
@@ -96,42 +155,380 @@ func (dbm *DatabaseManager) GetTableMetadata() ([]*dbstructs.TableMetadata, erro
 		return nil, errors.New("DB connector not initialized")
 	}
 
-	tables, err := dbm.connector.GetTableMetadata(dbm.DB)
+	dbm.CapturedAt = time.Now()
+	dbm.ServerVersion = ""
+	if versionConnector, ok := dbm.connector.(VersionConnector); ok {
+		version, err := versionConnector.GetServerVersion(ctx, dbm.DB)
+		if err != nil {
+			log.Println("database_manager.go:[8]", err)
+			return nil, err
+		}
+		dbm.ServerVersion = version
+	}
+
+	tables, err := dbm.loadTables(ctx)
 	if err != nil {
 		log.Println("database_manager.go:[2]", err)
 		return nil, err
 	}
 	dbm.Tables = tables
-	log.Printf("%#v\n", dbm.Tables)
+
+	dbm.Views = nil
+	if viewConnector, ok := dbm.connector.(ViewConnector); ok {
+		views, err := viewConnector.GetViewMetadata(ctx, dbm.DB)
+		if err != nil {
+			log.Println("database_manager.go:[3]", err)
+			return nil, err
+		}
+		dbm.Views = views
+	}
+
+	dbm.Types = nil
+	if typeConnector, ok := dbm.connector.(TypeConnector); ok {
+		types, err := typeConnector.GetTypeMetadata(ctx, dbm.DB)
+		if err != nil {
+			log.Println("database_manager.go:[4]", err)
+			return nil, err
+		}
+		dbm.Types = types
+	}
+
+	dbm.Triggers = nil
+	if triggerConnector, ok := dbm.connector.(TriggerConnector); ok {
+		triggers, err := triggerConnector.GetTriggerMetadata(ctx, dbm.DB)
+		if err != nil {
+			log.Println("database_manager.go:[5]", err)
+			return nil, err
+		}
+		dbm.Triggers = triggers
+	}
+
+	dbm.Routines = nil
+	if routineConnector, ok := dbm.connector.(RoutineConnector); ok {
+		routines, err := routineConnector.GetRoutineMetadata(ctx, dbm.DB)
+		if err != nil {
+			log.Println("database_manager.go:[6]", err)
+			return nil, err
+		}
+		dbm.Routines = routines
+	}
+
+	dbm.Sequences = nil
+	if sequenceConnector, ok := dbm.connector.(SequenceConnector); ok {
+		sequences, err := sequenceConnector.GetSequenceMetadata(ctx, dbm.DB)
+		if err != nil {
+			log.Println("database_manager.go:[7]", err)
+			return nil, err
+		}
+		dbm.Sequences = sequences
+	}
+
 	dbm.TransformToGraph()
 	return dbm.Tables, nil
 }
 
+// Snapshot returns the loaded metadata, to be written with snapshot.Write.
+func (dbm *DatabaseManager) Snapshot() *snapshot.Snapshot {
+	return &snapshot.Snapshot{
+		DBType:        dbm.DBType,
+		ServerVersion: dbm.ServerVersion,
+		CapturedAt:    dbm.CapturedAt,
+		Schemas:       dbm.Schemas,
+		Tables:        dbm.Tables,
+		Views:         dbm.Views,
+		Types:         dbm.Types,
+		Triggers:      dbm.Triggers,
+		Routines:      dbm.Routines,
+		Sequences:     dbm.Sequences,
+	}
+}
+
+// NewOfflineManager returns a manager holding the metadata of a snapshot,
+// without connection: the metadata and graph methods work as after
+// GetTableMetadata, connecting is left to Connect.
+func NewOfflineManager(s *snapshot.Snapshot) *DatabaseManager {
+	dbm := &DatabaseManager{
+		DBType:        s.DBType,
+		Schemas:       s.Schemas,
+		ServerVersion: s.ServerVersion,
+		CapturedAt:    s.CapturedAt,
+		Tables:        s.Tables,
+		Views:         s.Views,
+		Types:         s.Types,
+		Triggers:      s.Triggers,
+		Routines:      s.Routines,
+		Sequences:     s.Sequences,
+	}
+	dbm.TransformToGraph()
+	return dbm
+}
+
+// loadTables loads the tables, batch by batch on loadWorkers goroutines when the
+// connector supports it, and reports the progress to OnProgress.
+func (dbm *DatabaseManager) loadTables(ctx context.Context) ([]*dbstructs.TableMetadata, error) {
+	batchConnector, ok := dbm.connector.(TableBatchConnector)
+	if !ok {
+		tables, err := dbm.connector.GetTableMetadata(ctx, dbm.DB)
+		if err == nil {
+			dbm.progress(len(tables), len(tables))
+		}
+		return tables, err
+	}
+
+	tables, err := batchConnector.GetTables(ctx, dbm.DB)
+	if err != nil {
+		return nil, err
+	}
+	dbm.progress(0, len(tables))
+
+	// the first error stops the other workers
+	loadCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		loadErr error
+		done    int
+		batches = make(chan []*dbstructs.TableMetadata)
+	)
+	for i := 0; i < loadWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				err := batchConnector.LoadTableMetadata(loadCtx, dbm.DB, batch)
+				mu.Lock()
+				if err != nil && loadErr == nil {
+					loadErr = err
+					cancel()
+				} else if err == nil {
+					done += len(batch)
+					dbm.progress(done, len(tables))
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+feed:
+	for start := 0; start < len(tables); start += tableBatchSize {
+		end := start + tableBatchSize
+		if end > len(tables) {
+			end = len(tables)
+		}
+		select {
+		case batches <- tables[start:end]:
+		case <-loadCtx.Done():
+			break feed
+		}
+	}
+	close(batches)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if loadErr != nil {
+		return nil, loadErr
+	}
+	return tables, nil
+}
+
+func (dbm *DatabaseManager) progress(done, total int) {
+	if dbm.OnProgress != nil {
+		dbm.OnProgress(done, total)
+	}
+}
+
+// TransformToGraph builds the graph nodes and edges from the loaded metadata.
+// With CollapsePartitions, partitions are folded into the node of their root
+// parent and their edges are moved to it.
 func (dbm *DatabaseManager) TransformToGraph() {
 	dbm.Nodes = []*dbstructs.NodeElement{}
 	dbm.Edges = []*dbstructs.RelationshipEdge{}
 
-	for index, table := range dbm.Tables {
+	nodeNames := make(map[string]bool)
+	for _, table := range dbm.Tables {
+		nodeNames[table.QualifiedName()] = true
+	}
+	for _, view := range dbm.Views {
+		nodeNames[view.QualifiedName()] = true
+	}
+
+	collapsed := dbm.collapsedPartitions()
+	nodeName := func(name string) string {
+		if root, ok := collapsed[name]; ok {
+			return root
+		}
+		return name
+	}
+	seenEdges := make(map[string]bool)
+	addEdge := func(edge *dbstructs.EdgeData) {
+		edge.Source, edge.Target = nodeName(edge.Source), nodeName(edge.Target)
+		// PostgreSQL lists a foreign key under both its tables, and partitions
+		// of a same parent share their foreign keys
+		key := edge.ID + "\x00" + edge.Source + "\x00" + edge.Target
+		if seenEdges[key] {
+			return
+		}
+		seenEdges[key] = true
+		dbm.Edges = append(dbm.Edges, &dbstructs.RelationshipEdge{Data: edge})
+	}
+
+	tableNodes := make(map[string]*dbstructs.NodeData)
+	for _, table := range dbm.Tables {
 		// Add Nodes
-		dbm.Nodes = append(dbm.Nodes, &dbstructs.NodeElement{
-			Data: &dbstructs.NodeData{
-				ID:         strconv.Itoa(index),
-				Name:       table.TableName,
+		if _, ok := collapsed[table.QualifiedName()]; !ok {
+			node := &dbstructs.NodeData{
+				ID:         strconv.Itoa(len(dbm.Nodes)),
+				Name:       table.QualifiedName(),
+				Schema:     table.Schema,
+				Kind:       dbstructs.NodeKindTable,
 				Columns:    table.Columns,
 				PrimaryKey: table.PrimaryKey,
 				Indexes:    table.Indexes,
-			},
-		})
+			}
+			tableNodes[node.Name] = node
+			dbm.Nodes = append(dbm.Nodes, &dbstructs.NodeElement{Data: node})
+		}
 
 		// Add relations
 		for _, rel := range table.Relationships {
-			dbm.Edges = append(dbm.Edges, &dbstructs.RelationshipEdge{
-				Data: &dbstructs.EdgeData{
-					ID:     rel.Conname,
-					Source: rel.SourceTableName,
-					Target: rel.RelatedTableName,
-				},
+			addEdge(&dbstructs.EdgeData{
+				ID:            rel.Conname,
+				Source:        rel.QualifiedSourceName(),
+				Target:        rel.QualifiedRelatedName(),
+				SourceColumns: rel.SourceColumns,
+				TargetColumns: rel.TargetColumns,
+				OnDelete:      rel.OnDelete,
+				OnUpdate:      rel.OnUpdate,
+				Kind:          dbstructs.EdgeKindForeignKey,
+			})
+		}
+
+		// Add partitioning and inheritance
+		if _, ok := collapsed[table.QualifiedName()]; !ok && nodeNames[table.PartitionOf] {
+			addEdge(&dbstructs.EdgeData{
+				ID:     table.QualifiedName() + "->" + table.PartitionOf,
+				Source: table.QualifiedName(),
+				Target: table.PartitionOf,
+				Kind:   dbstructs.EdgeKindPartitionOf,
+			})
+		}
+		for _, parent := range table.Inherits {
+			if nodeNames[parent] {
+				addEdge(&dbstructs.EdgeData{
+					ID:     table.QualifiedName() + "->" + parent,
+					Source: table.QualifiedName(),
+					Target: parent,
+					Kind:   dbstructs.EdgeKindInherits,
+				})
+			}
+		}
+	}
+
+	for _, table := range dbm.Tables {
+		if root, ok := collapsed[table.QualifiedName()]; ok {
+			tableNodes[root].Partitions = append(tableNodes[root].Partitions, table.QualifiedName())
+		}
+	}
+
+	for _, view := range dbm.Views {
+		kind := dbstructs.NodeKindView
+		if view.Materialized {
+			kind = dbstructs.NodeKindMaterializedView
+		}
+		dbm.Nodes = append(dbm.Nodes, &dbstructs.NodeElement{
+			Data: &dbstructs.NodeData{
+				ID:      strconv.Itoa(len(dbm.Nodes)),
+				Name:    view.QualifiedName(),
+				Schema:  view.Schema,
+				Kind:    kind,
+				Columns: view.Columns,
+			},
+		})
+
+		// Dependencies outside of the loaded schemas have no node to point to
+		for _, dependency := range view.Dependencies {
+			if !nodeNames[dependency] {
+				continue
+			}
+			addEdge(&dbstructs.EdgeData{
+				ID:     view.QualifiedName() + "->" + dependency,
+				Source: view.QualifiedName(),
+				Target: dependency,
+				Kind:   dbstructs.EdgeKindViewDependency,
 			})
 		}
 	}
+
+	for _, trigger := range dbm.Triggers {
+		table := trigger.QualifiedTableName()
+		if !nodeNames[table] {
+			continue
+		}
+		dbm.Nodes = append(dbm.Nodes, &dbstructs.NodeElement{
+			Data: &dbstructs.NodeData{
+				ID:      strconv.Itoa(len(dbm.Nodes)),
+				Name:    trigger.DisplayName(),
+				Schema:  trigger.TableSchema,
+				Kind:    dbstructs.NodeKindTrigger,
+				Columns: []*dbstructs.Column{},
+			},
+		})
+		addEdge(&dbstructs.EdgeData{
+			ID:     trigger.DisplayName() + "->" + table,
+			Source: trigger.DisplayName(),
+			Target: table,
+			Kind:   dbstructs.EdgeKindTriggerTable,
+		})
+
+		for _, reference := range trigger.References {
+			if reference == table || !nodeNames[reference] {
+				continue
+			}
+			addEdge(&dbstructs.EdgeData{
+				ID:     trigger.DisplayName() + "->" + reference,
+				Source: trigger.DisplayName(),
+				Target: reference,
+				Kind:   dbstructs.EdgeKindTriggerReference,
+			})
+		}
+	}
+}
+
+// collapsedPartitions maps the loaded partitions to their root loaded parent
+// when CollapsePartitions is set.
+func (dbm *DatabaseManager) collapsedPartitions() map[string]string {
+	collapsed := make(map[string]string)
+	if !dbm.CollapsePartitions {
+		return collapsed
+	}
+
+	parents := make(map[string]string)
+	for _, table := range dbm.Tables {
+		if table.PartitionOf != "" {
+			parents[table.QualifiedName()] = table.PartitionOf
+		}
+	}
+	for _, table := range dbm.Tables {
+		root := table.QualifiedName()
+		for dbm.findTableByName(parents[root]) != nil {
+			root = parents[root]
+		}
+		if root != table.QualifiedName() {
+			collapsed[table.QualifiedName()] = root
+		}
+	}
+	return collapsed
+}
+
+// findTableByName looks a table up by its qualified name
+func (dbm *DatabaseManager) findTableByName(tableName string) *dbstructs.TableMetadata {
+	for _, table := range dbm.Tables {
+		if table.QualifiedName() == tableName {
+			return table
+		}
+	}
+	return nil
 }
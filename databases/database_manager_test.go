@@ -2,9 +2,19 @@ package databases
 
 import (
 	"context"
+	_ "db_meta/databases/mysql"
+	_ "db_meta/databases/postgres"
+	"db_meta/databases/registry"
+	_ "db_meta/databases/sqlite"
+	_ "db_meta/databases/sqlserver"
+	"db_meta/databases/sshtunnel"
+	"db_meta/databases/sshtunnel/sshtest"
+	"db_meta/databases/tlsfixture"
 	"db_meta/dbstructs"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"reflect"
 	"strings"
 	"testing"
@@ -18,9 +28,9 @@ import (
 	"gorm.io/gorm"
 )
 
-const expectedPostgresJSON = `[{"tableName":"table1","columns":[{"columnName":"id","data_type":"integer","not_null":true,"unique":false},{"columnName":"name","data_type":"character varying","not_null":false,"unique":false}],"primary_key":["id"],"indexes":[{"name":"table1_pkey","columns":["id"]}],"relationships":[{"Conname":"table2_table1_id_fkey","SourceTableName":"table2","RelatedTableName":"table1"}]},{"tableName":"table2","columns":[{"columnName":"id","data_type":"integer","not_null":true,"unique":false},{"columnName":"table1_id","data_type":"integer","not_null":false,"unique":false},{"columnName":"description","data_type":"character varying","not_null":false,"unique":false}],"primary_key":["id"],"indexes":[{"name":"table2_pkey","columns":["id"]}],"relationships":[{"Conname":"table2_table1_id_fkey","SourceTableName":"table2","RelatedTableName":"table1"}]},{"tableName":"table3","columns":[{"columnName":"id","data_type":"integer","not_null":true,"unique":false},{"columnName":"info","data_type":"character varying","not_null":false,"unique":false}],"primary_key":["id"],"indexes":[{"name":"table3_pkey","columns":["id"]}],"relationships":null}]`
-const expectedMySQLJSON = `[{"tableName":"table1","columns":[{"columnName":"id","data_type":"bigint","not_null":true,"unique":true},{"columnName":"name","data_type":"varchar","not_null":false,"unique":false}],"primary_key":["id"],"indexes":[{"name":"PRIMARY","columns":["id"]}],"relationships":null},{"tableName":"table2","columns":[{"columnName":"description","data_type":"varchar","not_null":false,"unique":false},{"columnName":"id","data_type":"bigint","not_null":true,"unique":true},{"columnName":"table1_id","data_type":"bigint","not_null":false,"unique":false}],"primary_key":["id"],"indexes":[{"name":"PRIMARY","columns":["id"]},{"name":"table1_id","columns":["table1_id"]}],"relationships":[{"Conname":"table2_ibfk_1","RelatedTableName":"table1"}]},{"tableName":"table3","columns":[{"columnName":"id","data_type":"bigint","not_null":true,"unique":true},{"columnName":"info","data_type":"varchar","not_null":false,"unique":false}],"primary_key":["id"],"indexes":[{"name":"PRIMARY","columns":["id"]}],"relationships":null}]`
-const expectedSQLServerJSON = `[{"tableName":"spt_fallback_db","columns":[{"columnName":"xserver_name","data_type":"varchar","not_null":false,"unique":false},{"columnName":"xdttm_ins","data_type":"datetime","not_null":false,"unique":false},{"columnName":"xdttm_last_ins_upd","data_type":"datetime","not_null":false,"unique":false},{"columnName":"xfallback_dbid","data_type":"smallint","not_null":false,"unique":false},{"columnName":"name","data_type":"varchar","not_null":false,"unique":false},{"columnName":"dbid","data_type":"smallint","not_null":false,"unique":false},{"columnName":"status","data_type":"smallint","not_null":false,"unique":false},{"columnName":"version","data_type":"smallint","not_null":false,"unique":false}],"primary_key":null,"indexes":null,"relationships":null},{"tableName":"spt_fallback_dev","columns":[{"columnName":"xserver_name","data_type":"varchar","not_null":false,"unique":false},{"columnName":"xdttm_ins","data_type":"datetime","not_null":false,"unique":false},{"columnName":"xdttm_last_ins_upd","data_type":"datetime","not_null":false,"unique":false},{"columnName":"xfallback_low","data_type":"int","not_null":false,"unique":false},{"columnName":"xfallback_drive","data_type":"char","not_null":false,"unique":false},{"columnName":"low","data_type":"int","not_null":false,"unique":false},{"columnName":"high","data_type":"int","not_null":false,"unique":false},{"columnName":"status","data_type":"smallint","not_null":false,"unique":false},{"columnName":"name","data_type":"varchar","not_null":false,"unique":false},{"columnName":"phyname","data_type":"varchar","not_null":false,"unique":false}],"primary_key":null,"indexes":null,"relationships":null},{"tableName":"spt_fallback_usg","columns":[{"columnName":"xserver_name","data_type":"varchar","not_null":false,"unique":false},{"columnName":"xdttm_ins","data_type":"datetime","not_null":false,"unique":false},{"columnName":"xdttm_last_ins_upd","data_type":"datetime","not_null":false,"unique":false},{"columnName":"xfallback_vstart","data_type":"int","not_null":false,"unique":false},{"columnName":"dbid","data_type":"smallint","not_null":false,"unique":false},{"columnName":"segmap","data_type":"int","not_null":false,"unique":false},{"columnName":"lstart","data_type":"int","not_null":false,"unique":false},{"columnName":"sizepg","data_type":"int","not_null":false,"unique":false},{"columnName":"vstart","data_type":"int","not_null":false,"unique":false}],"primary_key":null,"indexes":null,"relationships":null},{"tableName":"table1","columns":[{"columnName":"id","data_type":"int","not_null":false,"unique":true},{"columnName":"name","data_type":"varchar","not_null":false,"unique":false}],"primary_key":["id"],"indexes":null,"relationships":null},{"tableName":"table2","columns":[{"columnName":"id","data_type":"int","not_null":false,"unique":true},{"columnName":"description","data_type":"varchar","not_null":false,"unique":false},{"columnName":"table1_id","data_type":"int","not_null":false,"unique":false}],"primary_key":["id"],"indexes":null,"relationships":[{"Conname":"FK__table2__table1_i__22CA2527","SourceTableName":"","RelatedTableName":""}]},{"tableName":"table3","columns":[{"columnName":"id","data_type":"int","not_null":false,"unique":true},{"columnName":"info","data_type":"varchar","not_null":false,"unique":false}],"primary_key":["id"],"indexes":null,"relationships":null},{"tableName":"spt_monitor","columns":[{"columnName":"lastrun","data_type":"datetime","not_null":false,"unique":false},{"columnName":"cpu_busy","data_type":"int","not_null":false,"unique":false},{"columnName":"io_busy","data_type":"int","not_null":false,"unique":false},{"columnName":"idle","data_type":"int","not_null":false,"unique":false},{"columnName":"pack_received","data_type":"int","not_null":false,"unique":false},{"columnName":"pack_sent","data_type":"int","not_null":false,"unique":false},{"columnName":"connections","data_type":"int","not_null":false,"unique":false},{"columnName":"pack_errors","data_type":"int","not_null":false,"unique":false},{"columnName":"total_read","data_type":"int","not_null":false,"unique":false},{"columnName":"total_write","data_type":"int","not_null":false,"unique":false},{"columnName":"total_errors","data_type":"int","not_null":false,"unique":false}],"primary_key":null,"indexes":null,"relationships":null},{"tableName":"MSreplication_options","columns":[{"columnName":"optname","data_type":"sysname","not_null":false,"unique":false},{"columnName":"value","data_type":"bit","not_null":false,"unique":false},{"columnName":"major_version","data_type":"int","not_null":false,"unique":false},{"columnName":"minor_version","data_type":"int","not_null":false,"unique":false},{"columnName":"revision","data_type":"int","not_null":false,"unique":false},{"columnName":"install_failures","data_type":"int","not_null":false,"unique":false}],"primary_key":null,"indexes":null,"relationships":null}]`
+const expectedPostgresJSON = `[{"schema":"public","tableName":"table1","columns":[{"columnName":"id","data_type":"integer","not_null":true,"unique":false,"default":"nextval('table1_id_seq'::regclass)","auto_increment":true,"numeric_precision":32,"numeric_scale":0,"ordinal_position":1},{"columnName":"name","data_type":"character varying","not_null":false,"unique":false,"character_length":255,"ordinal_position":2}],"primary_key":["id"],"indexes":[{"name":"table1_pkey","columns":["id"],"keys":[{"column":"id"}],"unique":true,"primary":true,"method":"btree"}],"relationships":[{"Conname":"table2_table1_id_fkey","SourceSchema":"public","SourceTableName":"table2","RelatedSchema":"public","RelatedTableName":"table1","SourceColumns":["table1_id"],"TargetColumns":["id"],"OnDelete":"NO ACTION","OnUpdate":"NO ACTION","Match":"SIMPLE","Deferrable":false,"InitiallyDeferred":false}]},{"schema":"public","tableName":"table2","columns":[{"columnName":"id","data_type":"integer","not_null":true,"unique":false,"default":"nextval('table2_id_seq'::regclass)","auto_increment":true,"numeric_precision":32,"numeric_scale":0,"ordinal_position":1},{"columnName":"description","data_type":"character varying","not_null":false,"unique":false,"character_length":255,"ordinal_position":2},{"columnName":"table1_id","data_type":"integer","not_null":false,"unique":false,"numeric_precision":32,"numeric_scale":0,"ordinal_position":3}],"primary_key":["id"],"indexes":[{"name":"table2_pkey","columns":["id"],"keys":[{"column":"id"}],"unique":true,"primary":true,"method":"btree"}],"relationships":[{"Conname":"table2_table1_id_fkey","SourceSchema":"public","SourceTableName":"table2","RelatedSchema":"public","RelatedTableName":"table1","SourceColumns":["table1_id"],"TargetColumns":["id"],"OnDelete":"NO ACTION","OnUpdate":"NO ACTION","Match":"SIMPLE","Deferrable":false,"InitiallyDeferred":false}]},{"schema":"public","tableName":"table3","columns":[{"columnName":"id","data_type":"integer","not_null":true,"unique":false,"default":"nextval('table3_id_seq'::regclass)","auto_increment":true,"numeric_precision":32,"numeric_scale":0,"ordinal_position":1},{"columnName":"info","data_type":"character varying","not_null":false,"unique":false,"character_length":255,"ordinal_position":2},{"columnName":"parent_id","data_type":"integer","not_null":false,"unique":false,"numeric_precision":32,"numeric_scale":0,"ordinal_position":3}],"primary_key":["id"],"indexes":[{"name":"table3_pkey","columns":["id"],"keys":[{"column":"id"}],"unique":true,"primary":true,"method":"btree"}],"relationships":[{"Conname":"table3_parent_id_fkey","SourceSchema":"public","SourceTableName":"table3","RelatedSchema":"public","RelatedTableName":"table3","SourceColumns":["parent_id"],"TargetColumns":["id"],"OnDelete":"NO ACTION","OnUpdate":"NO ACTION","Match":"SIMPLE","Deferrable":false,"InitiallyDeferred":false}]}]`
+const expectedMySQLJSON = `[{"tableName":"table1","columns":[{"columnName":"id","data_type":"bigint","not_null":true,"unique":true,"auto_increment":true,"numeric_precision":19,"numeric_scale":0,"ordinal_position":1},{"columnName":"name","data_type":"varchar","not_null":false,"unique":false,"character_length":255,"ordinal_position":2}],"primary_key":["id"],"indexes":[{"name":"PRIMARY","columns":["id"],"keys":[{"column":"id"}],"unique":true,"primary":true,"method":"btree"}],"relationships":null},{"tableName":"table2","columns":[{"columnName":"id","data_type":"bigint","not_null":true,"unique":true,"auto_increment":true,"numeric_precision":19,"numeric_scale":0,"ordinal_position":1},{"columnName":"description","data_type":"varchar","not_null":false,"unique":false,"character_length":255,"ordinal_position":2},{"columnName":"table1_id","data_type":"bigint","not_null":false,"unique":false,"numeric_precision":19,"numeric_scale":0,"ordinal_position":3}],"primary_key":["id"],"indexes":[{"name":"PRIMARY","columns":["id"],"keys":[{"column":"id"}],"unique":true,"primary":true,"method":"btree"},{"name":"table1_id","columns":["table1_id"],"keys":[{"column":"table1_id"}],"method":"btree"}],"relationships":[{"Conname":"table2_ibfk_1","SourceTableName":"table2","RelatedTableName":"table1","SourceColumns":["table1_id"],"TargetColumns":["id"],"OnDelete":"RESTRICT","OnUpdate":"RESTRICT","Match":"NONE","Deferrable":false,"InitiallyDeferred":false}]},{"tableName":"table3","columns":[{"columnName":"id","data_type":"bigint","not_null":true,"unique":true,"auto_increment":true,"numeric_precision":19,"numeric_scale":0,"ordinal_position":1},{"columnName":"info","data_type":"varchar","not_null":false,"unique":false,"character_length":255,"ordinal_position":2}],"primary_key":["id"],"indexes":[{"name":"PRIMARY","columns":["id"],"keys":[{"column":"id"}],"unique":true,"primary":true,"method":"btree"}],"relationships":null}]`
+const expectedSQLServerJSON = `[{"schema":"dbo","tableName":"spt_fallback_db","columns":[{"columnName":"xserver_name","data_type":"varchar","not_null":false,"unique":false,"character_length":30,"ordinal_position":1},{"columnName":"xdttm_ins","data_type":"datetime","not_null":false,"unique":false,"ordinal_position":2},{"columnName":"xdttm_last_ins_upd","data_type":"datetime","not_null":false,"unique":false,"ordinal_position":3},{"columnName":"xfallback_dbid","data_type":"smallint","not_null":false,"unique":false,"numeric_precision":5,"ordinal_position":4},{"columnName":"name","data_type":"varchar","not_null":false,"unique":false,"character_length":30,"ordinal_position":5},{"columnName":"dbid","data_type":"smallint","not_null":false,"unique":false,"numeric_precision":5,"ordinal_position":6},{"columnName":"status","data_type":"smallint","not_null":false,"unique":false,"numeric_precision":5,"ordinal_position":7},{"columnName":"version","data_type":"smallint","not_null":false,"unique":false,"numeric_precision":5,"ordinal_position":8}],"primary_key":null,"indexes":null,"relationships":null},{"schema":"dbo","tableName":"spt_fallback_dev","columns":[{"columnName":"xserver_name","data_type":"varchar","not_null":false,"unique":false,"character_length":30,"ordinal_position":1},{"columnName":"xdttm_ins","data_type":"datetime","not_null":false,"unique":false,"ordinal_position":2},{"columnName":"xdttm_last_ins_upd","data_type":"datetime","not_null":false,"unique":false,"ordinal_position":3},{"columnName":"xfallback_low","data_type":"int","not_null":false,"unique":false,"numeric_precision":10,"ordinal_position":4},{"columnName":"xfallback_drive","data_type":"char","not_null":false,"unique":false,"character_length":2,"ordinal_position":5},{"columnName":"low","data_type":"int","not_null":false,"unique":false,"numeric_precision":10,"ordinal_position":6},{"columnName":"high","data_type":"int","not_null":false,"unique":false,"numeric_precision":10,"ordinal_position":7},{"columnName":"status","data_type":"smallint","not_null":false,"unique":false,"numeric_precision":5,"ordinal_position":8},{"columnName":"name","data_type":"varchar","not_null":false,"unique":false,"character_length":30,"ordinal_position":9},{"columnName":"phyname","data_type":"varchar","not_null":false,"unique":false,"character_length":127,"ordinal_position":10}],"primary_key":null,"indexes":null,"relationships":null},{"schema":"dbo","tableName":"spt_fallback_usg","columns":[{"columnName":"xserver_name","data_type":"varchar","not_null":false,"unique":false,"character_length":30,"ordinal_position":1},{"columnName":"xdttm_ins","data_type":"datetime","not_null":false,"unique":false,"ordinal_position":2},{"columnName":"xdttm_last_ins_upd","data_type":"datetime","not_null":false,"unique":false,"ordinal_position":3},{"columnName":"xfallback_vstart","data_type":"int","not_null":false,"unique":false,"numeric_precision":10,"ordinal_position":4},{"columnName":"dbid","data_type":"smallint","not_null":false,"unique":false,"numeric_precision":5,"ordinal_position":5},{"columnName":"segmap","data_type":"int","not_null":false,"unique":false,"numeric_precision":10,"ordinal_position":6},{"columnName":"lstart","data_type":"int","not_null":false,"unique":false,"numeric_precision":10,"ordinal_position":7},{"columnName":"sizepg","data_type":"int","not_null":false,"unique":false,"numeric_precision":10,"ordinal_position":8},{"columnName":"vstart","data_type":"int","not_null":false,"unique":false,"numeric_precision":10,"ordinal_position":9}],"primary_key":null,"indexes":null,"relationships":null},{"schema":"dbo","tableName":"table1","columns":[{"columnName":"id","data_type":"int","not_null":true,"unique":true,"auto_increment":true,"numeric_precision":10,"ordinal_position":1},{"columnName":"name","data_type":"varchar","not_null":false,"unique":false,"character_length":255,"ordinal_position":2}],"primary_key":["id"],"indexes":null,"relationships":null},{"schema":"dbo","tableName":"table2","columns":[{"columnName":"id","data_type":"int","not_null":true,"unique":true,"auto_increment":true,"numeric_precision":10,"ordinal_position":1},{"columnName":"description","data_type":"varchar","not_null":false,"unique":false,"character_length":255,"ordinal_position":2},{"columnName":"table1_id","data_type":"int","not_null":false,"unique":false,"numeric_precision":10,"ordinal_position":3}],"primary_key":["id"],"indexes":null,"relationships":[{"Conname":"FK__table2__table1_i__22CA2527","SourceSchema":"dbo","SourceTableName":"table2","RelatedSchema":"dbo","RelatedTableName":"table1","SourceColumns":["table1_id"],"TargetColumns":["id"],"OnDelete":"NO ACTION","OnUpdate":"NO ACTION","Match":"","Deferrable":false,"InitiallyDeferred":false}]},{"schema":"dbo","tableName":"table3","columns":[{"columnName":"id","data_type":"int","not_null":true,"unique":true,"auto_increment":true,"numeric_precision":10,"ordinal_position":1},{"columnName":"info","data_type":"varchar","not_null":false,"unique":false,"character_length":255,"ordinal_position":2}],"primary_key":["id"],"indexes":null,"relationships":null},{"schema":"dbo","tableName":"spt_monitor","columns":[{"columnName":"lastrun","data_type":"datetime","not_null":false,"unique":false,"ordinal_position":1},{"columnName":"cpu_busy","data_type":"int","not_null":false,"unique":false,"numeric_precision":10,"ordinal_position":2},{"columnName":"io_busy","data_type":"int","not_null":false,"unique":false,"numeric_precision":10,"ordinal_position":3},{"columnName":"idle","data_type":"int","not_null":false,"unique":false,"numeric_precision":10,"ordinal_position":4},{"columnName":"pack_received","data_type":"int","not_null":false,"unique":false,"numeric_precision":10,"ordinal_position":5},{"columnName":"pack_sent","data_type":"int","not_null":false,"unique":false,"numeric_precision":10,"ordinal_position":6},{"columnName":"connections","data_type":"int","not_null":false,"unique":false,"numeric_precision":10,"ordinal_position":7},{"columnName":"pack_errors","data_type":"int","not_null":false,"unique":false,"numeric_precision":10,"ordinal_position":8},{"columnName":"total_read","data_type":"int","not_null":false,"unique":false,"numeric_precision":10,"ordinal_position":9},{"columnName":"total_write","data_type":"int","not_null":false,"unique":false,"numeric_precision":10,"ordinal_position":10},{"columnName":"total_errors","data_type":"int","not_null":false,"unique":false,"numeric_precision":10,"ordinal_position":11}],"primary_key":null,"indexes":null,"relationships":null},{"schema":"dbo","tableName":"MSreplication_options","columns":[{"columnName":"optname","data_type":"sysname","not_null":false,"unique":false,"ordinal_position":1},{"columnName":"value","data_type":"bit","not_null":false,"unique":false,"ordinal_position":2},{"columnName":"major_version","data_type":"int","not_null":false,"unique":false,"numeric_precision":10,"ordinal_position":3},{"columnName":"minor_version","data_type":"int","not_null":false,"unique":false,"numeric_precision":10,"ordinal_position":4},{"columnName":"revision","data_type":"int","not_null":false,"unique":false,"numeric_precision":10,"ordinal_position":5},{"columnName":"install_failures","data_type":"int","not_null":false,"unique":false,"numeric_precision":10,"ordinal_position":6}],"primary_key":null,"indexes":null,"relationships":null}]`
 
 func createTestPostgresSchema(db *gorm.DB) error {
 	schema := `
@@ -38,7 +48,8 @@ func createTestPostgresSchema(db *gorm.DB) error {
 
 	CREATE TABLE IF NOT EXISTS table3 (
 		id SERIAL PRIMARY KEY,
-		info VARCHAR(255)
+		info VARCHAR(255),
+		parent_id INT REFERENCES table3(id)
 	);`
 
 	return db.Exec(schema).Error
@@ -234,14 +245,14 @@ func TestDatabaseManager_postgres_GetTableMetadata(t *testing.T) {
 	defer (*postgresContainer).Terminate(context.Background())
 
 	dbm := DatabaseManager{}
-	db, err := dbm.Connect(postgresDB, host, port, database, user, password)
+	db, err := dbm.Connect(context.Background(), postgresDB, host, port, database, user, password)
 	assert.NoError(t, err)
 	assert.NotNil(t, db)
 
 	err = createTestPostgresSchema(db)
 	assert.NoError(t, err)
 
-	metaData, err := dbm.GetTableMetadata()
+	metaData, err := dbm.GetTableMetadata(context.Background())
 	assert.NoError(t, err)
 	metaDataJSON, err := json.Marshal(metaData)
 	assert.NoError(t, err)
@@ -257,14 +268,14 @@ func TestDatabaseManager_MySQL_GetTableMetadata(t *testing.T) {
 	defer (*mysqlContainer).Terminate(context.Background())
 
 	dbm := DatabaseManager{}
-	db, err := dbm.Connect(mysqldb, host, port, database, user, password)
+	db, err := dbm.Connect(context.Background(), mysqldb, host, port, database, user, password)
 	assert.NoError(t, err)
 	assert.NotNil(t, db)
 
 	err = createTestMySQLSchema(db)
 	assert.NoError(t, err)
 
-	actualData, err := dbm.GetTableMetadata()
+	actualData, err := dbm.GetTableMetadata(context.Background())
 	assert.NoError(t, err)
 
 	var expectedData []*dbstructs.TableMetadata
@@ -285,7 +296,7 @@ func TestDatabaseManager_SQLServer_GetTableMetadata(t *testing.T) {
 	defer (*sqlserverContainer).Terminate(context.Background())
 
 	dbm := DatabaseManager{}
-	db, err := dbm.Connect(sqlserverdb, host, port, database, user, password)
+	db, err := dbm.Connect(context.Background(), sqlserverdb, host, port, database, user, password)
 	if err != nil {
 		t.Fatalf("Failed to connect: %s", err)
 	}
@@ -295,7 +306,7 @@ func TestDatabaseManager_SQLServer_GetTableMetadata(t *testing.T) {
 	err = createTestSQLServerSchema(db)
 	assert.NoError(t, err)
 
-	metaData, err := dbm.GetTableMetadata()
+	metaData, err := dbm.GetTableMetadata(context.Background())
 	assert.NoError(t, err)
 
 	metaDataJSON, err := json.Marshal(metaData)
@@ -306,3 +317,293 @@ func TestDatabaseManager_SQLServer_GetTableMetadata(t *testing.T) {
 	assert.NoError(t, err)
 	defer SQLDB.Close()
 }
+
+func TestDatabaseManager_TransformToGraph_qualifiedNames(t *testing.T) {
+	// listed under both its tables, as PostgreSQL does
+	foreignKey := &dbstructs.RelationshipMetadata{
+		Conname:          "orders_customer_fkey",
+		SourceSchema:     "sales",
+		SourceTableName:  "orders",
+		RelatedSchema:    "crm",
+		RelatedTableName: "customer",
+		SourceColumns:    []string{"customer_id"},
+		TargetColumns:    []string{"id"},
+	}
+	dbm := &DatabaseManager{
+		Tables: []*dbstructs.TableMetadata{
+			{Schema: "sales", TableName: "customer"},
+			{Schema: "crm", TableName: "customer", Relationships: []*dbstructs.RelationshipMetadata{foreignKey}},
+			{Schema: "sales", TableName: "orders", Relationships: []*dbstructs.RelationshipMetadata{foreignKey}},
+		},
+	}
+	dbm.TransformToGraph()
+
+	assert.Len(t, dbm.Nodes, 3)
+	assert.Equal(t, "sales.customer", dbm.Nodes[0].Data.Name)
+	assert.Equal(t, "crm.customer", dbm.Nodes[1].Data.Name)
+	assert.Equal(t, "crm", dbm.Nodes[1].Data.Schema)
+	assert.Len(t, dbm.Edges, 1)
+	assert.Equal(t, "sales.orders", dbm.Edges[0].Data.Source)
+	assert.Equal(t, "crm.customer", dbm.Edges[0].Data.Target)
+}
+
+func TestDatabaseManager_TransformToGraph_views(t *testing.T) {
+	dbm := &DatabaseManager{
+		Tables: []*dbstructs.TableMetadata{{TableName: "table1"}},
+		Views: []*dbstructs.ViewMetadata{
+			{ViewName: "view1", Dependencies: []string{"table1", "other_schema.table9"}},
+			{ViewName: "view2", Materialized: true, Dependencies: []string{"view1"}},
+		},
+	}
+	dbm.TransformToGraph()
+
+	assert.Len(t, dbm.Nodes, 3)
+	assert.Equal(t, dbstructs.NodeKindTable, dbm.Nodes[0].Data.Kind)
+	assert.Equal(t, dbstructs.NodeKindView, dbm.Nodes[1].Data.Kind)
+	assert.Equal(t, dbstructs.NodeKindMaterializedView, dbm.Nodes[2].Data.Kind)
+	assert.Equal(t, "2", dbm.Nodes[2].Data.ID)
+
+	// The dependency on a table that was not loaded is dropped
+	assert.Len(t, dbm.Edges, 2)
+	assert.Equal(t, "view1", dbm.Edges[0].Data.Source)
+	assert.Equal(t, "table1", dbm.Edges[0].Data.Target)
+	assert.Equal(t, dbstructs.EdgeKindViewDependency, dbm.Edges[0].Data.Kind)
+	assert.Equal(t, "view2", dbm.Edges[1].Data.Source)
+	assert.Equal(t, "view1", dbm.Edges[1].Data.Target)
+}
+
+func TestDatabaseManager_TransformToGraph_triggers(t *testing.T) {
+	dbm := &DatabaseManager{
+		Tables: []*dbstructs.TableMetadata{{TableName: "orders"}, {TableName: "orders_audit"}},
+		Triggers: []*dbstructs.TriggerMetadata{
+			{TriggerName: "orders_log", TableName: "orders", References: []string{"orders", "orders_audit", "archive.orders"}},
+			{TriggerName: "ignored", TableName: "not_loaded"},
+		},
+	}
+	dbm.TransformToGraph()
+
+	assert.Len(t, dbm.Nodes, 3)
+	assert.Equal(t, "orders_log ON orders", dbm.Nodes[2].Data.Name)
+	assert.Equal(t, dbstructs.NodeKindTrigger, dbm.Nodes[2].Data.Kind)
+	assert.NotNil(t, dbm.Nodes[2].Data.Columns)
+
+	// The table the trigger fires on is not linked twice, unknown tables are dropped
+	assert.Len(t, dbm.Edges, 2)
+	assert.Equal(t, "orders_log ON orders", dbm.Edges[0].Data.Source)
+	assert.Equal(t, "orders", dbm.Edges[0].Data.Target)
+	assert.Equal(t, dbstructs.EdgeKindTriggerTable, dbm.Edges[0].Data.Kind)
+	assert.Equal(t, "orders_audit", dbm.Edges[1].Data.Target)
+	assert.Equal(t, dbstructs.EdgeKindTriggerReference, dbm.Edges[1].Data.Kind)
+}
+
+func TestDatabaseManager_TransformToGraph_partitions(t *testing.T) {
+	dbm := &DatabaseManager{
+		Tables: []*dbstructs.TableMetadata{
+			{TableName: "customer"},
+			{TableName: "orders", Partitioning: &dbstructs.Partitioning{Strategy: "list", Key: "region"}},
+			{TableName: "orders_eu", PartitionOf: "orders", Partitioning: &dbstructs.Partitioning{Strategy: "range", Key: "created_at"}},
+			{TableName: "orders_eu_2024", PartitionOf: "orders_eu", Relationships: []*dbstructs.RelationshipMetadata{{
+				Conname: "orders_customer_fkey", SourceTableName: "orders_eu_2024", RelatedTableName: "customer",
+			}}},
+			{TableName: "orders_us", PartitionOf: "orders", Relationships: []*dbstructs.RelationshipMetadata{{
+				Conname: "orders_customer_fkey", SourceTableName: "orders_us", RelatedTableName: "customer",
+			}}},
+			{TableName: "orders_archive", Inherits: []string{"orders"}},
+		},
+	}
+	dbm.TransformToGraph()
+
+	assert.Len(t, dbm.Nodes, 6)
+	assert.Len(t, dbm.Edges, 6)
+	assert.Equal(t, "orders_eu", dbm.Edges[0].Data.Source)
+	assert.Equal(t, "orders", dbm.Edges[0].Data.Target)
+	assert.Equal(t, dbstructs.EdgeKindPartitionOf, dbm.Edges[0].Data.Kind)
+	assert.Equal(t, dbstructs.EdgeKindInherits, dbm.Edges[5].Data.Kind)
+
+	// Partitions of partitions are folded into the root, their shared foreign key is kept once
+	dbm.CollapsePartitions = true
+	dbm.TransformToGraph()
+
+	assert.Len(t, dbm.Nodes, 3)
+	assert.Equal(t, "orders", dbm.Nodes[1].Data.Name)
+	assert.Equal(t, []string{"orders_eu", "orders_eu_2024", "orders_us"}, dbm.Nodes[1].Data.Partitions)
+	assert.Equal(t, "2", dbm.Nodes[2].Data.ID)
+	assert.Len(t, dbm.Edges, 2)
+	assert.Equal(t, "orders", dbm.Edges[0].Data.Source)
+	assert.Equal(t, "customer", dbm.Edges[0].Data.Target)
+	assert.Equal(t, "orders_archive", dbm.Edges[1].Data.Source)
+	assert.Equal(t, "orders", dbm.Edges[1].Data.Target)
+}
+
+// batchConnectorMock lists tableCount tables and loads them batch by batch,
+// failing on the batch holding failOn when set.
+type batchConnectorMock struct {
+	tableCount int
+	failOn     string
+}
+
+func (m *batchConnectorMock) Connect(context.Context, string, string, string, string, string) (*gorm.DB, error) {
+	return nil, nil
+}
+
+func (m *batchConnectorMock) GetTableMetadata(context.Context, *gorm.DB) ([]*dbstructs.TableMetadata, error) {
+	return nil, nil
+}
+
+func (m *batchConnectorMock) GetTables(context.Context, *gorm.DB) ([]*dbstructs.TableMetadata, error) {
+	var tables []*dbstructs.TableMetadata
+	for i := 0; i < m.tableCount; i++ {
+		tables = append(tables, &dbstructs.TableMetadata{TableName: fmt.Sprintf("table%d", i)})
+	}
+	return tables, nil
+}
+
+func (m *batchConnectorMock) LoadTableMetadata(ctx context.Context, db *gorm.DB, tables []*dbstructs.TableMetadata) error {
+	for _, table := range tables {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if table.TableName == m.failOn {
+			return errors.New("cannot load " + table.TableName)
+		}
+		table.PrimaryKey = []string{"id"}
+	}
+	return nil
+}
+
+func TestDatabaseManager_GetTableMetadata_batches(t *testing.T) {
+	dbm := &DatabaseManager{DB: new(gorm.DB)}
+	dbm.SetConnector(&batchConnectorMock{tableCount: 120})
+	var progress [][2]int
+	dbm.OnProgress = func(done, total int) { progress = append(progress, [2]int{done, total}) }
+
+	tables, err := dbm.GetTableMetadata(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, tables, 120)
+	for i, table := range tables {
+		assert.Equal(t, fmt.Sprintf("table%d", i), table.TableName)
+		assert.Equal(t, []string{"id"}, table.PrimaryKey)
+	}
+	assert.Equal(t, [2]int{0, 120}, progress[0])
+	assert.Equal(t, [2]int{120, 120}, progress[len(progress)-1])
+	assert.Len(t, progress, 4) // listing, then one call per batch of 50
+
+	// The first error is returned
+	dbm.SetConnector(&batchConnectorMock{tableCount: 120, failOn: "table60"})
+	_, err = dbm.GetTableMetadata(context.Background())
+	assert.EqualError(t, err, "cannot load table60")
+
+	// Cancelling stops the loading
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	dbm.SetConnector(&batchConnectorMock{tableCount: 120})
+	_, err = dbm.GetTableMetadata(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestDatabaseManager_Connect_unknownDatabase(t *testing.T) {
+	dbm := &DatabaseManager{}
+	_, err := dbm.Connect(context.Background(), "oracle", "localhost", "1521", "db", "user", "password")
+	assert.EqualError(t, err, `unsupported database "oracle"`)
+	assert.Nil(t, dbm.DB)
+}
+
+func TestDatabaseManager_Connect_invalidOptions(t *testing.T) {
+	dbm := &DatabaseManager{Options: registry.Options{TLSMode: "always"}}
+	_, err := dbm.Connect(context.Background(), "postgres", "localhost", "5432", "db", "user", "password")
+	assert.EqualError(t, err, `unknown TLS mode "always"`)
+}
+
+// sshOptions reach the databases through bastion.
+func sshOptions(t *testing.T, bastion *sshtest.Server) registry.Options {
+	return registry.Options{ConnectTimeout: 5, SSH: sshtunnel.Config{
+		Hops:           []sshtunnel.Hop{{Host: bastion.Host, Port: bastion.Port, User: "admin", Password: "secret"}},
+		KnownHostsFile: sshtest.KnownHosts(t, bastion),
+	}}
+}
+
+func TestDatabaseManager_Connect_ssh(t *testing.T) {
+	bastion := sshtest.NewServer(t, sshtest.Credentials{User: "admin", Password: "secret"})
+	certs := tlsfixture.NewCertificates(t, "127.0.0.1")
+	server := tlsfixture.NewServer(t, certs, tlsfixture.PostgresPreamble)
+
+	dbm := &DatabaseManager{Options: sshOptions(t, bastion)}
+	dbm.Options.TLSMode = registry.TLSVerifyFull
+	dbm.Options.CAFile = certs.CAFile
+	// the fixture closes the connection after the handshake
+	_, err := dbm.Connect(context.Background(), "postgres", server.Host, server.Port, "testdb", "user", "password")
+	assert.Error(t, err)
+	handshake, ok := server.Handshake()
+	assert.True(t, ok, "the client should start TLS through the tunnel")
+	assert.NoError(t, handshake.Err)
+	assert.Equal(t, []string{net.JoinHostPort(server.Host, server.Port)}, bastion.Dialed())
+	assert.Nil(t, dbm.tunnel, "a failed connection closes the tunnel")
+}
+
+func TestDatabaseManager_Connect_sshDrivers(t *testing.T) {
+	for _, dbType := range []string{"mysql", "sqlserver"} {
+		t.Run(dbType, func(t *testing.T) {
+			bastion := sshtest.NewServer(t, sshtest.Credentials{User: "admin", Password: "secret"})
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer listener.Close()
+			go func() {
+				for {
+					conn, err := listener.Accept()
+					if err != nil {
+						return
+					}
+					conn.Close()
+				}
+			}()
+			host, port, _ := net.SplitHostPort(listener.Addr().String())
+
+			dbm := &DatabaseManager{Options: sshOptions(t, bastion)}
+			_, err = dbm.Connect(context.Background(), dbType, host, port, "testdb", "user", "password")
+			assert.Error(t, err)
+			assert.Contains(t, bastion.Dialed(), listener.Addr().String())
+		})
+	}
+}
+
+func TestDatabaseManager_Connect_sshUnsupported(t *testing.T) {
+	bastion := sshtest.NewServer(t, sshtest.Credentials{User: "admin", Password: "secret"})
+	dbm := &DatabaseManager{Options: sshOptions(t, bastion)}
+	_, err := dbm.Connect(context.Background(), "sqlite", "", "", ":memory:", "", "")
+	assert.EqualError(t, err, "sqlite can't be reached through SSH")
+	assert.Empty(t, bastion.Dialed())
+}
+
+func TestDatabaseManager_Snapshot(t *testing.T) {
+	dbm := &DatabaseManager{
+		DBType:        "postgres",
+		ServerVersion: "16.2",
+		CapturedAt:    time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC),
+		Schemas:       []string{"public"},
+		Tables: []*dbstructs.TableMetadata{
+			{Schema: "public", TableName: "customers", PrimaryKey: []string{"id"}},
+			{Schema: "public", TableName: "orders", Relationships: []*dbstructs.RelationshipMetadata{{
+				Conname: "orders_customer_fk", SourceSchema: "public", SourceTableName: "orders",
+				RelatedSchema: "public", RelatedTableName: "customers",
+				SourceColumns: []string{"customer_id"}, TargetColumns: []string{"id"},
+			}}},
+		},
+		Views: []*dbstructs.ViewMetadata{{Schema: "public", ViewName: "big_orders", Dependencies: []string{"public.orders"}}},
+	}
+	dbm.TransformToGraph()
+
+	offline := NewOfflineManager(dbm.Snapshot())
+	assert.Nil(t, offline.DB)
+	assert.Equal(t, "postgres", offline.DBType)
+	assert.Equal(t, "16.2", offline.ServerVersion)
+	assert.Equal(t, dbm.Tables, offline.GetTablesList())
+	assert.Equal(t, dbm.Views, offline.GetViewsList())
+	assert.Equal(t, dbm.Nodes, offline.Nodes)
+	assert.Equal(t, dbm.Edges, offline.Edges)
+
+	// reloading needs a connection
+	_, err := offline.GetTableMetadata(context.Background())
+	assert.EqualError(t, err, "DB not connected")
+}
@@ -0,0 +1,277 @@
+// Package ddl reads the metadata of a database from a SQL script, such as the
+// output of pg_dump --schema-only or mysqldump --no-data, so that a schema can be
+// looked at without the database. CREATE TABLE, CREATE INDEX, ALTER TABLE,
+// CREATE VIEW, CREATE TYPE ... AS ENUM and CREATE SEQUENCE are read, other
+// statements are skipped. The metadata is spelled the way the connector of the
+// dialect reports it: data types, default constraint and index names, implied
+// NOT NULL and indexes.
+package ddl
+
+import (
+	"db_meta/databases/sqlutil"
+	"db_meta/dbstructs"
+	"db_meta/snapshot"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Dialects, named after the connectors of the registry
+const (
+	PostgreSQL = "postgres"
+	MySQL      = "mysql"
+	SQLite     = "sqlite"
+	SQLServer  = "sqlserver"
+)
+
+// dialect holds what the lexer and the parser need to know about a dialect.
+type dialect struct {
+	name          string
+	defaultSchema string
+
+	foldLower          bool // unquoted identifiers are lowercased (PostgreSQL)
+	hashComments       bool // # starts a comment (MySQL)
+	backslashEscapes   bool // \' escapes a quote in strings (MySQL)
+	doubleQuoteStrings bool // "..." is a string, not an identifier (MySQL)
+	backtickQuotes     bool
+	bracketQuotes      bool
+	dollarQuotes       bool // $$...$$ strings (PostgreSQL)
+
+	indexMethod        string // method of the indexes without USING
+	primaryKeyIndex    bool   // the primary key is listed among the indexes
+	primaryKeyNotNull  bool   // primary key columns are NOT NULL
+	foreignKeyIndex    bool   // foreign keys get an index when none covers them (MySQL)
+	match              string // MATCH option of the foreign keys without one
+	defaultReferential string // ON DELETE and ON UPDATE without action
+}
+
+var dialects = map[string]*dialect{
+	PostgreSQL: {
+		name: PostgreSQL, defaultSchema: "public",
+		foldLower: true, dollarQuotes: true,
+		indexMethod: "btree", primaryKeyIndex: true, primaryKeyNotNull: true,
+		match: "SIMPLE", defaultReferential: dbstructs.ReferentialActionNoAction,
+	},
+	MySQL: {
+		name:         MySQL,
+		hashComments: true, backslashEscapes: true, doubleQuoteStrings: true, backtickQuotes: true,
+		indexMethod: "btree", primaryKeyIndex: true, primaryKeyNotNull: true, foreignKeyIndex: true,
+		match: "NONE", defaultReferential: dbstructs.ReferentialActionNoAction,
+	},
+	SQLite: {
+		name:           SQLite,
+		backtickQuotes: true, bracketQuotes: true,
+		indexMethod: "btree", primaryKeyIndex: true,
+		match: "NONE", defaultReferential: dbstructs.ReferentialActionNoAction,
+	},
+	SQLServer: {
+		name: SQLServer, defaultSchema: "dbo",
+		bracketQuotes: true,
+		indexMethod:   "nonclustered", primaryKeyNotNull: true,
+		defaultReferential: dbstructs.ReferentialActionNoAction,
+	},
+}
+
+// Dialects returns the names of the dialects Parse reads.
+func Dialects() []string {
+	return []string{PostgreSQL, MySQL, SQLite, SQLServer}
+}
+
+// Parse reads the metadata declared by the script sql, written in dialect. The
+// snapshot has no server version nor capture time.
+func Parse(dialectName, sql string) (*snapshot.Snapshot, error) {
+	d, ok := dialects[dialectName]
+	if !ok {
+		return nil, fmt.Errorf("unknown SQL dialect %q, expected one of %s", dialectName, strings.Join(Dialects(), ", "))
+	}
+	tokens, err := tokenize(d, sql)
+	if err != nil {
+		return nil, err
+	}
+	p := newParser(d, sql, tokens)
+	if err := p.parse(); err != nil {
+		return nil, err
+	}
+	p.finish()
+
+	s := &snapshot.Snapshot{
+		DBType:    d.name,
+		Tables:    p.tables,
+		Views:     p.views,
+		Types:     p.types,
+		Sequences: p.sequences,
+	}
+	if s.Tables == nil {
+		s.Tables = []*dbstructs.TableMetadata{}
+	}
+	for _, table := range s.Tables {
+		if table.Schema != "" && !contains(s.Schemas, table.Schema) {
+			s.Schemas = append(s.Schemas, table.Schema)
+		}
+	}
+	return s, nil
+}
+
+// finish resolves what the order of the statements left open: the target
+// columns of the foreign keys to a primary key, and the implied names, flags
+// and indexes.
+func (p *parser) finish() {
+	for _, table := range p.tables {
+		for _, relationship := range table.Relationships {
+			if relationship.TargetColumns == nil {
+				if target := p.table(relationship.RelatedSchema, relationship.RelatedTableName); target != nil {
+					relationship.TargetColumns = append([]string{}, target.PrimaryKey...)
+				}
+			}
+		}
+		if p.dialect.foreignKeyIndex {
+			p.indexForeignKeys(table)
+		}
+		if p.dialect.primaryKeyNotNull {
+			for _, name := range table.PrimaryKey {
+				if column := findColumn(table, name); column != nil {
+					column.NotNull = true
+				}
+			}
+		}
+		for _, index := range table.Indexes {
+			if !index.Unique || index.Primary || len(index.Keys) != 1 {
+				continue
+			}
+			if column := findColumn(table, index.Keys[0].Column); column != nil {
+				column.Unique = true
+			}
+		}
+		for i, column := range table.Columns {
+			column.OrdinalPosition = i + 1
+			// pg_dump sets the default of serial columns apart
+			if p.dialect.name == PostgreSQL && column.Default != nil && strings.HasPrefix(*column.Default, "nextval(") {
+				column.AutoIncrement = true
+			}
+		}
+		for _, check := range table.CheckConstraints {
+			if check.Columns == nil {
+				check.Columns = sqlutil.ReferencedNames(check.Expression, table.ColumnNames())
+			}
+		}
+		p.nameChecks(table)
+	}
+	for _, view := range p.views {
+		view.Dependencies = p.dependencies(view.Definition)
+	}
+}
+
+// indexForeignKeys adds the index MySQL creates for a foreign key that no index
+// starts with, named after the constraint.
+func (p *parser) indexForeignKeys(table *dbstructs.TableMetadata) {
+	for _, relationship := range table.Relationships {
+		covered := false
+		for _, index := range table.Indexes {
+			if startsWith(index.Columns, relationship.SourceColumns) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			table.Indexes = append(table.Indexes, newIndex(relationship.Conname, relationship.SourceColumns, false, p.dialect.indexMethod))
+		}
+	}
+}
+
+// nameChecks names the CHECK constraints declared without a name the way the
+// dialect does.
+func (p *parser) nameChecks(table *dbstructs.TableMetadata) {
+	used := make(map[string]bool)
+	for _, check := range table.CheckConstraints {
+		used[strings.ToLower(check.Name)] = check.Name != ""
+	}
+	for i, check := range table.CheckConstraints {
+		if check.Name != "" {
+			continue
+		}
+		var base string
+		switch p.dialect.name {
+		case MySQL:
+			base = table.TableName + "_chk_" + strconv.Itoa(i+1)
+		case SQLServer:
+			base = "CK_" + table.TableName
+			if len(check.Columns) > 0 {
+				base += "_" + check.Columns[0]
+			}
+		default:
+			base = table.TableName + "_check"
+			if len(check.Columns) > 0 {
+				base = table.TableName + "_" + check.Columns[0] + "_check"
+			}
+		}
+		check.Name = base
+		for n := 1; used[strings.ToLower(check.Name)]; n++ {
+			check.Name = base + strconv.Itoa(n)
+		}
+		used[strings.ToLower(check.Name)] = true
+	}
+}
+
+// dependencies returns the qualified names of the tables and views a view
+// definition reads.
+func (p *parser) dependencies(definition string) []string {
+	var candidates []string
+	for _, table := range p.tables {
+		candidates = append(candidates, table.QualifiedName())
+	}
+	for _, view := range p.views {
+		candidates = append(candidates, view.QualifiedName())
+	}
+	dependencies := sqlutil.ReferencedNames(definition, candidates)
+	if dependencies == nil {
+		dependencies = []string{}
+	}
+	return dependencies
+}
+
+func identifierText(d *dialect, t token) string {
+	if t.kind == tokenWord && d.foldLower {
+		return strings.ToLower(t.text)
+	}
+	return t.text
+}
+
+func findColumn(table *dbstructs.TableMetadata, name string) *dbstructs.Column {
+	for _, column := range table.Columns {
+		if strings.EqualFold(column.ColumnName, name) {
+			return column
+		}
+	}
+	return nil
+}
+
+func newIndex(name string, columns []string, unique bool, method string) *dbstructs.Index {
+	index := &dbstructs.Index{Name: name, Columns: []string{}, Unique: unique, Method: method}
+	for _, column := range columns {
+		index.Keys = append(index.Keys, &dbstructs.IndexKey{Column: column})
+		index.Columns = append(index.Columns, column)
+	}
+	return index
+}
+
+// startsWith tells whether columns begins with prefix, ignoring case.
+func startsWith(columns, prefix []string) bool {
+	if len(prefix) > len(columns) {
+		return false
+	}
+	for i := range prefix {
+		if !strings.EqualFold(columns[i], prefix[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
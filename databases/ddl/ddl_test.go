@@ -0,0 +1,535 @@
+package ddl
+
+import (
+	"db_meta/dbstructs"
+	"db_meta/snapshot"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func parse(t *testing.T, dialect, sql string) *snapshot.Snapshot {
+	s, err := Parse(dialect, sql)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func findTable(s *snapshot.Snapshot, name string) *dbstructs.TableMetadata {
+	for _, table := range s.Tables {
+		if table.QualifiedName() == name {
+			return table
+		}
+	}
+	return nil
+}
+
+func findIndex(table *dbstructs.TableMetadata, name string) *dbstructs.Index {
+	for _, index := range table.Indexes {
+		if index.Name == name {
+			return index
+		}
+	}
+	return nil
+}
+
+const pgDump = `
+--
+-- PostgreSQL database dump
+--
+SET statement_timeout = 0;
+SELECT pg_catalog.set_config('search_path', '', false);
+
+CREATE TYPE public.order_status AS ENUM (
+    'pending',
+    'shipped'
+);
+
+CREATE FUNCTION public.touch() RETURNS trigger
+    LANGUAGE plpgsql
+    AS $$
+BEGIN
+  NEW.updated_at := now(); -- CREATE TABLE in a body
+  RETURN NEW;
+END;
+$$;
+
+CREATE TABLE public.customers (
+    id integer NOT NULL,
+    email character varying(255) NOT NULL,
+    "Display Name" text,
+    balance numeric(10,2) DEFAULT 0 NOT NULL,
+    tags text[],
+    CONSTRAINT customers_balance_check CHECK ((balance >= (0)::numeric))
+);
+
+ALTER TABLE public.customers OWNER TO postgres;
+
+CREATE SEQUENCE public.customers_id_seq
+    AS integer
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1;
+
+ALTER SEQUENCE public.customers_id_seq OWNED BY public.customers.id;
+
+CREATE TABLE public.orders (
+    id bigint NOT NULL,
+    customer_id integer,
+    status public.order_status DEFAULT 'pending'::public.order_status NOT NULL,
+    created_at timestamp with time zone DEFAULT now()
+)
+PARTITION BY RANGE (created_at);
+
+CREATE TABLE public.orders_2024 (
+    id bigint NOT NULL,
+    customer_id integer,
+    status public.order_status DEFAULT 'pending'::public.order_status NOT NULL,
+    created_at timestamp with time zone DEFAULT now()
+);
+
+ALTER TABLE ONLY public.orders ATTACH PARTITION public.orders_2024 FOR VALUES FROM ('2024-01-01 00:00:00+00') TO ('2025-01-01 00:00:00+00');
+
+ALTER TABLE public.orders ALTER COLUMN id ADD GENERATED ALWAYS AS IDENTITY (
+    SEQUENCE NAME public.orders_id_seq
+    START WITH 1
+    INCREMENT BY 1
+    NO MINVALUE
+    NO MAXVALUE
+    CACHE 1
+);
+
+CREATE VIEW public.customer_orders AS
+ SELECT c.email,
+    count(o.id) AS order_count
+   FROM (public.customers c
+     LEFT JOIN public.orders o ON ((o.customer_id = c.id)))
+  GROUP BY c.email;
+
+ALTER TABLE ONLY public.customers ALTER COLUMN id SET DEFAULT nextval('public.customers_id_seq'::regclass);
+
+ALTER TABLE ONLY public.customers
+    ADD CONSTRAINT customers_pkey PRIMARY KEY (id);
+
+ALTER TABLE ONLY public.orders
+    ADD CONSTRAINT orders_pkey PRIMARY KEY (id, created_at);
+
+CREATE UNIQUE INDEX customers_email_idx ON public.customers USING btree (lower((email)::text));
+
+CREATE INDEX orders_recent_idx ON ONLY public.orders USING btree (created_at DESC) INCLUDE (status) WHERE (status = 'pending'::public.order_status);
+
+CREATE TRIGGER customers_touch BEFORE UPDATE ON public.customers FOR EACH ROW EXECUTE FUNCTION public.touch();
+
+ALTER TABLE ONLY public.orders
+    ADD CONSTRAINT orders_customer_id_fkey FOREIGN KEY (customer_id) REFERENCES public.customers(id) ON DELETE SET NULL DEFERRABLE;
+`
+
+func TestParse_postgres(t *testing.T) {
+	s := parse(t, PostgreSQL, pgDump)
+	assert.Equal(t, PostgreSQL, s.DBType)
+	assert.Equal(t, []string{"public"}, s.Schemas)
+	assert.Len(t, s.Tables, 3)
+
+	customers := findTable(s, "public.customers")
+	if assert.NotNil(t, customers) {
+		assert.Equal(t, []string{"id", "email", "Display Name", "balance", "tags"}, customers.ColumnNames())
+		id := customers.Columns[0]
+		assert.Equal(t, "integer", id.DataType)
+		assert.True(t, id.NotNull)
+		assert.True(t, id.AutoIncrement)
+		assert.Equal(t, "nextval('public.customers_id_seq'::regclass)", *id.Default)
+		assert.Equal(t, 32, *id.NumericPrecision)
+
+		email := customers.Columns[1]
+		assert.Equal(t, "character varying", email.DataType)
+		assert.Equal(t, 255, *email.CharacterLength)
+		assert.Equal(t, "numeric", customers.Columns[3].DataType)
+		assert.Equal(t, 2, *customers.Columns[3].NumericScale)
+		assert.Equal(t, "0", *customers.Columns[3].Default)
+		assert.Equal(t, "ARRAY", customers.Columns[4].DataType)
+		assert.Equal(t, 5, customers.Columns[4].OrdinalPosition)
+
+		assert.Equal(t, []string{"id"}, customers.PrimaryKey)
+		assert.True(t, findIndex(customers, "customers_pkey").Primary)
+		emailIndex := findIndex(customers, "customers_email_idx")
+		if assert.NotNil(t, emailIndex) {
+			assert.True(t, emailIndex.Unique)
+			assert.Equal(t, "lower((email)::text)", emailIndex.Keys[0].Expression)
+		}
+		if assert.Len(t, customers.CheckConstraints, 1) {
+			assert.Equal(t, "(balance >= (0)::numeric)", customers.CheckConstraints[0].Expression)
+			assert.Equal(t, []string{"balance"}, customers.CheckConstraints[0].Columns)
+		}
+	}
+
+	orders := findTable(s, "public.orders")
+	if assert.NotNil(t, orders) {
+		status := orders.Columns[2]
+		assert.Equal(t, "USER-DEFINED", status.DataType)
+		assert.Equal(t, "public.order_status", status.UserType)
+		assert.Equal(t, "timestamp with time zone", orders.Columns[3].DataType)
+		assert.True(t, orders.Columns[0].AutoIncrement)
+
+		assert.Equal(t, "range", orders.Partitioning.Strategy)
+		assert.Equal(t, "created_at", orders.Partitioning.Key)
+		if assert.Len(t, orders.Partitioning.Partitions, 1) {
+			assert.Equal(t, "public.orders_2024", orders.Partitioning.Partitions[0].Name)
+		}
+
+		recent := findIndex(orders, "orders_recent_idx")
+		if assert.NotNil(t, recent) {
+			assert.Equal(t, []*dbstructs.IndexKey{{Column: "created_at", Descending: true}}, recent.Keys)
+			assert.Equal(t, []string{"status"}, recent.Include)
+			assert.Equal(t, "(status = 'pending'::public.order_status)", recent.Predicate)
+			assert.Equal(t, "btree", recent.Method)
+		}
+
+		if assert.Len(t, orders.Relationships, 1) {
+			fk := orders.Relationships[0]
+			assert.Equal(t, "orders_customer_id_fkey", fk.Conname)
+			assert.Equal(t, "public", fk.RelatedSchema)
+			assert.Equal(t, "customers", fk.RelatedTableName)
+			assert.Equal(t, []string{"customer_id"}, fk.SourceColumns)
+			assert.Equal(t, []string{"id"}, fk.TargetColumns)
+			assert.Equal(t, dbstructs.ReferentialActionSetNull, fk.OnDelete)
+			assert.Equal(t, dbstructs.ReferentialActionNoAction, fk.OnUpdate)
+			assert.Equal(t, "SIMPLE", fk.Match)
+			assert.True(t, fk.Deferrable)
+		}
+	}
+
+	partition := findTable(s, "public.orders_2024")
+	if assert.NotNil(t, partition) {
+		assert.Equal(t, "public.orders", partition.PartitionOf)
+		assert.Equal(t, "FOR VALUES FROM ('2024-01-01 00:00:00+00') TO ('2025-01-01 00:00:00+00')", partition.PartitionBound)
+	}
+
+	if assert.Len(t, s.Types, 1) {
+		assert.Equal(t, []string{"pending", "shipped"}, s.Types[0].Values)
+	}
+	if assert.Len(t, s.Sequences, 2) {
+		assert.Equal(t, "customers_id_seq", s.Sequences[0].SequenceName)
+		assert.Equal(t, "integer", s.Sequences[0].DataType)
+		assert.Equal(t, int64(2147483647), s.Sequences[0].MaxValue)
+		assert.Equal(t, "public.customers", s.Sequences[0].OwnerTable)
+		assert.Equal(t, "orders_id_seq", s.Sequences[1].SequenceName)
+		assert.Equal(t, "id", s.Sequences[1].OwnerColumn)
+	}
+	if assert.Len(t, s.Views, 1) {
+		view := s.Views[0]
+		assert.Equal(t, "customer_orders", view.ViewName)
+		assert.Equal(t, []string{"public.customers", "public.orders"}, view.Dependencies)
+		assert.Equal(t, []string{"email", "order_count"}, []string{view.Columns[0].ColumnName, view.Columns[1].ColumnName})
+	}
+}
+
+func TestParse_postgresInline(t *testing.T) {
+	s := parse(t, PostgreSQL, `
+CREATE SCHEMA sales;
+CREATE TABLE Sales.Accounts (
+    ID serial PRIMARY KEY,
+    parent_id int REFERENCES sales.accounts,
+    code varchar(10) UNIQUE CHECK (code <> ''),
+    total double precision GENERATED ALWAYS AS (1.5 * 2) STORED,
+    UNIQUE (parent_id, code)
+);
+CREATE TABLE sales.archived_accounts (archived_at timestamp) INHERITS (sales.accounts);`)
+	assert.Equal(t, []string{"sales"}, s.Schemas)
+	accounts := findTable(s, "sales.accounts")
+	if !assert.NotNil(t, accounts) {
+		return
+	}
+	id := accounts.Columns[0]
+	assert.Equal(t, "id", id.ColumnName)
+	assert.Equal(t, "integer", id.DataType)
+	assert.True(t, id.AutoIncrement)
+	assert.Equal(t, "nextval('sales.accounts_id_seq'::regclass)", *id.Default)
+	assert.Equal(t, "double precision", accounts.Columns[3].DataType)
+	assert.Equal(t, "1.5 * 2", accounts.Columns[3].Generated)
+
+	assert.NotNil(t, findIndex(accounts, "accounts_pkey"))
+	assert.NotNil(t, findIndex(accounts, "accounts_code_key"))
+	assert.NotNil(t, findIndex(accounts, "accounts_parent_id_code_key"))
+	assert.True(t, accounts.Columns[2].Unique)
+	assert.False(t, accounts.Columns[1].Unique)
+	if assert.Len(t, accounts.Relationships, 1) {
+		assert.Equal(t, "accounts_parent_id_fkey", accounts.Relationships[0].Conname)
+		assert.Equal(t, []string{"id"}, accounts.Relationships[0].TargetColumns)
+	}
+	if assert.Len(t, accounts.CheckConstraints, 1) {
+		assert.Equal(t, "accounts_code_check", accounts.CheckConstraints[0].Name)
+	}
+
+	archived := findTable(s, "sales.archived_accounts")
+	if assert.NotNil(t, archived) {
+		assert.Equal(t, []string{"sales.accounts"}, archived.Inherits)
+		assert.Equal(t, []string{"id", "parent_id", "code", "total", "archived_at"}, archived.ColumnNames())
+	}
+}
+
+const mysqlDump = `
+/*!40101 SET @OLD_CHARACTER_SET_CLIENT=@@CHARACTER_SET_CLIENT */;
+DROP TABLE IF EXISTS ` + "`customers`" + `;
+/*!40101 SET @saved_cs_client     = @@character_set_client */;
+CREATE TABLE ` + "`customers`" + ` (
+  ` + "`id`" + ` int unsigned NOT NULL AUTO_INCREMENT,
+  ` + "`email`" + ` varchar(255) COLLATE utf8mb4_bin NOT NULL,
+  ` + "`status`" + ` enum('active','it''s closed') NOT NULL DEFAULT 'active',
+  ` + "`balance`" + ` decimal(10,2) NOT NULL DEFAULT '0.00',
+  ` + "`updated_at`" + ` timestamp NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+  PRIMARY KEY (` + "`id`" + `),
+  UNIQUE KEY ` + "`email`" + ` (` + "`email`" + `),
+  FULLTEXT KEY ` + "`ft_email`" + ` (` + "`email`" + `),
+  CONSTRAINT ` + "`balance_positive`" + ` CHECK ((` + "`balance`" + ` >= 0))
+) ENGINE=InnoDB AUTO_INCREMENT=3 DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE ` + "`orders`" + ` (
+  ` + "`id`" + ` bigint NOT NULL,
+  ` + "`customer_id`" + ` int unsigned DEFAULT NULL,
+  ` + "`placed`" + ` date NOT NULL,
+  PRIMARY KEY (` + "`id`" + `,` + "`placed`" + `),
+  CONSTRAINT FOREIGN KEY (` + "`customer_id`" + `) REFERENCES ` + "`customers`" + ` (` + "`id`" + `) ON DELETE CASCADE,
+  CHECK (` + "`id`" + ` > 0)
+) ENGINE=InnoDB
+/*!50100 PARTITION BY RANGE (year(` + "`placed`" + `))
+(PARTITION p2023 VALUES LESS THAN (2024) ENGINE = InnoDB,
+ PARTITION pmax VALUES LESS THAN MAXVALUE ENGINE = InnoDB) */;
+
+ALTER TABLE ` + "`orders`" + ` MODIFY ` + "`id`" + ` bigint NOT NULL AUTO_INCREMENT;
+
+/*!50001 DROP VIEW IF EXISTS ` + "`active_customers`" + `*/;
+/*!50001 CREATE VIEW ` + "`active_customers`" + ` AS SELECT
+ 1 AS ` + "`id`" + `*/;
+/*!50001 DROP VIEW IF EXISTS ` + "`active_customers`" + `*/;
+/*!50001 CREATE ALGORITHM=UNDEFINED */
+/*!50013 DEFINER=` + "`root`@`localhost`" + ` SQL SECURITY DEFINER */
+/*!50001 VIEW ` + "`active_customers`" + ` AS select ` + "`customers`.`id` AS `id`" + ` from ` + "`customers`" + ` where (` + "`customers`.`status`" + ` = 'active') */;
+
+DELIMITER ;;
+CREATE TRIGGER ` + "`orders_check`" + ` BEFORE INSERT ON ` + "`orders`" + ` FOR EACH ROW BEGIN
+  SET NEW.placed = CURDATE();
+END ;;
+DELIMITER ;
+`
+
+func TestParse_mysql(t *testing.T) {
+	s := parse(t, MySQL, mysqlDump)
+	assert.Empty(t, s.Schemas)
+	customers := findTable(s, "customers")
+	if !assert.NotNil(t, customers) {
+		return
+	}
+	id := customers.Columns[0]
+	assert.Equal(t, "int", id.DataType)
+	assert.True(t, id.AutoIncrement)
+	assert.Equal(t, []string{"active", "it's closed"}, customers.Columns[2].EnumValues)
+	assert.Equal(t, "active", *customers.Columns[2].Default)
+	assert.Equal(t, "0.00", *customers.Columns[3].Default)
+	assert.Equal(t, "CURRENT_TIMESTAMP", *customers.Columns[4].Default)
+	assert.True(t, customers.Columns[1].Unique)
+
+	assert.True(t, findIndex(customers, "PRIMARY").Primary)
+	assert.True(t, findIndex(customers, "email").Unique)
+	assert.Equal(t, "fulltext", findIndex(customers, "ft_email").Method)
+	assert.Equal(t, "balance_positive", customers.CheckConstraints[0].Name)
+	assert.Equal(t, []string{"balance"}, customers.CheckConstraints[0].Columns)
+
+	orders := findTable(s, "orders")
+	if !assert.NotNil(t, orders) {
+		return
+	}
+	assert.True(t, orders.Columns[0].AutoIncrement)
+	assert.Equal(t, "id", orders.Columns[0].ColumnName)
+	assert.Nil(t, orders.Columns[1].Default)
+	if assert.Len(t, orders.Relationships, 1) {
+		assert.Equal(t, "orders_ibfk_1", orders.Relationships[0].Conname)
+		assert.Equal(t, "NONE", orders.Relationships[0].Match)
+		assert.Equal(t, dbstructs.ReferentialActionCascade, orders.Relationships[0].OnDelete)
+	}
+	// the index MySQL adds for the foreign key
+	assert.Equal(t, []string{"customer_id"}, findIndex(orders, "orders_ibfk_1").Columns)
+	assert.Equal(t, "orders_chk_1", orders.CheckConstraints[0].Name)
+	if assert.NotNil(t, orders.Partitioning) {
+		assert.Equal(t, "range", orders.Partitioning.Strategy)
+		assert.Equal(t, "year(`placed`)", orders.Partitioning.Key)
+		assert.Equal(t, []*dbstructs.Partition{
+			{Name: "p2023", Bound: "VALUES LESS THAN (2024)"},
+			{Name: "pmax", Bound: "VALUES LESS THAN MAXVALUE"},
+		}, orders.Partitioning.Partitions)
+	}
+
+	if assert.Len(t, s.Views, 1) {
+		assert.Equal(t, []string{"customers"}, s.Views[0].Dependencies)
+		assert.Equal(t, "id", s.Views[0].Columns[0].ColumnName)
+	}
+}
+
+func TestParse_sqlite(t *testing.T) {
+	s := parse(t, SQLite, `
+CREATE TABLE customers (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  email VARCHAR(255) NOT NULL UNIQUE,
+  [name] TEXT CHECK (length(name) > 0),
+  total REAL AS (1.0) STORED
+);
+CREATE TABLE sqlite_sequence(name,seq);
+CREATE TABLE "orders" (
+  customer_id INTEGER REFERENCES customers ON DELETE CASCADE,
+  code TEXT,
+  PRIMARY KEY (customer_id, code),
+  CHECK (code <> '')
+) WITHOUT ROWID;
+CREATE INDEX orders_code ON orders (code COLLATE NOCASE DESC) WHERE code IS NOT NULL;
+CREATE TRIGGER orders_touch AFTER INSERT ON orders BEGIN
+  UPDATE customers SET total = 0 WHERE id = NEW.customer_id;
+  CREATE TABLE not_a_table (id INTEGER);
+END;
+CREATE VIEW customer_emails AS SELECT email FROM customers;`)
+	assert.Len(t, s.Tables, 3)
+	customers := findTable(s, "customers")
+	if !assert.NotNil(t, customers) {
+		return
+	}
+	id := customers.Columns[0]
+	assert.Equal(t, "INTEGER", id.DataType)
+	assert.True(t, id.AutoIncrement)
+	assert.False(t, id.NotNull)
+	assert.Equal(t, "VARCHAR(255)", customers.Columns[1].DataType)
+	assert.Equal(t, 255, *customers.Columns[1].CharacterLength)
+	assert.Equal(t, "name", customers.Columns[2].ColumnName)
+	assert.Equal(t, "1.0", customers.Columns[3].Generated)
+	// the rowid has no index
+	assert.Len(t, customers.Indexes, 1)
+	assert.Equal(t, "sqlite_autoindex_customers_1", customers.Indexes[0].Name)
+	assert.Equal(t, "customers_name_check", customers.CheckConstraints[0].Name)
+
+	orders := findTable(s, "orders")
+	if !assert.NotNil(t, orders) {
+		return
+	}
+	assert.Equal(t, []string{"customer_id", "code"}, orders.PrimaryKey)
+	assert.True(t, findIndex(orders, "sqlite_autoindex_orders_1").Primary)
+	code := findIndex(orders, "orders_code")
+	if assert.NotNil(t, code) {
+		assert.Equal(t, []*dbstructs.IndexKey{{Column: "code", Descending: true}}, code.Keys)
+		assert.Equal(t, "code IS NOT NULL", code.Predicate)
+	}
+	if assert.Len(t, orders.Relationships, 1) {
+		fk := orders.Relationships[0]
+		assert.Equal(t, "customer_id", fk.Conname)
+		assert.Equal(t, []string{"id"}, fk.TargetColumns)
+		assert.Equal(t, dbstructs.ReferentialActionCascade, fk.OnDelete)
+	}
+	assert.Equal(t, "orders_code_check", orders.CheckConstraints[0].Name)
+	assert.Equal(t, []string{"customers"}, s.Views[0].Dependencies)
+}
+
+func TestParse_sqlserver(t *testing.T) {
+	s := parse(t, SQLServer, `
+USE [shop]
+GO
+SET ANSI_NULLS ON
+GO
+CREATE TABLE [dbo].[customers](
+	[id] [int] IDENTITY(1,1) NOT NULL,
+	[email] [nvarchar](255) NOT NULL,
+	[notes] [nvarchar](max) NULL,
+	[price] [decimal](10, 2) NULL,
+	[total] AS ([price]*(2)),
+ CONSTRAINT [PK_customers] PRIMARY KEY CLUSTERED
+(
+	[id] ASC
+)WITH (PAD_INDEX = OFF, STATISTICS_NORECOMPUTE = OFF) ON [PRIMARY],
+ CONSTRAINT [UQ_customers_email] UNIQUE NONCLUSTERED ([email] ASC)
+) ON [PRIMARY] TEXTIMAGE_ON [PRIMARY]
+GO
+CREATE TABLE [sales].[orders](
+	[id] [bigint] NOT NULL PRIMARY KEY,
+	[customer_id] [int] NULL,
+	[created_at] [datetime2](7) NOT NULL,
+	INDEX [IX_orders_created] NONCLUSTERED ([created_at] DESC)
+)
+GO
+ALTER TABLE [sales].[orders] ADD  DEFAULT (sysdatetime()) FOR [created_at]
+GO
+ALTER TABLE [sales].[orders]  WITH CHECK ADD  CONSTRAINT [FK_orders_customers] FOREIGN KEY([customer_id])
+REFERENCES [dbo].[customers] ([id])
+ON DELETE CASCADE
+GO
+ALTER TABLE [sales].[orders] CHECK CONSTRAINT [FK_orders_customers]
+GO
+ALTER TABLE [sales].[orders] ADD [note] varchar(20) NULL, CHECK ([id] > 0)
+GO
+CREATE NONCLUSTERED INDEX [IX_orders_customer] ON [sales].[orders]
+(
+	[customer_id] ASC
+)
+INCLUDE([created_at]) WHERE ([customer_id] IS NOT NULL) WITH (PAD_INDEX = OFF) ON [PRIMARY]
+GO
+CREATE VIEW [sales].[big_orders] AS
+SELECT o.id, c.email FROM sales.orders o JOIN dbo.customers c ON c.id = o.customer_id
+GO
+`)
+	assert.Equal(t, []string{"dbo", "sales"}, s.Schemas)
+	customers := findTable(s, "dbo.customers")
+	if !assert.NotNil(t, customers) {
+		return
+	}
+	assert.Equal(t, "int", customers.Columns[0].DataType)
+	assert.True(t, customers.Columns[0].AutoIncrement)
+	assert.Equal(t, "nvarchar", customers.Columns[1].DataType)
+	assert.Equal(t, 255, *customers.Columns[1].CharacterLength)
+	assert.Equal(t, -1, *customers.Columns[2].CharacterLength)
+	assert.Equal(t, 10, *customers.Columns[3].NumericPrecision)
+	assert.Equal(t, "[price]*(2)", customers.Columns[4].Generated)
+	assert.Equal(t, []string{"id"}, customers.PrimaryKey)
+	// SQL Server does not list the primary key among the indexes
+	if assert.Len(t, customers.Indexes, 1) {
+		assert.Equal(t, "UQ_customers_email", customers.Indexes[0].Name)
+		assert.Equal(t, "nonclustered", customers.Indexes[0].Method)
+	}
+
+	orders := findTable(s, "sales.orders")
+	if !assert.NotNil(t, orders) {
+		return
+	}
+	assert.Equal(t, []string{"id", "customer_id", "created_at", "note"}, orders.ColumnNames())
+	assert.Equal(t, "datetime2", orders.Columns[2].DataType)
+	assert.Equal(t, "(sysdatetime())", *orders.Columns[2].Default)
+	assert.True(t, findIndex(orders, "IX_orders_created").Keys[0].Descending)
+	include := findIndex(orders, "IX_orders_customer")
+	if assert.NotNil(t, include) {
+		assert.Equal(t, []string{"created_at"}, include.Include)
+		assert.Equal(t, "([customer_id] IS NOT NULL)", include.Predicate)
+	}
+	if assert.Len(t, orders.Relationships, 1) {
+		fk := orders.Relationships[0]
+		assert.Equal(t, "FK_orders_customers", fk.Conname)
+		assert.Equal(t, "dbo", fk.RelatedSchema)
+		assert.Equal(t, dbstructs.ReferentialActionCascade, fk.OnDelete)
+	}
+	if assert.Len(t, orders.CheckConstraints, 1) {
+		assert.Equal(t, "CK_orders_id", orders.CheckConstraints[0].Name)
+	}
+	if assert.Len(t, s.Views, 1) {
+		assert.Equal(t, []string{"dbo.customers", "sales.orders"}, s.Views[0].Dependencies)
+	}
+}
+
+func TestParse_errors(t *testing.T) {
+	_, err := Parse("oracle", "CREATE TABLE t (id int)")
+	assert.ErrorContains(t, err, "unknown SQL dialect")
+
+	_, err = Parse(PostgreSQL, "CREATE TABLE t (id int,\n  name text DEFAULT 'open)")
+	assert.ErrorContains(t, err, "line 2: unterminated string")
+
+	_, err = Parse(PostgreSQL, "CREATE TABLE t (\n  CONSTRAINT c id int)")
+	assert.ErrorContains(t, err, "line 2: expected a constraint")
+
+	s, err := Parse(MySQL, "-- nothing but comments\n# here")
+	assert.NoError(t, err)
+	assert.Empty(t, s.Tables)
+}
@@ -0,0 +1,301 @@
+package ddl
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+type tokenKind int
+
+const (
+	tokenWord   tokenKind = iota // keyword or unquoted identifier
+	tokenQuoted                  // quoted identifier, text unquoted
+	tokenString                  // string literal, text as written
+	tokenNumber
+	tokenSymbol // punctuation and operators
+	tokenEnd    // end of statement: ;, GO or the DELIMITER of MySQL
+)
+
+// token is a lexical token, start and end are its offsets in the script so that
+// expressions are kept as written.
+type token struct {
+	kind       tokenKind
+	text       string
+	start, end int
+	line       int
+}
+
+// is tells whether the token is the keyword word, ignoring case.
+func (t token) is(word string) bool {
+	return t.kind == tokenWord && strings.EqualFold(t.text, word)
+}
+
+func (t token) isSymbol(symbol string) bool {
+	return t.kind == tokenSymbol && t.text == symbol
+}
+
+// lexer splits a script into tokens, comments and whitespace are dropped.
+type lexer struct {
+	dialect *dialect
+	src     string
+	pos     int
+	line    int
+
+	delimiter        string // MySQL DELIMITER, ";" otherwise
+	executableDepth  int    // open MySQL /*! ... */ comments, their content is code
+	statementStarted bool   // a token was read since the last tokenEnd
+}
+
+// tokenize returns the tokens of src, the last one being a tokenEnd.
+func tokenize(d *dialect, src string) ([]token, error) {
+	l := &lexer{dialect: d, src: src, line: 1, delimiter: ";"}
+	var tokens []token
+	for {
+		tok, ok, err := l.next()
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		if tok.kind == tokenEnd {
+			l.statementStarted = false
+		} else {
+			l.statementStarted = true
+		}
+		tokens = append(tokens, tok)
+	}
+	return append(tokens, token{kind: tokenEnd, start: len(src), end: len(src), line: l.line}), nil
+}
+
+func (l *lexer) peekByte(offset int) byte {
+	if l.pos+offset < len(l.src) {
+		return l.src[l.pos+offset]
+	}
+	return 0
+}
+
+// advance moves n bytes ahead, counting lines.
+func (l *lexer) advance(n int) {
+	for i := 0; i < n && l.pos < len(l.src); i++ {
+		if l.src[l.pos] == '\n' {
+			l.line++
+		}
+		l.pos++
+	}
+}
+
+func (l *lexer) next() (token, bool, error) {
+	for l.pos < len(l.src) {
+		start, line := l.pos, l.line
+		c := l.src[l.pos]
+		switch {
+		case l.delimiter != ";" && strings.HasPrefix(l.src[l.pos:], l.delimiter):
+			l.advance(len(l.delimiter))
+			return token{kind: tokenEnd, text: l.delimiter, start: start, end: l.pos, line: line}, true, nil
+		case c == '\n' || c == ' ' || c == '\t' || c == '\r' || c == '\f':
+			l.advance(1)
+		case c == '-' && l.peekByte(1) == '-', c == '#' && l.dialect.hashComments:
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.pos++
+			}
+		case c == '/' && l.peekByte(1) == '*':
+			if l.dialect.name == MySQL && l.peekByte(2) == '!' {
+				// executable comment, /*!50001 CREATE VIEW ... */ runs on MySQL
+				l.advance(3)
+				for l.pos < len(l.src) && l.src[l.pos] >= '0' && l.src[l.pos] <= '9' {
+					l.pos++
+				}
+				l.executableDepth++
+				continue
+			}
+			end := strings.Index(l.src[l.pos+2:], "*/")
+			if end < 0 {
+				return token{}, false, fmt.Errorf("line %d: unterminated comment", line)
+			}
+			l.advance(end + 4)
+		case c == '*' && l.peekByte(1) == '/' && l.executableDepth > 0:
+			l.executableDepth--
+			l.advance(2)
+		case c == ';' && l.delimiter == ";":
+			l.advance(1)
+			return token{kind: tokenEnd, text: ";", start: start, end: l.pos, line: line}, true, nil
+		case c == '\'':
+			if err := l.skipString('\'', l.dialect.backslashEscapes); err != nil {
+				return token{}, false, err
+			}
+			return l.token(tokenString, start, line), true, nil
+		case c == '"' && l.dialect.doubleQuoteStrings:
+			if err := l.skipString('"', true); err != nil {
+				return token{}, false, err
+			}
+			return l.token(tokenString, start, line), true, nil
+		case c == '"' || c == '`' && l.dialect.backtickQuotes || c == '[' && l.dialect.bracketQuotes:
+			return l.quotedIdentifier(c)
+		case c == '$' && l.dialect.dollarQuotes && l.dollarTag() != "":
+			tag := l.dollarTag()
+			end := strings.Index(l.src[l.pos+len(tag):], tag)
+			if end < 0 {
+				return token{}, false, fmt.Errorf("line %d: unterminated %s string", line, tag)
+			}
+			l.advance(len(tag) + end + len(tag))
+			return l.token(tokenString, start, line), true, nil
+		case c >= '0' && c <= '9' || c == '.' && l.peekByte(1) >= '0' && l.peekByte(1) <= '9':
+			for l.pos < len(l.src) && (isDigit(l.src[l.pos]) || l.src[l.pos] == '.' ||
+				(l.src[l.pos] == 'e' || l.src[l.pos] == 'E') && l.pos+1 < len(l.src) && (isDigit(l.src[l.pos+1]) || l.src[l.pos+1] == '-' || l.src[l.pos+1] == '+')) {
+				if l.src[l.pos] == 'e' || l.src[l.pos] == 'E' {
+					l.pos++
+				}
+				l.pos++
+			}
+			return l.token(tokenNumber, start, line), true, nil
+		case l.isWordStart():
+			return l.word(start, line)
+		default:
+			for _, operator := range []string{"::", "<=", ">=", "<>", "!=", "||", "->>", "->"} {
+				if strings.HasPrefix(l.src[l.pos:], operator) {
+					l.advance(len(operator))
+					return l.token(tokenSymbol, start, line), true, nil
+				}
+			}
+			l.advance(1)
+			return l.token(tokenSymbol, start, line), true, nil
+		}
+	}
+	return token{}, false, nil
+}
+
+func (l *lexer) token(kind tokenKind, start, line int) token {
+	return token{kind: kind, text: l.src[start:l.pos], start: start, end: l.pos, line: line}
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func (l *lexer) isWordStart() bool {
+	r := []rune(l.src[l.pos:min(l.pos+4, len(l.src))])
+	if len(r) == 0 {
+		return false
+	}
+	return unicode.IsLetter(r[0]) || r[0] == '_' || l.dialect.name == SQLServer && (r[0] == '@' || r[0] == '#')
+}
+
+// word reads a keyword or an identifier, along with the prefixed strings
+// E'...', N'...', X'...' and B'...' and the MySQL DELIMITER and T-SQL GO lines.
+func (l *lexer) word(start, line int) (token, bool, error) {
+	for l.pos < len(l.src) {
+		r := []rune(l.src[l.pos:min(l.pos+4, len(l.src))])[0]
+		if !(unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '$' || r == '@' || r == '#') {
+			break
+		}
+		l.pos += len(string(r))
+	}
+	text := l.src[start:l.pos]
+
+	if l.pos < len(l.src) && l.src[l.pos] == '\'' && len(text) == 1 && strings.ContainsAny(text, "eEnNxXbB") {
+		if err := l.skipString('\'', text == "e" || text == "E" || l.dialect.backslashEscapes); err != nil {
+			return token{}, false, err
+		}
+		return l.token(tokenString, start, line), true, nil
+	}
+	if !l.statementStarted && l.dialect.name == MySQL && strings.EqualFold(text, "DELIMITER") {
+		end := strings.IndexByte(l.src[l.pos:], '\n')
+		if end < 0 {
+			end = len(l.src) - l.pos
+		}
+		delimiter := strings.TrimSpace(l.src[l.pos : l.pos+end])
+		if delimiter == "" {
+			return token{}, false, fmt.Errorf("line %d: DELIMITER without delimiter", line)
+		}
+		l.delimiter = delimiter
+		l.advance(end)
+		return l.next()
+	}
+	if l.dialect.name == SQLServer && strings.EqualFold(text, "GO") && l.aloneOnLine(start) {
+		// batch separator, optionally followed by a count
+		for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+			l.pos++
+		}
+		return token{kind: tokenEnd, text: text, start: start, end: l.pos, line: line}, true, nil
+	}
+	return l.token(tokenWord, start, line), true, nil
+}
+
+// aloneOnLine tells whether the word at start..pos is the only word of its line,
+// but for a repeat count.
+func (l *lexer) aloneOnLine(start int) bool {
+	lineStart := strings.LastIndexByte(l.src[:start], '\n') + 1
+	if strings.TrimSpace(l.src[lineStart:start]) != "" {
+		return false
+	}
+	lineEnd := strings.IndexByte(l.src[l.pos:], '\n')
+	if lineEnd < 0 {
+		lineEnd = len(l.src) - l.pos
+	}
+	return strings.Trim(l.src[l.pos:l.pos+lineEnd], " \t\r0123456789") == ""
+}
+
+// skipString moves past the string literal opened at pos, a doubled quote being
+// an escaped quote.
+func (l *lexer) skipString(quote byte, backslashEscapes bool) error {
+	line := l.line
+	for l.advance(1); l.pos < len(l.src); {
+		switch c := l.src[l.pos]; {
+		case c == '\\' && backslashEscapes:
+			l.advance(2)
+		case c == quote && l.peekByte(1) == quote:
+			l.advance(2)
+		case c == quote:
+			l.advance(1)
+			return nil
+		default:
+			l.advance(1)
+		}
+	}
+	return fmt.Errorf("line %d: unterminated string", line)
+}
+
+func (l *lexer) quotedIdentifier(open byte) (token, bool, error) {
+	start, line := l.pos, l.line
+	closing := open
+	if open == '[' {
+		closing = ']'
+	}
+	var text strings.Builder
+	for l.advance(1); l.pos < len(l.src); {
+		c := l.src[l.pos]
+		if c == closing {
+			if l.peekByte(1) == closing {
+				text.WriteByte(c)
+				l.advance(2)
+				continue
+			}
+			l.advance(1)
+			return token{kind: tokenQuoted, text: text.String(), start: start, end: l.pos, line: line}, true, nil
+		}
+		text.WriteByte(c)
+		l.advance(1)
+	}
+	return token{}, false, fmt.Errorf("line %d: unterminated quoted identifier", line)
+}
+
+// dollarTag returns the $tag$ opening a dollar-quoted string at pos, if any.
+func (l *lexer) dollarTag() string {
+	end := l.pos + 1
+	for end < len(l.src) && (l.src[end] == '_' || unicode.IsLetter(rune(l.src[end])) || end > l.pos+1 && isDigit(l.src[end])) {
+		end++
+	}
+	if end < len(l.src) && l.src[end] == '$' {
+		return l.src[l.pos : end+1]
+	}
+	return ""
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,1715 @@
+package ddl
+
+import (
+	"db_meta/dbstructs"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parser reads the statements of a script, collecting the objects they declare.
+type parser struct {
+	dialect *dialect
+	src     string
+	tokens  []token
+	pos     int
+
+	tables    []*dbstructs.TableMetadata
+	views     []*dbstructs.ViewMetadata
+	types     []*dbstructs.TypeMetadata
+	sequences []*dbstructs.SequenceMetadata
+
+	autoIndexes map[*dbstructs.TableMetadata]int // sqlite_autoindex_ numbers given
+	foreignKeys map[*dbstructs.TableMetadata]int // _ibfk_ numbers given
+}
+
+func newParser(d *dialect, src string, tokens []token) *parser {
+	return &parser{
+		dialect:     d,
+		src:         src,
+		tokens:      tokens,
+		autoIndexes: make(map[*dbstructs.TableMetadata]int),
+		foreignKeys: make(map[*dbstructs.TableMetadata]int),
+	}
+}
+
+// createStops are the objects after CREATE that are skipped, so that the search
+// for TABLE, INDEX or VIEW does not run into their definition.
+var createStops = []string{
+	"FUNCTION", "PROCEDURE", "PROC", "TRIGGER", "SCHEMA", "DATABASE", "EXTENSION", "ROLE", "USER",
+	"LOGIN", "EVENT", "AGGREGATE", "RULE", "POLICY", "SERVER", "STATISTICS", "PUBLICATION",
+	"SUBSCRIPTION", "OPERATOR", "CAST", "COLLATION", "CONVERSION", "LANGUAGE", "TABLESPACE",
+	"SYNONYM", "ASSEMBLY", "DEFAULT", "PARTITION", "XML", "CATALOG", "AS",
+}
+
+// expressionStops end a DEFAULT or computed column expression.
+var expressionStops = []string{
+	"NOT", "NULL", "CONSTRAINT", "PRIMARY", "UNIQUE", "REFERENCES", "CHECK", "GENERATED",
+	"AUTO_INCREMENT", "AUTOINCREMENT", "IDENTITY", "COLLATE", "ON", "COMMENT", "FOR", "WITH",
+	"PERSISTED", "STORED", "VIRTUAL",
+}
+
+// typeStops end the type of a column definition.
+var typeStops = []string{
+	"NOT", "NULL", "DEFAULT", "CONSTRAINT", "PRIMARY", "UNIQUE", "REFERENCES", "CHECK",
+	"GENERATED", "AS", "AUTO_INCREMENT", "AUTOINCREMENT", "IDENTITY", "COLLATE", "ON",
+	"COMMENT", "CHARSET", "KEY", "INVISIBLE", "VISIBLE", "STORAGE", "COLUMN_FORMAT", "SRID",
+	"ROWGUIDCOL", "SPARSE", "FILESTREAM", "MASKED", "ENCRYPTED", "COMPRESSION",
+}
+
+func (p *parser) parse() error {
+	for p.pos < len(p.tokens) {
+		if p.peek().kind == tokenEnd {
+			p.pos++
+			continue
+		}
+		if err := p.statement(); err != nil {
+			return err
+		}
+		p.skipStatement()
+	}
+	return nil
+}
+
+func (p *parser) statement() error {
+	switch {
+	case p.accept("CREATE"):
+		return p.create()
+	case p.accept("ALTER", "TABLE"):
+		return p.alterTable()
+	case p.accept("ALTER", "SEQUENCE"):
+		return p.alterSequence()
+	case p.accept("DROP"):
+		return p.drop()
+	}
+	return nil
+}
+
+func (p *parser) create() error {
+	start := p.pos
+	for t := p.peek(); t.kind != tokenEnd && !t.isSymbol("("); t = p.peek() {
+		p.pos++
+		switch {
+		case t.is("TABLE"):
+			return p.createTable()
+		case t.is("INDEX"):
+			return p.createIndex(p.modifiers(start))
+		case t.is("VIEW"):
+			return p.createView(p.modifiers(start)["MATERIALIZED"])
+		case t.is("TYPE"):
+			return p.createType()
+		case t.is("DOMAIN"):
+			return p.createDomain()
+		case t.is("SEQUENCE"):
+			return p.createSequence()
+		case t.is("TRIGGER") && p.dialect.name == SQLite:
+			p.skipTriggerBody()
+			return nil
+		case t.kind == tokenWord && isOneOf(t, createStops):
+			return nil
+		}
+	}
+	return nil
+}
+
+// modifiers returns the uppercased words between CREATE and the created object.
+func (p *parser) modifiers(from int) map[string]bool {
+	modifiers := make(map[string]bool)
+	for _, t := range p.tokens[from:p.pos] {
+		if t.kind == tokenWord {
+			modifiers[strings.ToUpper(t.text)] = true
+		}
+	}
+	return modifiers
+}
+
+func (p *parser) createTable() error {
+	p.accept("IF", "NOT", "EXISTS")
+	schema, name, err := p.qualifiedName()
+	if err != nil {
+		return err
+	}
+	table := &dbstructs.TableMetadata{Schema: schema, TableName: name, Columns: []*dbstructs.Column{}, PrimaryKey: []string{}}
+	p.addTable(table)
+
+	if p.accept("PARTITION", "OF") {
+		parentSchema, parentName, err := p.qualifiedName()
+		if err != nil {
+			return err
+		}
+		parent := p.table(parentSchema, parentName)
+		if parent != nil {
+			p.copyColumns(table, parent)
+			if len(parent.PrimaryKey) > 0 {
+				p.primaryKey(table, "", parent.PrimaryKey, "")
+			}
+		}
+		if p.peek().isSymbol("(") {
+			if err := p.tableElements(table); err != nil {
+				return err
+			}
+		}
+		from := p.pos
+		for !p.atEnd() && !p.peek().is("PARTITION") && !p.peek().is("WITH") && !p.peek().is("TABLESPACE") {
+			p.skipToken()
+		}
+		p.attachPartition(parent, table, dbstructs.QualifiedName(parentSchema, parentName), p.text(from, p.pos))
+	} else if p.peek().isSymbol("(") {
+		if err := p.tableElements(table); err != nil {
+			return err
+		}
+	}
+
+	for !p.atEnd() {
+		switch {
+		case p.accept("INHERITS"):
+			elements, err := p.elements()
+			if err != nil {
+				return err
+			}
+			for _, element := range elements {
+				parentSchema, parentName := p.elementName(element)
+				table.Inherits = append(table.Inherits, dbstructs.QualifiedName(parentSchema, parentName))
+				if parent := p.table(parentSchema, parentName); parent != nil {
+					p.copyColumns(table, parent)
+				}
+			}
+		case p.accept("PARTITION", "BY"):
+			if err := p.partitionBy(table); err != nil {
+				return err
+			}
+		default:
+			p.skipToken()
+		}
+	}
+	return nil
+}
+
+// copyColumns puts the columns of parent the table does not declare first, the
+// way INHERITS and PARTITION OF do.
+func (p *parser) copyColumns(table, parent *dbstructs.TableMetadata) {
+	var columns []*dbstructs.Column
+	for _, column := range parent.Columns {
+		if findColumn(table, column.ColumnName) == nil {
+			copied := *column
+			copied.Unique = false
+			columns = append(columns, &copied)
+		}
+	}
+	table.Columns = append(columns, table.Columns...)
+}
+
+// partitionBy reads PARTITION BY strategy (key), followed by the partitions on
+// MySQL.
+func (p *parser) partitionBy(table *dbstructs.TableMetadata) error {
+	p.accept("LINEAR")
+	strategy := strings.ToLower(p.next().text)
+	if strategy == "range" || strategy == "list" {
+		p.accept("COLUMNS")
+	}
+	key, err := p.parenthesized()
+	if err != nil {
+		return err
+	}
+	table.Partitioning = &dbstructs.Partitioning{Strategy: strategy, Key: key, Partitions: []*dbstructs.Partition{}}
+	if p.accept("PARTITIONS") {
+		p.skipToken()
+	}
+	if !p.peek().isSymbol("(") {
+		return nil
+	}
+	elements, err := p.elements()
+	if err != nil {
+		return err
+	}
+	for _, element := range elements {
+		if !p.tokens[element.from].is("PARTITION") || element.to-element.from < 2 {
+			continue
+		}
+		partition := &dbstructs.Partition{Name: identifierText(p.dialect, p.tokens[element.from+1])}
+		end := element.from + 2
+		for end < element.to && !p.tokens[end].is("ENGINE") && !p.tokens[end].is("COMMENT") {
+			if p.tokens[end].isSymbol("(") {
+				end = p.matching(end)
+			}
+			end++
+		}
+		partition.Bound = p.text(element.from+2, end)
+		table.Partitioning.Partitions = append(table.Partitioning.Partitions, partition)
+	}
+	return nil
+}
+
+// attachPartition records table as the partition of parent bounded by bound.
+func (p *parser) attachPartition(parent, table *dbstructs.TableMetadata, parentName, bound string) {
+	table.PartitionOf = parentName
+	table.PartitionBound = bound
+	if parent == nil {
+		return
+	}
+	if parent.Partitioning == nil {
+		parent.Partitioning = &dbstructs.Partitioning{Partitions: []*dbstructs.Partition{}}
+	}
+	parent.Partitioning.Partitions = append(parent.Partitioning.Partitions, &dbstructs.Partition{Name: table.QualifiedName(), Bound: bound})
+}
+
+func (p *parser) tableElements(table *dbstructs.TableMetadata) error {
+	if err := p.expectSymbol("("); err != nil {
+		return err
+	}
+	for {
+		if err := p.tableElement(table); err != nil {
+			return err
+		}
+		p.skipElement()
+		if p.acceptSymbol(",") {
+			continue
+		}
+		return p.expectSymbol(")")
+	}
+}
+
+func (p *parser) tableElement(table *dbstructs.TableMetadata) error {
+	constraintName, err := p.constraintName()
+	if err != nil {
+		return err
+	}
+	handled, err := p.tableConstraint(table, constraintName)
+	if handled || err != nil {
+		return err
+	}
+	if constraintName != "" {
+		return p.errorf("expected a constraint")
+	}
+	return p.columnDefinition(table)
+}
+
+// constraintName reads CONSTRAINT name, if any. MySQL allows CONSTRAINT without
+// name.
+func (p *parser) constraintName() (string, error) {
+	if !p.accept("CONSTRAINT") {
+		return "", nil
+	}
+	for _, keyword := range []string{"PRIMARY", "UNIQUE", "FOREIGN", "CHECK"} {
+		if p.peek().is(keyword) {
+			return "", nil
+		}
+	}
+	return p.identifier()
+}
+
+// tableConstraint reads a table constraint or index, telling whether there was
+// one.
+func (p *parser) tableConstraint(table *dbstructs.TableMetadata, constraintName string) (bool, error) {
+	inlineIndexes := p.dialect.name == MySQL || p.dialect.name == SQLServer
+	switch {
+	case p.accept("PRIMARY", "KEY"):
+		method := p.clustering()
+		p.accept("USING", "BTREE")
+		columns, err := p.columnList()
+		if err != nil {
+			return true, err
+		}
+		p.primaryKey(table, constraintName, columns, method)
+	case p.accept("UNIQUE"):
+		if !p.accept("KEY") {
+			p.accept("INDEX")
+		}
+		method := p.clustering()
+		var name string
+		if !p.peek().isSymbol("(") && !p.peek().is("USING") {
+			var err error
+			if name, err = p.identifier(); err != nil {
+				return true, err
+			}
+		}
+		if p.accept("USING") {
+			method = strings.ToLower(p.next().text)
+		}
+		columns, err := p.columnList()
+		if err != nil {
+			return true, err
+		}
+		if name == "" {
+			name = constraintName
+		}
+		p.unique(table, name, columns, method)
+	case p.accept("FOREIGN", "KEY"):
+		if !p.peek().isSymbol("(") {
+			p.skipToken() // MySQL index name
+		}
+		columns, err := p.columnList()
+		if err != nil {
+			return true, err
+		}
+		return true, p.references(table, constraintName, columns)
+	case p.accept("CHECK"):
+		expression, err := p.parenthesized()
+		if err != nil {
+			return true, err
+		}
+		table.CheckConstraints = append(table.CheckConstraints, &dbstructs.CheckConstraint{Name: constraintName, Expression: expression})
+	case p.peek().is("EXCLUDE") || p.peek().is("LIKE") || p.peek().is("PERIOD"):
+		// skipped
+	case inlineIndexes && constraintName == "" && (p.peek().is("KEY") || p.peek().is("INDEX") || p.peek().is("FULLTEXT") || p.peek().is("SPATIAL")):
+		return true, p.inlineIndex(table)
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// inlineIndex reads the KEY name (columns) of MySQL and the INDEX name (columns)
+// of SQL Server in a table definition.
+func (p *parser) inlineIndex(table *dbstructs.TableMetadata) error {
+	method := p.dialect.indexMethod
+	if p.peek().is("FULLTEXT") || p.peek().is("SPATIAL") {
+		method = strings.ToLower(p.next().text)
+	}
+	if !p.accept("KEY") {
+		p.accept("INDEX")
+	}
+	var name string
+	if !p.peek().isSymbol("(") && !p.peek().is("USING") {
+		var err error
+		if name, err = p.identifier(); err != nil {
+			return err
+		}
+	}
+	unique := p.accept("UNIQUE")
+	if clustering := p.clustering(); clustering != "" {
+		method = clustering
+	}
+	if p.accept("USING") {
+		method = strings.ToLower(p.next().text)
+	}
+	keys, err := p.indexKeys()
+	if err != nil {
+		return err
+	}
+	if p.accept("USING") {
+		method = strings.ToLower(p.next().text)
+	}
+	if name == "" && len(keys) > 0 {
+		name = p.freeIndexName(table, keys[0].Column+keys[0].Expression)
+	}
+	index := &dbstructs.Index{Name: name, Columns: []string{}, Unique: unique, Method: method}
+	p.setKeys(index, keys)
+	p.putIndex(table, index)
+	return nil
+}
+
+// clustering reads the CLUSTERED or NONCLUSTERED of SQL Server, returning the
+// index method it gives.
+func (p *parser) clustering() string {
+	if p.peek().is("CLUSTERED") || p.peek().is("NONCLUSTERED") {
+		return strings.ToLower(p.next().text)
+	}
+	return ""
+}
+
+// primaryKey sets the primary key of table, with its index when the dialect
+// lists it. A single INTEGER primary key is the rowid on SQLite, without index.
+func (p *parser) primaryKey(table *dbstructs.TableMetadata, constraintName string, columns []string, method string) {
+	table.PrimaryKey = columns
+	if p.dialect.name == SQLite && len(columns) == 1 {
+		if column := findColumn(table, columns[0]); column != nil && strings.EqualFold(column.DataType, "INTEGER") {
+			column.AutoIncrement = true
+			return
+		}
+	}
+	if !p.dialect.primaryKeyIndex {
+		return
+	}
+	var name string
+	switch p.dialect.name {
+	case PostgreSQL:
+		name = constraintName
+		if name == "" {
+			name = table.TableName + "_pkey"
+		}
+	case MySQL:
+		name = "PRIMARY"
+	case SQLite:
+		name = p.autoIndexName(table)
+	}
+	if method == "" {
+		method = p.dialect.indexMethod
+	}
+	index := newIndex(name, columns, true, method)
+	index.Primary = true
+	p.putIndex(table, index)
+}
+
+// unique adds the index of a UNIQUE constraint, named name when given.
+func (p *parser) unique(table *dbstructs.TableMetadata, name string, columns []string, method string) {
+	switch {
+	case p.dialect.name == SQLite:
+		name = p.autoIndexName(table)
+	case name != "":
+	case p.dialect.name == PostgreSQL:
+		name = p.freeIndexName(table, table.TableName+"_"+strings.Join(columns, "_")+"_key")
+	case p.dialect.name == SQLServer:
+		name = p.freeIndexName(table, "UQ_"+table.TableName+"_"+strings.Join(columns, "_"))
+	default:
+		name = p.freeIndexName(table, columns[0])
+	}
+	if method == "" {
+		method = p.dialect.indexMethod
+	}
+	p.putIndex(table, newIndex(name, columns, true, method))
+}
+
+func (p *parser) autoIndexName(table *dbstructs.TableMetadata) string {
+	p.autoIndexes[table]++
+	return "sqlite_autoindex_" + table.TableName + "_" + strconv.Itoa(p.autoIndexes[table])
+}
+
+// freeIndexName returns base, or base followed by a number when an index of the
+// table already has this name.
+func (p *parser) freeIndexName(table *dbstructs.TableMetadata, base string) string {
+	taken := func(name string) bool {
+		for _, index := range table.Indexes {
+			if strings.EqualFold(index.Name, name) {
+				return true
+			}
+		}
+		return false
+	}
+	name := base
+	for n := 1; taken(name); n++ {
+		if p.dialect.name == MySQL {
+			name = base + "_" + strconv.Itoa(n+1)
+		} else {
+			name = base + strconv.Itoa(n)
+		}
+	}
+	return name
+}
+
+// putIndex adds index to table, replacing the one of the same name.
+func (p *parser) putIndex(table *dbstructs.TableMetadata, index *dbstructs.Index) {
+	for i, existing := range table.Indexes {
+		if strings.EqualFold(existing.Name, index.Name) {
+			table.Indexes[i] = index
+			return
+		}
+	}
+	table.Indexes = append(table.Indexes, index)
+}
+
+// references reads REFERENCES table [(columns)] and its options. The target
+// columns are left nil when omitted, finish sets them to the primary key.
+func (p *parser) references(table *dbstructs.TableMetadata, constraintName string, columns []string) error {
+	if err := p.expect("REFERENCES"); err != nil {
+		return err
+	}
+	schema, name, err := p.qualifiedName()
+	if err != nil {
+		return err
+	}
+	relationship := &dbstructs.RelationshipMetadata{
+		Conname:          constraintName,
+		SourceSchema:     table.Schema,
+		SourceTableName:  table.TableName,
+		RelatedSchema:    schema,
+		RelatedTableName: name,
+		SourceColumns:    columns,
+		OnDelete:         p.dialect.defaultReferential,
+		OnUpdate:         p.dialect.defaultReferential,
+		Match:            p.dialect.match,
+	}
+	if p.peek().isSymbol("(") {
+		if relationship.TargetColumns, err = p.columnList(); err != nil {
+			return err
+		}
+	}
+	for {
+		switch {
+		case p.accept("MATCH"):
+			relationship.Match = strings.ToUpper(p.next().text)
+		case p.accept("ON", "DELETE"):
+			relationship.OnDelete = p.referentialAction()
+		case p.accept("ON", "UPDATE"):
+			relationship.OnUpdate = p.referentialAction()
+		case p.accept("DEFERRABLE"):
+			relationship.Deferrable = true
+		case p.accept("INITIALLY", "DEFERRED"):
+			relationship.InitiallyDeferred = true
+		case p.accept("NOT", "DEFERRABLE"), p.accept("INITIALLY", "IMMEDIATE"), p.accept("NOT", "VALID"),
+			p.accept("NOT", "FOR", "REPLICATION"), p.accept("NOT", "ENFORCED"), p.accept("ENFORCED"):
+		default:
+			if relationship.Conname == "" || p.dialect.name == SQLite {
+				relationship.Conname = p.foreignKeyName(table, relationship)
+			}
+			table.Relationships = append(table.Relationships, relationship)
+			return nil
+		}
+	}
+}
+
+// referentialAction reads the action of ON DELETE or ON UPDATE.
+func (p *parser) referentialAction() string {
+	switch {
+	case p.accept("CASCADE"):
+		return dbstructs.ReferentialActionCascade
+	case p.accept("RESTRICT"):
+		return dbstructs.ReferentialActionRestrict
+	case p.accept("SET", "NULL"):
+		if p.peek().isSymbol("(") {
+			p.skipToken()
+		}
+		return dbstructs.ReferentialActionSetNull
+	case p.accept("SET", "DEFAULT"):
+		if p.peek().isSymbol("(") {
+			p.skipToken()
+		}
+		return dbstructs.ReferentialActionSetDefault
+	}
+	p.accept("NO", "ACTION")
+	return dbstructs.ReferentialActionNoAction
+}
+
+// foreignKeyName names a foreign key declared without a name the way the
+// dialect does. SQLite does not keep the names, the connector names them all
+// after their columns.
+func (p *parser) foreignKeyName(table *dbstructs.TableMetadata, relationship *dbstructs.RelationshipMetadata) string {
+	switch p.dialect.name {
+	case SQLite:
+		return strings.Join(relationship.SourceColumns, "_")
+	case PostgreSQL:
+		return table.TableName + "_" + strings.Join(relationship.SourceColumns, "_") + "_fkey"
+	case MySQL:
+		p.foreignKeys[table]++
+		return table.TableName + "_ibfk_" + strconv.Itoa(p.foreignKeys[table])
+	case SQLServer:
+		return "FK_" + table.TableName + "_" + relationship.RelatedTableName
+	}
+	return ""
+}
+
+// columnDefinition reads a column, its type and its constraints.
+func (p *parser) columnDefinition(table *dbstructs.TableMetadata) error {
+	name, err := p.identifier()
+	if err != nil {
+		return err
+	}
+	column := &dbstructs.Column{ColumnName: name}
+	typeStart := p.pos
+	for !p.atElementEnd() {
+		t := p.peek()
+		if t.kind == tokenWord && isOneOf(t, typeStops) || t.is("CHARACTER") && p.peekAt(1).is("SET") ||
+			t.kind == tokenSymbol && !t.isSymbol("(") && !t.isSymbol("[") && !t.isSymbol("]") && !t.isSymbol(".") {
+			break
+		}
+		p.skipToken()
+	}
+	serial := p.setType(column, p.typeDeclaration(typeStart, p.pos))
+	table.Columns = append(table.Columns, column)
+	if serial {
+		p.serial(table, column)
+	}
+
+	var constraintName string
+	for !p.atElementEnd() {
+		named := false
+		switch {
+		case p.accept("CONSTRAINT"):
+			if constraintName, err = p.identifier(); err != nil {
+				return err
+			}
+			named = true
+		case p.accept("NOT", "NULL"):
+			column.NotNull = true
+		case p.accept("NULL"):
+		case p.accept("DEFAULT"):
+			column.Default = p.defaultValue()
+		case p.accept("PRIMARY", "KEY"):
+			if !p.accept("ASC") {
+				p.accept("DESC")
+			}
+			p.primaryKey(table, constraintName, []string{column.ColumnName}, p.clustering())
+		case p.accept("UNIQUE"):
+			p.accept("KEY")
+			p.unique(table, constraintName, []string{column.ColumnName}, p.clustering())
+		case p.peek().is("REFERENCES"):
+			if err := p.references(table, constraintName, []string{column.ColumnName}); err != nil {
+				return err
+			}
+		case p.accept("CHECK"):
+			expression, err := p.parenthesized()
+			if err != nil {
+				return err
+			}
+			table.CheckConstraints = append(table.CheckConstraints, &dbstructs.CheckConstraint{
+				Name: constraintName, Columns: []string{column.ColumnName}, Expression: expression,
+			})
+		case p.accept("GENERATED"):
+			if !p.accept("ALWAYS") {
+				p.accept("BY", "DEFAULT")
+			}
+			if err := p.expect("AS"); err != nil {
+				return err
+			}
+			if p.peek().is("IDENTITY") {
+				p.identity(table, column)
+				break
+			}
+			if column.Generated, err = p.parenthesized(); err != nil {
+				return err
+			}
+		case p.accept("AS"):
+			if p.peek().isSymbol("(") {
+				column.Generated, err = p.parenthesized()
+			} else {
+				column.Generated = p.expression()
+			}
+			if err != nil {
+				return err
+			}
+		case p.accept("AUTO_INCREMENT"), p.accept("AUTOINCREMENT"):
+			column.AutoIncrement = true
+		case p.peek().is("IDENTITY"):
+			p.identity(table, column)
+		case p.accept("ON", "UPDATE"):
+			p.expression()
+		case p.accept("COLLATE"), p.accept("CHARSET"), p.accept("CHARACTER", "SET"), p.accept("COMMENT"),
+			p.accept("COMPRESSION"), p.accept("STORAGE"), p.accept("COLUMN_FORMAT"), p.accept("SRID"), p.accept("ON", "CONFLICT"):
+			p.skipToken()
+		default:
+			p.skipToken()
+		}
+		if !named {
+			constraintName = ""
+		}
+	}
+	return nil
+}
+
+// identity reads IDENTITY [(options)], the sequence of a PostgreSQL identity
+// column is named after it when the options do not name it.
+func (p *parser) identity(table *dbstructs.TableMetadata, column *dbstructs.Column) {
+	p.accept("IDENTITY")
+	column.AutoIncrement = true
+	var sequenceName string
+	if p.peek().isSymbol("(") {
+		end := p.matching(p.pos)
+		for i := p.pos; i < end; i++ {
+			if p.tokens[i].is("SEQUENCE") && p.tokens[i+1].is("NAME") {
+				sequenceName = identifierText(p.dialect, p.tokens[i+2])
+				for i+4 < end && p.tokens[i+3].isSymbol(".") {
+					sequenceName = identifierText(p.dialect, p.tokens[i+4])
+					i += 2
+				}
+			}
+		}
+		p.pos = end + 1
+	}
+	if p.dialect.name == PostgreSQL {
+		column.NotNull = true
+		p.ownedSequence(table, column, sequenceName)
+	}
+}
+
+// serial turns a PostgreSQL serial column into an integer defaulting to its
+// sequence.
+func (p *parser) serial(table *dbstructs.TableMetadata, column *dbstructs.Column) {
+	sequence := p.ownedSequence(table, column, "")
+	nextval := fmt.Sprintf("nextval('%s'::regclass)", sequence.SequenceName)
+	if table.Schema != p.dialect.defaultSchema {
+		nextval = fmt.Sprintf("nextval('%s'::regclass)", sequence.QualifiedName())
+	}
+	column.Default = &nextval
+	column.NotNull = true
+	column.AutoIncrement = true
+}
+
+// ownedSequence adds the sequence of a serial or identity column, named
+// table_column_seq when name is empty.
+func (p *parser) ownedSequence(table *dbstructs.TableMetadata, column *dbstructs.Column, name string) *dbstructs.SequenceMetadata {
+	if name == "" {
+		name = table.TableName + "_" + column.ColumnName + "_seq"
+	}
+	sequence := &dbstructs.SequenceMetadata{
+		Schema:       table.Schema,
+		SequenceName: name,
+		DataType:     column.DataType,
+		Start:        1,
+		Increment:    1,
+		MinValue:     1,
+		MaxValue:     maxValue(column.DataType),
+		OwnerTable:   table.QualifiedName(),
+		OwnerColumn:  column.ColumnName,
+	}
+	p.putSequence(sequence)
+	return sequence
+}
+
+func (p *parser) putSequence(sequence *dbstructs.SequenceMetadata) {
+	for i, existing := range p.sequences {
+		if strings.EqualFold(existing.QualifiedName(), sequence.QualifiedName()) {
+			p.sequences[i] = sequence
+			return
+		}
+	}
+	p.sequences = append(p.sequences, sequence)
+}
+
+// defaultValue reads the expression of DEFAULT, nil for NULL. MySQL reports the
+// string defaults without their quotes.
+func (p *parser) defaultValue() *string {
+	value := p.expression()
+	if strings.EqualFold(value, "NULL") {
+		return nil
+	}
+	if p.dialect.name == MySQL && strings.HasPrefix(value, "'") {
+		value = unquote(p.dialect, value)
+	}
+	return &value
+}
+
+// expression reads an expression up to the next column constraint, as written.
+func (p *parser) expression() string {
+	from := p.pos
+	p.skipToken()
+	for !p.atElementEnd() {
+		if t := p.peek(); t.kind == tokenWord && isOneOf(t, expressionStops) {
+			break
+		}
+		p.skipToken()
+	}
+	return p.text(from, p.pos)
+}
+
+func (p *parser) createIndex(modifiers map[string]bool) error {
+	p.accept("CONCURRENTLY")
+	p.accept("IF", "NOT", "EXISTS")
+	method := p.dialect.indexMethod
+	switch {
+	case modifiers["CLUSTERED"]:
+		method = "clustered"
+	case modifiers["FULLTEXT"]:
+		method = "fulltext"
+	case modifiers["SPATIAL"]:
+		method = "spatial"
+	}
+	var name string
+	if !p.peek().is("ON") {
+		var err error
+		if _, name, err = p.qualifiedName(); err != nil {
+			return err
+		}
+	}
+	if p.accept("USING") {
+		method = strings.ToLower(p.next().text)
+	}
+	if err := p.expect("ON"); err != nil {
+		return err
+	}
+	p.accept("ONLY")
+	schema, tableName, err := p.qualifiedName()
+	if err != nil {
+		return err
+	}
+	if p.accept("USING") {
+		method = strings.ToLower(p.next().text)
+	}
+	keys, err := p.indexKeys()
+	if err != nil {
+		return err
+	}
+	table := p.table(schema, tableName)
+	if table == nil || name == "" {
+		return nil
+	}
+	index := &dbstructs.Index{Name: name, Columns: []string{}, Unique: modifiers["UNIQUE"], Method: method}
+	p.setKeys(index, keys)
+	for !p.atEnd() {
+		switch {
+		case p.accept("INCLUDE"):
+			if index.Include, err = p.columnList(); err != nil {
+				return err
+			}
+		case p.accept("USING"):
+			index.Method = strings.ToLower(p.next().text)
+		case p.accept("WHERE"):
+			from := p.pos
+			for !p.atEnd() && !(p.peek().is("WITH") && p.peekAt(1).isSymbol("(")) && !p.peek().is("ON") {
+				p.skipToken()
+			}
+			index.Predicate = p.text(from, p.pos)
+		default:
+			p.skipToken()
+		}
+	}
+	if p.dialect.name == SQLServer && index.Method == "clustered" {
+		index.Predicate = ""
+	}
+	p.putIndex(table, index)
+	return nil
+}
+
+// indexKeys reads the (keys) of an index: columns, or expressions, with their
+// ordering.
+func (p *parser) indexKeys() ([]*dbstructs.IndexKey, error) {
+	elements, err := p.elements()
+	if err != nil {
+		return nil, err
+	}
+	var keys []*dbstructs.IndexKey
+	for _, element := range elements {
+		key := &dbstructs.IndexKey{}
+		end := element.to
+		for end > element.from+1 {
+			last := p.tokens[end-1]
+			switch {
+			case (last.is("FIRST") || last.is("LAST")) && p.tokens[end-2].is("NULLS"):
+				end -= 2
+				continue
+			case last.is("DESC"):
+				key.Descending = true
+				end--
+				continue
+			case last.is("ASC"):
+				end--
+				continue
+			}
+			break
+		}
+		first := p.tokens[element.from]
+		isName := first.kind == tokenWord || first.kind == tokenQuoted
+		switch {
+		case isName && end == element.from+1,
+			isName && (p.tokens[element.from+1].kind == tokenWord || p.tokens[element.from+1].kind == tokenQuoted),
+			isName && p.dialect.name == MySQL && p.tokens[element.from+1].isSymbol("("): // prefix length
+			key.Column = identifierText(p.dialect, first)
+		default:
+			key.Expression = p.text(element.from, end)
+			if p.tokens[element.from].isSymbol("(") && p.matching(element.from) == end-1 {
+				key.Expression = p.text(element.from+1, end-1)
+			}
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (p *parser) setKeys(index *dbstructs.Index, keys []*dbstructs.IndexKey) {
+	index.Keys = keys
+	for _, key := range keys {
+		index.Columns = append(index.Columns, key.Column+key.Expression)
+	}
+}
+
+func (p *parser) createView(materialized bool) error {
+	p.accept("IF", "NOT", "EXISTS")
+	schema, name, err := p.qualifiedName()
+	if err != nil {
+		return err
+	}
+	view := &dbstructs.ViewMetadata{Schema: schema, ViewName: name, Materialized: materialized, Columns: []*dbstructs.Column{}}
+	var columnNames []string
+	if p.peek().isSymbol("(") {
+		if columnNames, err = p.columnList(); err != nil {
+			return err
+		}
+	}
+	for !p.atEnd() && !p.peek().is("AS") {
+		p.skipToken()
+	}
+	if err := p.expect("AS"); err != nil {
+		return err
+	}
+	from := p.pos
+	for !p.atEnd() && !(p.peek().is("WITH") && (p.peekAt(1).is("CHECK") || p.peekAt(2).is("CHECK") || p.peekAt(1).is("NO") || p.peekAt(1).is("DATA"))) {
+		p.skipToken()
+	}
+	view.Definition = p.text(from, p.pos)
+	if columnNames == nil {
+		columnNames = p.selectNames(from, p.pos)
+	}
+	for i, columnName := range columnNames {
+		view.Columns = append(view.Columns, &dbstructs.Column{ColumnName: columnName, OrdinalPosition: i + 1})
+	}
+
+	for i, existing := range p.views {
+		if strings.EqualFold(existing.QualifiedName(), view.QualifiedName()) {
+			p.views[i] = view
+			return nil
+		}
+	}
+	p.views = append(p.views, view)
+	return nil
+}
+
+// selectNames returns the names of the columns of the SELECT between the tokens
+// from and to: their alias, or the column they read. Names are not known for *.
+func (p *parser) selectNames(from, to int) []string {
+	if from >= to || !p.tokens[from].is("SELECT") {
+		return nil
+	}
+	from++
+	if p.tokens[from].is("DISTINCT") || p.tokens[from].is("ALL") {
+		from++
+	}
+	var names []string
+	start := from
+	for i := from; i <= to; i++ {
+		t := p.tokens[i]
+		if t.isSymbol("(") {
+			i = p.matching(i)
+			continue
+		}
+		if i < to && !t.isSymbol(",") && !t.is("FROM") {
+			continue
+		}
+		if i == start {
+			return names
+		}
+		last := p.tokens[i-1]
+		if last.isSymbol("*") || last.kind != tokenWord && last.kind != tokenQuoted {
+			return nil
+		}
+		names = append(names, identifierText(p.dialect, last))
+		if i == to || t.is("FROM") {
+			return names
+		}
+		start = i + 1
+	}
+	return names
+}
+
+func (p *parser) createType() error {
+	schema, name, err := p.qualifiedName()
+	if err != nil {
+		return err
+	}
+	typ := &dbstructs.TypeMetadata{Schema: schema, TypeName: name}
+	switch {
+	case p.accept("AS", "ENUM"):
+		typ.Kind = dbstructs.TypeKindEnum
+		elements, err := p.elements()
+		if err != nil {
+			return err
+		}
+		for _, element := range elements {
+			typ.Values = append(typ.Values, unquote(p.dialect, p.text(element.from, element.to)))
+		}
+	case p.accept("AS") && p.peek().isSymbol("("):
+		typ.Kind = dbstructs.TypeKindComposite
+		attributes := &dbstructs.TableMetadata{Schema: schema, TableName: name}
+		if err := p.tableElements(attributes); err != nil {
+			return err
+		}
+		typ.Attributes = attributes.Columns
+		for i, attribute := range typ.Attributes {
+			attribute.OrdinalPosition = i + 1
+		}
+	default:
+		return nil
+	}
+	p.types = append(p.types, typ)
+	return nil
+}
+
+func (p *parser) createDomain() error {
+	schema, name, err := p.qualifiedName()
+	if err != nil {
+		return err
+	}
+	p.accept("AS")
+	typ := &dbstructs.TypeMetadata{Schema: schema, TypeName: name, Kind: dbstructs.TypeKindDomain}
+	from := p.pos
+	for !p.atEnd() && !(p.peek().kind == tokenWord && isOneOf(p.peek(), typeStops)) {
+		p.skipToken()
+	}
+	base := &dbstructs.Column{}
+	p.setType(base, p.typeDeclaration(from, p.pos))
+	typ.BaseType = base.DataType
+	for !p.atEnd() {
+		switch {
+		case p.accept("NOT", "NULL"):
+			typ.NotNull = true
+		case p.accept("DEFAULT"):
+			typ.Default = p.defaultValue()
+		case p.accept("CHECK"):
+			check, err := p.parenthesized()
+			if err != nil {
+				return err
+			}
+			typ.Checks = append(typ.Checks, check)
+		default:
+			p.skipToken()
+		}
+	}
+	p.types = append(p.types, typ)
+	return nil
+}
+
+func (p *parser) createSequence() error {
+	p.accept("IF", "NOT", "EXISTS")
+	schema, name, err := p.qualifiedName()
+	if err != nil {
+		return err
+	}
+	sequence := &dbstructs.SequenceMetadata{Schema: schema, SequenceName: name, DataType: "bigint", Increment: 1}
+	var start, minimum, maximum *int64
+	for !p.atEnd() {
+		switch {
+		case p.accept("AS"):
+			typ := &dbstructs.Column{}
+			from := p.pos
+			p.skipToken()
+			p.setType(typ, p.typeDeclaration(from, p.pos))
+			sequence.DataType = typ.DataType
+		case p.accept("START"):
+			p.accept("WITH")
+			start = p.number()
+		case p.accept("INCREMENT"):
+			p.accept("BY")
+			if increment := p.number(); increment != nil {
+				sequence.Increment = *increment
+			}
+		case p.accept("MINVALUE"):
+			minimum = p.number()
+		case p.accept("MAXVALUE"):
+			maximum = p.number()
+		case p.accept("NO", "CYCLE"):
+		case p.accept("CYCLE"):
+			sequence.Cycle = true
+		case p.accept("OWNED", "BY"):
+			p.ownedBy(sequence)
+		default:
+			p.skipToken()
+		}
+	}
+	if minimum == nil {
+		minimum = new(int64)
+		*minimum = 1
+		if sequence.Increment < 0 {
+			*minimum = -maxValue(sequence.DataType) - 1
+		}
+	}
+	if maximum == nil {
+		maximum = new(int64)
+		*maximum = maxValue(sequence.DataType)
+		if sequence.Increment < 0 {
+			*maximum = -1
+		}
+	}
+	sequence.MinValue, sequence.MaxValue = *minimum, *maximum
+	sequence.Start = sequence.MinValue
+	if sequence.Increment < 0 {
+		sequence.Start = sequence.MaxValue
+	}
+	if start != nil {
+		sequence.Start = *start
+	}
+	p.putSequence(sequence)
+	return nil
+}
+
+func (p *parser) alterSequence() error {
+	p.accept("IF", "EXISTS")
+	schema, name, err := p.qualifiedName()
+	if err != nil {
+		return err
+	}
+	for _, sequence := range p.sequences {
+		if strings.EqualFold(sequence.QualifiedName(), dbstructs.QualifiedName(schema, name)) {
+			for !p.atEnd() {
+				if p.accept("OWNED", "BY") {
+					p.ownedBy(sequence)
+				} else {
+					p.skipToken()
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// ownedBy reads the table.column of OWNED BY.
+func (p *parser) ownedBy(sequence *dbstructs.SequenceMetadata) {
+	if p.accept("NONE") {
+		sequence.OwnerTable, sequence.OwnerColumn = "", ""
+		return
+	}
+	var parts []string
+	for {
+		part, err := p.identifier()
+		if err != nil {
+			return
+		}
+		parts = append(parts, part)
+		if !p.acceptSymbol(".") {
+			break
+		}
+	}
+	if len(parts) < 2 {
+		return
+	}
+	schema := p.dialect.defaultSchema
+	if len(parts) > 2 {
+		schema = parts[len(parts)-3]
+	}
+	sequence.OwnerTable = dbstructs.QualifiedName(schema, parts[len(parts)-2])
+	sequence.OwnerColumn = parts[len(parts)-1]
+}
+
+func (p *parser) alterTable() error {
+	p.accept("ONLY")
+	p.accept("IF", "EXISTS")
+	schema, name, err := p.qualifiedName()
+	if err != nil {
+		return err
+	}
+	table := p.table(schema, name)
+	if table == nil {
+		return nil
+	}
+	if !p.accept("WITH", "CHECK") {
+		p.accept("WITH", "NOCHECK")
+	}
+	adding := false
+	for {
+		if err := p.alterAction(table, &adding); err != nil {
+			return err
+		}
+		p.skipElement()
+		if !p.acceptSymbol(",") {
+			return nil
+		}
+	}
+}
+
+// alterAction reads an action of ALTER TABLE. adding is set after an ADD, SQL
+// Server lists the columns and constraints added after a single ADD.
+func (p *parser) alterAction(table *dbstructs.TableMetadata, adding *bool) error {
+	switch {
+	case p.accept("ADD"):
+		*adding = true
+		return p.alterAdd(table)
+	case p.accept("ALTER"):
+		*adding = false
+		p.accept("COLUMN")
+		return p.alterColumn(table)
+	case p.accept("MODIFY"):
+		*adding = false
+		p.accept("COLUMN")
+		return p.redefineColumn(table, p.peek(), false)
+	case p.accept("CHANGE"):
+		*adding = false
+		p.accept("COLUMN")
+		old := p.next()
+		return p.redefineColumn(table, old, false)
+	case p.accept("DROP"):
+		*adding = false
+		p.alterDrop(table)
+	case p.accept("ATTACH", "PARTITION"):
+		*adding = false
+		schema, name, err := p.qualifiedName()
+		if err != nil {
+			return err
+		}
+		if partition := p.table(schema, name); partition != nil {
+			from := p.pos
+			p.skipElement()
+			p.attachPartition(table, partition, table.QualifiedName(), p.text(from, p.pos))
+		}
+	case *adding:
+		return p.alterAdd(table)
+	}
+	return nil
+}
+
+func (p *parser) alterAdd(table *dbstructs.TableMetadata) error {
+	if p.accept("COLUMN") {
+		p.accept("IF", "NOT", "EXISTS")
+		return p.columnDefinition(table)
+	}
+	constraintName, err := p.constraintName()
+	if err != nil {
+		return err
+	}
+	if p.accept("DEFAULT") {
+		value := p.defaultValue()
+		if err := p.expect("FOR"); err != nil {
+			return err
+		}
+		name, err := p.identifier()
+		if err != nil {
+			return err
+		}
+		if column := findColumn(table, name); column != nil {
+			column.Default = value
+		}
+		return nil
+	}
+	if p.peek().is("INDEX") || p.peek().is("KEY") || p.peek().is("FULLTEXT") || p.peek().is("SPATIAL") {
+		return p.inlineIndex(table)
+	}
+	handled, err := p.tableConstraint(table, constraintName)
+	if handled || err != nil {
+		return err
+	}
+	return p.columnDefinition(table)
+}
+
+func (p *parser) alterColumn(table *dbstructs.TableMetadata) error {
+	nameToken := p.peek()
+	name, err := p.identifier()
+	if err != nil {
+		return err
+	}
+	column := findColumn(table, name)
+	if column == nil {
+		return nil
+	}
+	switch {
+	case p.accept("SET", "DEFAULT"):
+		column.Default = p.defaultValue()
+	case p.accept("DROP", "DEFAULT"):
+		column.Default = nil
+	case p.accept("SET", "NOT", "NULL"):
+		column.NotNull = true
+	case p.accept("DROP", "NOT", "NULL"):
+		column.NotNull = false
+	case p.accept("ADD", "GENERATED"):
+		if !p.accept("ALWAYS") {
+			p.accept("BY", "DEFAULT")
+		}
+		p.accept("AS")
+		p.identity(table, column)
+	case p.accept("SET", "DATA", "TYPE"), p.accept("TYPE"):
+		from := p.pos
+		for !p.atElementEnd() && !p.peek().is("USING") && !p.peek().is("COLLATE") {
+			p.skipToken()
+		}
+		p.setType(column, p.typeDeclaration(from, p.pos))
+	case p.peek().is("SET") || p.peek().is("DROP") || p.peek().is("ADD"):
+	default:
+		// SQL Server: ALTER COLUMN name type [NOT] NULL
+		p.pos--
+		return p.redefineColumn(table, nameToken, true)
+	}
+	return nil
+}
+
+// redefineColumn replaces the column named by old with the column definition
+// that follows, keeping its position. keep keeps the default and identity.
+func (p *parser) redefineColumn(table *dbstructs.TableMetadata, old token, keep bool) error {
+	oldName := identifierText(p.dialect, old)
+	if err := p.columnDefinition(table); err != nil {
+		return err
+	}
+	last := len(table.Columns) - 1
+	for i, column := range table.Columns[:last] {
+		if strings.EqualFold(column.ColumnName, oldName) {
+			redefined := table.Columns[last]
+			if keep {
+				redefined.Default, redefined.AutoIncrement = column.Default, column.AutoIncrement
+			}
+			table.Columns[i] = redefined
+			table.Columns = table.Columns[:last]
+			break
+		}
+	}
+	return nil
+}
+
+// alterDrop reads the DROP of a column, a constraint or an index.
+func (p *parser) alterDrop(table *dbstructs.TableMetadata) {
+	switch {
+	case p.accept("PRIMARY", "KEY"):
+		table.PrimaryKey = []string{}
+		p.dropIndexes(table, func(index *dbstructs.Index) bool { return index.Primary })
+	case p.accept("CONSTRAINT"), p.accept("FOREIGN", "KEY"), p.accept("INDEX"), p.accept("KEY"), p.accept("CHECK"):
+		p.accept("IF", "EXISTS")
+		name, err := p.identifier()
+		if err != nil {
+			return
+		}
+		p.dropConstraint(table, name)
+	default:
+		p.accept("COLUMN")
+		p.accept("IF", "EXISTS")
+		name, err := p.identifier()
+		if err != nil {
+			return
+		}
+		for i, column := range table.Columns {
+			if strings.EqualFold(column.ColumnName, name) {
+				table.Columns = append(table.Columns[:i], table.Columns[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// dropConstraint removes the constraint or index name from table.
+func (p *parser) dropConstraint(table *dbstructs.TableMetadata, name string) {
+	p.dropIndexes(table, func(index *dbstructs.Index) bool {
+		if strings.EqualFold(index.Name, name) && index.Primary {
+			table.PrimaryKey = []string{}
+		}
+		return strings.EqualFold(index.Name, name)
+	})
+	if p.dialect.name == SQLServer && strings.EqualFold("PK_"+table.TableName, name) {
+		table.PrimaryKey = []string{}
+	}
+	var relationships []*dbstructs.RelationshipMetadata
+	for _, relationship := range table.Relationships {
+		if !strings.EqualFold(relationship.Conname, name) {
+			relationships = append(relationships, relationship)
+		}
+	}
+	table.Relationships = relationships
+	var checks []*dbstructs.CheckConstraint
+	for _, check := range table.CheckConstraints {
+		if !strings.EqualFold(check.Name, name) {
+			checks = append(checks, check)
+		}
+	}
+	table.CheckConstraints = checks
+}
+
+func (p *parser) dropIndexes(table *dbstructs.TableMetadata, drop func(*dbstructs.Index) bool) {
+	var indexes []*dbstructs.Index
+	for _, index := range table.Indexes {
+		if !drop(index) {
+			indexes = append(indexes, index)
+		}
+	}
+	table.Indexes = indexes
+}
+
+// drop reads DROP TABLE and DROP VIEW, mysqldump creates and drops a table in
+// place of each view.
+func (p *parser) drop() error {
+	isTable := p.accept("TABLE")
+	if !isTable && !p.accept("VIEW") && !p.accept("MATERIALIZED", "VIEW") {
+		return nil
+	}
+	p.accept("IF", "EXISTS")
+	for {
+		schema, name, err := p.qualifiedName()
+		if err != nil {
+			return err
+		}
+		key := strings.ToLower(dbstructs.QualifiedName(schema, name))
+		if isTable {
+			for i, table := range p.tables {
+				if strings.ToLower(table.QualifiedName()) == key {
+					p.tables = append(p.tables[:i], p.tables[i+1:]...)
+					break
+				}
+			}
+		} else {
+			for i, view := range p.views {
+				if strings.ToLower(view.QualifiedName()) == key {
+					p.views = append(p.views[:i], p.views[i+1:]...)
+					break
+				}
+			}
+		}
+		if !p.acceptSymbol(",") {
+			return nil
+		}
+	}
+}
+
+// skipTriggerBody moves to the END closing the body of a SQLite trigger, its
+// statements end with semicolons.
+func (p *parser) skipTriggerBody() {
+	for p.pos < len(p.tokens)-1 {
+		if p.peek().is("END") && p.peekAt(1).kind == tokenEnd {
+			return
+		}
+		p.pos++
+	}
+}
+
+func (p *parser) addTable(table *dbstructs.TableMetadata) {
+	for i, existing := range p.tables {
+		if strings.EqualFold(existing.QualifiedName(), table.QualifiedName()) {
+			p.tables[i] = table
+			return
+		}
+	}
+	p.tables = append(p.tables, table)
+}
+
+// table returns the table schema.name declared so far, or nil.
+func (p *parser) table(schema, name string) *dbstructs.TableMetadata {
+	for _, table := range p.tables {
+		if strings.EqualFold(table.Schema, schema) && strings.EqualFold(table.TableName, name) {
+			return table
+		}
+	}
+	return nil
+}
+
+// Token helpers
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) peekAt(offset int) token {
+	if p.pos+offset < len(p.tokens) {
+		return p.tokens[p.pos+offset]
+	}
+	return p.tokens[len(p.tokens)-1]
+}
+
+// next returns the next token and moves past it, but for the end of statement.
+func (p *parser) next() token {
+	t := p.peek()
+	if t.kind != tokenEnd {
+		p.pos++
+	}
+	return t
+}
+
+// accept moves past the keywords words when they come next.
+func (p *parser) accept(words ...string) bool {
+	for i, word := range words {
+		if !p.peekAt(i).is(word) {
+			return false
+		}
+	}
+	p.pos += len(words)
+	return true
+}
+
+func (p *parser) acceptSymbol(symbol string) bool {
+	if p.peek().isSymbol(symbol) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(words ...string) error {
+	if !p.accept(words...) {
+		return p.errorf("expected %s", strings.Join(words, " "))
+	}
+	return nil
+}
+
+func (p *parser) expectSymbol(symbol string) error {
+	if !p.acceptSymbol(symbol) {
+		return p.errorf("expected %s", symbol)
+	}
+	return nil
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	t := p.peek()
+	found := strconv.Quote(t.text)
+	if t.kind == tokenEnd {
+		found = "end of statement"
+	}
+	return fmt.Errorf("line %d: %s, found %s", t.line, fmt.Sprintf(format, args...), found)
+}
+
+func (p *parser) atEnd() bool {
+	return p.peek().kind == tokenEnd
+}
+
+// atElementEnd tells whether the next token ends an element of a list.
+func (p *parser) atElementEnd() bool {
+	t := p.peek()
+	return t.kind == tokenEnd || t.isSymbol(",") || t.isSymbol(")")
+}
+
+// skipToken moves past the next token, or past the parentheses it opens.
+func (p *parser) skipToken() {
+	if p.peek().isSymbol("(") {
+		p.pos = p.matching(p.pos) + 1
+		return
+	}
+	p.next()
+}
+
+// skipElement moves to the end of the current element of a list.
+func (p *parser) skipElement() {
+	for !p.atElementEnd() {
+		p.skipToken()
+	}
+}
+
+func (p *parser) skipStatement() {
+	for p.pos < len(p.tokens) && p.peek().kind != tokenEnd {
+		p.pos++
+	}
+	p.pos++
+}
+
+// matching returns the index of the parenthesis closing the one at open, or of
+// the end of statement.
+func (p *parser) matching(open int) int {
+	depth := 0
+	for i := open; i < len(p.tokens); i++ {
+		switch t := p.tokens[i]; {
+		case t.kind == tokenEnd:
+			return i - 1
+		case t.isSymbol("("):
+			depth++
+		case t.isSymbol(")"):
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return len(p.tokens) - 1
+}
+
+// text returns the script from token from to token to, excluded, as written.
+func (p *parser) text(from, to int) string {
+	if to <= from {
+		return ""
+	}
+	return strings.TrimSpace(p.src[p.tokens[from].start:p.tokens[to-1].end])
+}
+
+func (p *parser) identifier() (string, error) {
+	t := p.peek()
+	if t.kind != tokenWord && t.kind != tokenQuoted {
+		return "", p.errorf("expected a name")
+	}
+	p.pos++
+	return identifierText(p.dialect, t), nil
+}
+
+// qualifiedName reads [database.][schema.]name, the schema defaulting to the
+// one of the dialect. MySQL and SQLite have no schemas.
+func (p *parser) qualifiedName() (schema, name string, err error) {
+	if name, err = p.identifier(); err != nil {
+		return "", "", err
+	}
+	for p.peek().isSymbol(".") {
+		p.pos++
+		schema = name
+		if name, err = p.identifier(); err != nil {
+			return "", "", err
+		}
+	}
+	if p.dialect.defaultSchema == "" {
+		schema = ""
+	} else if schema == "" {
+		schema = p.dialect.defaultSchema
+	}
+	return schema, name, nil
+}
+
+func (p *parser) parenthesized() (string, error) {
+	if !p.peek().isSymbol("(") {
+		return "", p.errorf("expected (")
+	}
+	open := p.pos
+	close := p.matching(open)
+	if !p.tokens[close].isSymbol(")") {
+		return "", fmt.Errorf("line %d: unclosed parenthesis", p.tokens[open].line)
+	}
+	p.pos = close + 1
+	return p.text(open+1, close), nil
+}
+
+// element is the range of tokens of an element of a parenthesized list.
+type element struct {
+	from, to int
+}
+
+// elements reads a parenthesized list.
+func (p *parser) elements() ([]element, error) {
+	if !p.peek().isSymbol("(") {
+		return nil, p.errorf("expected (")
+	}
+	close := p.matching(p.pos)
+	if !p.tokens[close].isSymbol(")") {
+		return nil, fmt.Errorf("line %d: unclosed parenthesis", p.peek().line)
+	}
+	var elements []element
+	start := p.pos + 1
+	for i := start; i <= close; i++ {
+		if p.tokens[i].isSymbol("(") && i < close {
+			i = p.matching(i)
+			continue
+		}
+		if p.tokens[i].isSymbol(",") || i == close {
+			if i > start {
+				elements = append(elements, element{from: start, to: i})
+			}
+			start = i + 1
+		}
+	}
+	p.pos = close + 1
+	return elements, nil
+}
+
+// columnList reads (column, ...), ignoring orderings and MySQL prefix lengths.
+func (p *parser) columnList() ([]string, error) {
+	elements, err := p.elements()
+	if err != nil {
+		return nil, err
+	}
+	columns := []string{}
+	for _, element := range elements {
+		_, name := p.elementName(element)
+		columns = append(columns, name)
+	}
+	return columns, nil
+}
+
+// elementName returns the (possibly qualified) name an element starts with.
+func (p *parser) elementName(e element) (schema, name string) {
+	name = identifierText(p.dialect, p.tokens[e.from])
+	for i := e.from + 1; i+1 < e.to && p.tokens[i].isSymbol("."); i += 2 {
+		schema, name = name, identifierText(p.dialect, p.tokens[i+1])
+	}
+	if p.dialect.defaultSchema == "" {
+		schema = ""
+	} else if schema == "" {
+		schema = p.dialect.defaultSchema
+	}
+	return schema, name
+}
+
+// number reads an optionally signed integer.
+func (p *parser) number() *int64 {
+	sign := int64(1)
+	if p.acceptSymbol("-") {
+		sign = -1
+	}
+	value, err := strconv.ParseInt(p.peek().text, 10, 64)
+	if err != nil {
+		return nil
+	}
+	p.pos++
+	value *= sign
+	return &value
+}
+
+func isOneOf(t token, words []string) bool {
+	for _, word := range words {
+		if t.is(word) {
+			return true
+		}
+	}
+	return false
+}
+
+// unquote returns the content of a string literal, or literal as is.
+func unquote(d *dialect, literal string) string {
+	if len(literal) < 2 || literal[0] != '\'' || literal[len(literal)-1] != '\'' {
+		return literal
+	}
+	value := strings.ReplaceAll(literal[1:len(literal)-1], "''", "'")
+	if d.backslashEscapes {
+		value = strings.NewReplacer(`\\`, `\`, `\'`, `'`, `\"`, `"`, `\n`, "\n", `\t`, "\t").Replace(value)
+	}
+	return value
+}
@@ -0,0 +1,316 @@
+package ddl
+
+import (
+	"db_meta/dbstructs"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// typeDeclaration is a column type as declared: CHARACTER VARYING(255) has the
+// name "character varying" and the arguments ["255"].
+type typeDeclaration struct {
+	text      string   // as written
+	name      string   // lowercased words before the arguments, unquoted
+	arguments []string // between the parentheses
+	suffix    string   // lowercased words after the arguments: unsigned, with time zone...
+	array     bool
+	schema    string // of a qualified type name
+}
+
+// typeDeclaration reads the type declared by the tokens from to to.
+func (p *parser) typeDeclaration(from, to int) typeDeclaration {
+	declaration := typeDeclaration{text: p.text(from, to)}
+	var name, suffix []string
+	for i := from; i < to; i++ {
+		t := p.tokens[i]
+		switch {
+		case t.isSymbol("("):
+			close := p.matching(i)
+			if declaration.arguments == nil && !declaration.array {
+				start := i + 1
+				for j := start; j <= close; j++ {
+					if p.tokens[j].isSymbol("(") && j < close {
+						j = p.matching(j)
+						continue
+					}
+					if p.tokens[j].isSymbol(",") || j == close {
+						declaration.arguments = append(declaration.arguments, p.text(start, j))
+						start = j + 1
+					}
+				}
+			}
+			i = close
+		case t.isSymbol("[") || t.is("ARRAY"):
+			declaration.array = true
+		case t.isSymbol("."):
+			declaration.schema = strings.Join(name, " ")
+			name = nil
+		case t.kind == tokenWord || t.kind == tokenQuoted:
+			if declaration.arguments != nil {
+				suffix = append(suffix, strings.ToLower(t.text))
+			} else {
+				name = append(name, strings.ToLower(t.text))
+			}
+		}
+	}
+	declaration.name = strings.Join(name, " ")
+	declaration.suffix = strings.Join(suffix, " ")
+	// timestamp with time zone, numeric unsigned: the words after the name
+	// without arguments are a suffix as well
+	for _, word := range []string{" with", " without", " unsigned", " signed", " zerofill"} {
+		if i := strings.Index(declaration.name, word); i > 0 && declaration.suffix == "" {
+			declaration.name, declaration.suffix = declaration.name[:i], declaration.name[i+1:]
+		}
+	}
+	return declaration
+}
+
+// intArguments returns the arguments as integers, nil when one is not.
+func (d typeDeclaration) intArguments() []int {
+	var values []int
+	for _, argument := range d.arguments {
+		value, err := strconv.Atoi(strings.TrimSpace(argument))
+		if err != nil {
+			return nil
+		}
+		values = append(values, value)
+	}
+	return values
+}
+
+// setType sets the data type of column the way the connector of the dialect
+// reports it, telling whether the column is a PostgreSQL serial.
+func (p *parser) setType(column *dbstructs.Column, declaration typeDeclaration) bool {
+	column.CharacterLength, column.NumericPrecision, column.NumericScale = nil, nil, nil
+	column.UserType, column.EnumValues = "", nil
+	switch p.dialect.name {
+	case PostgreSQL:
+		return p.setPostgresType(column, declaration)
+	case MySQL:
+		setMySQLType(column, declaration)
+	case SQLServer:
+		setSQLServerType(column, declaration)
+	default:
+		// SQLite keeps the declared type
+		column.DataType = declaration.text
+		setModifiers(column, declaration, strings.Contains(declaration.name, "char") ||
+			strings.Contains(declaration.name, "text") || strings.Contains(declaration.name, "binary"))
+	}
+	return false
+}
+
+// setModifiers sets the length, or the precision and scale, given as arguments.
+func setModifiers(column *dbstructs.Column, declaration typeDeclaration, isCharacter bool) {
+	values := declaration.intArguments()
+	switch {
+	case len(values) == 0:
+	case isCharacter:
+		column.CharacterLength = &values[0]
+	case len(values) > 1:
+		column.NumericPrecision, column.NumericScale = &values[0], &values[1]
+	default:
+		column.NumericPrecision = &values[0]
+	}
+}
+
+func intPointer(value int) *int {
+	return &value
+}
+
+// postgresTypes maps the aliases of the PostgreSQL types to the names of
+// information_schema.columns.data_type.
+var postgresTypes = map[string]string{
+	"int": "integer", "int4": "integer", "integer": "integer", "serial": "integer", "serial4": "integer",
+	"int8": "bigint", "bigint": "bigint", "bigserial": "bigint", "serial8": "bigint",
+	"int2": "smallint", "smallint": "smallint", "smallserial": "smallint", "serial2": "smallint",
+	"float8": "double precision", "double precision": "double precision",
+	"float4": "real", "real": "real",
+	"decimal": "numeric", "numeric": "numeric",
+	"bool": "boolean", "boolean": "boolean",
+	"varchar": "character varying", "character varying": "character varying", "char varying": "character varying",
+	"char": "character", "character": "character", "bpchar": "character",
+	"varbit": "bit varying", "bit varying": "bit varying",
+	"timestamptz": "timestamp with time zone", "timetz": "time with time zone",
+}
+
+// postgresPrecisions are the numeric_precision of the PostgreSQL number types.
+var postgresPrecisions = map[string]int{"smallint": 16, "integer": 32, "bigint": 64, "real": 24, "double precision": 53}
+
+func (p *parser) setPostgresType(column *dbstructs.Column, declaration typeDeclaration) bool {
+	name := declaration.name
+	dataType, known := postgresTypes[name]
+	switch {
+	case known:
+	case name == "float":
+		dataType = "double precision"
+		if values := declaration.intArguments(); len(values) == 1 && values[0] <= 24 {
+			dataType = "real"
+		}
+	case name == "timestamp" || name == "time":
+		dataType = name + " without time zone"
+		if strings.HasPrefix(declaration.suffix, "with ") {
+			dataType = name + " with time zone"
+		}
+	default:
+		dataType = name
+		if typ := p.userType(declaration); typ != nil {
+			column.UserType = typ.QualifiedName()
+			dataType = "USER-DEFINED"
+			if typ.Kind == dbstructs.TypeKindDomain {
+				dataType = typ.BaseType
+			}
+		}
+	}
+	column.DataType = dataType
+	if declaration.array {
+		column.DataType = "ARRAY"
+		return false
+	}
+	switch {
+	case dataType == "character varying" || dataType == "character" || dataType == "bit" || dataType == "bit varying":
+		setModifiers(column, declaration, true)
+		if column.CharacterLength == nil && (dataType == "character" || dataType == "bit") {
+			column.CharacterLength = intPointer(1)
+		}
+	case dataType == "numeric":
+		setModifiers(column, declaration, false)
+		if column.NumericPrecision != nil && column.NumericScale == nil {
+			column.NumericScale = intPointer(0)
+		}
+	case postgresPrecisions[dataType] > 0:
+		column.NumericPrecision = intPointer(postgresPrecisions[dataType])
+		if dataType != "real" && dataType != "double precision" {
+			column.NumericScale = intPointer(0)
+		}
+	}
+	return strings.Contains(name, "serial")
+}
+
+// userType returns the type declared by CREATE TYPE or CREATE DOMAIN the
+// declaration names, or nil.
+func (p *parser) userType(declaration typeDeclaration) *dbstructs.TypeMetadata {
+	schema := declaration.schema
+	if schema == "" {
+		schema = p.dialect.defaultSchema
+	}
+	for _, typ := range p.types {
+		if strings.EqualFold(typ.Schema, schema) && strings.EqualFold(typ.TypeName, declaration.name) {
+			return typ
+		}
+	}
+	return nil
+}
+
+// mysqlTypes maps the aliases of the MySQL types to the names of
+// information_schema.columns.DATA_TYPE.
+var mysqlTypes = map[string]string{
+	"integer": "int", "bool": "tinyint", "boolean": "tinyint",
+	"dec": "decimal", "numeric": "decimal", "fixed": "decimal",
+	"double precision": "double", "real": "double",
+	"character": "char", "character varying": "varchar", "char varying": "varchar",
+	"national char": "char", "national varchar": "varchar", "nchar": "char", "nvarchar": "varchar",
+	"long": "mediumtext", "long varchar": "mediumtext", "serial": "bigint",
+}
+
+// mysqlPrecisions are the NUMERIC_PRECISION of the MySQL integer types.
+var mysqlPrecisions = map[string]int{"tinyint": 3, "smallint": 5, "mediumint": 7, "int": 10, "bigint": 19}
+
+func setMySQLType(column *dbstructs.Column, declaration typeDeclaration) {
+	dataType, known := mysqlTypes[declaration.name]
+	if !known {
+		dataType = declaration.name
+	}
+	column.DataType = dataType
+	switch {
+	case dataType == "enum" || dataType == "set":
+		column.EnumValues = []string{}
+		for _, argument := range declaration.arguments {
+			column.EnumValues = append(column.EnumValues, unquote(dialects[MySQL], strings.TrimSpace(argument)))
+		}
+	case strings.Contains(dataType, "char") || strings.Contains(dataType, "binary"):
+		setModifiers(column, declaration, true)
+		if column.CharacterLength == nil && (dataType == "char" || dataType == "binary") {
+			column.CharacterLength = intPointer(1)
+		}
+	case dataType == "decimal":
+		setModifiers(column, declaration, false)
+		if column.NumericPrecision == nil {
+			column.NumericPrecision = intPointer(10)
+		}
+		if column.NumericScale == nil {
+			column.NumericScale = intPointer(0)
+		}
+	case mysqlPrecisions[dataType] > 0:
+		precision := mysqlPrecisions[dataType]
+		if dataType == "bigint" && strings.Contains(declaration.suffix, "unsigned") {
+			precision = 20
+		}
+		column.NumericPrecision, column.NumericScale = intPointer(precision), intPointer(0)
+	case dataType == "float" || dataType == "double":
+		setModifiers(column, declaration, false)
+	}
+	if declaration.name == "serial" {
+		column.AutoIncrement = true
+		column.NotNull = true
+	}
+}
+
+// sqlServerTypes maps the ANSI names accepted by SQL Server to its own.
+var sqlServerTypes = map[string]string{
+	"integer": "int", "dec": "decimal", "double precision": "float",
+	"character": "char", "character varying": "varchar", "char varying": "varchar",
+	"national character": "nchar", "national char": "nchar",
+	"national character varying": "nvarchar", "national char varying": "nvarchar",
+	"rowversion": "timestamp",
+}
+
+// sqlServerPrecisions are the precision of the SQL Server number types.
+var sqlServerPrecisions = map[string]int{"tinyint": 3, "smallint": 5, "int": 10, "bigint": 19, "money": 19, "smallmoney": 10}
+
+func setSQLServerType(column *dbstructs.Column, declaration typeDeclaration) {
+	dataType, known := sqlServerTypes[declaration.name]
+	if !known {
+		dataType = declaration.name
+	}
+	column.DataType = dataType
+	switch {
+	case strings.Contains(dataType, "char") || strings.Contains(dataType, "binary"):
+		if len(declaration.arguments) == 1 && strings.EqualFold(strings.TrimSpace(declaration.arguments[0]), "max") {
+			column.CharacterLength = intPointer(-1)
+			break
+		}
+		setModifiers(column, declaration, true)
+		if column.CharacterLength == nil {
+			column.CharacterLength = intPointer(1)
+		}
+	case dataType == "decimal" || dataType == "numeric":
+		setModifiers(column, declaration, false)
+		if column.NumericPrecision == nil {
+			column.NumericPrecision = intPointer(18)
+		}
+		if column.NumericScale == nil {
+			column.NumericScale = intPointer(0)
+		}
+	case sqlServerPrecisions[dataType] > 0:
+		column.NumericPrecision = intPointer(sqlServerPrecisions[dataType])
+		column.NumericScale = intPointer(0)
+	case dataType == "float":
+		column.NumericPrecision = intPointer(53)
+		if values := declaration.intArguments(); len(values) == 1 && values[0] <= 24 {
+			column.NumericPrecision = intPointer(24)
+		}
+	}
+}
+
+// maxValue is the largest value of an integer type, the default maximum of the
+// sequences.
+func maxValue(dataType string) int64 {
+	switch dataType {
+	case "smallint":
+		return math.MaxInt16
+	case "integer", "int":
+		return math.MaxInt32
+	}
+	return math.MaxInt64
+}
@@ -41,7 +41,7 @@ func dfs1(node *dbstructs.NodeData, graph *dbstructs.GraphResponse, visited map[
 	visited[node.Name] = true
 	for _, edge := range graph.Edges {
 		targetNode := findNodeData(edge.Data.Target, graph)
-		if edge.Data.Source == node.Name && !visited[targetNode.Name] {
+		if edge.Data.Source == node.Name && targetNode != nil && !visited[targetNode.Name] {
 			dfs1(targetNode, graph, visited, stack)
 		}
 	}
@@ -51,10 +51,11 @@ func dfs1(node *dbstructs.NodeData, graph *dbstructs.GraphResponse, visited map[
 func dfs2(node *dbstructs.NodeData, graph *dbstructs.GraphResponse, visited map[string]bool, scc *[]string) {
 	visited[node.Name] = true
 	*scc = append(*scc, node.Name)
+	// graph is transposed, its edges are followed forward as in dfs1
 	for _, edge := range graph.Edges {
-		sourceNode := findNodeData(edge.Data.Source, graph)
-		if edge.Data.Target == node.Name && !visited[sourceNode.Name] {
-			dfs2(sourceNode, graph, visited, scc)
+		targetNode := findNodeData(edge.Data.Target, graph)
+		if edge.Data.Source == node.Name && targetNode != nil && !visited[targetNode.Name] {
+			dfs2(targetNode, graph, visited, scc)
 		}
 	}
 }
@@ -71,6 +72,8 @@ func transposeGraph(originalGraph *dbstructs.GraphResponse) *dbstructs.GraphResp
 			Data: &dbstructs.NodeData{
 				Name:       node.Data.Name,
 				ID:         node.Data.ID,
+				Schema:     node.Data.Schema,
+				Kind:       node.Data.Kind,
 				Columns:    node.Data.Columns,
 				PrimaryKey: node.Data.PrimaryKey,
 				Indexes:    node.Data.Indexes,
@@ -85,6 +88,7 @@ func transposeGraph(originalGraph *dbstructs.GraphResponse) *dbstructs.GraphResp
 				ID:     edge.Data.ID,
 				Source: edge.Data.Target,
 				Target: edge.Data.Source,
+				Kind:   edge.Data.Kind,
 			},
 		}
 		transposedGraph.Edges = append(transposedGraph.Edges, transposedEdge)
@@ -1,134 +1,813 @@
 package mysqlConnector
 
 import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"db_meta/databases/registry"
+	"db_meta/databases/sqlutil"
 	"db_meta/dbstructs"
 	"fmt"
 	"log"
+	"net"
 	"strings"
+	"time"
 
+	mysqlDriver "github.com/go-sql-driver/mysql"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 )
 
-type MySQLConnector struct{}
+type MySQLConnector struct {
+	Options registry.Options
 
-func (conn MySQLConnector) Connect(host, port, database, user, password string) (*gorm.DB, error) {
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local", user, password, host, port, database)
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	dial registry.DialFunc // SSH tunnel, direct connection when nil
+}
+
+// tunnelNetwork is the network of the SSH tunnels. The driver only takes custom
+// dialers by network name, so it is registered once and the dialer of each
+// connection comes with the context of its tunnelConnector.
+const tunnelNetwork = "sshtunnel"
+
+type tunnelDialKey struct{}
+
+// tunnelConnector passes dial to the dialer of tunnelNetwork.
+type tunnelConnector struct {
+	driver.Connector
+	dial registry.DialFunc
+}
+
+func (conn tunnelConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	return conn.Connector.Connect(context.WithValue(ctx, tunnelDialKey{}, conn.dial))
+}
+
+func init() {
+	mysqlDriver.RegisterDialContext(tunnelNetwork, func(ctx context.Context, addr string) (net.Conn, error) {
+		dial, ok := ctx.Value(tunnelDialKey{}).(registry.DialFunc)
+		if !ok {
+			return nil, fmt.Errorf("no SSH tunnel to dial %s", addr)
+		}
+		return dial(ctx, "tcp", addr)
+	})
+	registry.Register(registry.Driver{
+		Name:  "mysql",
+		Label: "MySQL",
+		Fields: append([]registry.Field{
+			registry.HostField,
+			registry.PortField("3306"),
+			registry.DatabaseField,
+			registry.UserField,
+			registry.PasswordField,
+		}, registry.AdvancedFields("user:password@tcp(localhost:3306)/database", true)...),
+		New: func() registry.Connector { return &MySQLConnector{} },
+	})
+}
+
+func (conn MySQLConnector) Connect(ctx context.Context, host, port, database, user, password string) (*gorm.DB, error) {
+	config, err := conn.config(host, port, database, user, password)
+	if err != nil {
+		log.Println("mysql.go:[1]", err)
+		return nil, err
+	}
+	tunneled := conn.dial != nil && config.Net == "tcp"
+	if tunneled {
+		if _, _, err := net.SplitHostPort(config.Addr); err != nil {
+			config.Addr = net.JoinHostPort(config.Addr, "3306")
+		}
+		config.Net = tunnelNetwork
+	}
+	// the connector keeps the tls.Config, which a DSN can't carry
+	driverConnector, err := mysqlDriver.NewConnector(config)
 	if err != nil {
 		log.Println("mysql.go:[1]", err)
 		return nil, err
 	}
+	if tunneled {
+		driverConnector = tunnelConnector{Connector: driverConnector, dial: conn.dial}
+	}
+	sqlDB := sql.OpenDB(driverConnector)
+	db, err := gorm.Open(mysql.New(mysql.Config{Conn: sqlDB, DSNConfig: config}), &gorm.Config{DisableAutomaticPing: true})
+	if err == nil {
+		err = sqlDB.PingContext(ctx)
+	}
+	if err != nil {
+		log.Println("mysql.go:[1]", err)
+		sqlDB.Close()
+		return nil, err
+	}
 	return db, nil
 }
 
-func (conn MySQLConnector) GetTableMetadata(db *gorm.DB) ([]*dbstructs.TableMetadata, error) {
-	tableNames, err := conn.GetTableNames(db)
+func (conn *MySQLConnector) SetOptions(options registry.Options) {
+	conn.Options = options
+}
+
+func (conn *MySQLConnector) SetDialer(dial registry.DialFunc) {
+	conn.dial = dial
+}
+
+// config parses Options.DSN, or builds the driver configuration of the
+// parameters, then applies the TLS, timeout and application name options.
+func (conn MySQLConnector) config(host, port, database, user, password string) (*mysqlDriver.Config, error) {
+	options := conn.Options
+	config := mysqlDriver.NewConfig()
+	if options.DSN != "" {
+		var err error
+		if config, err = mysqlDriver.ParseDSN(options.DSN); err != nil {
+			return nil, err
+		}
+	} else {
+		config.User = user
+		config.Passwd = password
+		config.Net = "tcp"
+		config.Addr = net.JoinHostPort(host, port)
+		config.DBName = database
+		config.Params = map[string]string{"charset": "utf8mb4"}
+		config.ParseTime = true
+	}
+
+	serverName, _, err := net.SplitHostPort(config.Addr)
+	if err != nil {
+		serverName = config.Addr
+	}
+	tlsConfig, err := options.TLSConfig(serverName)
+	if err != nil {
+		return nil, err
+	}
+	switch options.TLSMode {
+	case registry.TLSDisable:
+		config.TLS = nil
+		config.TLSConfig = "false"
+	case registry.TLSPrefer:
+		config.TLS = tlsConfig
+		config.AllowFallbackToPlaintext = true
+	case "":
+	default:
+		config.TLS = tlsConfig
+	}
+	if options.ConnectTimeout > 0 {
+		config.Timeout = time.Duration(options.ConnectTimeout) * time.Second
+	}
+	if options.ApplicationName != "" {
+		// performance_schema.session_connect_attrs, the attributes are comma separated
+		config.ConnectionAttributes = "program_name:" + strings.ReplaceAll(options.ApplicationName, ",", " ")
+	}
+	return config, nil
+}
+
+// GetServerVersion returns the version of the server, such as "8.0.35".
+func (conn MySQLConnector) GetServerVersion(ctx context.Context, db *gorm.DB) (string, error) {
+	var version string
+	if err := db.WithContext(ctx).Raw(`SELECT VERSION()`).Scan(&version).Error; err != nil {
+		return "", err
+	}
+	return version, nil
+}
+
+func (conn MySQLConnector) GetTableMetadata(ctx context.Context, db *gorm.DB) ([]*dbstructs.TableMetadata, error) {
+	tables, err := conn.GetTables(ctx, db)
 	if err != nil {
 		log.Println("Error fetching table names:", err)
 		return nil, err
 	}
+	if err := conn.loadTableMetadata(db.WithContext(ctx), tables, nil); err != nil {
+		return nil, err
+	}
+	return tables, nil
+}
 
+// GetTables lists the tables of the current database, only TableName is set.
+func (conn MySQLConnector) GetTables(ctx context.Context, db *gorm.DB) ([]*dbstructs.TableMetadata, error) {
+	tableNames, err := conn.GetTableNames(db.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
 	var tables []*dbstructs.TableMetadata
 	for _, tableName := range tableNames {
-		table := &dbstructs.TableMetadata{TableName: tableName}
+		tables = append(tables, &dbstructs.TableMetadata{TableName: tableName})
+	}
+	return tables, nil
+}
+
+// LoadTableMetadata fills in the columns, keys, indexes, constraints and
+// partitioning of tables, as listed by GetTables.
+func (conn MySQLConnector) LoadTableMetadata(ctx context.Context, db *gorm.DB, tables []*dbstructs.TableMetadata) error {
+	tableNames := make([]string, len(tables))
+	for i, table := range tables {
+		tableNames[i] = table.TableName
+	}
+	return conn.loadTableMetadata(db.WithContext(ctx), tables, tableNames)
+}
+
+// loadTableMetadata fills in tables from the rows of the current database, or of
+// tableNames only when set.
+func (conn MySQLConnector) loadTableMetadata(db *gorm.DB, tables []*dbstructs.TableMetadata, tableNames []string) error {
+	// Get columns
+	columns, err := conn.columnsByTable(db, tableNames)
+	if err != nil {
+		log.Println("mysql.go:[2]", err)
+		return err
+	}
+
+	// Get enum and set values
+	enumValues, err := conn.enumValuesByTable(db, tableNames)
+	if err != nil {
+		log.Println("mysql.go:[10]", err)
+		return err
+	}
+
+	// Get primary keys
+	primaryKeys, err := conn.primaryKeysByTable(db, tableNames)
+	if err != nil {
+		log.Println("mysql.go:[3]", err)
+		return err
+	}
 
-		// Get columns
-		var columns []*dbstructs.Column
-		result := db.Raw(`
+	// Get relationships
+	relationships, err := conn.relationshipsByTable(db, tableNames)
+	if err != nil {
+		log.Println("mysql.go:[5]", err)
+		return err
+	}
+
+	// Get indexes
+	indexes, err := conn.indexesByTable(db, tableNames)
+	if err != nil {
+		log.Println("Error fetching indexes:", err)
+		return err
+	}
+
+	// Get check constraints, information_schema.check_constraints only
+	// exists since MySQL 8.0.16 so older servers simply report none
+	checks, err := conn.checkConstraintsByTable(db, tableNames)
+	if err != nil {
+		log.Println("mysql.go:[9]", err)
+	}
+
+	// Get partitions
+	partitionings, err := conn.partitioningByTable(db, tableNames)
+	if err != nil {
+		log.Println("mysql.go:[14]", err)
+		return err
+	}
+
+	for _, table := range tables {
+		tableName := table.TableName
+		table.Columns = columns[tableName]
+		for _, column := range table.Columns {
+			column.EnumValues = enumValues[tableName][column.ColumnName]
+		}
+		table.PrimaryKey = primaryKeys[tableName]
+		table.Relationships = relationships[tableName]
+		table.Indexes = indexes[tableName]
+		table.CheckConstraints = checks[tableName]
+		for _, check := range table.CheckConstraints {
+			check.Columns = sqlutil.ReferencedNames(check.Expression, table.ColumnNames())
+		}
+		table.Partitioning = partitionings[tableName]
+	}
+
+	return nil
+}
+
+// columnsByTable returns the columns of the tables of the current database, or
+// of tableNames only when set, by table name.
+func (conn MySQLConnector) columnsByTable(db *gorm.DB, tableNames []string) (map[string][]*dbstructs.Column, error) {
+	var tableColumns []*dbstructs.TableColumn
+	result := db.Raw(`
         SELECT 
+            TABLE_SCHEMA as table_schema,
+            TABLE_NAME as table_name,
             COLUMN_NAME as column_name, 
             DATA_TYPE as data_type, 
             IS_NULLABLE = 'NO' as not_null,
             (SELECT COUNT(*) 
              FROM information_schema.statistics 
-             WHERE TABLE_NAME = ? 
+             WHERE TABLE_NAME = columns.TABLE_NAME 
              AND table_schema = DATABASE() 
              AND NON_UNIQUE = 0 
              AND COLUMN_NAME = columns.COLUMN_NAME
-            ) > 0 as is_unique
+            ) > 0 as is_unique,
+            COLUMN_DEFAULT as column_default,
+            EXTRA LIKE '%auto_increment%' as is_auto_increment,
+            GENERATION_EXPRESSION as generation_expression,
+            CHARACTER_MAXIMUM_LENGTH as character_maximum_length,
+            NUMERIC_PRECISION as numeric_precision,
+            NUMERIC_SCALE as numeric_scale,
+            ORDINAL_POSITION as ordinal_position
         FROM information_schema.columns
-        WHERE table_name = ? 
-        AND table_schema = DATABASE()`, tableName, tableName).Scan(&columns)
-		if result.Error != nil {
-			log.Println("mysql.go:[2]", result.Error)
-			return nil, result.Error
-		}
-		table.Columns = append(table.Columns, columns...)
-
-		// Get primary keys
-		var primaryKeys []string
-		rows, err := db.Raw(`
-                SELECT column_name
-                FROM information_schema.columns
-                WHERE table_name = ? AND column_key = 'PRI'
-        `, tableName).Rows()
-		if err != nil {
-			log.Println("mysql.go:[3]", err)
+        WHERE table_schema = DATABASE()
+        AND (? = 0 OR table_name IN ?)
+        ORDER BY TABLE_NAME, ORDINAL_POSITION`, len(tableNames), tableNames).Scan(&tableColumns)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	columns := make(map[string][]*dbstructs.Column)
+	for _, tableColumn := range tableColumns {
+		columns[tableColumn.TableName] = append(columns[tableColumn.TableName], &tableColumn.Column)
+	}
+
+	return columns, nil
+}
+
+// primaryKeysByTable returns the primary key columns of the tables of the
+// current database, or of tableNames only when set.
+func (conn MySQLConnector) primaryKeysByTable(db *gorm.DB, tableNames []string) (map[string][]string, error) {
+	rows, err := db.Raw(`
+            SELECT table_name, column_name
+            FROM information_schema.columns
+            WHERE table_schema = DATABASE() AND (? = 0 OR table_name IN ?) AND column_key = 'PRI'
+            ORDER BY table_name, ordinal_position
+    `, len(tableNames), tableNames).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	primaryKeys := make(map[string][]string)
+	for rows.Next() {
+		var table, pkColumn string
+		if err := rows.Scan(&table, &pkColumn); err != nil {
+			log.Println("mysql.go:[4]", err)
 			return nil, err
 		}
-		defer rows.Close()
+		primaryKeys[table] = append(primaryKeys[table], pkColumn)
+	}
+
+	return primaryKeys, nil
+}
+
+// GetRelationships returns the foreign keys declared on tableName, with their
+// columns in key order (composite keys included).
+func (conn MySQLConnector) GetRelationships(db *gorm.DB, tableName string) ([]*dbstructs.RelationshipMetadata, error) {
+	relationships, err := conn.relationshipsByTable(db, []string{tableName})
+	if err != nil {
+		return nil, err
+	}
+	return relationships[tableName], nil
+}
 
-		for rows.Next() {
-			var pkColumn string
-			if err := rows.Scan(&pkColumn); err != nil {
-				log.Println("mysql.go:[4]", err)
-				return nil, err
+// relationshipsByTable returns the foreign keys declared on the tables of the
+// current database, or on tableNames only when set, by table name.
+func (conn MySQLConnector) relationshipsByTable(db *gorm.DB, tableNames []string) (map[string][]*dbstructs.RelationshipMetadata, error) {
+	rows, err := db.Raw(`
+            SELECT
+                kcu.constraint_name,
+                kcu.table_name,
+                kcu.referenced_table_name,
+                GROUP_CONCAT(kcu.column_name ORDER BY kcu.ordinal_position) AS source_columns,
+                GROUP_CONCAT(kcu.referenced_column_name ORDER BY kcu.ordinal_position) AS target_columns,
+                rc.delete_rule,
+                rc.update_rule,
+                rc.match_option
+            FROM information_schema.key_column_usage kcu
+            INNER JOIN information_schema.referential_constraints rc
+                ON rc.constraint_schema = kcu.table_schema
+                AND rc.constraint_name = kcu.constraint_name
+            WHERE kcu.table_schema = (SELECT DATABASE()) AND (? = 0 OR kcu.table_name IN ?)
+                AND kcu.referenced_table_name IS NOT NULL
+            GROUP BY kcu.constraint_name, kcu.table_name, kcu.referenced_table_name,
+                rc.delete_rule, rc.update_rule, rc.match_option
+            ORDER BY kcu.table_name, kcu.constraint_name
+    `, len(tableNames), tableNames).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	relationships := make(map[string][]*dbstructs.RelationshipMetadata)
+	for rows.Next() {
+		var rel dbstructs.RelationshipMetadata
+		var sourceColumns, targetColumns string
+		// MySQL constraints are never deferrable
+		if err := rows.Scan(&rel.Conname, &rel.SourceTableName, &rel.RelatedTableName, &sourceColumns, &targetColumns,
+			&rel.OnDelete, &rel.OnUpdate, &rel.Match); err != nil {
+			return nil, err
+		}
+		rel.SourceColumns = strings.Split(sourceColumns, ",")
+		rel.TargetColumns = strings.Split(targetColumns, ",")
+		relationships[rel.SourceTableName] = append(relationships[rel.SourceTableName], &rel)
+	}
+
+	return relationships, nil
+}
+
+// indexStatistic is a row of information_schema.statistics. The EXPRESSION
+// column only exists since MySQL 8.0.13, selecting * keeps older servers working.
+type indexStatistic struct {
+	TableName  string  `gorm:"column:TABLE_NAME"`
+	IndexName  string  `gorm:"column:INDEX_NAME"`
+	NonUnique  bool    `gorm:"column:NON_UNIQUE"`
+	IndexType  string  `gorm:"column:INDEX_TYPE"`
+	ColumnName *string `gorm:"column:COLUMN_NAME"`
+	Expression *string `gorm:"column:EXPRESSION"`
+	Collation  *string `gorm:"column:COLLATION"`
+}
+
+// GetIndexes returns the indexes of tableName, keys being columns or, for
+// functional key parts, expressions.
+func (conn MySQLConnector) GetIndexes(db *gorm.DB, tableName string) ([]*dbstructs.Index, error) {
+	indexes, err := conn.indexesByTable(db, []string{tableName})
+	if err != nil {
+		return nil, err
+	}
+	return indexes[tableName], nil
+}
+
+// indexesByTable returns the indexes of the tables of the current database, or
+// of tableNames only when set, by table name.
+func (conn MySQLConnector) indexesByTable(db *gorm.DB, tableNames []string) (map[string][]*dbstructs.Index, error) {
+	var statistics []indexStatistic
+	result := db.Raw(`
+            SELECT *
+            FROM information_schema.statistics
+            WHERE table_schema = (SELECT DATABASE()) AND (? = 0 OR table_name IN ?)
+            ORDER BY table_name, index_name, seq_in_index
+    `, len(tableNames), tableNames).Scan(&statistics)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	indexes := make(map[string][]*dbstructs.Index)
+	var index *dbstructs.Index
+	var indexTable string
+	for _, statistic := range statistics {
+		if index == nil || indexTable != statistic.TableName || index.Name != statistic.IndexName {
+			index = &dbstructs.Index{
+				Name:    statistic.IndexName,
+				Columns: []string{},
+				Unique:  !statistic.NonUnique,
+				Primary: statistic.IndexName == "PRIMARY",
+				Method:  strings.ToLower(statistic.IndexType),
 			}
-			primaryKeys = append(primaryKeys, pkColumn)
+			indexTable = statistic.TableName
+			indexes[indexTable] = append(indexes[indexTable], index)
 		}
 
-		table.PrimaryKey = primaryKeys
+		key := &dbstructs.IndexKey{Descending: statistic.Collation != nil && *statistic.Collation == "D"}
+		if statistic.ColumnName != nil {
+			key.Column = *statistic.ColumnName
+			index.Columns = append(index.Columns, key.Column)
+		} else if statistic.Expression != nil {
+			key.Expression = *statistic.Expression
+			index.Columns = append(index.Columns, key.Expression)
+		}
+		index.Keys = append(index.Keys, key)
+	}
 
-		// Get relationships
-		var relationships []*dbstructs.RelationshipMetadata
-		result = db.Raw(`
-                SELECT constraint_name AS conname, referenced_table_name AS confrelid
-                FROM information_schema.key_column_usage
-                WHERE table_name = ? AND referenced_table_name IS NOT NULL`, tableName).Scan(&relationships)
-		if result.Error != nil {
-			log.Println("mysql.go:[5]", result.Error)
-			return nil, result.Error
+	return indexes, nil
+}
+
+// GetEnumValues returns the values of the enum and set columns of tableName, by
+// column name. MySQL declares them inline, as in enum('small','large').
+func (conn MySQLConnector) GetEnumValues(db *gorm.DB, tableName string) (map[string][]string, error) {
+	enumValues, err := conn.enumValuesByTable(db, []string{tableName})
+	if err != nil {
+		return nil, err
+	}
+	if enumValues[tableName] == nil {
+		return make(map[string][]string), nil
+	}
+	return enumValues[tableName], nil
+}
+
+// enumValuesByTable returns the values of the enum and set columns of the tables
+// of the current database, or of tableNames only when set, by table then column.
+func (conn MySQLConnector) enumValuesByTable(db *gorm.DB, tableNames []string) (map[string]map[string][]string, error) {
+	rows, err := db.Raw(`
+            SELECT TABLE_NAME, COLUMN_NAME, COLUMN_TYPE
+            FROM information_schema.columns
+            WHERE table_schema = DATABASE()
+            AND (? = 0 OR table_name IN ?)
+            AND DATA_TYPE IN ('enum', 'set')`, len(tableNames), tableNames).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	enumValues := make(map[string]map[string][]string)
+	for rows.Next() {
+		var table, columnName, columnType string
+		if err := rows.Scan(&table, &columnName, &columnType); err != nil {
+			return nil, err
+		}
+		if enumValues[table] == nil {
+			enumValues[table] = make(map[string][]string)
+		}
+		enumValues[table][columnName] = parseEnumValues(columnType)
+	}
+
+	return enumValues, nil
+}
+
+// parseEnumValues reads the quoted values of enum('a','b'), quotes in values
+// are doubled or escaped with a backslash.
+func parseEnumValues(columnType string) []string {
+	var values []string
+	var value strings.Builder
+	inQuote := false
+	for i := 0; i < len(columnType); i++ {
+		char := columnType[i]
+		switch {
+		case !inQuote && char == '\'':
+			inQuote = true
+			value.Reset()
+		case inQuote && char == '\'' && i+1 < len(columnType) && columnType[i+1] == '\'':
+			value.WriteByte('\'')
+			i++
+		case inQuote && char == '\'':
+			inQuote = false
+			values = append(values, value.String())
+		case inQuote && char == '\\' && i+1 < len(columnType):
+			i++
+			value.WriteByte(columnType[i])
+		case inQuote:
+			value.WriteByte(char)
 		}
-		table.Relationships = relationships
+	}
+	return values
+}
+
+// GetPartitioning returns the partitioning of tableName, or nil when it is not
+// partitioned. Subpartitions are not reported.
+func (conn MySQLConnector) GetPartitioning(db *gorm.DB, tableName string) (*dbstructs.Partitioning, error) {
+	partitionings, err := conn.partitioningByTable(db, []string{tableName})
+	if err != nil {
+		return nil, err
+	}
+	return partitionings[tableName], nil
+}
 
-		// Get indexes
-		indexes, err := conn.GetIndexes(db, tableName)
-		if err != nil {
-			log.Println("Error fetching indexes:", err)
+// partitioningByTable returns the partitioning of the partitioned tables of the
+// current database, or of tableNames only when set, by table name.
+func (conn MySQLConnector) partitioningByTable(db *gorm.DB, tableNames []string) (map[string]*dbstructs.Partitioning, error) {
+	rows, err := db.Raw(`
+            SELECT TABLE_NAME, PARTITION_NAME, PARTITION_METHOD, COALESCE(PARTITION_EXPRESSION, ''), COALESCE(PARTITION_DESCRIPTION, '')
+            FROM information_schema.partitions
+            WHERE table_schema = DATABASE()
+            AND (? = 0 OR table_name IN ?)
+            AND PARTITION_NAME IS NOT NULL
+            AND (SUBPARTITION_NAME IS NULL OR SUBPARTITION_ORDINAL_POSITION = 1)
+            ORDER BY TABLE_NAME, PARTITION_ORDINAL_POSITION`, len(tableNames), tableNames).Rows()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	partitionings := make(map[string]*dbstructs.Partitioning)
+	for rows.Next() {
+		var table, name, method, expression, description string
+		if err := rows.Scan(&table, &name, &method, &expression, &description); err != nil {
 			return nil, err
 		}
-		table.Indexes = indexes
-		tables = append(tables, table)
+		partitioning := partitionings[table]
+		if partitioning == nil {
+			partitioning = &dbstructs.Partitioning{Strategy: strings.ToLower(method), Key: expression}
+			partitionings[table] = partitioning
+		}
+		partitioning.Partitions = append(partitioning.Partitions, &dbstructs.Partition{
+			Name:  name,
+			Bound: partitionBound(method, description),
+		})
 	}
 
-	return tables, nil
+	return partitionings, nil
 }
 
-func (conn MySQLConnector) GetIndexes(db *gorm.DB, tableName string) ([]*dbstructs.Index, error) {
-	var indexes []*dbstructs.Index
+// partitionBound spells the bound of a partition as in CREATE TABLE, from its
+// PARTITION_DESCRIPTION. HASH and KEY partitions have none.
+func partitionBound(method, description string) string {
+	switch {
+	case description == "":
+		return ""
+	case strings.HasPrefix(method, "RANGE") && description == "MAXVALUE":
+		return "VALUES LESS THAN MAXVALUE"
+	case strings.HasPrefix(method, "RANGE"):
+		return "VALUES LESS THAN (" + description + ")"
+	case strings.HasPrefix(method, "LIST"):
+		return "VALUES IN (" + description + ")"
+	default:
+		return ""
+	}
+}
+
+// GetCheckConstraints returns the CHECK constraints of tableName, MySQL does not
+// record their columns so they are read from the expressions.
+func (conn MySQLConnector) GetCheckConstraints(db *gorm.DB, tableName string, columnNames []string) ([]*dbstructs.CheckConstraint, error) {
+	checks, err := conn.checkConstraintsByTable(db, []string{tableName})
+	if err != nil {
+		return nil, err
+	}
+	for _, check := range checks[tableName] {
+		check.Columns = sqlutil.ReferencedNames(check.Expression, columnNames)
+	}
+	return checks[tableName], nil
+}
+
+// checkConstraintsByTable returns the CHECK constraints of the tables of the
+// current database, or of tableNames only when set, without their columns.
+func (conn MySQLConnector) checkConstraintsByTable(db *gorm.DB, tableNames []string) (map[string][]*dbstructs.CheckConstraint, error) {
 	rows, err := db.Raw(`
-            SELECT index_name, GROUP_CONCAT(column_name ORDER BY seq_in_index) AS columns
-            FROM information_schema.statistics
-            WHERE table_name = ? AND table_schema = (SELECT DATABASE())
-            GROUP BY index_name
-    `, tableName).Rows()
+            SELECT tc.TABLE_NAME, cc.CONSTRAINT_NAME, cc.CHECK_CLAUSE
+            FROM information_schema.check_constraints cc
+            INNER JOIN information_schema.table_constraints tc
+                ON tc.CONSTRAINT_SCHEMA = cc.CONSTRAINT_SCHEMA
+                AND tc.CONSTRAINT_NAME = cc.CONSTRAINT_NAME
+            WHERE tc.CONSTRAINT_TYPE = 'CHECK'
+            AND tc.TABLE_SCHEMA = DATABASE()
+            AND (? = 0 OR tc.TABLE_NAME IN ?)
+            ORDER BY tc.TABLE_NAME, cc.CONSTRAINT_NAME`, len(tableNames), tableNames).Rows()
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
+	checks := make(map[string][]*dbstructs.CheckConstraint)
 	for rows.Next() {
-		var index dbstructs.Index
-		var colNames string
-		if err := rows.Scan(&index.Name, &colNames); err != nil {
+		var table string
+		var check dbstructs.CheckConstraint
+		if err := rows.Scan(&table, &check.Name, &check.Expression); err != nil {
 			return nil, err
 		}
-		index.Columns = strings.Split(colNames, ",") // Split the string into a slice
-		indexes = append(indexes, &index)
+		checks[table] = append(checks[table], &check)
 	}
 
-	return indexes, nil
+	return checks, nil
+}
+
+// GetViewMetadata returns the views of the current database. MySQL 5.7 has no
+// dependency catalog, dependencies are read from the view definitions.
+func (conn MySQLConnector) GetViewMetadata(ctx context.Context, db *gorm.DB) ([]*dbstructs.ViewMetadata, error) {
+	db = db.WithContext(ctx)
+	var views []*dbstructs.ViewMetadata
+	rows, err := db.Raw(`
+            SELECT table_name, view_definition
+            FROM information_schema.views
+            WHERE table_schema = (SELECT DATABASE())
+            ORDER BY table_name`).Rows()
+	if err != nil {
+		log.Println("mysql.go:[7]", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		view := &dbstructs.ViewMetadata{}
+		if err := rows.Scan(&view.ViewName, &view.Definition); err != nil {
+			return nil, err
+		}
+		views = append(views, view)
+	}
+	rows.Close()
+
+	candidates, err := conn.GetTableNames(db)
+	if err != nil {
+		return nil, err
+	}
+	for _, view := range views {
+		candidates = append(candidates, view.ViewName)
+	}
+
+	var viewColumns []*dbstructs.TableColumn
+	result := db.Raw(`
+            SELECT 
+                c.TABLE_NAME as table_name,
+                c.COLUMN_NAME as column_name, 
+                c.DATA_TYPE as data_type, 
+                c.IS_NULLABLE = 'NO' as not_null,
+                false as is_unique,
+                c.CHARACTER_MAXIMUM_LENGTH as character_maximum_length,
+                c.NUMERIC_PRECISION as numeric_precision,
+                c.NUMERIC_SCALE as numeric_scale,
+                c.ORDINAL_POSITION as ordinal_position
+            FROM information_schema.columns c
+            INNER JOIN information_schema.views v
+                ON v.TABLE_SCHEMA = c.TABLE_SCHEMA AND v.TABLE_NAME = c.TABLE_NAME
+            WHERE c.TABLE_SCHEMA = DATABASE()
+            ORDER BY c.TABLE_NAME, c.ORDINAL_POSITION`).Scan(&viewColumns)
+	if result.Error != nil {
+		log.Println("mysql.go:[8]", result.Error)
+		return nil, result.Error
+	}
+	columns := make(map[string][]*dbstructs.Column)
+	for _, viewColumn := range viewColumns {
+		columns[viewColumn.TableName] = append(columns[viewColumn.TableName], &viewColumn.Column)
+	}
+
+	for _, view := range views {
+		view.Columns = columns[view.ViewName]
+		for _, name := range sqlutil.ReferencedNames(view.Definition, candidates) {
+			if name != view.ViewName {
+				view.Dependencies = append(view.Dependencies, name)
+			}
+		}
+	}
+
+	return views, nil
+}
+
+// GetTriggerMetadata returns the triggers of the current database. MySQL
+// triggers fire on a single event and for each row.
+func (conn MySQLConnector) GetTriggerMetadata(ctx context.Context, db *gorm.DB) ([]*dbstructs.TriggerMetadata, error) {
+	db = db.WithContext(ctx)
+	var triggers []*dbstructs.TriggerMetadata
+	rows, err := db.Raw(`
+            SELECT TRIGGER_NAME, EVENT_OBJECT_TABLE, ACTION_TIMING, EVENT_MANIPULATION, ACTION_ORIENTATION, ACTION_STATEMENT
+            FROM information_schema.triggers
+            WHERE TRIGGER_SCHEMA = (SELECT DATABASE())
+            ORDER BY EVENT_OBJECT_TABLE, TRIGGER_NAME`).Rows()
+	if err != nil {
+		log.Println("mysql.go:[11]", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event string
+		trigger := &dbstructs.TriggerMetadata{}
+		if err := rows.Scan(&trigger.TriggerName, &trigger.TableName, &trigger.Timing, &event, &trigger.Orientation, &trigger.Body); err != nil {
+			return nil, err
+		}
+		trigger.Events = []string{event}
+		triggers = append(triggers, trigger)
+	}
+	rows.Close()
+
+	candidates, err := conn.GetTableNames(db)
+	if err != nil {
+		return nil, err
+	}
+	for _, trigger := range triggers {
+		trigger.References = sqlutil.ReferencedNames(trigger.Body, candidates)
+	}
+
+	return triggers, nil
+}
+
+// GetRoutineMetadata returns the stored procedures and functions of the current
+// database.
+func (conn MySQLConnector) GetRoutineMetadata(ctx context.Context, db *gorm.DB) ([]*dbstructs.RoutineMetadata, error) {
+	db = db.WithContext(ctx)
+	var routines []*dbstructs.RoutineMetadata
+	bySpecificName := make(map[string]*dbstructs.RoutineMetadata)
+	rows, err := db.Raw(`
+            SELECT SPECIFIC_NAME, ROUTINE_NAME, LOWER(ROUTINE_TYPE), COALESCE(DTD_IDENTIFIER, ''), ROUTINE_BODY,
+                COALESCE(ROUTINE_DEFINITION, '')
+            FROM information_schema.routines
+            WHERE ROUTINE_SCHEMA = (SELECT DATABASE())
+            ORDER BY ROUTINE_NAME`).Rows()
+	if err != nil {
+		log.Println("mysql.go:[12]", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var specificName string
+		routine := &dbstructs.RoutineMetadata{}
+		if err := rows.Scan(&specificName, &routine.RoutineName, &routine.Kind, &routine.ReturnType, &routine.Language,
+			&routine.Body); err != nil {
+			return nil, err
+		}
+		bySpecificName[specificName] = routine
+		routines = append(routines, routine)
+	}
+	rows.Close()
+
+	// Position 0 is the return value of a function
+	rows, err = db.Raw(`
+            SELECT SPECIFIC_NAME, COALESCE(PARAMETER_NAME, ''), DTD_IDENTIFIER, COALESCE(PARAMETER_MODE, 'IN')
+            FROM information_schema.parameters
+            WHERE SPECIFIC_SCHEMA = (SELECT DATABASE()) AND ORDINAL_POSITION > 0
+            ORDER BY SPECIFIC_NAME, ORDINAL_POSITION`).Rows()
+	if err != nil {
+		log.Println("mysql.go:[13]", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var specificName string
+		argument := &dbstructs.RoutineArgument{}
+		if err := rows.Scan(&specificName, &argument.Name, &argument.DataType, &argument.Mode); err != nil {
+			return nil, err
+		}
+		if routine, ok := bySpecificName[specificName]; ok {
+			routine.Arguments = append(routine.Arguments, argument)
+		}
+	}
+	rows.Close()
+
+	candidates, err := conn.GetTableNames(db)
+	if err != nil {
+		return nil, err
+	}
+	for _, routine := range routines {
+		routine.References = sqlutil.ReferencedNames(routine.Body, candidates)
+	}
+
+	return routines, nil
 }
 
 func (conn MySQLConnector) GetTableNames(db *gorm.DB) ([]string, error) {
@@ -1,7 +1,13 @@
 package mysqlConnector
 
 import (
+	"context"
+	"db_meta/databases/registry"
+	"db_meta/databases/tlsfixture"
+	"errors"
+	"net"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -10,6 +16,86 @@ func TestPostgresConnector_Connect_error(t *testing.T) {
 	connector := MySQLConnector{}
 
 	// Test with invalid params
-	_, err := connector.Connect("invalidHost", "invalidPort", "invalidDatabase", "invalidUser", "invalidPassword")
+	_, err := connector.Connect(context.Background(), "invalidHost", "invalidPort", "invalidDatabase", "invalidUser", "invalidPassword")
 	assert.Error(t, err, "Invalid parameters should fail to connect")
 }
+
+func TestParseEnumValues(t *testing.T) {
+	assert.Equal(t, []string{"small", "large"}, parseEnumValues("enum('small','large')"))
+	assert.Equal(t, []string{"it's", "a,b", ""}, parseEnumValues("set('it''s','a,b','')"))
+	assert.Nil(t, parseEnumValues("varchar(10)"))
+}
+
+func TestPartitionBound(t *testing.T) {
+	assert.Equal(t, "VALUES LESS THAN (2020)", partitionBound("RANGE", "2020"))
+	assert.Equal(t, "VALUES LESS THAN MAXVALUE", partitionBound("RANGE", "MAXVALUE"))
+	assert.Equal(t, "VALUES LESS THAN ('m',10)", partitionBound("RANGE COLUMNS", "'m',10"))
+	assert.Equal(t, "VALUES IN (1,3,5)", partitionBound("LIST", "1,3,5"))
+	assert.Empty(t, partitionBound("HASH", ""))
+}
+
+func TestMySQLConnector_config(t *testing.T) {
+	connector := MySQLConnector{}
+	config, err := connector.config("localhost", "3306", "testdb", "user", "secret")
+	assert.NoError(t, err)
+	assert.Equal(t, "user:secret@tcp(localhost:3306)/testdb?parseTime=true&charset=utf8mb4", config.FormatDSN())
+
+	// the DSN replaces the parameters, the options still apply
+	connector.Options = registry.Options{DSN: "user:secret@tcp(db.example.com:3307)/testdb?tls=false", TLSMode: registry.TLSRequire, ConnectTimeout: 5, ApplicationName: "db_meta"}
+	config, err = connector.config("ignored", "1", "ignored", "ignored", "ignored")
+	assert.NoError(t, err)
+	assert.Equal(t, "db.example.com:3307", config.Addr)
+	assert.True(t, config.TLS.InsecureSkipVerify)
+	assert.Equal(t, 5*time.Second, config.Timeout)
+	assert.Equal(t, "program_name:db_meta", config.ConnectionAttributes)
+
+	connector.Options = registry.Options{TLSMode: registry.TLSPrefer}
+	config, err = connector.config("localhost", "3306", "testdb", "user", "secret")
+	assert.NoError(t, err)
+	assert.True(t, config.AllowFallbackToPlaintext)
+}
+
+func TestMySQLConnector_Connect_tls(t *testing.T) {
+	certs := tlsfixture.NewCertificates(t, "127.0.0.1")
+	server := tlsfixture.NewServer(t, certs, tlsfixture.MySQLPreamble)
+
+	connector := MySQLConnector{Options: registry.Options{
+		TLSMode:        registry.TLSVerifyFull,
+		CAFile:         certs.CAFile,
+		CertFile:       certs.ClientCertFile,
+		KeyFile:        certs.ClientKeyFile,
+		ConnectTimeout: 5,
+	}}
+	// the fixture closes the connection after the handshake
+	_, err := connector.Connect(context.Background(), server.Host, server.Port, "testdb", "user", "password")
+	assert.Error(t, err)
+	handshake, ok := server.Handshake()
+	assert.True(t, ok, "the client should start TLS")
+	assert.NoError(t, handshake.Err)
+	assert.Equal(t, tlsfixture.ClientCommonName, handshake.ClientCommonName)
+
+	// server certificate signed by another CA
+	connector.Options.CAFile = tlsfixture.NewCertificates(t, "127.0.0.1").CAFile
+	_, err = connector.Connect(context.Background(), server.Host, server.Port, "testdb", "user", "password")
+	assert.ErrorContains(t, err, "certificate")
+	handshake, ok = server.Handshake()
+	assert.True(t, ok)
+	assert.Error(t, handshake.Err)
+}
+
+func TestMySQLConnector_Connect_tunnel(t *testing.T) {
+	// each connection dials through its own tunnel
+	dialed := make(map[string]string)
+	connect := func(tunnel, host string) error {
+		connector := MySQLConnector{}
+		connector.SetDialer(func(ctx context.Context, network, addr string) (net.Conn, error) {
+			dialed[tunnel] = addr
+			return nil, errors.New("tunnel closed")
+		})
+		_, err := connector.Connect(context.Background(), host, "3306", "testdb", "user", "password")
+		return err
+	}
+	assert.ErrorContains(t, connect("first", "db1.internal"), "tunnel closed")
+	assert.ErrorContains(t, connect("second", "db2.internal"), "tunnel closed")
+	assert.Equal(t, map[string]string{"first": "db1.internal:3306", "second": "db2.internal:3306"}, dialed)
+}
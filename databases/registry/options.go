@@ -0,0 +1,136 @@
+package registry
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"db_meta/databases/sshtunnel"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+)
+
+// TLS modes of Options, named after the libpq sslmode values
+const (
+	TLSDisable    = "disable"     // plain connection
+	TLSPrefer     = "prefer"      // TLS when the server offers it, certificate not verified
+	TLSRequire    = "require"     // TLS, certificate not verified
+	TLSVerifyCA   = "verify-ca"   // TLS, certificate signed by the CA
+	TLSVerifyFull = "verify-full" // verify-ca and the certificate matches the host name
+)
+
+// TLSModes lists the TLS modes, from the least to the most secure.
+var TLSModes = []string{TLSDisable, TLSPrefer, TLSRequire, TLSVerifyCA, TLSVerifyFull}
+
+// Options are the advanced connection settings, the zero value keeps the
+// defaults of the driver.
+type Options struct {
+	// DSN is a full connection string or URL in the format of the driver. It
+	// replaces host, port, database, user and password, the options below still
+	// apply on top of it.
+	DSN string `json:"dsn"`
+
+	TLSMode  string `json:"tlsMode"`  // one of TLSModes, driver default when empty
+	CAFile   string `json:"caFile"`   // PEM file of the CA signing the server certificate, system CAs when empty
+	CertFile string `json:"certFile"` // PEM client certificate, along with KeyFile
+	KeyFile  string `json:"keyFile"`
+
+	ConnectTimeout  int    `json:"connectTimeout"`  // seconds, driver default when 0
+	ApplicationName string `json:"applicationName"` // reported to the server
+
+	// SSH is the chain of SSH servers the database is reached through, a
+	// direct connection without hops.
+	SSH sshtunnel.Config `json:"ssh"`
+}
+
+// DialFunc opens the network connections of a connector, the SSH tunnel
+// resolving and dialing addr from its last server.
+type DialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+// Validate checks the TLS mode, that the certificate files go together and the
+// SSH hops.
+func (o Options) Validate() error {
+	if o.TLSMode != "" && !contains(TLSModes, o.TLSMode) {
+		return fmt.Errorf("unknown TLS mode %q", o.TLSMode)
+	}
+	if (o.CertFile == "") != (o.KeyFile == "") {
+		return errors.New("the client certificate and its key must be given together")
+	}
+	if o.ConnectTimeout < 0 {
+		return errors.New("the connect timeout can't be negative")
+	}
+	return o.SSH.Validate()
+}
+
+// TLSConfig builds the client TLS configuration of the mode for serverName, nil
+// for TLSDisable and the empty mode. TLSPrefer is not verified, as TLSRequire.
+func (o Options) TLSConfig(serverName string) (*tls.Config, error) {
+	if o.TLSMode == "" || o.TLSMode == TLSDisable {
+		return nil, nil
+	}
+	config := &tls.Config{ServerName: serverName}
+	if o.CertFile != "" {
+		certificate, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{certificate}
+	}
+
+	var roots *x509.CertPool
+	if o.CAFile != "" {
+		pem, err := os.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificate found in %s", o.CAFile)
+		}
+	}
+
+	switch o.TLSMode {
+	case TLSPrefer, TLSRequire:
+		config.InsecureSkipVerify = true
+	case TLSVerifyCA:
+		// the chain is checked by hand, without the host name
+		config.InsecureSkipVerify = true
+		config.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			return verifyChain(rawCerts, roots)
+		}
+	case TLSVerifyFull:
+		config.RootCAs = roots
+	}
+	return config, nil
+}
+
+func verifyChain(rawCerts [][]byte, roots *x509.CertPool) error {
+	if len(rawCerts) == 0 {
+		return errors.New("the server sent no certificate")
+	}
+	intermediates := x509.NewCertPool()
+	var leaf *x509.Certificate
+	for i, raw := range rawCerts {
+		certificate, err := x509.ParseCertificate(raw)
+		if err != nil {
+			return err
+		}
+		if i == 0 {
+			leaf = certificate
+		} else {
+			intermediates.AddCert(certificate)
+		}
+	}
+	_, err := leaf.Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates})
+	return err
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
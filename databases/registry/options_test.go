@@ -0,0 +1,67 @@
+package registry
+
+import (
+	"crypto/tls"
+	"db_meta/databases/tlsfixture"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptions_Validate(t *testing.T) {
+	assert.NoError(t, Options{}.Validate())
+	assert.NoError(t, Options{TLSMode: TLSVerifyFull, CertFile: "client.pem", KeyFile: "client.key"}.Validate())
+	assert.EqualError(t, Options{TLSMode: "always"}.Validate(), `unknown TLS mode "always"`)
+	assert.Error(t, Options{CertFile: "client.pem"}.Validate())
+	assert.Error(t, Options{ConnectTimeout: -1}.Validate())
+}
+
+// handshake starts TLS with the fixture server using the configuration of
+// options, and returns what the server saw along with the client error.
+func handshake(t *testing.T, server *tlsfixture.Server, options Options, serverName string) (tlsfixture.Handshake, error) {
+	config, err := options.TLSConfig(serverName)
+	assert.NoError(t, err)
+	conn, err := net.Dial("tcp", net.JoinHostPort(server.Host, server.Port))
+	assert.NoError(t, err)
+	defer conn.Close()
+
+	// PostgreSQL SSLRequest, answered with 'S'
+	_, err = conn.Write([]byte{0, 0, 0, 8, 4, 210, 22, 47})
+	assert.NoError(t, err)
+	_, err = conn.Read(make([]byte, 1))
+	assert.NoError(t, err)
+
+	clientErr := tls.Client(conn, config).Handshake()
+	serverSide, ok := server.Handshake()
+	assert.True(t, ok)
+	return serverSide, clientErr
+}
+
+func TestOptions_TLSConfig(t *testing.T) {
+	config, err := Options{TLSMode: TLSDisable}.TLSConfig("localhost")
+	assert.NoError(t, err)
+	assert.Nil(t, config)
+
+	certs := tlsfixture.NewCertificates(t, "db.example.com")
+	server := tlsfixture.NewServer(t, certs, tlsfixture.PostgresPreamble)
+
+	// the certificate does not match 127.0.0.1
+	_, err = handshake(t, server, Options{TLSMode: TLSRequire}, server.Host)
+	assert.NoError(t, err)
+	_, err = handshake(t, server, Options{TLSMode: TLSVerifyCA, CAFile: certs.CAFile}, server.Host)
+	assert.NoError(t, err)
+	_, err = handshake(t, server, Options{TLSMode: TLSVerifyFull, CAFile: certs.CAFile}, server.Host)
+	assert.Error(t, err)
+	_, err = handshake(t, server, Options{TLSMode: TLSVerifyFull, CAFile: certs.CAFile}, "db.example.com")
+	assert.NoError(t, err)
+
+	other := tlsfixture.NewCertificates(t, "db.example.com")
+	_, err = handshake(t, server, Options{TLSMode: TLSVerifyCA, CAFile: other.CAFile}, server.Host)
+	assert.Error(t, err)
+
+	serverSide, err := handshake(t, server, Options{TLSMode: TLSRequire, CertFile: certs.ClientCertFile, KeyFile: certs.ClientKeyFile}, server.Host)
+	assert.NoError(t, err)
+	assert.NoError(t, serverSide.Err)
+	assert.Equal(t, tlsfixture.ClientCommonName, serverSide.ClientCommonName)
+}
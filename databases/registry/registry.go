@@ -0,0 +1,142 @@
+// Package registry lists the database connectors. Connector packages register
+// themselves from their init function, the application imports them for this
+// side effect only:
+//
+//	import _ "db_meta/databases/postgres"
+package registry
+
+import (
+	"context"
+	"db_meta/dbstructs"
+	"fmt"
+	"sort"
+	"sync"
+
+	"gorm.io/gorm"
+)
+
+// Connector connects to a database and introspects it. Cancelling ctx aborts
+// the connection attempt or the running catalog queries.
+type Connector interface {
+	Connect(ctx context.Context, host, port, database, user, password string) (*gorm.DB, error)
+	GetTableMetadata(context.Context, *gorm.DB) ([]*dbstructs.TableMetadata, error)
+}
+
+// Field types of the connection form
+const (
+	FieldText     = "text"
+	FieldPassword = "password"
+	FieldNumber   = "number"
+	FieldSelect   = "select" // one of Field.Options
+)
+
+// Field describes an input of the connection form. Name is the Connect
+// parameter it fills: host, port, database, user, password or schemas, or the
+// json name of the Options field for the Advanced ones.
+type Field struct {
+	Name        string   `json:"name"`
+	Label       string   `json:"label"`
+	Type        string   `json:"type"`
+	Options     []string `json:"options,omitempty"` // values of a FieldSelect
+	Default     string   `json:"default,omitempty"`
+	Placeholder string   `json:"placeholder,omitempty"`
+	Required    bool     `json:"required,omitempty"`
+	Advanced    bool     `json:"advanced,omitempty"` // folded in the advanced settings of the form
+}
+
+// Fields shared by the network connectors, none is required as a DSN can
+// replace them.
+var (
+	HostField     = Field{Name: "host", Label: "Host", Type: FieldText, Default: "localhost"}
+	UserField     = Field{Name: "user", Label: "User", Type: FieldText}
+	PasswordField = Field{Name: "password", Label: "Password", Type: FieldPassword}
+	DatabaseField = Field{Name: "database", Label: "Database", Type: FieldText}
+)
+
+// PortField is the port input, defaultPort being the usual port of the server.
+func PortField(defaultPort string) Field {
+	return Field{Name: "port", Label: "Port", Type: FieldNumber, Default: defaultPort}
+}
+
+// AdvancedFields are the Options inputs of the network connectors, dsnExample
+// showing the connection string format of the driver. The client certificate
+// inputs are left out without clientCertificate. The SSH hosts are a comma
+// separated chain of user@host:port, the bastion first, sharing the password
+// and key of the form.
+func AdvancedFields(dsnExample string, clientCertificate bool) []Field {
+	fields := []Field{
+		{Name: "dsn", Label: "Connection string", Type: FieldText, Placeholder: dsnExample, Advanced: true},
+		{Name: "tlsMode", Label: "TLS mode", Type: FieldSelect, Options: TLSModes, Advanced: true},
+		{Name: "caFile", Label: "CA certificate", Type: FieldText, Placeholder: "/path/to/ca.pem", Advanced: true},
+	}
+	if clientCertificate {
+		fields = append(fields,
+			Field{Name: "certFile", Label: "Client certificate", Type: FieldText, Placeholder: "/path/to/client.pem", Advanced: true},
+			Field{Name: "keyFile", Label: "Client key", Type: FieldText, Placeholder: "/path/to/client.key", Advanced: true},
+		)
+	}
+	return append(fields,
+		Field{Name: "connectTimeout", Label: "Connect timeout (s)", Type: FieldNumber, Advanced: true},
+		Field{Name: "applicationName", Label: "Application name", Type: FieldText, Placeholder: "db_meta", Advanced: true},
+		Field{Name: "sshHosts", Label: "SSH hosts", Type: FieldText, Placeholder: "admin@bastion:22, deploy@jump", Advanced: true},
+		Field{Name: "sshPassword", Label: "SSH password", Type: FieldPassword, Advanced: true},
+		Field{Name: "sshKeyFile", Label: "SSH private key", Type: FieldText, Placeholder: "~/.ssh/id_ed25519", Advanced: true},
+		Field{Name: "sshKeyPassphrase", Label: "SSH key passphrase", Type: FieldPassword, Advanced: true},
+		Field{Name: "knownHostsFile", Label: "SSH known hosts", Type: FieldText, Placeholder: "~/.ssh/known_hosts", Advanced: true},
+	)
+}
+
+// SchemasField is the comma separated list of schemas to load, for connectors
+// implementing SetSchemas.
+func SchemasField(placeholder string) Field {
+	return Field{Name: "schemas", Label: "Schemas", Type: FieldText, Placeholder: placeholder}
+}
+
+// Driver is a registered connector: its name (the dbType of
+// DatabaseManager.Connect), a display label and the fields of its connection
+// form, in display order.
+type Driver struct {
+	Name   string           `json:"name"`
+	Label  string           `json:"label"`
+	Fields []Field          `json:"fields"`
+	New    func() Connector `json:"-"` // returns a new, unconnected connector
+}
+
+var (
+	mu      sync.RWMutex
+	drivers = make(map[string]Driver)
+)
+
+// Register makes a connector available under driver.Name. It panics if the
+// name is empty, already registered or if New is nil.
+func Register(driver Driver) {
+	mu.Lock()
+	defer mu.Unlock()
+	if driver.Name == "" || driver.New == nil {
+		panic("registry: Register needs a name and a New function")
+	}
+	if _, dup := drivers[driver.Name]; dup {
+		panic(fmt.Sprintf("registry: Register called twice for connector %q", driver.Name))
+	}
+	drivers[driver.Name] = driver
+}
+
+// Lookup returns the connector registered under name.
+func Lookup(name string) (Driver, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	driver, ok := drivers[name]
+	return driver, ok
+}
+
+// Drivers returns the registered connectors, sorted by label.
+func Drivers() []Driver {
+	mu.RLock()
+	defer mu.RUnlock()
+	list := make([]Driver, 0, len(drivers))
+	for _, driver := range drivers {
+		list = append(list, driver)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Label < list[j].Label })
+	return list
+}
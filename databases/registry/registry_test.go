@@ -0,0 +1,47 @@
+package registry
+
+import (
+	"context"
+	"db_meta/dbstructs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/gorm"
+)
+
+type connectorMock struct{}
+
+func (connectorMock) Connect(context.Context, string, string, string, string, string) (*gorm.DB, error) {
+	return nil, nil
+}
+
+func (connectorMock) GetTableMetadata(context.Context, *gorm.DB) ([]*dbstructs.TableMetadata, error) {
+	return nil, nil
+}
+
+func newConnectorMock() Connector { return connectorMock{} }
+
+func TestRegister(t *testing.T) {
+	Register(Driver{Name: "zeta", Label: "Zeta", Fields: []Field{HostField, PortField("1234")}, New: newConnectorMock})
+	Register(Driver{Name: "alpha", Label: "Alpha", New: newConnectorMock})
+
+	driver, ok := Lookup("zeta")
+	assert.True(t, ok)
+	assert.Equal(t, "Zeta", driver.Label)
+	assert.Equal(t, []string{"host", "port"}, []string{driver.Fields[0].Name, driver.Fields[1].Name})
+	assert.Equal(t, "1234", driver.Fields[1].Default)
+	assert.IsType(t, connectorMock{}, driver.New())
+
+	_, ok = Lookup("unknown")
+	assert.False(t, ok)
+
+	var labels []string
+	for _, driver := range Drivers() {
+		labels = append(labels, driver.Label)
+	}
+	assert.Equal(t, []string{"Alpha", "Zeta"}, labels)
+
+	assert.Panics(t, func() { Register(Driver{Name: "zeta", New: newConnectorMock}) })
+	assert.Panics(t, func() { Register(Driver{Name: "noNew"}) })
+	assert.Panics(t, func() { Register(Driver{New: newConnectorMock}) })
+}
@@ -0,0 +1,120 @@
+package databases
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+)
+
+// ErrUnknownSession is returned for a session ID that was never opened or has
+// been closed.
+var ErrUnknownSession = errors.New("unknown session")
+
+// Session is a named connection and its metadata. The DatabaseManager of a
+// session is only reached through Read and Update, which hold its lock.
+type Session struct {
+	ID string
+
+	mu  sync.RWMutex
+	dbm *DatabaseManager
+}
+
+// Read calls fn with the manager of the session, along with other readers. A
+// closed session returns ErrUnknownSession.
+func (s *Session) Read(fn func(dbm *DatabaseManager) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.dbm == nil {
+		return fmt.Errorf("%w: %q", ErrUnknownSession, s.ID)
+	}
+	return fn(s.dbm)
+}
+
+// Update calls fn with exclusive access to the manager of the session.
+func (s *Session) Update(fn func(dbm *DatabaseManager) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.dbm == nil {
+		return fmt.Errorf("%w: %q", ErrUnknownSession, s.ID)
+	}
+	return fn(s.dbm)
+}
+
+// replace swaps the manager of the session and closes the previous connection.
+func (s *Session) replace(dbm *DatabaseManager) {
+	s.mu.Lock()
+	previous := s.dbm
+	s.dbm = dbm
+	s.mu.Unlock()
+
+	if previous != nil && previous != dbm {
+		if err := previous.Close(); err != nil {
+			log.Println("session_manager.go:[1]", err)
+		}
+	}
+}
+
+// SessionManager holds the open sessions by ID, so that several databases can
+// be looked at side by side.
+type SessionManager struct {
+	mu       sync.RWMutex
+	sessions map[string]*Session
+}
+
+func NewSessionManager() *SessionManager {
+	return &SessionManager{sessions: make(map[string]*Session)}
+}
+
+// Open makes dbm, already loaded, the manager of session id. A session already
+// open under id keeps its readers safe and has its previous connection closed.
+func (sm *SessionManager) Open(id string, dbm *DatabaseManager) *Session {
+	sm.mu.Lock()
+	session, ok := sm.sessions[id]
+	if !ok {
+		session = &Session{ID: id}
+		sm.sessions[id] = session
+	}
+	sm.mu.Unlock()
+
+	session.replace(dbm)
+	return session
+}
+
+// Get returns the session id, or ErrUnknownSession.
+func (sm *SessionManager) Get(id string) (*Session, error) {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	session, ok := sm.sessions[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownSession, id)
+	}
+	return session, nil
+}
+
+// Close forgets the session id and closes its connection.
+func (sm *SessionManager) Close(id string) error {
+	sm.mu.Lock()
+	session, ok := sm.sessions[id]
+	delete(sm.sessions, id)
+	sm.mu.Unlock()
+
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownSession, id)
+	}
+	session.replace(nil)
+	return nil
+}
+
+// IDs returns the IDs of the open sessions, sorted.
+func (sm *SessionManager) IDs() []string {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	ids := make([]string, 0, len(sm.sessions))
+	for id := range sm.sessions {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
@@ -0,0 +1,77 @@
+package databases
+
+import (
+	"db_meta/dbstructs"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSessionManager_sessions(t *testing.T) {
+	sm := NewSessionManager()
+	sm.Open("staging", &DatabaseManager{Tables: []*dbstructs.TableMetadata{{TableName: "orders"}}})
+	sm.Open("production", &DatabaseManager{Tables: []*dbstructs.TableMetadata{{TableName: "orders"}, {TableName: "customer"}}})
+	assert.Equal(t, []string{"production", "staging"}, sm.IDs())
+
+	// Each session keeps its own metadata
+	staging, err := sm.Get("staging")
+	assert.NoError(t, err)
+	assert.NoError(t, staging.Read(func(dbm *DatabaseManager) error {
+		assert.Len(t, dbm.Tables, 1)
+		return nil
+	}))
+
+	// Opening an existing session replaces its manager
+	sm.Open("staging", &DatabaseManager{})
+	assert.NoError(t, staging.Read(func(dbm *DatabaseManager) error {
+		assert.Empty(t, dbm.Tables)
+		return nil
+	}))
+
+	assert.NoError(t, sm.Close("staging"))
+	assert.Equal(t, []string{"production"}, sm.IDs())
+	_, err = sm.Get("staging")
+	assert.ErrorIs(t, err, ErrUnknownSession)
+	assert.ErrorIs(t, sm.Close("staging"), ErrUnknownSession)
+	assert.ErrorIs(t, staging.Read(func(*DatabaseManager) error { return nil }), ErrUnknownSession)
+}
+
+// Run with -race: readers, graph updates and reloads of a session do not race
+func TestSessionManager_concurrentAccess(t *testing.T) {
+	sm := NewSessionManager()
+	newManager := func() *DatabaseManager {
+		return &DatabaseManager{Tables: []*dbstructs.TableMetadata{{TableName: "table1"}, {TableName: "table2"}}}
+	}
+	sm.Open("default", newManager())
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			session, err := sm.Get("default")
+			assert.NoError(t, err)
+			assert.NoError(t, session.Read(func(dbm *DatabaseManager) error {
+				assert.Len(t, dbm.GetTablesList(), 2)
+				return nil
+			}))
+		}()
+		go func(collapse bool) {
+			defer wg.Done()
+			session, err := sm.Get("default")
+			assert.NoError(t, err)
+			assert.NoError(t, session.Update(func(dbm *DatabaseManager) error {
+				dbm.CollapsePartitions = collapse
+				dbm.TransformToGraph()
+				return nil
+			}))
+		}(i%2 == 0)
+		go func() {
+			defer wg.Done()
+			sm.Open("default", newManager())
+			sm.IDs()
+		}()
+	}
+	wg.Wait()
+}
@@ -1,19 +1,49 @@
 package sqliteConnector
 
 import (
+	"context"
+	"database/sql"
+	"db_meta/databases/registry"
+	"db_meta/databases/sqlutil"
 	"db_meta/dbstructs"
-	"fmt"
 	"log"
+	"strconv"
+	"strings"
 
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 )
 
-type SQLiteConnector struct{}
+type SQLiteConnector struct {
+	Options registry.Options // only DSN applies to a local file
+}
+
+func init() {
+	registry.Register(registry.Driver{
+		Name:  "sqlite",
+		Label: "SQLite",
+		Fields: []registry.Field{
+			{Name: "database", Label: "Database file", Type: registry.FieldText, Placeholder: "./data.db"},
+			{Name: "dsn", Label: "Connection string", Type: registry.FieldText, Placeholder: "file:data.db?mode=ro", Advanced: true},
+		},
+		New: func() registry.Connector { return &SQLiteConnector{} },
+	})
+}
 
-func (conn SQLiteConnector) Connect(host, port, database, user, password string) (*gorm.DB, error) {
+func (conn SQLiteConnector) Connect(ctx context.Context, host, port, database, user, password string) (*gorm.DB, error) {
 	// only the 'database' parameter is used...
-	db, err := gorm.Open(sqlite.Open(database), &gorm.Config{})
+	if conn.Options.DSN != "" {
+		database = conn.Options.DSN
+	}
+	db, err := gorm.Open(sqlite.Open(database), &gorm.Config{DisableAutomaticPing: true})
+	if err != nil {
+		log.Println("sqlite.go: Connect error", err)
+		return nil, err
+	}
+	sqlDB, err := db.DB()
+	if err == nil {
+		err = sqlDB.PingContext(ctx)
+	}
 	if err != nil {
 		log.Println("sqlite.go: Connect error", err)
 		return nil, err
@@ -21,226 +51,805 @@ func (conn SQLiteConnector) Connect(host, port, database, user, password string)
 	return db, nil
 }
 
-// FFS this one was such a pain...
-func (conn SQLiteConnector) GetTableMetadata(db *gorm.DB) ([]*dbstructs.TableMetadata, error) {
-	tableNames, err := conn.GetTableNames(db)
+func (conn *SQLiteConnector) SetOptions(options registry.Options) {
+	conn.Options = options
+}
+
+// GetServerVersion returns the version of the server, such as "3.44.0".
+func (conn SQLiteConnector) GetServerVersion(ctx context.Context, db *gorm.DB) (string, error) {
+	var version string
+	if err := db.WithContext(ctx).Raw(`SELECT sqlite_version()`).Scan(&version).Error; err != nil {
+		return "", err
+	}
+	return version, nil
+}
+
+// GetTableMetadata loads the whole schema with one query per kind of object,
+// the pragma table-valued functions are joined to sqlite_master.
+func (conn SQLiteConnector) GetTableMetadata(ctx context.Context, db *gorm.DB) ([]*dbstructs.TableMetadata, error) {
+	tables, err := conn.GetTables(ctx, db)
 	if err != nil {
 		log.Println("Error fetching table names:", err)
 		return nil, err
 	}
+	if err := conn.loadTableMetadata(db.WithContext(ctx), tables, "m.type = 'table'"); err != nil {
+		return nil, err
+	}
+	return tables, nil
+}
 
+// GetTables lists the tables, only TableName is set.
+func (conn SQLiteConnector) GetTables(ctx context.Context, db *gorm.DB) ([]*dbstructs.TableMetadata, error) {
+	tableNames, err := conn.GetTableNames(db.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
 	var tables []*dbstructs.TableMetadata
 	for _, tableName := range tableNames {
-		table := &dbstructs.TableMetadata{TableName: tableName}
+		tables = append(tables, &dbstructs.TableMetadata{TableName: tableName})
+	}
+	return tables, nil
+}
 
-		// Get columns
-		columns, err := conn.GetColumns(db, tableName)
-		if err != nil {
-			log.Println("Error fetching columns:", err)
-			return nil, err
-		}
-		table.Columns = columns
+// LoadTableMetadata fills in the columns, keys, indexes and constraints of
+// tables, as listed by GetTables.
+func (conn SQLiteConnector) LoadTableMetadata(ctx context.Context, db *gorm.DB, tables []*dbstructs.TableMetadata) error {
+	var tableNames []string
+	for _, table := range tables {
+		tableNames = append(tableNames, table.TableName)
+	}
+	return conn.loadTableMetadata(db.WithContext(ctx), tables, "m.type = 'table' AND m.name IN ?", tableNames)
+}
 
-		// Get primary keys
-		primaryKeys, err := conn.GetPrimaryKeys(db, tableName)
-		if err != nil {
-			log.Println("Error fetching primary keys:", err)
-			return nil, err
-		}
-		table.PrimaryKey = primaryKeys
+// loadTableMetadata fills in tables from the objects of sqlite_master m matching
+// filter.
+func (conn SQLiteConnector) loadTableMetadata(db *gorm.DB, tables []*dbstructs.TableMetadata, filter string, args ...interface{}) error {
+	// Get columns and primary keys
+	columns, primaryKeys, err := conn.columnsByTable(db, filter, args...)
+	if err != nil {
+		log.Println("Error fetching columns:", err)
+		return err
+	}
 
-		// Get relationships
-		relationships, err := conn.GetRelationships(db, tableName)
-		if err != nil {
-			log.Println("Error fetching relationships:", err)
-			return nil, err
-		}
-		table.Relationships = relationships
+	// Get indexes
+	indexes, err := conn.indexesByTable(db, filter, args...)
+	if err != nil {
+		log.Println("Error fetching indexes:", err)
+		return err
+	}
 
-		// Get indexes
-		indexes, err := conn.GetIndexes(db, tableName)
-		if err != nil {
-			log.Println("Error fetching indexes:", err)
-			return nil, err
-		}
-		table.Indexes = indexes
+	// Get relationships
+	relationships, err := conn.relationshipsByTable(db, primaryKeys, filter, args...)
+	if err != nil {
+		log.Println("Error fetching relationships:", err)
+		return err
+	}
 
-		tables = append(tables, table)
+	createStatements, err := conn.createTableStatements(db, filter, args...)
+	if err != nil {
+		log.Println("Error fetching table definitions:", err)
+		return err
 	}
 
-	return tables, nil
+	for _, table := range tables {
+		tableName := table.TableName
+		table.Columns = columns[tableName]
+		table.PrimaryKey = primaryKeys[tableName]
+		table.Relationships = relationships[tableName]
+		table.Indexes = indexes[tableName]
+		markUniqueColumns(table.Columns, table.Indexes)
+		table.CheckConstraints = checkConstraints(tableName, createStatements[tableName], table.ColumnNames())
+	}
+
+	return nil
 }
 
 func (conn SQLiteConnector) GetColumns(db *gorm.DB, tableName string) ([]*dbstructs.Column, error) {
-	var columns []*dbstructs.Column
-	rows, err := db.Raw(fmt.Sprintf("PRAGMA table_info('%s');", tableName)).Rows()
+	columns, _, err := conn.columnsByTable(db, "m.name = ?", tableName)
+	if err != nil {
+		return nil, err
+	}
+	indexes, err := conn.indexesByTable(db, "m.name = ?", tableName)
 	if err != nil {
+		log.Println("Error fetching unique indexes:", err)
 		return nil, err
 	}
+	markUniqueColumns(columns[tableName], indexes[tableName])
+	return columns[tableName], nil
+}
+
+func (conn SQLiteConnector) GetPrimaryKeys(db *gorm.DB, tableName string) ([]string, error) {
+	_, primaryKeys, err := conn.columnsByTable(db, "m.name = ?", tableName)
+	if err != nil {
+		return nil, err
+	}
+	return primaryKeys[tableName], nil
+}
+
+// GetRelationships returns the foreign keys declared on tableName.
+func (conn SQLiteConnector) GetRelationships(db *gorm.DB, tableName string) ([]*dbstructs.RelationshipMetadata, error) {
+	relationships, err := conn.relationshipsByTable(db, nil, "m.name = ?", tableName)
+	if err != nil {
+		return nil, err
+	}
+	return relationships[tableName], nil
+}
+
+// GetIndexes returns the indexes of tableName. Expression keys and partial
+// index predicates are read from the CREATE INDEX statements.
+func (conn SQLiteConnector) GetIndexes(db *gorm.DB, tableName string) ([]*dbstructs.Index, error) {
+	indexes, err := conn.indexesByTable(db, "m.name = ?", tableName)
+	if err != nil {
+		return nil, err
+	}
+	return indexes[tableName], nil
+}
+
+// columnsByTable returns the columns and the primary keys of the tables and
+// views of sqlite_master m matching filter, by table name.
+func (conn SQLiteConnector) columnsByTable(db *gorm.DB, filter string, args ...interface{}) (map[string][]*dbstructs.Column, map[string][]string, error) {
+	// table_xinfo also lists generated columns, flagged by "hidden"
+	rows, err := db.Raw(`
+      SELECT m.name, m.sql, p.name, p.type, p."notnull", p.dflt_value, p.pk, p.hidden
+      FROM sqlite_master m
+      INNER JOIN pragma_table_xinfo(m.name) p
+      WHERE `+filter+`
+      ORDER BY m.name, p.cid;`, args...).Rows()
+	if err != nil {
+		return nil, nil, err
+	}
 	defer rows.Close()
 
+	columns := make(map[string][]*dbstructs.Column)
+	primaryKeys := make(map[string][]string)
 	for rows.Next() {
 		var (
-			cid        int
+			tableName  string
+			createSQL  sql.NullString
 			name       string
 			dataType   string
 			notNullInt int
-			dfltValue  interface{}
+			dfltValue  sql.NullString
 			pkInt      int
+			hidden     int
 		)
-		if err := rows.Scan(&cid, &name, &dataType, &notNullInt, &dfltValue, &pkInt); err != nil {
-			return nil, err
+		if err := rows.Scan(&tableName, &createSQL, &name, &dataType, &notNullInt, &dfltValue, &pkInt, &hidden); err != nil {
+			return nil, nil, err
+		}
+		if hidden == 1 { // hidden column of a virtual table
+			continue
 		}
 		column := &dbstructs.Column{
-			ColumnName: name,
-			DataType:   dataType,
-			NotNull:    notNullInt != 0,
-			Unique:     false, // Will be updated after fetching unique indexes
+			ColumnName:      name,
+			DataType:        dataType,
+			NotNull:         notNullInt != 0,
+			Unique:          false, // Will be updated after fetching unique indexes
+			OrdinalPosition: len(columns[tableName]) + 1,
 		}
-		columns = append(columns, column)
+		if dfltValue.Valid {
+			column.Default = &dfltValue.String
+		}
+		column.CharacterLength, column.NumericPrecision, column.NumericScale = typeModifiers(dataType)
+		// pk is the 1-based position of the column in the primary key
+		if pkInt > 0 {
+			for len(primaryKeys[tableName]) < pkInt {
+				primaryKeys[tableName] = append(primaryKeys[tableName], "")
+			}
+			primaryKeys[tableName][pkInt-1] = name
+		}
+		// The pragma does not expose generation expressions, read them from the DDL
+		if hidden == 2 || hidden == 3 { // virtual or stored generated column
+			column.Generated = generationExpressions(createSQL.String)[strings.ToLower(name)]
+		}
+		columns[tableName] = append(columns[tableName], column)
 	}
 
-	// Check for unique columns
-	uniqueIndexes, err := conn.GetUniqueIndexes(db, tableName)
+	// A lone INTEGER PRIMARY KEY aliases the rowid and is assigned automatically
+	for tableName, keys := range primaryKeys {
+		if len(keys) != 1 {
+			continue
+		}
+		for _, column := range columns[tableName] {
+			if column.ColumnName == keys[0] && strings.EqualFold(column.DataType, "INTEGER") {
+				column.AutoIncrement = true
+			}
+		}
+	}
+
+	return columns, primaryKeys, nil
+}
+
+// markUniqueColumns flags the columns that a single column unique index covers.
+func markUniqueColumns(columns []*dbstructs.Column, indexes []*dbstructs.Index) {
+	for _, index := range indexes {
+		if !index.Unique || len(index.Keys) != 1 || index.Keys[0].Column == "" {
+			continue
+		}
+		for _, column := range columns {
+			if column.ColumnName == index.Keys[0].Column {
+				column.Unique = true
+			}
+		}
+	}
+}
+
+// relationshipsByTable groups the foreign_key_list rows of the tables of
+// sqlite_master m matching filter by constraint id, SQLite reports one row per
+// column of a (possibly composite) foreign key. Implied parent keys are looked
+// up in primaryKeys, the missing ones are queried together.
+func (conn SQLiteConnector) relationshipsByTable(db *gorm.DB, primaryKeys map[string][]string, filter string, args ...interface{}) (map[string][]*dbstructs.RelationshipMetadata, error) {
+	rows, err := db.Raw(`
+      SELECT m.name, m.sql, f.id, f."table", f."from", f."to", f.on_update, f.on_delete, f."match"
+      FROM sqlite_master m
+      INNER JOIN pragma_foreign_key_list(m.name) f
+      WHERE `+filter+`
+      ORDER BY m.name, f.id, f.seq;`, args...).Rows()
 	if err != nil {
-		log.Println("Error fetching unique indexes:", err)
 		return nil, err
 	}
-	for _, column := range columns {
-		if _, exists := uniqueIndexes[column.ColumnName]; exists {
-			column.Unique = true
+	defer rows.Close()
+
+	relationships := make(map[string][]*dbstructs.RelationshipMetadata)
+	createStatements := make(map[string]string)
+	var relationship *dbstructs.RelationshipMetadata
+	lastID := -1
+	for rows.Next() {
+		var (
+			tableName string
+			createSQL string
+			id        int
+			table     string
+			from      string
+			to        sql.NullString // NULL when the parent primary key is implied
+			onUpdate  string
+			onDelete  string
+			match     string
+		)
+		if err := rows.Scan(&tableName, &createSQL, &id, &table, &from, &to, &onUpdate, &onDelete, &match); err != nil {
+			return nil, err
+		}
+		if relationship == nil || relationship.SourceTableName != tableName || id != lastID {
+			relationship = &dbstructs.RelationshipMetadata{
+				SourceTableName:  tableName,
+				RelatedTableName: table,
+				OnDelete:         onDelete,
+				OnUpdate:         onUpdate,
+				Match:            match,
+			}
+			lastID = id
+			createStatements[tableName] = createSQL
+			relationships[tableName] = append(relationships[tableName], relationship)
+		}
+		relationship.SourceColumns = append(relationship.SourceColumns, from)
+		relationship.TargetColumns = append(relationship.TargetColumns, to.String)
+	}
+	rows.Close()
+
+	// the implied parent keys missing from primaryKeys are queried at once
+	var missing []string
+	for _, tableRelationships := range relationships {
+		for _, relationship := range tableRelationships {
+			if _, ok := primaryKeys[relationship.RelatedTableName]; !ok && relationship.TargetColumns[0] == "" {
+				missing = append(missing, relationship.RelatedTableName)
+			}
+		}
+	}
+	parentKeys := primaryKeys
+	if len(missing) > 0 {
+		if _, parentKeys, err = conn.columnsByTable(db, "m.name IN ?", missing); err != nil {
+			return nil, err
+		}
+		for tableName, primaryKey := range primaryKeys {
+			parentKeys[tableName] = primaryKey
 		}
 	}
 
-	return columns, nil
+	for tableName, tableRelationships := range relationships {
+		// foreign_key_list does not report deferrability, read it from the DDL
+		clauses := foreignKeyClauses(createStatements[tableName])
+
+		for _, relationship := range tableRelationships {
+			// SQLite foreign keys are anonymous, name them after their columns
+			relationship.Conname = strings.Join(relationship.SourceColumns, "_")
+
+			clause := clauses[strings.ToLower(strings.Join(relationship.SourceColumns, ","))]
+			relationship.Deferrable = strings.Contains(clause, "DEFERRABLE") && !strings.Contains(clause, "NOT DEFERRABLE")
+			relationship.InitiallyDeferred = relationship.Deferrable && strings.Contains(clause, "INITIALLY DEFERRED")
+
+			if relationship.TargetColumns[0] == "" {
+				parentKey := parentKeys[relationship.RelatedTableName]
+				if len(parentKey) == len(relationship.SourceColumns) {
+					relationship.TargetColumns = parentKey
+				}
+			}
+		}
+	}
+
+	return relationships, nil
 }
 
-func (conn SQLiteConnector) GetPrimaryKeys(db *gorm.DB, tableName string) ([]string, error) {
-	var primaryKeys []string
-	rows, err := db.Raw(fmt.Sprintf("PRAGMA table_info('%s');", tableName)).Rows()
+// indexesByTable returns the indexes of the tables of sqlite_master m matching
+// filter, with their keys in order.
+func (conn SQLiteConnector) indexesByTable(db *gorm.DB, filter string, args ...interface{}) (map[string][]*dbstructs.Index, error) {
+	// The CREATE INDEX statement is NULL for the automatic indexes of constraints
+	rows, err := db.Raw(`
+      SELECT m.name, il.name, il."unique", il.origin, s.sql,
+          ix.seqno, ix.cid, ix.name, ix."desc", ix."key"
+      FROM sqlite_master m
+      INNER JOIN pragma_index_list(m.name) il
+      INNER JOIN pragma_index_xinfo(il.name) ix
+      LEFT JOIN sqlite_master s ON s.type = 'index' AND s.name = il.name
+      WHERE `+filter+`
+      ORDER BY m.name, il.seq, ix.seqno;`, args...).Rows()
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
+	indexes := make(map[string][]*dbstructs.Index)
+	var index *dbstructs.Index
+	var definitions []string
 	for rows.Next() {
 		var (
+			tableName  string
+			indexName  string
+			unique     bool
+			origin     string
+			createSQL  sql.NullString
+			seqno      int
 			cid        int
-			name       string
-			dataType   string
-			notNullInt int
-			dfltValue  interface{}
-			pkInt      int
+			colName    sql.NullString // NULL for expressions
+			descending bool
+			isKey      bool
 		)
-		if err := rows.Scan(&cid, &name, &dataType, &notNullInt, &dfltValue, &pkInt); err != nil {
+		if err := rows.Scan(&tableName, &indexName, &unique, &origin, &createSQL,
+			&seqno, &cid, &colName, &descending, &isKey); err != nil {
 			return nil, err
 		}
-		if pkInt == 1 {
-			primaryKeys = append(primaryKeys, name)
+		if index == nil || index.Name != indexName {
+			index = &dbstructs.Index{Name: indexName, Columns: []string{}, Unique: unique, Primary: origin == "pk", Method: "btree"}
+			definitions, index.Predicate = indexDefinitions(createSQL.String)
+			indexes[tableName] = append(indexes[tableName], index)
+		}
+		if !isKey { // the rowid every index entry points to
+			continue
+		}
+		key := &dbstructs.IndexKey{Column: colName.String, Descending: descending}
+		if cid == -2 && seqno < len(definitions) {
+			key = &dbstructs.IndexKey{Expression: definitions[seqno], Descending: descending}
 		}
+		index.Keys = append(index.Keys, key)
+		index.Columns = append(index.Columns, key.Column+key.Expression)
 	}
 
-	return primaryKeys, nil
+	return indexes, nil
 }
 
-func (conn SQLiteConnector) GetRelationships(db *gorm.DB, tableName string) ([]*dbstructs.RelationshipMetadata, error) {
-	var relationships []*dbstructs.RelationshipMetadata
-	rows, err := db.Raw(fmt.Sprintf("PRAGMA foreign_key_list('%s');", tableName)).Rows()
+// indexDefinitions returns the key definitions of a CREATE INDEX statement,
+// without their ordering and collation, and its WHERE clause.
+func indexDefinitions(createSQL string) ([]string, string) {
+	upper := strings.ToUpper(createSQL)
+	on := strings.Index(upper, " ON ")
+	if on < 0 {
+		return nil, ""
+	}
+	open := strings.Index(createSQL[on:], "(")
+	if open < 0 {
+		return nil, ""
+	}
+	open += on
+	close := closingParenthesis(createSQL, open)
+	if close < 0 {
+		return nil, ""
+	}
+
+	var definitions []string
+	for _, definition := range tableDefinitions(createSQL[open : close+1]) {
+		fields := strings.Fields(definition)
+		for len(fields) > 1 {
+			last := strings.ToUpper(fields[len(fields)-1])
+			if last == "ASC" || last == "DESC" {
+				fields = fields[:len(fields)-1]
+			} else if len(fields) > 2 && strings.ToUpper(fields[len(fields)-2]) == "COLLATE" {
+				fields = fields[:len(fields)-2]
+			} else {
+				break
+			}
+		}
+		definitions = append(definitions, strings.Join(fields, " "))
+	}
+
+	var predicate string
+	if where := strings.Index(upper[close:], "WHERE"); where >= 0 {
+		predicate = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(createSQL[close+where+len("WHERE"):]), ";"))
+	}
+	return definitions, predicate
+}
+
+// GetViewMetadata returns the views, SQLite keeps no dependency catalog so
+// dependencies are read from the view definitions.
+func (conn SQLiteConnector) GetViewMetadata(ctx context.Context, db *gorm.DB) ([]*dbstructs.ViewMetadata, error) {
+	db = db.WithContext(ctx)
+	var views []*dbstructs.ViewMetadata
+	rows, err := db.Raw("SELECT name, sql FROM sqlite_master WHERE type='view' ORDER BY name;").Rows()
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		var (
-			id       int
-			seq      int
-			table    string
-			from     string
-			to       string
-			onUpdate string
-			onDelete string
-			match    string
-		)
-		if err := rows.Scan(&id, &seq, &table, &from, &to, &onUpdate, &onDelete, &match); err != nil {
+		view := &dbstructs.ViewMetadata{}
+		if err := rows.Scan(&view.ViewName, &view.Definition); err != nil {
 			return nil, err
 		}
-		relationship := &dbstructs.RelationshipMetadata{
-			Conname:          from,
-			RelatedTableName: table,
+		views = append(views, view)
+	}
+	rows.Close()
+
+	candidates, err := conn.GetTableNames(db)
+	if err != nil {
+		return nil, err
+	}
+	for _, view := range views {
+		candidates = append(candidates, view.ViewName)
+	}
+
+	columns, _, err := conn.columnsByTable(db, "m.type = 'view'")
+	if err != nil {
+		log.Println("Error fetching view columns:", err)
+		return nil, err
+	}
+
+	for _, view := range views {
+		view.Columns = columns[view.ViewName]
+
+		// the definition starts with CREATE VIEW <name> AS
+		for _, name := range sqlutil.ReferencedNames(view.Definition, candidates) {
+			if name != view.ViewName {
+				view.Dependencies = append(view.Dependencies, name)
+			}
 		}
-		relationships = append(relationships, relationship)
 	}
 
-	return relationships, nil
+	return views, nil
 }
 
-func (conn SQLiteConnector) GetIndexes(db *gorm.DB, tableName string) ([]*dbstructs.Index, error) {
-	var indexes []*dbstructs.Index
-	indexRows, err := db.Raw(fmt.Sprintf("PRAGMA index_list('%s');", tableName)).Rows()
+// GetTriggerMetadata returns the triggers, their timing, event and body are read
+// from their CREATE TRIGGER statement. SQLite only has row level triggers.
+func (conn SQLiteConnector) GetTriggerMetadata(ctx context.Context, db *gorm.DB) ([]*dbstructs.TriggerMetadata, error) {
+	db = db.WithContext(ctx)
+	var triggers []*dbstructs.TriggerMetadata
+	rows, err := db.Raw("SELECT name, tbl_name, sql FROM sqlite_master WHERE type='trigger' ORDER BY tbl_name, name;").Rows()
 	if err != nil {
 		return nil, err
 	}
-	defer indexRows.Close()
+	defer rows.Close()
 
-	for indexRows.Next() {
-		var index dbstructs.Index
-		if err := indexRows.Scan(&index.Name, nil, nil); err != nil {
+	for rows.Next() {
+		var createSQL string
+		trigger := &dbstructs.TriggerMetadata{Orientation: "ROW"}
+		if err := rows.Scan(&trigger.TriggerName, &trigger.TableName, &createSQL); err != nil {
 			return nil, err
 		}
+		trigger.Timing, trigger.Events, trigger.Body = triggerClauses(createSQL)
+		triggers = append(triggers, trigger)
+	}
+	rows.Close()
 
-		colRows, err := db.Raw(fmt.Sprintf("PRAGMA index_info('%s');", index.Name)).Rows()
-		if err != nil {
-			return nil, err
+	candidates, err := conn.GetTableNames(db)
+	if err != nil {
+		return nil, err
+	}
+	for _, trigger := range triggers {
+		trigger.References = sqlutil.ReferencedNames(trigger.Body, candidates)
+	}
+
+	return triggers, nil
+}
+
+// triggerClauses reads the timing, the event and the BEGIN ... END body of a
+// CREATE TRIGGER statement. The timing defaults to BEFORE when omitted.
+func triggerClauses(createSQL string) (string, []string, string) {
+	begin := keywordIndex(createSQL, "BEGIN")
+	if begin < 0 {
+		return "", nil, ""
+	}
+
+	timing := "BEFORE"
+	var events []string
+	// CREATE [TEMP] TRIGGER [IF NOT EXISTS] name [timing] event [OF columns] ON table
+	for _, field := range strings.Fields(strings.ToUpper(createSQL[:begin])) {
+		if field == "ON" {
+			break
 		}
-		defer colRows.Close()
+		switch field {
+		case "BEFORE", "AFTER":
+			timing = field
+		case "INSTEAD":
+			timing = "INSTEAD OF"
+		case "INSERT", "UPDATE", "DELETE":
+			events = append(events, field)
+		}
+	}
 
-		for colRows.Next() {
-			var colName string
-			if err := colRows.Scan(nil, &colName); err != nil {
-				return nil, err
-			}
-			index.Columns = append(index.Columns, colName)
+	body := createSQL[begin+len("BEGIN"):]
+	if end := strings.LastIndex(strings.ToUpper(body), "END"); end >= 0 {
+		body = body[:end]
+	}
+	return timing, events, strings.TrimSpace(body)
+}
+
+// keywordIndex returns the index of the first occurrence of keyword in text as
+// a whole word, ignoring case, or -1.
+func keywordIndex(text, keyword string) int {
+	upper := strings.ToUpper(text)
+	for from := 0; ; {
+		index := strings.Index(upper[from:], keyword)
+		if index < 0 {
+			return -1
 		}
+		index += from
+		end := index + len(keyword)
+		if (index == 0 || !isIdentifierChar(upper[index-1])) && (end == len(upper) || !isIdentifierChar(upper[end])) {
+			return index
+		}
+		from = end
+	}
+}
 
-		indexes = append(indexes, &index)
+// GetCheckConstraints returns the CHECK constraints of tableName, read from its
+// DDL.
+func (conn SQLiteConnector) GetCheckConstraints(db *gorm.DB, tableName string, columnNames []string) ([]*dbstructs.CheckConstraint, error) {
+	createSQL, err := conn.GetCreateTableSQL(db, tableName)
+	if err != nil {
+		return nil, err
 	}
+	return checkConstraints(tableName, createSQL, columnNames), nil
+}
 
-	return indexes, nil
+// checkConstraints reads the CHECK constraints of a CREATE TABLE statement.
+// SQLite does not name anonymous constraints, they are named like PostgreSQL
+// would: table_column_check or table_check.
+func checkConstraints(tableName, createSQL string, columnNames []string) []*dbstructs.CheckConstraint {
+	checks := checkClauses(createSQL)
+	used := make(map[string]bool)
+	for _, check := range checks {
+		if check.Columns == nil {
+			check.Columns = sqlutil.ReferencedNames(check.Expression, columnNames)
+		}
+		if check.Name == "" {
+			base := tableName + "_check"
+			if len(check.Columns) == 1 {
+				base = tableName + "_" + check.Columns[0] + "_check"
+			}
+			check.Name = base
+			for n := 1; used[check.Name]; n++ {
+				check.Name = base + strconv.Itoa(n)
+			}
+		}
+		used[check.Name] = true
+	}
+	return checks
+}
+
+// GetCreateTableSQL returns the CREATE TABLE statement stored in sqlite_master.
+func (conn SQLiteConnector) GetCreateTableSQL(db *gorm.DB, tableName string) (string, error) {
+	var createSQL sql.NullString
+	row := db.Raw("SELECT sql FROM sqlite_master WHERE type = 'table' AND name = ?;", tableName).Row()
+	if err := row.Scan(&createSQL); err != nil && err != sql.ErrNoRows {
+		return "", err
+	}
+	return createSQL.String, nil
 }
 
-func (conn SQLiteConnector) GetUniqueIndexes(db *gorm.DB, tableName string) (map[string]bool, error) {
-	uniqueIndexes := make(map[string]bool)
-	indexRows, err := db.Raw(fmt.Sprintf("PRAGMA index_list('%s');", tableName)).Rows()
+// createTableStatements returns the CREATE TABLE statements of the tables of
+// sqlite_master m matching filter, by table name.
+func (conn SQLiteConnector) createTableStatements(db *gorm.DB, filter string, args ...interface{}) (map[string]string, error) {
+	rows, err := db.Raw("SELECT m.name, m.sql FROM sqlite_master m WHERE "+filter+";", args...).Rows()
 	if err != nil {
 		return nil, err
 	}
-	defer indexRows.Close()
+	defer rows.Close()
 
-	for indexRows.Next() {
-		var indexName string
-		var unique bool
-		if err := indexRows.Scan(&indexName, &unique, nil); err != nil {
+	createStatements := make(map[string]string)
+	for rows.Next() {
+		var tableName string
+		var createSQL sql.NullString
+		if err := rows.Scan(&tableName, &createSQL); err != nil {
 			return nil, err
 		}
-		if unique {
-			colRows, err := db.Raw(fmt.Sprintf("PRAGMA index_info('%s');", indexName)).Rows()
-			if err != nil {
-				return nil, err
+		createStatements[tableName] = createSQL.String
+	}
+	return createStatements, nil
+}
+
+// typeModifiers reads the length of VARCHAR(255) or the precision and scale of
+// DECIMAL(10, 2) from a declared column type.
+func typeModifiers(dataType string) (length, precision, scale *int) {
+	open := strings.Index(dataType, "(")
+	close := strings.LastIndex(dataType, ")")
+	if open < 0 || close < open {
+		return nil, nil, nil
+	}
+
+	var values []int
+	for _, value := range strings.Split(dataType[open+1:close], ",") {
+		number, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return nil, nil, nil
+		}
+		values = append(values, number)
+	}
+
+	baseType := strings.ToUpper(strings.TrimSpace(dataType[:open]))
+	if strings.Contains(baseType, "CHAR") || strings.Contains(baseType, "TEXT") || strings.Contains(baseType, "BINARY") {
+		return &values[0], nil, nil
+	}
+	if len(values) > 1 {
+		return nil, &values[0], &values[1]
+	}
+	return nil, &values[0], nil
+}
+
+// generationExpressions maps the lowercased name of each generated column
+// declared in createSQL to its expression.
+func generationExpressions(createSQL string) map[string]string {
+	expressions := make(map[string]string)
+	for _, definition := range tableDefinitions(createSQL) {
+		fields := strings.Fields(definition)
+		asIndex := strings.Index(strings.ToUpper(definition), " AS (")
+		if len(fields) == 0 || asIndex < 0 {
+			continue
+		}
+		expressions[strings.ToLower(unquoteIdentifier(fields[0]))] = parenthesized(definition, asIndex+4)
+	}
+	return expressions
+}
+
+// checkClauses returns the CHECK constraints declared in createSQL. Column
+// constraints get their column, and a name only when one is declared.
+func checkClauses(createSQL string) []*dbstructs.CheckConstraint {
+	var checks []*dbstructs.CheckConstraint
+	for _, definition := range tableDefinitions(createSQL) {
+		upper := strings.ToUpper(definition)
+		fields := strings.Fields(upper)
+		if len(fields) == 0 {
+			continue
+		}
+		isColumn := fields[0] != "CHECK" && fields[0] != "CONSTRAINT"
+
+		for from := 0; ; {
+			index := strings.Index(upper[from:], "CHECK")
+			if index < 0 {
+				break
 			}
-			defer colRows.Close()
+			index += from
+			from = index + len("CHECK")
 
-			for colRows.Next() {
-				var colName string
-				if err := colRows.Scan(nil, &colName); err != nil {
-					return nil, err
-				}
-				uniqueIndexes[colName] = true
+			open := from + len(upper[from:]) - len(strings.TrimLeft(upper[from:], " \t\r\n"))
+			if (index > 0 && isIdentifierChar(upper[index-1])) || open >= len(upper) || upper[open] != '(' {
+				continue
 			}
+
+			check := &dbstructs.CheckConstraint{Expression: parenthesized(definition, open)}
+			// [CONSTRAINT name] CHECK (...)
+			if before := strings.Fields(definition[:index]); len(before) >= 2 && strings.EqualFold(before[len(before)-2], "CONSTRAINT") {
+				check.Name = unquoteIdentifier(before[len(before)-1])
+			}
+			if isColumn {
+				check.Columns = []string{unquoteIdentifier(strings.Fields(definition)[0])}
+			}
+			checks = append(checks, check)
+		}
+	}
+	return checks
+}
+
+func isIdentifierChar(char byte) bool {
+	return char == '_' || char >= 'A' && char <= 'Z' || char >= 'a' && char <= 'z' || char >= '0' && char <= '9'
+}
+
+// parenthesized returns the trimmed text between the parenthesis at index open
+// and its matching closing one, ignoring parentheses in quotes.
+func parenthesized(text string, open int) string {
+	close := closingParenthesis(text, open)
+	if close < 0 {
+		return ""
+	}
+	return strings.TrimSpace(text[open+1 : close])
+}
+
+// closingParenthesis returns the index of the parenthesis matching the one at
+// index open, or -1.
+func closingParenthesis(text string, open int) int {
+	depth := 0
+	var quote rune
+	for i, char := range text[open:] {
+		switch {
+		case quote != 0:
+			if char == quote {
+				quote = 0
+			}
+		case char == '\'' || char == '"' || char == '`':
+			quote = char
+		case char == '(':
+			depth++
+		case char == ')':
+			depth--
+			if depth == 0 {
+				return open + i
+			}
+		}
+	}
+	return -1
+}
+
+// tableDefinitions splits the body of a CREATE TABLE statement into its column
+// and table constraint definitions, ignoring commas nested in parentheses.
+func tableDefinitions(createSQL string) []string {
+	start := strings.Index(createSQL, "(")
+	end := strings.LastIndex(createSQL, ")")
+	if start < 0 || end <= start {
+		return nil
+	}
+
+	var definitions []string
+	depth, from := 0, start+1
+	var quote rune
+	for i, char := range createSQL[start+1 : end] {
+		switch {
+		case quote != 0:
+			if char == quote {
+				quote = 0
+			}
+		case char == '\'' || char == '"' || char == '`':
+			quote = char
+		case char == '[':
+			quote = ']'
+		case char == '(':
+			depth++
+		case char == ')':
+			depth--
+		case char == ',' && depth == 0:
+			definitions = append(definitions, strings.TrimSpace(createSQL[from:start+1+i]))
+			from = start + 2 + i
 		}
 	}
+	return append(definitions, strings.TrimSpace(createSQL[from:end]))
+}
+
+// foreignKeyClauses maps the lowercased, comma separated source columns of each
+// foreign key declared in createSQL to its uppercased definition.
+func foreignKeyClauses(createSQL string) map[string]string {
+	clauses := make(map[string]string)
+	for _, definition := range tableDefinitions(createSQL) {
+		upper := strings.ToUpper(definition)
+		if !strings.Contains(upper, "REFERENCES") {
+			continue
+		}
+
+		if fkIndex := strings.Index(upper, "FOREIGN KEY"); fkIndex >= 0 {
+			// table constraint: [CONSTRAINT name] FOREIGN KEY (a, b) REFERENCES ...
+			open := strings.Index(definition[fkIndex:], "(")
+			close := strings.Index(definition[fkIndex:], ")")
+			if open < 0 || close < open {
+				continue
+			}
+			var columns []string
+			for _, column := range strings.Split(definition[fkIndex+open+1:fkIndex+close], ",") {
+				columns = append(columns, unquoteIdentifier(column))
+			}
+			clauses[strings.ToLower(strings.Join(columns, ","))] = upper
+		} else if fields := strings.Fields(definition); len(fields) > 0 {
+			// column constraint: name TYPE ... REFERENCES ...
+			clauses[strings.ToLower(unquoteIdentifier(fields[0]))] = upper
+		}
+	}
+	return clauses
+}
 
-	return uniqueIndexes, nil
+func unquoteIdentifier(identifier string) string {
+	return strings.Trim(strings.TrimSpace(identifier), "\"`[]'")
 }
 
 func (conn SQLiteConnector) GetTableNames(db *gorm.DB) ([]string, error) {
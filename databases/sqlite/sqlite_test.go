@@ -0,0 +1,382 @@
+package sqliteConnector
+
+import (
+	"context"
+	"db_meta/dbstructs"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"gorm.io/gorm"
+)
+
+func createTestSchema(db *gorm.DB) error {
+	statements := []string{`
+  CREATE TABLE IF NOT EXISTS table1 (
+      id INTEGER PRIMARY KEY,
+      name VARCHAR(255)
+  );`,
+
+		`CREATE TABLE IF NOT EXISTS table2 (
+      id INTEGER PRIMARY KEY,
+      description VARCHAR(255),
+      table1_id INT REFERENCES table1
+  );`,
+
+		`CREATE TABLE IF NOT EXISTS table3 (
+      region VARCHAR(10),
+      code INT,
+      info VARCHAR(255),
+      price DECIMAL(10, 2) NOT NULL DEFAULT 0,
+      label TEXT GENERATED ALWAYS AS (region || '-' || code) VIRTUAL,
+      PRIMARY KEY (region, code)
+  );`,
+
+		`CREATE TABLE IF NOT EXISTS table4 (
+      id INTEGER PRIMARY KEY,
+      table3_region VARCHAR(10),
+      table3_code INT,
+      FOREIGN KEY (table3_region, table3_code) REFERENCES table3(region, code)
+          ON DELETE CASCADE ON UPDATE SET NULL DEFERRABLE INITIALLY DEFERRED
+  );`,
+
+		`CREATE TABLE IF NOT EXISTS table5 (
+      id INTEGER PRIMARY KEY,
+      status TEXT CHECK (status IN ('draft', 'published')),
+      quantity INT NOT NULL CONSTRAINT quantity_positive CHECK (quantity >= 0),
+      code VARCHAR(8),
+      CHECK (length(code) <= 8 AND quantity < 100)
+  );`,
+
+		`CREATE INDEX IF NOT EXISTS table5_status_quantity_idx ON table5 (status, quantity DESC) WHERE quantity > 0;`,
+
+		`CREATE INDEX IF NOT EXISTS table5_code_idx ON table5 (lower(code) COLLATE NOCASE);`,
+
+		`CREATE TABLE IF NOT EXISTS table5_log (
+      table5_id INT,
+      logged_at TEXT
+  );`,
+
+		`CREATE TRIGGER IF NOT EXISTS table5_after_update AFTER UPDATE OF status ON table5
+      FOR EACH ROW WHEN NEW.status = 'published'
+      BEGIN
+          INSERT INTO table5_log (table5_id, logged_at) VALUES (NEW.id, datetime('now'));
+      END;`,
+
+		`CREATE TRIGGER IF NOT EXISTS table1_delete DELETE ON table1
+      BEGIN
+          DELETE FROM table2 WHERE table1_id = OLD.id;
+      END;`,
+
+		`CREATE VIEW IF NOT EXISTS view1 AS
+      SELECT table2.id, table2.description, table1.name
+      FROM table2 JOIN table1 ON table1.id = table2.table1_id;`,
+
+		`CREATE VIEW IF NOT EXISTS view2 AS SELECT id FROM view1 WHERE name = 'table3';`}
+
+	for _, stmt := range statements {
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func connectTestDB(t *testing.T) *gorm.DB {
+	connector := SQLiteConnector{}
+	db, err := connector.Connect(context.Background(), "", "", filepath.Join(t.TempDir(), "test.db"), "", "")
+	assert.NoError(t, err)
+	assert.NoError(t, createTestSchema(db))
+	return db
+}
+
+func TestSQLiteConnector_GetPrimaryKeys_composite(t *testing.T) {
+	db := connectTestDB(t)
+	connector := SQLiteConnector{}
+
+	primaryKeys, err := connector.GetPrimaryKeys(db, "table3")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"region", "code"}, primaryKeys)
+}
+
+func TestSQLiteConnector_GetRelationships(t *testing.T) {
+	db := connectTestDB(t)
+	connector := SQLiteConnector{}
+
+	// Implied parent key
+	relationships, err := connector.GetRelationships(db, "table2")
+	assert.NoError(t, err)
+	assert.Len(t, relationships, 1)
+	assert.Equal(t, "table2", relationships[0].SourceTableName)
+	assert.Equal(t, "table1", relationships[0].RelatedTableName)
+	assert.Equal(t, []string{"table1_id"}, relationships[0].SourceColumns)
+	assert.Equal(t, []string{"id"}, relationships[0].TargetColumns)
+
+	// Composite key
+	relationships, err = connector.GetRelationships(db, "table4")
+	assert.NoError(t, err)
+	assert.Len(t, relationships, 1)
+	assert.Equal(t, "table3_region_table3_code", relationships[0].Conname)
+	assert.Equal(t, []string{"table3_region", "table3_code"}, relationships[0].SourceColumns)
+	assert.Equal(t, []string{"region", "code"}, relationships[0].TargetColumns)
+}
+
+func TestSQLiteConnector_GetRelationships_referentialActions(t *testing.T) {
+	db := connectTestDB(t)
+	connector := SQLiteConnector{}
+
+	relationships, err := connector.GetRelationships(db, "table2")
+	assert.NoError(t, err)
+	assert.Equal(t, "NO ACTION", relationships[0].OnDelete)
+	assert.Equal(t, "NO ACTION", relationships[0].OnUpdate)
+	assert.False(t, relationships[0].Deferrable)
+
+	relationships, err = connector.GetRelationships(db, "table4")
+	assert.NoError(t, err)
+	assert.Equal(t, "CASCADE", relationships[0].OnDelete)
+	assert.Equal(t, "SET NULL", relationships[0].OnUpdate)
+	assert.True(t, relationships[0].Deferrable)
+	assert.True(t, relationships[0].InitiallyDeferred)
+}
+
+func TestSQLiteConnector_GetViewMetadata(t *testing.T) {
+	db := connectTestDB(t)
+	connector := SQLiteConnector{}
+
+	views, err := connector.GetViewMetadata(context.Background(), db)
+	assert.NoError(t, err)
+	assert.Len(t, views, 2)
+
+	assert.Equal(t, "view1", views[0].ViewName)
+	assert.False(t, views[0].Materialized)
+	assert.Contains(t, views[0].Definition, "CREATE VIEW")
+	assert.Equal(t, []string{"table1", "table2"}, views[0].Dependencies)
+	assert.Len(t, views[0].Columns, 3)
+	assert.Equal(t, "description", views[0].Columns[1].ColumnName)
+
+	assert.Equal(t, "view2", views[1].ViewName)
+	assert.Equal(t, []string{"view1"}, views[1].Dependencies)
+}
+
+func TestSQLiteConnector_GetColumns(t *testing.T) {
+	db := connectTestDB(t)
+	connector := SQLiteConnector{}
+
+	// INTEGER PRIMARY KEY aliases the rowid
+	columns, err := connector.GetColumns(db, "table1")
+	assert.NoError(t, err)
+	assert.True(t, columns[0].AutoIncrement)
+	assert.Equal(t, 255, *columns[1].CharacterLength)
+	assert.Equal(t, 2, columns[1].OrdinalPosition)
+
+	columns, err = connector.GetColumns(db, "table3")
+	assert.NoError(t, err)
+	assert.Len(t, columns, 5)
+	assert.False(t, columns[0].AutoIncrement)
+
+	price := columns[3]
+	assert.Equal(t, "price", price.ColumnName)
+	assert.Equal(t, "0", *price.Default)
+	assert.Equal(t, 10, *price.NumericPrecision)
+	assert.Equal(t, 2, *price.NumericScale)
+	assert.Nil(t, price.CharacterLength)
+
+	label := columns[4]
+	assert.Equal(t, "label", label.ColumnName)
+	assert.Equal(t, "region || '-' || code", label.Generated)
+	assert.Nil(t, label.Default)
+}
+
+func TestSQLiteConnector_GetCheckConstraints(t *testing.T) {
+	db := connectTestDB(t)
+	connector := SQLiteConnector{}
+
+	checks, err := connector.GetCheckConstraints(db, "table5", []string{"id", "status", "quantity", "code"})
+	assert.NoError(t, err)
+	assert.Len(t, checks, 3)
+
+	assert.Equal(t, "table5_status_check", checks[0].Name)
+	assert.Equal(t, []string{"status"}, checks[0].Columns)
+	assert.Equal(t, "status IN ('draft', 'published')", checks[0].Expression)
+
+	assert.Equal(t, "quantity_positive", checks[1].Name)
+	assert.Equal(t, []string{"quantity"}, checks[1].Columns)
+
+	assert.Equal(t, "table5_check", checks[2].Name)
+	assert.Equal(t, []string{"quantity", "code"}, checks[2].Columns)
+	assert.Equal(t, "length(code) <= 8 AND quantity < 100", checks[2].Expression)
+
+	checks, err = connector.GetCheckConstraints(db, "table1", []string{"id", "name"})
+	assert.NoError(t, err)
+	assert.Empty(t, checks)
+}
+
+func TestSQLiteConnector_GetIndexes(t *testing.T) {
+	db := connectTestDB(t)
+	connector := SQLiteConnector{}
+
+	indexes, err := connector.GetIndexes(db, "table5")
+	assert.NoError(t, err)
+	assert.Len(t, indexes, 2)
+
+	byName := make(map[string]*dbstructs.Index)
+	for _, index := range indexes {
+		byName[index.Name] = index
+	}
+
+	partial := byName["table5_status_quantity_idx"]
+	assert.Equal(t, []string{"status", "quantity"}, partial.Columns)
+	assert.False(t, partial.Keys[0].Descending)
+	assert.True(t, partial.Keys[1].Descending)
+	assert.Equal(t, "quantity > 0", partial.Predicate)
+	assert.False(t, partial.Unique)
+
+	expression := byName["table5_code_idx"]
+	assert.Equal(t, "lower(code)", expression.Keys[0].Expression)
+	assert.Empty(t, expression.Keys[0].Column)
+
+	// Composite primary key index
+	indexes, err = connector.GetIndexes(db, "table3")
+	assert.NoError(t, err)
+	assert.Len(t, indexes, 1)
+	assert.True(t, indexes[0].Primary)
+	assert.True(t, indexes[0].Unique)
+	assert.Equal(t, []string{"region", "code"}, indexes[0].Columns)
+}
+
+func TestSQLiteConnector_GetTriggerMetadata(t *testing.T) {
+	db := connectTestDB(t)
+	connector := SQLiteConnector{}
+
+	triggers, err := connector.GetTriggerMetadata(context.Background(), db)
+	assert.NoError(t, err)
+	assert.Len(t, triggers, 2)
+
+	// Timing defaults to BEFORE
+	assert.Equal(t, "table1_delete", triggers[0].TriggerName)
+	assert.Equal(t, "table1", triggers[0].TableName)
+	assert.Equal(t, "BEFORE", triggers[0].Timing)
+	assert.Equal(t, []string{"DELETE"}, triggers[0].Events)
+	assert.Equal(t, "DELETE FROM table2 WHERE table1_id = OLD.id;", triggers[0].Body)
+	assert.Equal(t, []string{"table2"}, triggers[0].References)
+
+	assert.Equal(t, "table5_after_update", triggers[1].TriggerName)
+	assert.Equal(t, "AFTER", triggers[1].Timing)
+	assert.Equal(t, []string{"UPDATE"}, triggers[1].Events)
+	assert.Equal(t, "ROW", triggers[1].Orientation)
+	assert.Equal(t, []string{"table5_log"}, triggers[1].References)
+}
+
+// The bulk load must agree with the per table queries
+func TestSQLiteConnector_GetTableMetadata(t *testing.T) {
+	db := connectTestDB(t)
+	connector := SQLiteConnector{}
+
+	tables, err := connector.GetTableMetadata(context.Background(), db)
+	assert.NoError(t, err)
+	assert.Len(t, tables, 6)
+
+	for _, table := range tables {
+		columns, err := connector.GetColumns(db, table.TableName)
+		assert.NoError(t, err)
+		assert.Equal(t, columns, table.Columns, table.TableName)
+
+		primaryKeys, err := connector.GetPrimaryKeys(db, table.TableName)
+		assert.NoError(t, err)
+		assert.Equal(t, primaryKeys, table.PrimaryKey, table.TableName)
+
+		relationships, err := connector.GetRelationships(db, table.TableName)
+		assert.NoError(t, err)
+		assert.Equal(t, relationships, table.Relationships, table.TableName)
+
+		indexes, err := connector.GetIndexes(db, table.TableName)
+		assert.NoError(t, err)
+		assert.Equal(t, indexes, table.Indexes, table.TableName)
+
+		checks, err := connector.GetCheckConstraints(db, table.TableName, table.ColumnNames())
+		assert.NoError(t, err)
+		assert.Equal(t, checks, table.CheckConstraints, table.TableName)
+	}
+}
+
+// Loading the tables batch by batch must give the same result
+func TestSQLiteConnector_LoadTableMetadata(t *testing.T) {
+	db := connectTestDB(t)
+	connector := SQLiteConnector{}
+
+	expected, err := connector.GetTableMetadata(context.Background(), db)
+	assert.NoError(t, err)
+
+	tables, err := connector.GetTables(context.Background(), db)
+	assert.NoError(t, err)
+	assert.Len(t, tables, len(expected))
+	assert.NoError(t, connector.LoadTableMetadata(context.Background(), db, tables[:2]))
+	assert.NoError(t, connector.LoadTableMetadata(context.Background(), db, tables[2:]))
+	assert.Equal(t, expected, tables)
+
+	// the implied parent key of table2 is outside of the batch
+	batch := []*dbstructs.TableMetadata{{TableName: "table2"}}
+	assert.NoError(t, connector.LoadTableMetadata(context.Background(), db, batch))
+	assert.Equal(t, []string{"id"}, batch[0].Relationships[0].TargetColumns)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.ErrorIs(t, connector.LoadTableMetadata(ctx, db, tables), context.Canceled)
+}
+
+// createLargeSchema creates tableCount chained tables, each with a foreign key,
+// a check constraint and two indexes.
+func createLargeSchema(db *gorm.DB, tableCount int) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		for i := 0; i < tableCount; i++ {
+			statements := []string{
+				fmt.Sprintf(`CREATE TABLE t%d (
+            id INTEGER PRIMARY KEY,
+            parent_id INT REFERENCES t%d(id) ON DELETE CASCADE,
+            name VARCHAR(64) NOT NULL,
+            amount DECIMAL(10, 2) CHECK (amount >= 0),
+            created_at TEXT DEFAULT CURRENT_TIMESTAMP
+        );`, i, (i+tableCount-1)%tableCount),
+				fmt.Sprintf(`CREATE INDEX t%d_parent_idx ON t%d (parent_id);`, i, i),
+				fmt.Sprintf(`CREATE UNIQUE INDEX t%d_name_key ON t%d (name);`, i, i),
+			}
+			for _, stmt := range statements {
+				if err := tx.Exec(stmt).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+func BenchmarkSQLiteConnector_GetTableMetadata(b *testing.B) {
+	connector := SQLiteConnector{}
+	db, err := connector.Connect(context.Background(), "", "", filepath.Join(b.TempDir(), "large.db"), "", "")
+	if err != nil {
+		b.Fatal(err)
+	}
+	if err := createLargeSchema(db, 2000); err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tables, err := connector.GetTableMetadata(context.Background(), db)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if len(tables) != 2000 {
+			b.Fatalf("got %d tables", len(tables))
+		}
+	}
+}
+
+func TestSQLiteConnector_GetServerVersion(t *testing.T) {
+	db := connectTestDB(t)
+	version, err := SQLiteConnector{}.GetServerVersion(context.Background(), db)
+	assert.NoError(t, err)
+	assert.Regexp(t, `^3\.\d+\.\d+`, version)
+}
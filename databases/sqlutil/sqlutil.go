@@ -0,0 +1,115 @@
+package sqlutil
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Identifiers returns the (possibly dotted) identifier chains found in sqlText,
+// unquoted, skipping string literals and comments: `db`.`t1` gives "db.t1".
+func Identifiers(sqlText string) []string {
+	var chains []string
+	var chain []string
+	expectPart := false // a dot was just read, the chain goes on
+
+	flush := func() {
+		if len(chain) > 0 {
+			chains = append(chains, strings.Join(chain, "."))
+		}
+		chain = nil
+		expectPart = false
+	}
+
+	runes := []rune(sqlText)
+	for i := 0; i < len(runes); i++ {
+		char := runes[i]
+		switch {
+		case char == '-' && i+1 < len(runes) && runes[i+1] == '-':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+			flush()
+		case char == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			i += 2
+			for i+1 < len(runes) && !(runes[i] == '*' && runes[i+1] == '/') {
+				i++
+			}
+			i++
+			flush()
+		case char == '\'':
+			i++
+			for i < len(runes) && !(runes[i] == '\'' && (i+1 >= len(runes) || runes[i+1] != '\'')) {
+				if runes[i] == '\'' {
+					i++ // escaped quote
+				}
+				i++
+			}
+			flush()
+		case char == '"' || char == '`' || char == '[':
+			closing := char
+			if char == '[' {
+				closing = ']'
+			}
+			start := i + 1
+			for i++; i < len(runes) && runes[i] != closing; i++ {
+			}
+			if len(chain) > 0 && !expectPart {
+				flush()
+			}
+			chain = append(chain, string(runes[start:i]))
+			expectPart = false
+		case unicode.IsLetter(char) || char == '_':
+			start := i
+			for i+1 < len(runes) && (unicode.IsLetter(runes[i+1]) || unicode.IsDigit(runes[i+1]) || runes[i+1] == '_' || runes[i+1] == '$') {
+				i++
+			}
+			if len(chain) > 0 && !expectPart {
+				flush()
+			}
+			chain = append(chain, string(runes[start:i+1]))
+			expectPart = false
+		case char == '.' && len(chain) > 0:
+			expectPart = true
+		case unicode.IsSpace(char) && expectPart:
+			// "schema. table" is unusual but valid
+		default:
+			flush()
+		}
+	}
+	flush()
+	return chains
+}
+
+// ReferencedNames returns the candidates (plain or "schema.name") referenced in
+// sqlText, in candidates order. A reference matches a candidate when their last
+// parts are equal, ignoring case, and their schemas agree whenever both have one.
+func ReferencedNames(sqlText string, candidates []string) []string {
+	found := make(map[string]bool)
+	for _, identifier := range Identifiers(sqlText) {
+		parts := strings.Split(strings.ToLower(identifier), ".")
+		name := parts[len(parts)-1]
+		schema := ""
+		if len(parts) > 1 {
+			schema = parts[len(parts)-2]
+		}
+		for _, candidate := range candidates {
+			candidateParts := strings.Split(strings.ToLower(candidate), ".")
+			if candidateParts[len(candidateParts)-1] != name {
+				continue
+			}
+			if len(candidateParts) > 1 && schema != "" && candidateParts[len(candidateParts)-2] != schema {
+				continue
+			}
+			found[candidate] = true
+		}
+	}
+
+	var names []string
+	for _, candidate := range candidates {
+		if found[candidate] {
+			names = append(names, candidate)
+			delete(found, candidate)
+		}
+	}
+	return names
+}
@@ -0,0 +1,29 @@
+package sqlutil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdentifiers(t *testing.T) {
+	identifiers := Identifiers("select `t`.`id`, 'it''s -- not a comment' from `testdb`.`table1` t -- table2\n join [dbo].[table3] /* table4 */ on \"x\".y = 1")
+	assert.Equal(t, []string{"select", "t.id", "from", "testdb.table1", "t", "join", "dbo.table3", "on", "x.y"}, identifiers)
+}
+
+func TestReferencedNames(t *testing.T) {
+	candidates := []string{"table1", "table2", "sales.orders", "crm.orders"}
+
+	names := ReferencedNames("SELECT * FROM `testdb`.`table2` JOIN Table1 ON 1 = 1", candidates)
+	assert.Equal(t, []string{"table1", "table2"}, names)
+
+	names = ReferencedNames("SELECT * FROM sales.orders", candidates)
+	assert.Equal(t, []string{"sales.orders"}, names)
+
+	// unqualified, could be any schema
+	names = ReferencedNames("SELECT * FROM orders", candidates)
+	assert.Equal(t, []string{"sales.orders", "crm.orders"}, names)
+
+	names = ReferencedNames("SELECT 'table1' AS label", candidates)
+	assert.Empty(t, names)
+}
@@ -0,0 +1,185 @@
+// Package sshtest runs in-process SSH servers standing in for bastion hosts in
+// tests. They authenticate one user and forward direct-tcpip channels, the
+// requests of ssh -L and of Tunnel.DialContext.
+package sshtest
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// Server is an SSH server listening on 127.0.0.1 until the end of the test.
+type Server struct {
+	Host string
+	Port string
+
+	hostKey ssh.Signer
+	mu      sync.Mutex
+	dialed  []string
+}
+
+// Credentials are accepted by a Server, a password, a public key or both.
+type Credentials struct {
+	User      string
+	Password  string
+	PublicKey ssh.PublicKey
+}
+
+// NewServer starts a server accepting credentials.
+func NewServer(t testing.TB, credentials Credentials) *Server {
+	t.Helper()
+	hostKey, _ := NewKey(t, "")
+	config := &ssh.ServerConfig{
+		PasswordCallback: func(meta ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if credentials.Password != "" && meta.User() == credentials.User && string(password) == credentials.Password {
+				return nil, nil
+			}
+			return nil, errors.New("wrong password")
+		},
+		PublicKeyCallback: func(meta ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if credentials.PublicKey != nil && meta.User() == credentials.User && bytes.Equal(key.Marshal(), credentials.PublicKey.Marshal()) {
+				return nil, nil
+			}
+			return nil, errors.New("unknown key")
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	server := &Server{Host: host, Port: port, hostKey: hostKey}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn, config)
+		}
+	}()
+	return server
+}
+
+func (s *Server) serve(conn net.Conn, config *ssh.ServerConfig) {
+	serverConn, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	defer serverConn.Close()
+	go ssh.DiscardRequests(requests)
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "direct-tcpip" {
+			newChannel.Reject(ssh.UnknownChannelType, "only direct-tcpip is supported")
+			continue
+		}
+		go s.forward(newChannel)
+	}
+}
+
+// forward connects a direct-tcpip channel to its destination.
+func (s *Server) forward(newChannel ssh.NewChannel) {
+	// RFC 4254 7.2: host, port, originator host and port
+	var request struct {
+		Host       string
+		Port       uint32
+		OriginHost string
+		OriginPort uint32
+	}
+	if err := ssh.Unmarshal(newChannel.ExtraData(), &request); err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	addr := net.JoinHostPort(request.Host, strconv.FormatUint(uint64(request.Port), 10))
+	target, err := net.Dial("tcp", addr)
+	if err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	s.mu.Lock()
+	s.dialed = append(s.dialed, addr)
+	s.mu.Unlock()
+
+	channel, requests, err := newChannel.Accept()
+	if err != nil {
+		target.Close()
+		return
+	}
+	go ssh.DiscardRequests(requests)
+	go func() {
+		io.Copy(channel, target)
+		channel.CloseWrite()
+	}()
+	io.Copy(target, channel)
+	target.Close()
+	channel.Close()
+}
+
+// Dialed returns the addresses the server forwarded connections to.
+func (s *Server) Dialed() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.dialed...)
+}
+
+// KnownHosts writes a known_hosts file holding the keys of servers and returns
+// its path.
+func KnownHosts(t testing.TB, servers ...*Server) string {
+	t.Helper()
+	var lines []string
+	for _, server := range servers {
+		lines = append(lines, knownhosts.Line([]string{net.JoinHostPort(server.Host, server.Port)}, server.hostKey.PublicKey()))
+	}
+	path := filepath.Join(t.TempDir(), "known_hosts")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// NewKey generates an ed25519 key, returned as a signer and as an OpenSSH
+// private key file, encrypted when passphrase is set.
+func NewKey(t testing.TB, passphrase string) (ssh.Signer, string) {
+	t.Helper()
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := ssh.NewSignerFromKey(privateKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var block *pem.Block
+	if passphrase != "" {
+		block, err = ssh.MarshalPrivateKeyWithPassphrase(privateKey, "db_meta test", []byte(passphrase))
+	} else {
+		block, err = ssh.MarshalPrivateKey(privateKey, "db_meta test")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "id_ed25519")
+	if err := os.WriteFile(path, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return signer, path
+}
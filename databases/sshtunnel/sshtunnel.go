@@ -0,0 +1,287 @@
+// Package sshtunnel reaches databases through SSH: a chain of SSH servers, the
+// bastion first, each one being dialed from the previous one. The connections
+// to the database are then dialed from the last server of the chain.
+package sshtunnel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// Hop is an SSH server of the chain, authenticated by password, private key or
+// both.
+type Hop struct {
+	Host          string `json:"host"`
+	Port          string `json:"port"` // 22 when empty
+	User          string `json:"user"`
+	Password      string `json:"password"`
+	KeyFile       string `json:"keyFile"`       // PEM or OpenSSH private key
+	KeyPassphrase string `json:"keyPassphrase"` // when KeyFile is encrypted
+}
+
+func (h Hop) address() string {
+	port := h.Port
+	if port == "" {
+		port = "22"
+	}
+	return net.JoinHostPort(h.Host, port)
+}
+
+// Config is the SSH chain, no tunnel is opened without hops.
+type Config struct {
+	Hops []Hop `json:"hops"`
+	// KnownHostsFile holds the keys of the servers, ~/.ssh/known_hosts when
+	// empty. Unknown or mismatching host keys are rejected.
+	KnownHostsFile string `json:"knownHostsFile"`
+}
+
+// Enabled tells whether the connections go through a tunnel.
+func (c Config) Enabled() bool {
+	return len(c.Hops) > 0
+}
+
+// Validate checks that every hop has a host, a user and a way to authenticate.
+func (c Config) Validate() error {
+	for i, hop := range c.Hops {
+		if hop.Host == "" || hop.User == "" {
+			return fmt.Errorf("SSH hop %d needs a host and a user", i+1)
+		}
+		if hop.Password == "" && hop.KeyFile == "" {
+			return fmt.Errorf("SSH hop %s needs a password or a key file", hop.address())
+		}
+	}
+	return nil
+}
+
+// Tunnel is an open SSH chain.
+type Tunnel struct {
+	mu      sync.Mutex
+	clients []*ssh.Client // in connection order, nil once closed
+}
+
+// Open connects the SSH chain, cancelling ctx aborts it.
+func Open(ctx context.Context, config Config) (*Tunnel, error) {
+	if !config.Enabled() {
+		return nil, errors.New("no SSH host to connect to")
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	hostKeyCallback, err := hostKeyCallback(config.KnownHostsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	tunnel := &Tunnel{}
+	for _, hop := range config.Hops {
+		clientConfig, err := hop.clientConfig(hostKeyCallback)
+		if err != nil {
+			tunnel.Close()
+			return nil, err
+		}
+		var conn net.Conn
+		if len(tunnel.clients) == 0 {
+			var dialer net.Dialer
+			conn, err = dialer.DialContext(ctx, "tcp", hop.address())
+		} else {
+			conn, err = tunnel.dial(ctx, "tcp", hop.address())
+		}
+		if err != nil {
+			tunnel.Close()
+			return nil, fmt.Errorf("SSH %s: %w", hop.address(), err)
+		}
+		client, err := handshake(ctx, conn, hop.address(), clientConfig)
+		if err != nil {
+			conn.Close()
+			tunnel.Close()
+			return nil, fmt.Errorf("SSH %s: %w", hop.address(), err)
+		}
+		tunnel.clients = append(tunnel.clients, client)
+	}
+	return tunnel, nil
+}
+
+func hostKeyCallback(knownHostsFile string) (ssh.HostKeyCallback, error) {
+	if knownHostsFile == "" {
+		knownHostsFile = "~/.ssh/known_hosts"
+	}
+	path, err := expandHome(knownHostsFile)
+	if err != nil {
+		return nil, err
+	}
+	return knownhosts.New(path)
+}
+
+// expandHome replaces the ~/ prefix of path by the home directory.
+func expandHome(path string) (string, error) {
+	if !strings.HasPrefix(path, "~/") {
+		return path, nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, path[2:]), nil
+}
+
+func (h Hop) clientConfig(hostKeyCallback ssh.HostKeyCallback) (*ssh.ClientConfig, error) {
+	config := &ssh.ClientConfig{User: h.User, HostKeyCallback: hostKeyCallback}
+	if h.KeyFile != "" {
+		path, err := expandHome(h.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		pem, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var signer ssh.Signer
+		if h.KeyPassphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(pem, []byte(h.KeyPassphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey(pem)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("SSH key %s: %w", h.KeyFile, err)
+		}
+		config.Auth = append(config.Auth, ssh.PublicKeys(signer))
+	}
+	if h.Password != "" {
+		config.Auth = append(config.Auth, ssh.Password(h.Password))
+	}
+	return config, nil
+}
+
+// handshake runs the SSH handshake on conn, closing it if ctx is done first.
+func handshake(ctx context.Context, conn net.Conn, addr string, config *ssh.ClientConfig) (*ssh.Client, error) {
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+	clientConn, channels, requests, err := ssh.NewClientConn(conn, addr, config)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ssh.NewClient(clientConn, channels, requests), nil
+}
+
+// DialContext opens a connection to addr from the last server of the chain,
+// addr being resolved there.
+func (t *Tunnel) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	conn, err := t.dial(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	return &deadlineConn{Conn: conn}, nil
+}
+
+func (t *Tunnel) dial(ctx context.Context, network, addr string) (net.Conn, error) {
+	t.mu.Lock()
+	if len(t.clients) == 0 {
+		t.mu.Unlock()
+		return nil, net.ErrClosed
+	}
+	client := t.clients[len(t.clients)-1]
+	t.mu.Unlock()
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+	dialed := make(chan result, 1)
+	go func() {
+		conn, err := client.Dial(network, addr)
+		dialed <- result{conn, err}
+	}()
+	select {
+	case r := <-dialed:
+		return r.conn, r.err
+	case <-ctx.Done():
+		go func() {
+			// the dial can't be interrupted, drop its connection
+			if r := <-dialed; r.conn != nil {
+				r.conn.Close()
+			}
+		}()
+		return nil, ctx.Err()
+	}
+}
+
+// Close disconnects the chain, the last server first.
+func (t *Tunnel) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var err error
+	for i := len(t.clients) - 1; i >= 0; i-- {
+		if closeErr := t.clients[i].Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+	}
+	t.clients = nil
+	return err
+}
+
+// deadlineConn adds deadlines to the SSH channels, which don't support them: a
+// connection outliving its deadline is closed, failing the pending reads and
+// writes as a timeout would.
+type deadlineConn struct {
+	net.Conn
+	mu         sync.Mutex
+	readTimer  *time.Timer
+	writeTimer *time.Timer
+}
+
+func (c *deadlineConn) SetDeadline(t time.Time) error {
+	c.SetReadDeadline(t)
+	return c.SetWriteDeadline(t)
+}
+
+func (c *deadlineConn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.readTimer = c.closeAt(c.readTimer, t)
+	return nil
+}
+
+func (c *deadlineConn) SetWriteDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.writeTimer = c.closeAt(c.writeTimer, t)
+	return nil
+}
+
+// closeAt replaces timer by one closing the connection at t, none for a zero t.
+func (c *deadlineConn) closeAt(timer *time.Timer, t time.Time) *time.Timer {
+	if timer != nil {
+		timer.Stop()
+	}
+	if t.IsZero() {
+		return nil
+	}
+	return time.AfterFunc(time.Until(t), func() { c.Conn.Close() })
+}
+
+func (c *deadlineConn) Close() error {
+	c.mu.Lock()
+	c.readTimer = c.closeAt(c.readTimer, time.Time{})
+	c.writeTimer = c.closeAt(c.writeTimer, time.Time{})
+	c.mu.Unlock()
+	return c.Conn.Close()
+}
@@ -0,0 +1,169 @@
+package sshtunnel
+
+import (
+	"bufio"
+	"context"
+	"db_meta/databases/sshtunnel/sshtest"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// startEcho starts a TCP server sending back every line it reads.
+func startEcho(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				io.Copy(conn, conn)
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func assertEcho(t *testing.T, tunnel *Tunnel, addr string) {
+	conn, err := tunnel.DialContext(context.Background(), "tcp", addr)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.Close()
+	_, err = conn.Write([]byte("hello\n"))
+	assert.NoError(t, err)
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	assert.NoError(t, err)
+	assert.Equal(t, "hello\n", line)
+}
+
+func hop(server *sshtest.Server, user string) Hop {
+	return Hop{Host: server.Host, Port: server.Port, User: user}
+}
+
+func TestOpen_password(t *testing.T) {
+	echo := startEcho(t)
+	bastion := sshtest.NewServer(t, sshtest.Credentials{User: "admin", Password: "secret"})
+
+	bastionHop := hop(bastion, "admin")
+	bastionHop.Password = "secret"
+	tunnel, err := Open(context.Background(), Config{Hops: []Hop{bastionHop}, KnownHostsFile: sshtest.KnownHosts(t, bastion)})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer tunnel.Close()
+	assertEcho(t, tunnel, echo)
+	assert.Equal(t, []string{echo}, bastion.Dialed())
+
+	bastionHop.Password = "wrong"
+	_, err = Open(context.Background(), Config{Hops: []Hop{bastionHop}, KnownHostsFile: sshtest.KnownHosts(t, bastion)})
+	assert.ErrorContains(t, err, "unable to authenticate")
+}
+
+func TestOpen_key(t *testing.T) {
+	echo := startEcho(t)
+	signer, keyFile := sshtest.NewKey(t, "passphrase")
+	bastion := sshtest.NewServer(t, sshtest.Credentials{User: "admin", PublicKey: signer.PublicKey()})
+
+	bastionHop := hop(bastion, "admin")
+	bastionHop.KeyFile = keyFile
+	bastionHop.KeyPassphrase = "passphrase"
+	tunnel, err := Open(context.Background(), Config{Hops: []Hop{bastionHop}, KnownHostsFile: sshtest.KnownHosts(t, bastion)})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer tunnel.Close()
+	assertEcho(t, tunnel, echo)
+
+	bastionHop.KeyPassphrase = ""
+	_, err = Open(context.Background(), Config{Hops: []Hop{bastionHop}, KnownHostsFile: sshtest.KnownHosts(t, bastion)})
+	assert.ErrorContains(t, err, "passphrase")
+}
+
+func TestOpen_knownHosts(t *testing.T) {
+	bastion := sshtest.NewServer(t, sshtest.Credentials{User: "admin", Password: "secret"})
+	other := sshtest.NewServer(t, sshtest.Credentials{User: "admin", Password: "secret"})
+
+	bastionHop := hop(bastion, "admin")
+	bastionHop.Password = "secret"
+	// the key of other under the address of bastion
+	knownHosts := sshtest.KnownHosts(t, other)
+	_, err := Open(context.Background(), Config{Hops: []Hop{bastionHop}, KnownHostsFile: knownHosts})
+	assert.ErrorContains(t, err, "knownhosts: key is unknown")
+}
+
+func TestOpen_jumpHosts(t *testing.T) {
+	echo := startEcho(t)
+	signer, keyFile := sshtest.NewKey(t, "")
+	bastion := sshtest.NewServer(t, sshtest.Credentials{User: "admin", Password: "secret"})
+	jump := sshtest.NewServer(t, sshtest.Credentials{User: "deploy", PublicKey: signer.PublicKey()})
+
+	bastionHop := hop(bastion, "admin")
+	bastionHop.Password = "secret"
+	jumpHop := hop(jump, "deploy")
+	jumpHop.KeyFile = keyFile
+	tunnel, err := Open(context.Background(), Config{Hops: []Hop{bastionHop, jumpHop}, KnownHostsFile: sshtest.KnownHosts(t, bastion, jump)})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer tunnel.Close()
+	assertEcho(t, tunnel, echo)
+
+	// the bastion reaches the jump host, which reaches the database
+	assert.Equal(t, []string{net.JoinHostPort(jump.Host, jump.Port)}, bastion.Dialed())
+	assert.Equal(t, []string{echo}, jump.Dialed())
+}
+
+func TestTunnel_DialContext_deadline(t *testing.T) {
+	echo := startEcho(t)
+	bastion := sshtest.NewServer(t, sshtest.Credentials{User: "admin", Password: "secret"})
+	bastionHop := hop(bastion, "admin")
+	bastionHop.Password = "secret"
+	tunnel, err := Open(context.Background(), Config{Hops: []Hop{bastionHop}, KnownHostsFile: sshtest.KnownHosts(t, bastion)})
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer tunnel.Close()
+
+	conn, err := tunnel.DialContext(context.Background(), "tcp", echo)
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer conn.Close()
+	// the echo server has nothing to send back
+	assert.NoError(t, conn.SetReadDeadline(time.Now().Add(50*time.Millisecond)))
+	_, err = conn.Read(make([]byte, 1))
+	assert.Error(t, err)
+
+	tunnel.Close()
+	_, err = tunnel.DialContext(context.Background(), "tcp", echo)
+	assert.ErrorIs(t, err, net.ErrClosed)
+}
+
+func TestOpen_cancelled(t *testing.T) {
+	bastion := sshtest.NewServer(t, sshtest.Credentials{User: "admin", Password: "secret"})
+	bastionHop := hop(bastion, "admin")
+	bastionHop.Password = "secret"
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := Open(ctx, Config{Hops: []Hop{bastionHop}, KnownHostsFile: sshtest.KnownHosts(t, bastion)})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestConfig_Validate(t *testing.T) {
+	assert.NoError(t, Config{}.Validate())
+	assert.Error(t, Config{Hops: []Hop{{Host: "bastion", Password: "secret"}}}.Validate())
+	assert.Error(t, Config{Hops: []Hop{{Host: "bastion", User: "admin"}}}.Validate())
+	assert.NoError(t, Config{Hops: []Hop{{Host: "bastion", User: "admin", KeyFile: "id_ed25519"}}}.Validate())
+}
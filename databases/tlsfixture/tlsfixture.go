@@ -0,0 +1,248 @@
+// Package tlsfixture runs local TLS servers for the connector tests. A server
+// speaks just enough of a database protocol for the client to start TLS, then
+// reports the handshake and closes the connection: the connection itself fails,
+// the tests check the negotiated TLS.
+package tlsfixture
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/binary"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// ClientCommonName is the subject of the client certificate of Certificates.
+const ClientCommonName = "db_meta-client"
+
+// Certificates are a CA and the server and client certificates it signed, as
+// PEM files in a temporary directory.
+type Certificates struct {
+	CAFile         string
+	ClientCertFile string
+	ClientKeyFile  string
+
+	caPool *x509.CertPool
+	server tls.Certificate
+}
+
+// NewCertificates creates the certificates, the server one being valid for
+// serverName only.
+func NewCertificates(t testing.TB, serverName string) *Certificates {
+	t.Helper()
+	dir := t.TempDir()
+	caKey := newKey(t)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "db_meta test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, _ := x509.ParseCertificate(caDER)
+
+	certs := &Certificates{caPool: x509.NewCertPool()}
+	certs.caPool.AddCert(ca)
+	certs.CAFile = writePEM(t, dir, "ca.pem", "CERTIFICATE", caDER)
+
+	serverKey := newKey(t)
+	serverTemplate := leafTemplate(2, "db_meta test server", x509.ExtKeyUsageServerAuth)
+	if ip := net.ParseIP(serverName); ip != nil {
+		serverTemplate.IPAddresses = []net.IP{ip}
+	} else {
+		serverTemplate.DNSNames = []string{serverName}
+	}
+	serverDER, err := x509.CreateCertificate(rand.Reader, serverTemplate, ca, &serverKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certs.server = tls.Certificate{Certificate: [][]byte{serverDER}, PrivateKey: serverKey}
+
+	clientKey := newKey(t)
+	clientDER, err := x509.CreateCertificate(rand.Reader, leafTemplate(3, ClientCommonName, x509.ExtKeyUsageClientAuth), ca, &clientKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certs.ClientCertFile = writePEM(t, dir, "client.pem", "CERTIFICATE", clientDER)
+	clientKeyDER, err := x509.MarshalECPrivateKey(clientKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certs.ClientKeyFile = writePEM(t, dir, "client.key", "EC PRIVATE KEY", clientKeyDER)
+	return certs
+}
+
+func newKey(t testing.TB) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func leafTemplate(serial int64, commonName string, usage x509.ExtKeyUsage) *x509.Certificate {
+	return &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+}
+
+func writePEM(t testing.TB, dir, name, blockType string, der []byte) string {
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// Handshake is the outcome of a TLS handshake on the server side.
+type Handshake struct {
+	ClientCommonName string // empty without client certificate
+	Err              error
+}
+
+// Server accepts connections on 127.0.0.1 until the end of the test.
+type Server struct {
+	Host string
+	Port string
+
+	handshakes chan Handshake
+}
+
+// Preamble is the plain text exchange of a protocol before the TLS handshake.
+type Preamble func(conn net.Conn) error
+
+// NewServer starts a server presenting the server certificate of certs.
+func NewServer(t testing.TB, certs *Certificates, preamble Preamble) *Server {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	server := &Server{Host: host, Port: port, handshakes: make(chan Handshake, 16)}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{certs.server},
+		ClientAuth:   tls.VerifyClientCertIfGiven,
+		ClientCAs:    certs.caPool,
+	}
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go server.serve(conn, config, preamble)
+		}
+	}()
+	return server
+}
+
+func (s *Server) serve(conn net.Conn, config *tls.Config, preamble Preamble) {
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	if err := preamble(conn); err != nil {
+		// a client without TLS, not a handshake
+		return
+	}
+	tlsConn := tls.Server(conn, config)
+	err := tlsConn.Handshake()
+	handshake := Handshake{Err: err}
+	if err == nil {
+		if peers := tlsConn.ConnectionState().PeerCertificates; len(peers) > 0 {
+			handshake.ClientCommonName = peers[0].Subject.CommonName
+		}
+	}
+	s.handshakes <- handshake
+}
+
+// Handshake waits for the next handshake, ok is false if the client did not
+// start one within a few seconds.
+func (s *Server) Handshake() (handshake Handshake, ok bool) {
+	select {
+	case handshake := <-s.handshakes:
+		return handshake, true
+	case <-time.After(5 * time.Second):
+		return Handshake{}, false
+	}
+}
+
+// PostgresPreamble answers the SSLRequest of the client with 'S'.
+func PostgresPreamble(conn net.Conn) error {
+	request := make([]byte, 8)
+	if _, err := io.ReadFull(conn, request); err != nil {
+		return err
+	}
+	if binary.BigEndian.Uint32(request[4:]) != 80877103 {
+		return io.ErrUnexpectedEOF
+	}
+	_, err := conn.Write([]byte{'S'})
+	return err
+}
+
+// MySQL capability flags
+const (
+	mysqlClientProtocol41       = 0x00000200
+	mysqlClientSSL              = 0x00000800
+	mysqlClientSecureConnection = 0x00008000
+	mysqlClientPluginAuth       = 0x00080000
+)
+
+// MySQLPreamble sends the initial handshake packet of a server supporting TLS,
+// then reads the SSLRequest packet of the client.
+func MySQLPreamble(conn net.Conn) error {
+	capabilities := uint32(mysqlClientProtocol41 | mysqlClientSSL | mysqlClientSecureConnection | mysqlClientPluginAuth)
+	payload := []byte{10} // protocol version
+	payload = append(payload, "8.0.0-tlsfixture\x00"...)
+	payload = append(payload, 1, 0, 0, 0) // connection id
+	payload = append(payload, "abcdefgh"...)
+	payload = append(payload, 0) // filler
+	payload = append(payload, byte(capabilities), byte(capabilities>>8))
+	payload = append(payload, 45)   // utf8mb4_general_ci
+	payload = append(payload, 2, 0) // SERVER_STATUS_AUTOCOMMIT
+	payload = append(payload, byte(capabilities>>16), byte(capabilities>>24))
+	payload = append(payload, 21) // auth-plugin-data length
+	payload = append(payload, make([]byte, 10)...)
+	payload = append(payload, "ijklmnopqrst\x00"...)
+	payload = append(payload, "mysql_native_password\x00"...)
+
+	header := []byte{byte(len(payload)), byte(len(payload) >> 8), byte(len(payload) >> 16), 0}
+	if _, err := conn.Write(append(header, payload...)); err != nil {
+		return err
+	}
+
+	// SSLRequest: capabilities, max packet size, charset and filler
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return err
+	}
+	request := make([]byte, int(header[0])|int(header[1])<<8|int(header[2])<<16)
+	if _, err := io.ReadFull(conn, request); err != nil {
+		return err
+	}
+	if len(request) < 4 || binary.LittleEndian.Uint32(request)&mysqlClientSSL == 0 {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
@@ -3,29 +3,241 @@ package dbstructs
 // Schema related
 
 type Column struct {
-	ColumnName string `gorm:"column:column_name" json:"columnName"`
-	DataType   string `json:"data_type"`
-	NotNull    bool   `json:"not_null"`
-	Unique     bool   `gorm:"column:is_unique" json:"unique"`
+	ColumnName       string   `gorm:"column:column_name" json:"columnName"`
+	DataType         string   `json:"data_type"`
+	NotNull          bool     `json:"not_null"`
+	Unique           bool     `gorm:"column:is_unique" json:"unique"`
+	Default          *string  `gorm:"column:column_default" json:"default,omitempty"`           // SQL expression, nil without default
+	AutoIncrement    bool     `gorm:"column:is_auto_increment" json:"auto_increment,omitempty"` // identity, serial, AUTO_INCREMENT or rowid alias
+	Generated        string   `gorm:"column:generation_expression" json:"generated,omitempty"`  // expression of generated/computed columns
+	CharacterLength  *int     `gorm:"column:character_maximum_length" json:"character_length,omitempty"`
+	NumericPrecision *int     `gorm:"column:numeric_precision" json:"numeric_precision,omitempty"`
+	NumericScale     *int     `gorm:"column:numeric_scale" json:"numeric_scale,omitempty"`
+	OrdinalPosition  int      `gorm:"column:ordinal_position" json:"ordinal_position,omitempty"` // 1-based
+	UserType         string   `gorm:"column:user_type" json:"user_type,omitempty"`               // qualified name of an enum, domain or composite type
+	EnumValues       []string `gorm:"-" json:"enum_values,omitempty"`                            // inline enum('a', 'b') and set('a', 'b') values
+}
+
+// TableColumn is a Column along with its table, as scanned from the bulk catalog
+// queries of the connectors.
+type TableColumn struct {
+	TableSchema string `gorm:"column:table_schema"`
+	TableName   string `gorm:"column:table_name"`
+	Column
 }
 
 type RelationshipMetadata struct {
-	Conname          string `gorm:"column:conname"`
-	SourceTableName  string `gorm:"column:source_table"`
-	RelatedTableName string `gorm:"column:related_table_name"`
+	Conname           string   `gorm:"column:conname"`
+	SourceSchema      string   `gorm:"column:source_schema" json:"SourceSchema,omitempty"`
+	SourceTableName   string   `gorm:"column:source_table"`
+	RelatedSchema     string   `gorm:"column:related_schema" json:"RelatedSchema,omitempty"`
+	RelatedTableName  string   `gorm:"column:related_table_name"`
+	SourceColumns     []string `gorm:"-"` // ordered, matches TargetColumns one by one
+	TargetColumns     []string `gorm:"-"`
+	OnDelete          string   `gorm:"column:on_delete"` // one of the ReferentialAction* values
+	OnUpdate          string   `gorm:"column:on_update"`
+	Match             string   `gorm:"column:match_option"` // SIMPLE, FULL, PARTIAL or NONE
+	Deferrable        bool     `gorm:"column:is_deferrable"`
+	InitiallyDeferred bool     `gorm:"column:initially_deferred"`
+}
+
+// Referential actions, as spelled in SQL
+const (
+	ReferentialActionNoAction   = "NO ACTION"
+	ReferentialActionRestrict   = "RESTRICT"
+	ReferentialActionCascade    = "CASCADE"
+	ReferentialActionSetNull    = "SET NULL"
+	ReferentialActionSetDefault = "SET DEFAULT"
+)
+
+// IndexKey is a key of an index, either a column or an expression
+type IndexKey struct {
+	Column     string `json:"column,omitempty"`
+	Expression string `json:"expression,omitempty"`
+	Descending bool   `json:"descending,omitempty"`
 }
 
 type Index struct {
-	Name    string   `json:"name"`
-	Columns []string `json:"columns"`
+	Name      string      `json:"name"`
+	Columns   []string    `json:"columns"` // one per key, the expression text for expression keys
+	Keys      []*IndexKey `json:"keys,omitempty"`
+	Include   []string    `json:"include,omitempty"` // non-key columns (INCLUDE)
+	Unique    bool        `json:"unique,omitempty"`
+	Primary   bool        `json:"primary,omitempty"`
+	Method    string      `json:"method,omitempty"`    // btree, hash, gin, gist, clustered...
+	Predicate string      `json:"predicate,omitempty"` // WHERE clause of a partial index
+}
+
+// CheckConstraint is a CHECK constraint with its raw expression, Columns are the
+// columns the expression reads (a single one for a column constraint).
+type CheckConstraint struct {
+	Name       string   `json:"name"`
+	Columns    []string `json:"columns"`
+	Expression string   `json:"expression"`
 }
 
 type TableMetadata struct {
-	TableName     string                  `json:"tableName"`
-	Columns       []*Column               `json:"columns"`
-	PrimaryKey    []string                `json:"primary_key"`
-	Indexes       []*Index                `json:"indexes"`
-	Relationships []*RelationshipMetadata `json:"relationships"`
+	Schema           string                  `json:"schema,omitempty"` // empty for single schema databases
+	TableName        string                  `json:"tableName"`
+	Columns          []*Column               `json:"columns"`
+	PrimaryKey       []string                `json:"primary_key"`
+	Indexes          []*Index                `json:"indexes"`
+	Relationships    []*RelationshipMetadata `json:"relationships"`
+	CheckConstraints []*CheckConstraint      `json:"check_constraints,omitempty"`
+	Partitioning     *Partitioning           `json:"partitioning,omitempty"`    // set on partitioned tables
+	PartitionOf      string                  `json:"partition_of,omitempty"`    // qualified name of the partitioned parent
+	PartitionBound   string                  `json:"partition_bound,omitempty"` // FOR VALUES ... of a partition
+	Inherits         []string                `json:"inherits,omitempty"`        // qualified names of the parents (INHERITS)
+}
+
+// Partitioning describes how a table is partitioned. Partitions are tables of
+// their own on PostgreSQL, they only exist as names and bounds on MySQL.
+type Partitioning struct {
+	Strategy   string       `json:"strategy"` // range, list, hash, key...
+	Key        string       `json:"key"`      // partition key columns or expression
+	Partitions []*Partition `json:"partitions"`
+}
+
+type Partition struct {
+	Name  string `json:"name"` // qualified table name on PostgreSQL
+	Bound string `json:"bound,omitempty"`
+}
+
+// ColumnNames returns the names of the table columns, in order.
+func (table *TableMetadata) ColumnNames() []string {
+	names := make([]string, 0, len(table.Columns))
+	for _, column := range table.Columns {
+		names = append(names, column.ColumnName)
+	}
+	return names
+}
+
+const (
+	TypeKindEnum      = "enum"
+	TypeKindDomain    = "domain"
+	TypeKindComposite = "composite"
+)
+
+// TypeMetadata is a user-defined type: the labels of an enum, the base type and
+// checks of a domain, or the attributes of a composite type.
+type TypeMetadata struct {
+	Schema     string    `json:"schema,omitempty"`
+	TypeName   string    `json:"typeName"`
+	Kind       string    `json:"kind"`
+	Values     []string  `json:"values,omitempty"`
+	BaseType   string    `json:"base_type,omitempty"`
+	NotNull    bool      `json:"not_null,omitempty"`
+	Default    *string   `json:"default,omitempty"`
+	Checks     []string  `json:"checks,omitempty"` // domain CHECK expressions, VALUE is the checked value
+	Attributes []*Column `json:"attributes,omitempty"`
+}
+
+func (typ *TypeMetadata) QualifiedName() string {
+	return QualifiedName(typ.Schema, typ.TypeName)
+}
+
+type ViewMetadata struct {
+	Schema       string    `json:"schema,omitempty"`
+	ViewName     string    `json:"viewName"`
+	Materialized bool      `json:"materialized"`
+	Columns      []*Column `json:"columns"`
+	Definition   string    `json:"definition"`
+	Dependencies []string  `json:"dependencies"` // qualified names of the tables and views it reads
+}
+
+// SequenceMetadata is a sequence, OwnerTable and OwnerColumn are set when it is
+// owned by a column (serial, identity or OWNED BY).
+type SequenceMetadata struct {
+	Schema       string `json:"schema,omitempty"`
+	SequenceName string `json:"sequenceName"`
+	DataType     string `json:"data_type"`
+	Start        int64  `json:"start"`
+	Increment    int64  `json:"increment"`
+	MinValue     int64  `json:"min_value"`
+	MaxValue     int64  `json:"max_value"`
+	Cycle        bool   `json:"cycle"`
+	OwnerTable   string `json:"owner_table,omitempty"` // qualified name
+	OwnerColumn  string `json:"owner_column,omitempty"`
+}
+
+func (sequence *SequenceMetadata) QualifiedName() string {
+	return QualifiedName(sequence.Schema, sequence.SequenceName)
+}
+
+// TriggerMetadata describes a trigger and the table it fires on, triggers live
+// in the schema of their table. Body holds the trigger action, or the source of
+// the trigger function on PostgreSQL.
+type TriggerMetadata struct {
+	TriggerName string   `json:"triggerName"`
+	TableSchema string   `json:"table_schema,omitempty"`
+	TableName   string   `json:"tableName"`
+	Timing      string   `json:"timing"`      // BEFORE, AFTER or INSTEAD OF
+	Events      []string `json:"events"`      // INSERT, UPDATE, DELETE, TRUNCATE
+	Orientation string   `json:"orientation"` // ROW or STATEMENT
+	Body        string   `json:"body"`
+	References  []string `json:"references"` // qualified names of the tables the body reads or writes
+}
+
+// QualifiedTableName is the qualified name of the table the trigger fires on.
+func (trigger *TriggerMetadata) QualifiedTableName() string {
+	return QualifiedName(trigger.TableSchema, trigger.TableName)
+}
+
+// DisplayName returns "trigger ON schema.table", trigger names are only unique per
+// table on PostgreSQL.
+func (trigger *TriggerMetadata) DisplayName() string {
+	return trigger.TriggerName + " ON " + trigger.QualifiedTableName()
+}
+
+const (
+	RoutineKindFunction  = "function"
+	RoutineKindProcedure = "procedure"
+)
+
+type RoutineArgument struct {
+	Name     string `json:"name"`
+	DataType string `json:"data_type"`
+	Mode     string `json:"mode"` // IN, OUT, INOUT or VARIADIC
+}
+
+// RoutineMetadata describes a stored procedure or function.
+type RoutineMetadata struct {
+	Schema      string             `json:"schema,omitempty"`
+	RoutineName string             `json:"routineName"`
+	Kind        string             `json:"kind"`
+	Arguments   []*RoutineArgument `json:"arguments"`
+	ReturnType  string             `json:"return_type,omitempty"`
+	Language    string             `json:"language"`
+	Body        string             `json:"body"`
+	References  []string           `json:"references"` // qualified names of the tables the body reads or writes
+}
+
+func (routine *RoutineMetadata) QualifiedName() string {
+	return QualifiedName(routine.Schema, routine.RoutineName)
+}
+
+// QualifiedName returns "schema.name", or name alone when schema is empty.
+func QualifiedName(schema, name string) string {
+	if schema == "" {
+		return name
+	}
+	return schema + "." + name
+}
+
+func (table *TableMetadata) QualifiedName() string {
+	return QualifiedName(table.Schema, table.TableName)
+}
+
+func (view *ViewMetadata) QualifiedName() string {
+	return QualifiedName(view.Schema, view.ViewName)
+}
+
+func (rel *RelationshipMetadata) QualifiedSourceName() string {
+	return QualifiedName(rel.SourceSchema, rel.SourceTableName)
+}
+
+func (rel *RelationshipMetadata) QualifiedRelatedName() string {
+	return QualifiedName(rel.RelatedSchema, rel.RelatedTableName)
 }
 
 // Graph related
@@ -36,10 +248,13 @@ type NodeElement struct {
 
 type NodeData struct {
 	ID         string    `json:"id"`
-	Name       string    `json:"name"`
+	Name       string    `json:"name"` // qualified table name, edges refer to it
+	Schema     string    `json:"schema,omitempty"`
+	Kind       string    `json:"kind"` // one of the NodeKind* values
 	Columns    []*Column `json:"columns"`
 	PrimaryKey []string  `json:"primary_key"`
 	Indexes    []*Index  `json:"indexes"`
+	Partitions []string  `json:"partitions,omitempty"` // partitions collapsed into the node
 }
 
 type RelationshipEdge struct {
@@ -47,54 +262,32 @@ type RelationshipEdge struct {
 }
 
 type EdgeData struct {
-	ID     string `json:"id"`
-	Source string `json:"source"`
-	Target string `json:"target"`
+	ID            string   `json:"id"`
+	Source        string   `json:"source"`
+	Target        string   `json:"target"`
+	SourceColumns []string `json:"sourceColumns"`
+	TargetColumns []string `json:"targetColumns"`
+	OnDelete      string   `json:"onDelete"`
+	OnUpdate      string   `json:"onUpdate"`
+	Kind          string   `json:"kind"` // one of the EdgeKind* values
 }
 
+const (
+	NodeKindTable            = "table"
+	NodeKindView             = "view"
+	NodeKindMaterializedView = "materialized_view"
+	NodeKindTrigger          = "trigger"
+
+	EdgeKindForeignKey       = "foreign_key"
+	EdgeKindViewDependency   = "view_dependency"   // from the view to what it reads
+	EdgeKindTriggerTable     = "trigger_table"     // from the trigger to the table it fires on
+	EdgeKindTriggerReference = "trigger_reference" // from the trigger to a table its body uses
+	EdgeKindPartitionOf      = "partition_of"      // from the partition to its parent
+	EdgeKindInherits         = "inherits"          // from the child table to its parent
+)
+
 type GraphResponse struct {
 	Edges []*RelationshipEdge `json:"edges"`
 	Nodes []*NodeElement      `json:"nodes"`
 }
 
-// Schema integrity related
-
-type PrimaryKeyIssue struct {
-	TableName        string `json:"tableName"`
-	IssueDescription string `json:"issueDescription"`
-}
-
-type UniqueIndexIssue struct {
-	TableName        string `json:"tableName"`
-	ColumnName       string `json:"columnName"`
-	IssueDescription string `json:"issueDescription"`
-}
-
-type RedundantIndexIssue struct {
-	TableName        string `json:"tableName"`
-	IndexName        string `json:"indexName"`
-	RedundantWith    string `json:"redundantWith"`
-	IssueDescription string `json:"issueDescription"`
-}
-
-type ForeignKeyIssue struct {
-	TableName        string `json:"tableName"`
-	ColumnName       string `json:"columnName"`
-	RelatedTableName string `json:"relatedTableName"`
-	IssueDescription string `json:"issueDescription"`
-}
-
-type NullableColumnIssue struct {
-	TableName        string `json:"tableName"`
-	ColumnName       string `json:"columnName"`
-	IssueDescription string `json:"issueDescription"`
-}
-
-type SchemaVerificationResults struct {
-	MissingPrimaryKeys   []*PrimaryKeyIssue     `json:"missingPrimaryKeys"`
-	NullableColumns      []*NullableColumnIssue `json:"nullableColumns"`
-	MissingUniqueIndexes []*UniqueIndexIssue    `json:"missingUniqueIndexes"`
-	ForeignKeyIssues     []*ForeignKeyIssue     `json:"foreignKeyIssues"`
-	RedundantIndexes     []*RedundantIndexIssue `json:"redundantIndexes"`
-	SCCs                 [][]string             `json:"sccs"`
-}
@@ -3,6 +3,8 @@ import * as nav from './pages/nav/script.js';
 import * as graphPage from './pages/graph/script.js';
 import * as integrityPage from './pages/integrity/script.js';
 import * as restApiPage from './pages/apigen/script.js';
+import * as diffPage from './pages/diff/script.js';
+import * as sqlgenPage from './pages/sqlgen/script.js';
 import './style.css';
 import './app.css';
 
@@ -11,6 +13,8 @@ export const pagesKeys = {
     'graph': 'graph',
     'integrity': 'integrity',
     'restapi': 'restapi',
+    'diff': 'diff',
+    'sqlgen': 'sqlgen',
 };
 
 export const loadPage = async (pageName, data = undefined) => {
@@ -34,6 +38,12 @@ export const loadPage = async (pageName, data = undefined) => {
             case pagesKeys.restapi:
                 pageModule = restApiPage;
                 break;
+            case pagesKeys.diff:
+                pageModule = diffPage;
+                break;
+            case pagesKeys.sqlgen:
+                pageModule = sqlgenPage;
+                break;
             default:
                 pageModule = connectionPage;
                 break;
@@ -1,6 +1,7 @@
 import jsYaml from 'js-yaml';
-import { GetTablesList, GenerateOpenApi } from '../../../wailsjs/go/main/App';
+import { GetTablesList, GetViewsList, GenerateOpenApi } from '../../../wailsjs/go/main/App';
 import './styles.css';
+import { getSessionId } from '../../utils/utils';
 
 export const html = `
 <div id="apiConfigPage">
@@ -22,7 +23,14 @@ let apiConfig = {};
 let currentTable = null;
 
 export async function init() {
-  dbMetadata = await GetTablesList();
+  const tables = await GetTablesList(getSessionId()) ?? [];
+  const views = (await GetViewsList(getSessionId()) ?? []).map(view => ({
+    tableName: view.viewName,
+    columns: view.columns ?? [],
+    relationships: [],
+    readOnly: true, // views only get a list endpoint
+  }));
+  dbMetadata = [...tables, ...views];
   renderInterface();
   setupButtons();
 }
@@ -46,7 +54,7 @@ function renderTablesList() {
     if (currentTable && currentTable.tableName === table.tableName) {
       tableElement.classList.add('active');
     }
-    tableElement.textContent = table.tableName;
+    tableElement.textContent = table.readOnly ? `${table.tableName} (view)` : table.tableName;
     tableElement.onclick = () => {
       currentTable = table;
       renderInterface();
@@ -68,6 +76,9 @@ function renderTableEndpoints(table) {
 
 function generateEndpointsForTable(table) {
   const tableName = table.tableName.toLowerCase();
+  if (table.readOnly) {
+    return [{ method: 'GET', path: `/${tableName}`, description: `List all ${table.tableName}`, hasFilters: true }];
+  }
   const endpoints = [
     { method: 'GET', path: `/${tableName}`, description: `List all ${table.tableName}`, hasFilters: true },
     { method: 'POST', path: `/${tableName}`, description: `Create a new ${table.tableName}` },
@@ -256,7 +267,7 @@ function resetAllOptions() {
 async function generateOpenAPISpec() {
   try {
     console.log({ apiConfig });
-    const spec = await GenerateOpenApi(apiConfig);
+    const spec = await GenerateOpenApi(getSessionId(), apiConfig);
     const previewPanel = document.getElementById('previewPanel');
     previewPanel.innerHTML = '<pre>' + jsYaml.dump(jsYaml.load(spec)) + '</pre>';
   } catch (error) {
@@ -1,66 +1,396 @@
-import { ConfigureGorm } from '../../../wailsjs/go/main/App';
+import { CancelConfigureGorm, ConfigureGorm, ConnectProfile, DeleteProfile, ExportProfiles, GetConnectors, GetProfile, ImportProfiles, ListProfiles, OpenDDL, OpenSnapshot, ProfilesUnlocked, SaveProfile, TestProfile, UnlockProfiles } from '../../../wailsjs/go/main/App';
+import { EventsOff, EventsOn } from '../../../wailsjs/runtime/runtime';
 import { pagesKeys, loadPage } from '../../main';
+import { getSessionId, setSessionId } from '../../utils/utils';
 import './styles.css';
 
 export const html = `<div id="connectionPage">
 <h1>string:pageName;</h1>
 
 <div id="result" class="result"></div>
+<section id="profiles" class="profiles">
+    <h2>string:profiles;</h2>
+    <div class="profilesRow">
+        <input type="password" id="masterPassphrase">
+        <button type="button" id="unlockProfiles" class="btn-green">string:unlock;</button>
+        <span id="profilesStatus"></span>
+    </div>
+    <div class="profilesRow">
+        <select id="profileList"></select>
+        <button type="button" id="connectProfile" class="btn-green">string:connect;</button>
+        <button type="button" id="testProfile">string:test;</button>
+        <button type="button" id="editProfile">string:edit;</button>
+        <button type="button" id="deleteProfile" class="btn-red">string:delete;</button>
+    </div>
+    <div class="profilesRow">
+        <button type="button" id="exportProfiles">string:export;</button>
+        <button type="button" id="importProfiles">string:import;</button>
+        <input type="file" id="importFile" accept=".json,application/json" hidden>
+    </div>
+</section>
 <form id="connectionForm">
-    <label for="host">Hôte :</label>
-    <input type="text" id="host" name="host">
-
-    <label for="port">Port :</label>
-    <input type="text" id="port" name="port">
+    <label for="session">Nom de la session :</label>
+    <input type="text" id="session" name="session" placeholder="production">
 
     <label for="database">Type de la base de données :</label>
-    <select name="database" id="database">
-        <option value="postgres">PostgreSQL</option>
-        <option value="mysql">MySQL</option>
-        <option value="sqlite">SQLite</option>
-        <option value="sqlserver">SQL Server</option>
-    </select>
-
-    <label for="username">Nom d'utilisateur :</label>
-    <input type="text" id="username" name="username">
+    <select name="database" id="database"></select>
 
-    <label for="password">Mot de passe :</label>
-    <input type="password" id="password" name="password">
+    <div id="connectorFields" class="connectorFields"></div>
 
-    <label for="dbname">Nom de la base de données :</label>
-    <input type="text" id="dbname" name="dbname">
+    <div class="profilesRow">
+        <input type="text" id="profileName">
+        <button type="button" id="saveProfile">string:saveProfile;</button>
+    </div>
 
     <button type="submit" class="btn-green">Connexion à la DB</button>
+    <div id="progress" class="progress">
+        <span id="progressText"></span>
+        <button type="button" id="cancelConnection" class="btn-red">string:cancel;</button>
+    </div>
+    <button type="button" id="openSnapshot" class="snapshot">string:openSnapshot;</button>
+    <input type="file" id="snapshotFile" accept=".json,application/json" hidden>
+    <button type="button" id="openDDL" class="snapshot">string:openDDL;</button>
+    <input type="file" id="ddlFile" accept=".sql,text/plain" hidden>
 </form>
 </div>`
 
 export async function init() {
-    document.querySelector('#connectionForm').addEventListener('submit', function(event) {
-        event.preventDefault();
+    const progressDiv = document.getElementById("progress");
+    const progressText = document.getElementById("progressText");
+    const databaseSelect = document.getElementById("database");
+    const translations = await getTranslations();
+    document.getElementById("session").value = getSessionId();
+
+    // Le formulaire est construit à partir des connecteurs enregistrés côté Go
+    const connectors = await GetConnectors();
+    connectors.forEach(connector => databaseSelect.add(new Option(connector.label, connector.name)));
+    const selectedConnector = () => connectors.find(connector => connector.name === databaseSelect.value);
+    renderFields(selectedConnector(), translations);
+    databaseSelect.addEventListener('change', () => renderFields(selectedConnector(), translations));
 
-        const host = document.getElementById("host").value;
-        const port = document.getElementById("port").value;
-        const database = document.getElementById("database").value;
-        const username = document.getElementById("username").value;
-        const password = document.getElementById("password").value;
-        const dbname = document.getElementById("dbname").value;
-        const resultDiv = document.getElementById("result");
+    // Annule la connexion ou le chargement en cours
+    document.getElementById("cancelConnection").addEventListener('click', () => CancelConfigureGorm(document.getElementById("session").value.trim() || 'default'));
+
+    const resultDiv = document.getElementById("result");
+    const showError = (err) => {
+        resultDiv.style.display = 'flex';
+        resultDiv.innerText = err;
+    };
+
+    // Lance la connexion de la session, connect appelle ConfigureGorm ou ConnectProfile
+    const connectSession = (session, connect) => {
+        // Avancement du chargement envoyé par le backend : "Tables 120/2000"
+        progressText.innerText = translations.connecting;
+        progressDiv.style.display = 'flex';
+        EventsOn('metadataProgress', (sessionId, done, total) => {
+            if (sessionId !== session) return;
+            progressText.innerText = `${translations.tables} ${done}/${total}`;
+        });
+        const stopProgress = () => {
+            EventsOff('metadataProgress');
+            progressDiv.style.display = 'none';
+        };
 
         try {
-            ConfigureGorm(database, host, port, dbname, username, password)
+            connect()
                 .then(() => {
+                   stopProgress();
+                   setSessionId(session);
                    loadPage(pagesKeys.graph);
                 })
                 .catch((err) => {
-                    resultDiv.style.display = 'flex';
-                    resultDiv.innerText = err;
+                    stopProgress();
+                    showError(err);
                 });
         } catch (err) {
             console.error(err.message);
-            resultDiv.style.display = 'flex';
-            resultDiv.innerText = err;
+            showError(err);
+        }
+    };
+
+    document.querySelector('#connectionForm').addEventListener('submit', function(event) {
+        event.preventDefault();
+
+        const session = document.getElementById("session").value.trim() || 'default';
+        const values = fieldValues(selectedConnector());
+        connectSession(session, () => ConfigureGorm(session, databaseSelect.value, values.host, values.port, values.database, values.user, values.password, values.schemas, formOptions(values)));
+    });
+
+    // Mode hors ligne : la session reprend les métadonnées d'un instantané exporté
+    const snapshotFile = document.getElementById("snapshotFile");
+    document.getElementById("openSnapshot").addEventListener('click', () => snapshotFile.click());
+    snapshotFile.addEventListener('change', async () => {
+        const file = snapshotFile.files[0];
+        snapshotFile.value = '';
+        if (!file) return;
+        const session = document.getElementById("session").value.trim() || 'default';
+        try {
+            await OpenSnapshot(session, await file.text());
+            setSessionId(session);
+            loadPage(pagesKeys.graph);
+        } catch (err) {
+            showError(err);
+        }
+    });
+
+    // Mode hors ligne : les métadonnées sont lues dans un script SQL (pg_dump, mysqldump...),
+    // écrit dans le dialecte du connecteur sélectionné
+    const ddlFile = document.getElementById("ddlFile");
+    document.getElementById("openDDL").addEventListener('click', () => ddlFile.click());
+    ddlFile.addEventListener('change', async () => {
+        const file = ddlFile.files[0];
+        ddlFile.value = '';
+        if (!file) return;
+        const session = document.getElementById("session").value.trim() || 'default';
+        try {
+            await OpenDDL(session, databaseSelect.value, await file.text());
+            setSessionId(session);
+            loadPage(pagesKeys.graph);
+        } catch (err) {
+            showError(err);
+        }
+    });
+
+    initProfiles(translations, {
+        showError,
+        connect: (name) => {
+            const session = document.getElementById("session").value.trim() || 'default';
+            connectSession(session, () => ConnectProfile(session, name));
+        },
+        // Profil construit à partir du formulaire
+        formProfile: (name) => {
+            const values = fieldValues(selectedConnector());
+            return {
+                name: name,
+                dbType: databaseSelect.value,
+                host: values.host ?? '',
+                port: values.port ?? '',
+                database: values.database ?? '',
+                user: values.user ?? '',
+                password: values.password ?? '',
+                schemas: values.schemas ?? '',
+                options: formOptions(values),
+            };
+        },
+        // Remplit le formulaire avec un profil
+        fillForm: (profile) => {
+            databaseSelect.value = profile.dbType;
+            renderFields(selectedConnector(), translations);
+            const values = profileValues(profile);
+            Object.keys(values).forEach(name => {
+                const input = document.getElementById(`field-${name}`);
+                if (input) input.value = values[name];
+            });
+            document.getElementById("profileName").value = profile.name;
+        },
+    });
+}
+
+// Section des profils enregistrés : les secrets (mots de passe) sont chiffrés
+// avec la phrase de passe maîtresse, à saisir avant de les lire ou de les écrire.
+async function initProfiles(translations, form) {
+    const profileList = document.getElementById("profileList");
+    const status = document.getElementById("profilesStatus");
+    const passphrase = document.getElementById("masterPassphrase");
+    passphrase.placeholder = translations.masterPassphrase;
+    document.getElementById("profileName").placeholder = translations.profileName;
+
+    const refresh = async (selected) => {
+        status.innerText = await ProfilesUnlocked() ? translations.unlocked : translations.locked;
+        const profiles = await ListProfiles();
+        profileList.innerHTML = '';
+        profiles.forEach(profile => profileList.add(new Option(`${profile.name} (${profile.dbType})`, profile.name)));
+        if (selected) profileList.value = selected;
+        document.getElementById("profiles").classList.toggle('empty', profiles.length === 0);
+    };
+    // Exécute une action sur le profil sélectionné, les erreurs sont affichées
+    const withProfile = (action) => async () => {
+        if (!profileList.value) return;
+        try {
+            await action(profileList.value);
+        } catch (err) {
+            form.showError(err);
         }
+    };
+
+    document.getElementById("unlockProfiles").addEventListener('click', async () => {
+        try {
+            await UnlockProfiles(passphrase.value);
+            passphrase.value = '';
+            await refresh(profileList.value);
+        } catch (err) {
+            form.showError(err);
+        }
+    });
+    document.getElementById("connectProfile").addEventListener('click', withProfile(async (name) => form.connect(name)));
+    document.getElementById("testProfile").addEventListener('click', withProfile(async (name) => {
+        status.innerText = translations.testing;
+        try {
+            await TestProfile(name);
+            status.innerText = translations.testSucceeded;
+        } catch (err) {
+            status.innerText = '';
+            throw err;
+        }
+    }));
+    document.getElementById("editProfile").addEventListener('click', withProfile(async (name) => form.fillForm(await GetProfile(name))));
+    document.getElementById("deleteProfile").addEventListener('click', withProfile(async (name) => {
+        if (!confirm(`${translations.confirmDelete} ${name} ?`)) return;
+        await DeleteProfile(name);
+        await refresh();
+    }));
+    document.getElementById("saveProfile").addEventListener('click', async () => {
+        const name = document.getElementById("profileName").value.trim();
+        if (!name) return form.showError(translations.profileNameRequired);
+        try {
+            await SaveProfile(form.formProfile(name));
+            await refresh(name);
+        } catch (err) {
+            form.showError(err);
+        }
+    });
+
+    // L'export ne contient aucun secret
+    document.getElementById("exportProfiles").addEventListener('click', async () => {
+        try {
+            const link = document.createElement('a');
+            link.href = URL.createObjectURL(new Blob([await ExportProfiles()], { type: 'application/json' }));
+            link.download = 'db_meta-profiles.json';
+            link.click();
+            URL.revokeObjectURL(link.href);
+        } catch (err) {
+            form.showError(err);
+        }
+    });
+    const importFile = document.getElementById("importFile");
+    document.getElementById("importProfiles").addEventListener('click', () => importFile.click());
+    importFile.addEventListener('change', async () => {
+        const file = importFile.files[0];
+        importFile.value = '';
+        if (!file) return;
+        try {
+            const count = await ImportProfiles(await file.text());
+            status.innerText = `${count} ${translations.imported}`;
+            await refresh();
+        } catch (err) {
+            form.showError(err);
+        }
+    });
+
+    await refresh();
+}
+
+// Paramètres avancés (DSN, TLS, SSH...) saisis dans le formulaire
+function formOptions(values) {
+    return {
+        dsn: values.dsn ?? '',
+        tlsMode: values.tlsMode ?? '',
+        caFile: values.caFile ?? '',
+        certFile: values.certFile ?? '',
+        keyFile: values.keyFile ?? '',
+        connectTimeout: parseInt(values.connectTimeout, 10) || 0,
+        applicationName: values.applicationName ?? '',
+        ssh: sshConfig(values),
+    };
+}
+
+// Valeurs des champs du formulaire d'un profil, l'inverse de formOptions
+function profileValues(profile) {
+    const options = profile.options ?? {};
+    const hops = options.ssh?.hops ?? [];
+    return {
+        host: profile.host,
+        port: profile.port,
+        database: profile.database,
+        user: profile.user,
+        password: profile.password,
+        schemas: profile.schemas,
+        dsn: options.dsn ?? '',
+        tlsMode: options.tlsMode ?? '',
+        caFile: options.caFile ?? '',
+        certFile: options.certFile ?? '',
+        keyFile: options.keyFile ?? '',
+        connectTimeout: options.connectTimeout || '',
+        applicationName: options.applicationName ?? '',
+        sshHosts: hops.map(hop => `${hop.user ? hop.user + '@' : ''}${hop.host}${hop.port ? ':' + hop.port : ''}`).join(', '),
+        sshPassword: hops[0]?.password ?? '',
+        sshKeyFile: hops[0]?.keyFile ?? '',
+        sshKeyPassphrase: hops[0]?.keyPassphrase ?? '',
+        knownHostsFile: options.ssh?.knownHostsFile ?? '',
+    };
+}
+
+// Affiche les champs du connecteur, les valeurs saisies sont conservées d'un
+// connecteur à l'autre, les valeurs par défaut (port) sont remplacées. Les
+// champs avancés (DSN, TLS...) sont repliés dans une section à part.
+function renderFields(connector, translations) {
+    const container = document.getElementById("connectorFields");
+    const previous = {};
+    container.querySelectorAll('input, select').forEach(input => {
+        if (input.value !== input.dataset.default) previous[input.name] = input.value;
+    });
+    container.innerHTML = '';
+    const advanced = document.createElement('details');
+    const summary = document.createElement('summary');
+    summary.innerText = translations.advanced;
+    advanced.append(summary);
+
+    (connector?.fields ?? []).forEach(field => {
+        const label = document.createElement('label');
+        label.htmlFor = `field-${field.name}`;
+        label.innerText = `${translations.fields[field.label] ?? field.label} :`;
+
+        const input = field.type === 'select' ? selectInput(field, translations) : document.createElement('input');
+        if (field.type !== 'select') {
+            input.type = field.type;
+            input.placeholder = field.placeholder ?? '';
+        }
+        input.id = `field-${field.name}`;
+        input.name = field.name;
+        input.required = field.required ?? false;
+        input.dataset.default = field.default ?? '';
+        input.value = previous[field.name] ?? field.default ?? '';
+
+        (field.advanced ? advanced : container).append(label, input);
+    });
+    if (advanced.children.length > 1) container.append(advanced);
+}
+
+// Liste déroulante d'un champ 'select', la première option garde le défaut du driver
+function selectInput(field, translations) {
+    const select = document.createElement('select');
+    select.add(new Option(translations.driverDefault, ''));
+    (field.options ?? []).forEach(option => select.add(new Option(option, option)));
+    return select;
+}
+
+// Valeurs des champs du connecteur, indexées par nom de paramètre
+function fieldValues(connector) {
+    const values = {};
+    (connector?.fields ?? []).forEach(field => {
+        const input = document.getElementById(`field-${field.name}`);
+        if (input) values[field.name] = input.value;
+    });
+    return values;
+}
+
+// Chaîne SSH saisie sous la forme "admin@bastion:22, deploy@jump", le bastion
+// en premier. Tous les hôtes partagent le mot de passe et la clé du formulaire.
+function sshConfig(values) {
+    const hops = (values.sshHosts ?? '').split(',').map(host => host.trim()).filter(host => host).map(host => {
+        const at = host.lastIndexOf('@');
+        const user = at >= 0 ? host.slice(0, at) : '';
+        const [hostName, port] = host.slice(at + 1).split(':');
+        return {
+            host: hostName,
+            port: port ?? '',
+            user: user,
+            password: values.sshPassword ?? '',
+            keyFile: values.sshKeyFile ?? '',
+            keyPassphrase: values.sshKeyPassphrase ?? '',
+        };
     });
+    return { hops: hops, knownHostsFile: values.knownHostsFile ?? '' };
 }
 
 // As of now, simply returns the key/val pairs
@@ -68,6 +398,52 @@ export async function getTranslations(msg = null) {
   // Here FetchTranslations will be called in near future
   return {
     pageName: 'Connexion à la base de données',
+    cancel: 'Annuler',
+    connecting: 'Connexion en cours...',
+    tables: 'Tables',
+    fields: {
+      'Host': 'Hôte',
+      'Port': 'Port',
+      'Database': 'Nom de la base de données',
+      'Database file': 'Fichier de la base de données',
+      'User': "Nom d'utilisateur",
+      'Password': 'Mot de passe',
+      'Schemas': 'Schémas (séparés par des virgules)',
+      'Connection string': 'Chaîne de connexion (remplace les champs ci-dessus)',
+      'TLS mode': 'Mode TLS',
+      'CA certificate': "Certificat de l'autorité (CA)",
+      'Client certificate': 'Certificat client',
+      'Client key': 'Clé du certificat client',
+      'Connect timeout (s)': 'Délai de connexion (s)',
+      'Application name': "Nom de l'application",
+      'SSH hosts': 'Hôtes SSH (utilisateur@hôte:port, le bastion en premier)',
+      'SSH password': 'Mot de passe SSH',
+      'SSH private key': 'Clé privée SSH',
+      'SSH key passphrase': 'Phrase de passe de la clé SSH',
+      'SSH known hosts': 'Fichier known_hosts',
+    },
+    advanced: 'Paramètres avancés',
+    profiles: 'Profils enregistrés',
+    masterPassphrase: 'Phrase de passe maîtresse',
+    unlock: 'Déverrouiller',
+    locked: 'Mots de passe verrouillés',
+    unlocked: 'Mots de passe déverrouillés',
+    connect: 'Connexion',
+    test: 'Tester',
+    testing: 'Test en cours...',
+    testSucceeded: 'Connexion réussie',
+    edit: 'Modifier',
+    delete: 'Supprimer',
+    confirmDelete: 'Supprimer le profil',
+    export: 'Exporter (sans mots de passe)',
+    import: 'Importer',
+    imported: 'profil(s) importé(s)',
+    profileName: 'Nom du profil',
+    profileNameRequired: 'Le profil doit avoir un nom',
+    saveProfile: 'Enregistrer le profil',
+    openSnapshot: 'Ouvrir un instantané (hors ligne)',
+    openDDL: 'Ouvrir un script SQL (hors ligne)',
+    driverDefault: 'Défaut du driver',
     // Add more trads HERE
     // You will also need to place it on the html like: string:your_var;
   };
@@ -0,0 +1,273 @@
+import { CompareSessions, GenerateMigration, ListSessions, OpenSnapshot } from '../../../wailsjs/go/main/App';
+import './styles.css'
+import { getSessionId } from '../../utils/utils';
+
+export const html = `
+<div id="diff">
+  <h1>string:pageTitle;</h1>
+  <div id="result" class="result"></div>
+
+  <div class="filterBar">
+    <label for="sourceSession">string:sourceSession; :</label>
+    <select id="sourceSession" class="filterInput"></select>
+
+    <label for="targetSession">string:targetSession; :</label>
+    <select id="targetSession" class="filterInput"></select>
+
+    <button type="button" id="compare" class="btn-green">string:compare;</button>
+    <button type="button" id="openSnapshot">string:openSnapshot;</button>
+    <input type="file" id="snapshotFile" accept=".json,application/json" hidden>
+  </div>
+
+  <div id="summary" class="diffSummary"></div>
+  <section id="diffContainer" class="diffContainer">
+    <!-- Dynamically filled -->
+  </section>
+
+  <div class="filterBar">
+    <label for="dialect">string:dialect; :</label>
+    <select id="dialect" class="filterInput">
+      <option value="">string:sourceDialect;</option>
+      <option value="postgres">PostgreSQL</option>
+      <option value="mysql">MySQL</option>
+      <option value="sqlite">SQLite</option>
+      <option value="sqlserver">SQL Server</option>
+    </select>
+    <button type="button" id="generateMigration" class="btn-green">string:generateMigration;</button>
+  </div>
+  <section id="migration" class="migration" hidden>
+    <div class="migrationScript">
+      <h2>string:upScript;</h2>
+      <ul id="upWarnings" class="migrationWarnings"></ul>
+      <pre id="upSql"></pre>
+      <button type="button" id="downloadUp">string:download;</button>
+    </div>
+    <div class="migrationScript">
+      <h2>string:downScript;</h2>
+      <ul id="downWarnings" class="migrationWarnings"></ul>
+      <pre id="downSql"></pre>
+      <button type="button" id="downloadDown">string:download;</button>
+    </div>
+  </section>
+</div>
+`
+
+export async function init() {
+  const translations = await getTranslations();
+  const sourceSelect = document.getElementById('sourceSession');
+  const targetSelect = document.getElementById('targetSession');
+  const resultDiv = document.getElementById('result');
+  const showError = (err) => {
+    resultDiv.style.display = 'flex';
+    resultDiv.innerText = err;
+  };
+
+  const fillSessions = async (source, target) => {
+    const sessions = await ListSessions() ?? [];
+    [sourceSelect, targetSelect].forEach(select => {
+      select.innerHTML = '';
+      sessions.forEach(session => select.add(new Option(session, session)));
+    });
+    sourceSelect.value = source;
+    targetSelect.value = target ?? sessions.find(session => session !== source) ?? source;
+  };
+  await fillSessions(getSessionId());
+
+  const compare = async () => {
+    resultDiv.style.display = 'none';
+    try {
+      const diff = JSON.parse(await CompareSessions(sourceSelect.value, targetSelect.value));
+      renderDiff(diff, translations);
+    } catch (err) {
+      showError(err);
+    }
+  };
+  document.getElementById('compare').addEventListener('click', compare);
+
+  // Un instantané exporté (production, recette...) devient une session à comparer
+  const snapshotFile = document.getElementById('snapshotFile');
+  document.getElementById('openSnapshot').addEventListener('click', () => snapshotFile.click());
+  snapshotFile.addEventListener('change', async () => {
+    const file = snapshotFile.files[0];
+    snapshotFile.value = '';
+    if (!file) return;
+    const session = file.name.replace(/\.json$/, '');
+    try {
+      await OpenSnapshot(session, await file.text());
+      await fillSessions(sourceSelect.value, session);
+      await compare();
+    } catch (err) {
+      showError(err);
+    }
+  });
+
+  // Scripts de migration de la source vers la cible (up) et retour (down)
+  let migration = null;
+  document.getElementById('generateMigration').addEventListener('click', async () => {
+    resultDiv.style.display = 'none';
+    try {
+      migration = JSON.parse(await GenerateMigration(sourceSelect.value, targetSelect.value, document.getElementById('dialect').value));
+      renderMigration(migration, translations);
+    } catch (err) {
+      showError(err);
+    }
+  });
+  const download = (sql, direction) => {
+    const link = document.createElement('a');
+    link.href = URL.createObjectURL(new Blob([sql], { type: 'application/sql' }));
+    link.download = `${sourceSelect.value}-to-${targetSelect.value}-${direction}.sql`;
+    link.click();
+    URL.revokeObjectURL(link.href);
+  };
+  document.getElementById('downloadUp').addEventListener('click', () => download(migration.upSql, 'up'));
+  document.getElementById('downloadDown').addEventListener('click', () => download(migration.downSql, 'down'));
+
+  if (sourceSelect.value !== targetSelect.value) await compare();
+}
+
+function renderMigration(migration, translations) {
+  document.getElementById('migration').hidden = false;
+  [['up', migration.upSql, migration.upWarnings], ['down', migration.downSql, migration.downWarnings]].forEach(([direction, sql, warnings]) => {
+    document.getElementById(`${direction}Sql`).textContent = sql || translations.noStatement;
+    const list = document.getElementById(`${direction}Warnings`);
+    list.innerHTML = '';
+    safeMap(warnings).forEach(warning => {
+      const item = document.createElement('li');
+      item.textContent = warning;
+      list.appendChild(item);
+    });
+    document.getElementById(`download${direction === 'up' ? 'Up' : 'Down'}`).disabled = !sql;
+  });
+}
+
+const safeMap = supposedArray => supposedArray ?? [];
+
+function renderDiff(diff, translations) {
+  const container = document.getElementById('diffContainer');
+  container.innerHTML = '';
+
+  const added = safeMap(diff.addedTables);
+  const removed = safeMap(diff.removedTables);
+  const changed = safeMap(diff.changedTables);
+  document.getElementById('summary').textContent = added.length + removed.length + changed.length === 0
+    ? translations.identical
+    : `${added.length} ${translations.addedTables}, ${removed.length} ${translations.removedTables}, ${changed.length} ${translations.changedTables}`;
+
+  safeMap(diff.renameCandidates).forEach(candidate => {
+    container.appendChild(card(`${candidate.from} → ${candidate.to}`, 'renamed', [
+      line('~', `${translations.renameCandidate} (${Math.round(candidate.similarity * 100)} %)`),
+    ]));
+  });
+  added.forEach(table => {
+    container.appendChild(card(tableName(table), 'added', [
+      line('+', translations.addedTable),
+      ...safeMap(table.columns).map(column => line('+', column.columnName, column.data_type)),
+    ]));
+  });
+  removed.forEach(table => {
+    container.appendChild(card(tableName(table), 'removed', [line('-', translations.removedTable)]));
+  });
+  changed.forEach(table => container.appendChild(card(table.tableName, 'changed', tableLines(table, translations))));
+}
+
+// Une ligne par changement de la table : colonnes, clé primaire, index, clés étrangères, CHECK
+function tableLines(table, translations) {
+  const lines = [];
+  safeMap(table.addedColumns).forEach(column => lines.push(line('+', `${translations.column} ${column.columnName}`, column.data_type)));
+  safeMap(table.removedColumns).forEach(column => lines.push(line('-', `${translations.column} ${column.columnName}`, column.data_type)));
+  safeMap(table.changedColumns).forEach(column => {
+    const changes = column.changes.map(change => translations[change] ?? change).join(', ');
+    const detail = column.sourceType !== column.targetType ? `${column.sourceType} → ${column.targetType}` : changes;
+    lines.push(line('~', `${translations.column} ${column.columnName}`, detail, changes));
+  });
+  safeMap(table.renameCandidates).forEach(candidate => {
+    lines.push(line('~', `${candidate.from} → ${candidate.to}`, `${translations.renameCandidate} (${Math.round(candidate.similarity * 100)} %)`));
+  });
+  if (table.primaryKey) {
+    lines.push(line('~', translations.primaryKey, `(${table.primaryKey.source.join(', ')}) → (${table.primaryKey.target.join(', ')})`));
+  }
+  safeMap(table.addedIndexes).forEach(index => lines.push(line('+', `${translations.index} ${index.name}`, `(${index.columns.join(', ')})`)));
+  safeMap(table.removedIndexes).forEach(index => lines.push(line('-', `${translations.index} ${index.name}`, `(${index.columns.join(', ')})`)));
+  safeMap(table.changedIndexes).forEach(index => lines.push(changeLine(translations.index, index, translations)));
+  safeMap(table.addedForeignKeys).forEach(fk => lines.push(line('+', `${translations.foreignKey} ${fk.Conname}`, foreignKeyText(fk))));
+  safeMap(table.removedForeignKeys).forEach(fk => lines.push(line('-', `${translations.foreignKey} ${fk.Conname}`, foreignKeyText(fk))));
+  safeMap(table.changedForeignKeys).forEach(fk => lines.push(changeLine(translations.foreignKey, fk, translations)));
+  safeMap(table.addedChecks).forEach(check => lines.push(line('+', `CHECK ${check.name}`, check.expression)));
+  safeMap(table.removedChecks).forEach(check => lines.push(line('-', `CHECK ${check.name}`, check.expression)));
+  safeMap(table.changedChecks).forEach(check => lines.push(changeLine('CHECK', check, translations)));
+  return lines;
+}
+
+const tableName = table => table.schema ? `${table.schema}.${table.tableName}` : table.tableName;
+
+const foreignKeyText = fk => `(${safeMap(fk.SourceColumns).join(', ')}) → ${fk.RelatedSchema ? fk.RelatedSchema + '.' : ''}${fk.RelatedTableName}(${safeMap(fk.TargetColumns).join(', ')})`;
+
+function changeLine(label, change, translations) {
+  const previousName = change.source.name ?? change.source.Conname;
+  const name = change.name !== previousName ? `${previousName} → ${change.name}` : change.name;
+  return line('~', `${label} ${name}`, change.changes.map(c => translations[c] ?? c).join(', '));
+}
+
+function line(sign, label, detail = '', title = '') {
+  const div = document.createElement('div');
+  div.className = `diffLine ${{ '+': 'added', '-': 'removed', '~': 'changed' }[sign]}`;
+  div.textContent = `${sign} ${label}${detail ? ' : ' + detail : ''}`;
+  if (title) div.title = title;
+  return div;
+}
+
+function card(title, kind, lines) {
+  const div = document.createElement('div');
+  div.className = `diffCard ${kind}`;
+  const titleDiv = document.createElement('div');
+  titleDiv.className = 'title';
+  titleDiv.textContent = title;
+  div.appendChild(titleDiv);
+  lines.forEach(line => div.appendChild(line));
+  return div;
+}
+
+// As of now, simply returns the key/val pairs
+export async function getTranslations(msg = null) {
+  return {
+    pageTitle: 'Comparaison de schémas',
+    sourceSession: 'Source',
+    targetSession: 'Cible',
+    compare: 'Comparer',
+    openSnapshot: 'Ouvrir un instantané',
+    identical: 'Les schémas sont identiques',
+    addedTables: 'table(s) ajoutée(s)',
+    removedTables: 'table(s) supprimée(s)',
+    changedTables: 'table(s) modifiée(s)',
+    addedTable: 'Table ajoutée',
+    removedTable: 'Table supprimée',
+    renameCandidate: 'Renommage probable',
+    column: 'Colonne',
+    primaryKey: 'Clé primaire',
+    index: 'Index',
+    foreignKey: 'Clé étrangère',
+    type: 'type',
+    nullability: 'NOT NULL',
+    default: 'valeur par défaut',
+    auto_increment: 'auto-incrément',
+    generated: 'colonne générée',
+    name: 'nom',
+    columns: 'colonnes',
+    unique: 'unicité',
+    method: 'méthode',
+    predicate: 'prédicat',
+    include: 'colonnes incluses',
+    target: 'table référencée',
+    on_delete: 'ON DELETE',
+    on_update: 'ON UPDATE',
+    deferrable: 'DEFERRABLE',
+    expression: 'expression',
+    dialect: 'Dialecte',
+    sourceDialect: 'Celui de la source',
+    generateMigration: 'Générer la migration',
+    upScript: 'Migration (up)',
+    downScript: 'Retour arrière (down)',
+    download: 'Télécharger',
+    noStatement: '-- Aucune instruction',
+  };
+}
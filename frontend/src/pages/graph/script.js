@@ -1,12 +1,15 @@
 import { GraphTransform } from '../../../wailsjs/go/main/App';
 import * as d3 from 'd3';
 import './styles.css';
-import { mergeArraysSafe } from '../../utils/utils';
+import { getSessionId, mergeArraysSafe } from '../../utils/utils';
 
 export const html = `
 <div id="graphPage">
   <h1>string:pageName;</h1>
 
+  <label class="graphOption">
+    <input type="checkbox" id="collapsePartitions" /> string:collapsePartitions;
+  </label>
   <div id="result" class="result"></div>
   <svg id="svg"></svg>
 </div>
@@ -14,8 +17,16 @@ export const html = `
 
 export async function init(data) {
   data['observer'].disconnect();
-  const res = await GraphTransform();
+  const collapseInput = document.getElementById('collapsePartitions');
+  collapseInput.addEventListener('change', () => drawGraph(collapseInput.checked));
+  await drawGraph(collapseInput.checked);
+}
+
+// Regroupe les partitions dans leur table parente si collapsePartitions
+async function drawGraph(collapsePartitions) {
+  const res = await GraphTransform(getSessionId(), collapsePartitions);
   const graph = JSON.parse(res);
+  d3.select("#svg").selectAll("*").remove();
 
   console.log({res})
   console.log({graph})
@@ -54,9 +65,13 @@ export async function init(data) {
   const link = svg.selectAll(".link")
     .data(graph.edges)
     .enter().append("line")
-    .attr("class", "link")
+    .attr("class", d => edgeClass(d.data))
     .attr("marker-end", "url(#end)");
 
+  // Colonnes de la clé étrangère au survol du lien
+  link.append("title")
+    .text(d => formatEdgeColumns(d.data));
+
   // Créer les nœuds
   const node = svg.selectAll(".node")
     .data(graph.nodes)
@@ -67,15 +82,16 @@ export async function init(data) {
   node.append("rect")
   //  .attr("width", calculateNodeWidth)
     .attr("height", d => 20 + d.data.columns.length * 15 + 10)
-    .attr("fill", "#fff")
-    .attr("stroke", "#999");
+    .attr("fill", d => nodeFill(d.data.kind)) // vues en bleu clair, déclencheurs en jaune
+    .attr("stroke", "#999")
+    .attr("stroke-dasharray", d => d.data.kind === 'view' ? "4 2" : null); // vues matérialisées en trait plein
 
   // Ajouter des titres aux cartes
   node.append("text")
   //  .attr("x", 60)
     .attr("y", 15)
     .attr("text-anchor", "middle")
-    .text(d => d.data.name)
+    .text(d => d.data.partitions ? `${d.data.name} (${d.data.partitions.length} partitions)` : d.data.name)
     .style("font-weight", "bold");
 
   // Ajouter les noms des colonnes
@@ -191,10 +207,42 @@ node.each(function(d) {
   console.log(graph);
 }
 
+// "table2(table1_id) -> table1(id)", or the constraint name when columns are unknown
+const formatEdgeColumns = edge => {
+  if (edge.kind === 'view_dependency') return `${edge.source} reads ${edge.target}`;
+  if (edge.kind === 'trigger_table') return `${edge.source} fires on ${edge.target}`;
+  if (edge.kind === 'trigger_reference') return `${edge.source} uses ${edge.target}`;
+  if (edge.kind === 'partition_of') return `${edge.source} is a partition of ${edge.target}`;
+  if (edge.kind === 'inherits') return `${edge.source} inherits ${edge.target}`;
+  const sourceColumns = edge.sourceColumns ?? [];
+  const targetColumns = edge.targetColumns ?? [];
+  if (sourceColumns.length === 0) return edge.id;
+  const actions = [];
+  if (edge.onDelete) actions.push(`ON DELETE ${edge.onDelete}`);
+  if (edge.onUpdate) actions.push(`ON UPDATE ${edge.onUpdate}`);
+  return `${edge.source}(${sourceColumns.join(', ')}) -> ${edge.target}(${targetColumns.join(', ')}) ${actions.join(' ')}`;
+};
+
+const nodeFill = kind => {
+  if (kind === 'table') return "#fff";
+  if (kind === 'trigger') return "#fff6d5";
+  return "#e8f0ff";
+};
+
+const isCascadeEdge = edge => edge.onDelete === 'CASCADE' || edge.onUpdate === 'CASCADE';
+
+const edgeClass = edge => {
+  if (edge.kind === 'view_dependency') return "link dependency";
+  if (edge.kind === 'trigger_table' || edge.kind === 'trigger_reference') return "link trigger";
+  if (edge.kind === 'partition_of' || edge.kind === 'inherits') return "link partition";
+  return isCascadeEdge(edge) ? "link cascade" : "link";
+};
+
 // As of now, simply returns the key/val pairs
 export async function getTranslations(msg = null) {
   // Here FetchTranslations will be called in near future
   return {
     pageName: 'Graphe structurel',
+    collapsePartitions: 'Regrouper les partitions',
   };
 }
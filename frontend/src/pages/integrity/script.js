@@ -1,5 +1,6 @@
-import { PerformAllVerifications, GetTablesList } from '../../../wailsjs/go/main/App';
+import { PerformAllVerifications, GetTablesList, PreviewFixes, ApplyFixes } from '../../../wailsjs/go/main/App';
 import './styles.css'
+import { getSessionId } from '../../utils/utils';
 
 export const html = `
 <div id="integrity">
@@ -7,15 +8,18 @@ export const html = `
   <div id="result" class="result"></div>
 
   <div class="filterBar">
-    <label for="checkTypeFilter">string:verificationsFilter; :</label>
-    <select id="checkTypeFilter" class="filterInput">
-      <option value="missingPrimaryKeys">string:missingPrimaryKeys;</option>
-      <option value="nullableColumns">string:nullableColumns;</option>
-      <option value="missingUniqueIndexes">string:missingUniqueIndexes;</option>
-      <option value="foreignKeyIssues">string:foreignKeyIssues;</option>
-      <option value="redundantIndexes">string:redundantIndexes;</option>
-      <option value="sccs">string:sccs;</option>
-      </select>
+    <label for="ruleFilter">string:verificationsFilter; :</label>
+    <select id="ruleFilter" class="filterInput">
+      <!-- Dynamically filled -->
+    </select>
+
+    <label for="severityFilter">string:severityFilter; :</label>
+    <select id="severityFilter" class="filterInput">
+      <option value="">string:allSeverities;</option>
+      <option value="error">string:error;</option>
+      <option value="warning">string:warning;</option>
+      <option value="info">string:info;</option>
+    </select>
 
     <label for="tableFilter">string:tableFilter; :</label>
     <select id="tableFilter" class="filterInput">
@@ -23,27 +27,152 @@ export const html = `
     </select>
   </div>
 
+  <div id="summary" class="integritySummary"></div>
+  <div class="fixBar">
+    <span id="selection"></span>
+    <button type="button" id="previewFixes">string:previewFixes;</button>
+    <button type="button" id="dryRunFixes" disabled>string:dryRunFixes;</button>
+    <button type="button" id="applyFixes" class="btn-green" disabled>string:applyFixes;</button>
+  </div>
+  <section id="fixScript" class="fixScript" hidden>
+    <ul id="fixNotes" class="fixNotes"></ul>
+    <pre id="fixSql"></pre>
+  </section>
   <section id="problemsContainer" class="schemaProblemsContainer">
     <!-- Dynamically filled -->
   </section>
 </div>
 `
 
+// Constats cochés, corrigés ensemble
+const selected = new Set();
+
 export async function init() {
-  const schemaChecks = await PerformAllVerifications();
-  const schemaData = JSON.parse(schemaChecks);
-  console.log(schemaData)
-  const tablesList = await GetTablesList();
+  const translations = await getTranslations();
+  selected.clear();
+  let report = await load(translations);
+
+  ['ruleFilter', 'severityFilter', 'tableFilter'].forEach(id => {
+    document.getElementById(id).addEventListener('change', () => report && applyFilters(report, translations));
+  });
+  document.getElementById('previewFixes').addEventListener('click', () => previewFixes(translations));
+  document.getElementById('dryRunFixes').addEventListener('click', () => applyFixes(translations, true));
+  document.getElementById('applyFixes').addEventListener('click', async () => {
+    if (!confirm(translations.confirmApply.replace('%d', selected.size))) return;
+    if (await applyFixes(translations, false)) {
+      // Les métadonnées sont rechargées, les constats corrigés disparaissent
+      selected.clear();
+      report = await load(translations);
+    }
+  });
+}
+
+async function load(translations) {
+  const resultDiv = document.getElementById('result');
+  let report;
+  try {
+    report = JSON.parse(await PerformAllVerifications(getSessionId()));
+  } catch (err) {
+    resultDiv.style.display = 'flex';
+    resultDiv.innerText = err;
+    return null;
+  }
+  const tablesList = await GetTablesList(getSessionId());
+  populateRuleFilter(safeMap(report.rules), translations);
   populateTableFilter(
-    safeMap(tablesList).map(item => item.tableName),
+    safeMap(tablesList).map(item => item.schema ? `${item.schema}.${item.tableName}` : item.tableName),
   );
-  applyFilters(schemaData);
-  document.getElementById('tableFilter').addEventListener('change', () => applyFilters(schemaData));
-  document.getElementById('checkTypeFilter').addEventListener('change', () => applyFilters(schemaData));
+
+  // Fichier de configuration des règles et constats qu'il écarte
+  const summary = [`${safeMap(report.findings).length} ${translations.findings}`];
+  if (report.suppressed) summary.push(`${report.suppressed} ${translations.suppressed}`);
+  if (report.configPath) summary.push(`${translations.configPath} : ${report.configPath}`);
+  document.getElementById('summary').textContent = summary.join(' · ');
+
+  updateSelection(translations);
+  applyFilters(report, translations);
+  return report;
+}
+
+function updateSelection(translations) {
+  document.getElementById('selection').textContent = selected.size
+    ? `${selected.size} ${translations.selectedFindings}`
+    : translations.noSelection;
+  document.getElementById('dryRunFixes').disabled = selected.size === 0;
+  document.getElementById('applyFixes').disabled = selected.size === 0;
+}
+
+// Script des constats cochés, de tous sans sélection
+async function previewFixes(translations) {
+  const resultDiv = document.getElementById('result');
+  resultDiv.style.display = 'none';
+  let script;
+  try {
+    script = JSON.parse(await PreviewFixes(getSessionId(), [...selected]));
+  } catch (err) {
+    resultDiv.style.display = 'flex';
+    resultDiv.innerText = err;
+    return;
+  }
+  const notes = safeMap(script.notes);
+  if (script.conflicts) notes.unshift(`${translations.conflicts} : ${script.conflicts.join(', ')}`);
+  showScript(notes, script.sql || `-- ${translations.noStatement}`);
+}
+
+// Applique les corrections cochées dans une transaction, annulée pour un essai
+async function applyFixes(translations, dryRun) {
+  const resultDiv = document.getElementById('result');
+  resultDiv.style.display = 'none';
+  let result;
+  try {
+    result = JSON.parse(await ApplyFixes(getSessionId(), [...selected], dryRun));
+  } catch (err) {
+    resultDiv.style.display = 'flex';
+    resultDiv.innerText = err;
+    return false;
+  }
+  showScript([dryRun ? translations.dryRunDone : translations.applyDone], result.sql);
+  return true;
+}
+
+function showScript(notes, sql) {
+  const list = document.getElementById('fixNotes');
+  list.innerHTML = '';
+  notes.forEach(note => {
+    const item = document.createElement('li');
+    item.textContent = note;
+    list.appendChild(item);
+  });
+  document.getElementById('fixSql').textContent = sql;
+  document.getElementById('fixScript').hidden = false;
 }
 
 const safeMap = supposedArray => supposedArray ?? [];
 
+// missing-primary-key -> missingPrimaryKey, clé des traductions de la règle
+const ruleKey = id => id.replace(/-([a-z])/g, (match, letter) => letter.toUpperCase());
+
+const ruleLabel = (id, translations) => translations[ruleKey(id)] ?? id;
+
+// Règles groupées par catégorie (clés, colonnes, index, relations, nommage)
+function populateRuleFilter(rules, translations) {
+  const select = document.getElementById('ruleFilter');
+  select.innerHTML = '';
+  select.add(new Option(translations.allRules, ''));
+  const groups = {};
+  rules.forEach(rule => {
+    if (!groups[rule.category]) {
+      groups[rule.category] = document.createElement('optgroup');
+      groups[rule.category].label = translations[`${rule.category}Category`] ?? rule.category;
+      select.appendChild(groups[rule.category]);
+    }
+    const label = ruleLabel(rule.id, translations);
+    const option = new Option(rule.enabled ? label : `${label} (${translations.disabled})`, rule.id);
+    option.disabled = !rule.enabled;
+    groups[rule.category].appendChild(option);
+  });
+}
+
 function populateTableFilter(tables) {
   const select = document.getElementById('tableFilter');
   select.innerHTML = '<option value="All Tables">string:allTables;</option>';
@@ -55,109 +184,127 @@ function populateTableFilter(tables) {
   });
 }
 
-function applyFilters(schemaData) {
+function applyFilters(report, translations) {
+  const selectedRule = document.getElementById('ruleFilter').value;
+  const selectedSeverity = document.getElementById('severityFilter').value;
   const selectedTable = document.getElementById('tableFilter').value;
-  const selectedCheckType = document.getElementById('checkTypeFilter').value;
   const problemsContainer = document.getElementById('problemsContainer');
   problemsContainer.innerHTML = '';
 
-  const filteredProblems = getFilteredProblems(selectedTable, selectedCheckType, schemaData);
-  filteredProblems.forEach(problem => {
-    problemsContainer.appendChild(formatProblemToCard(problem));
-  });
-}
-
-function getFilteredProblems(selectedTable, selectedCheckType, schemaData) {
-  let problems = [];
-
-  if (selectedCheckType === 'missingPrimaryKeys') {
-    problems = problems.concat(safeMap(schemaData.missingPrimaryKeys));
-  }
-  if (selectedCheckType === 'nullableColumns') {
-    problems = problems.concat(safeMap(schemaData.nullableColumns));
-  }
-  if (selectedCheckType === 'missingUniqueIndexes') {
-    problems = problems.concat(safeMap(schemaData.missingUniqueIndexes));
-  }
-  if (selectedCheckType === 'foreignKeyIssues') {
-    problems = problems.concat(safeMap(schemaData.foreignKeyIssues));
-  }
-  if (selectedCheckType === 'redundantIndexes') {
-    problems = problems.concat(safeMap(schemaData.redundantIndexes));
-  }
-  if (selectedCheckType === 'sccs') {
-    problems = problems.concat(safeMap(schemaData.sccs).reduce((acc, scc) => {
-    //  if (scc.length > 1) {
-        acc.push({ scc: scc, tableName: "string:SCC;" });
-        return acc;
-    //  } commented for debug
-      return acc;
-    }, []));
-  }
-
-  if (selectedTable !== 'All Tables') {
-    if (selectedCheckType !== 'sccs') {
-      problems = problems.filter(problem => problem.tableName === selectedTable);
-    } else {
-      problems = problems.filter(problem => problem.scc.includes(selectedTable));
-    }
-  }
-
-  console.log({ problems })
-  return problems;
+  safeMap(report.findings)
+    .filter(finding => !selectedRule || finding.ruleId === selectedRule)
+    .filter(finding => !selectedSeverity || finding.severity === selectedSeverity)
+    .filter(finding => selectedTable === 'All Tables' || finding.tableName === selectedTable || safeMap(finding.tables).includes(selectedTable))
+    .forEach(finding => problemsContainer.appendChild(formatProblemToCard(finding, translations)));
 }
 
-function formatProblemToCard(problem) {
+function formatProblemToCard(finding, translations) {
   const card = document.createElement('div');
-  card.className = 'problemCard';
+  card.className = `problemCard ${finding.severity}`;
+
+  // Règle et sévérité
+  const rule = document.createElement('div');
+  rule.className = 'rule';
+  rule.textContent = `${translations[finding.severity] ?? finding.severity} · ${ruleLabel(finding.ruleId, translations)}`;
+  rule.title = finding.ruleId;
+  card.appendChild(rule);
 
-  // Card title. as 'tableName' field
+  // Card title. as 'tableName' field, the group of tables otherwise
   const title = document.createElement('div');
   title.className = "title";
-  title.textContent = problem.tableName;
+  title.textContent = finding.tableName ?? translations.tablesGroup;
   card.appendChild(title);
 
-  // Col name if exists
-  if (problem.columnName ?? false) {
-    const columnName = document.createElement('div');
-    columnName.className = "column";
-    columnName.textContent = problem.columnName;
-    card.appendChild(columnName);
+  // Col names if exist
+  if (finding.columns ?? false) {
+    const columns = document.createElement('div');
+    columns.className = "column";
+    columns.textContent = finding.columns.join(', ');
+    card.appendChild(columns);
   }
 
   // index name...
-  if (problem.indexName ?? false) {
+  if (finding.indexName ?? false) {
     const indexName = document.createElement('div');
     indexName.className = "index";
-    indexName.textContent = problem.indexName;
+    indexName.textContent = finding.indexName;
     card.appendChild(indexName);
   }
 
   // Related Table..
-  if (problem.relatedTableName ?? false) {
+  if (finding.relatedTableName ?? false) {
     const relatedTable = document.createElement('div');
     relatedTable.className = "relatedTable";
-    relatedTable.textContent = problem.relatedTableName;
+    relatedTable.textContent = finding.relatedTableName;
     card.appendChild(relatedTable);
   }
 
-  // Related Table..
-  if (problem.scc ?? false) {
-    problem.scc.forEach(table => {
-      const scc = document.createElement('div');
-      scc.className = "column"; // columnName's css for bold font.
-      console.log(table);
-      scc.textContent = table;
-      card.appendChild(scc);
-    })
+  // Constraint name..
+  if (finding.constraintName ?? false) {
+    const constraintName = document.createElement('div');
+    constraintName.className = "index";
+    constraintName.textContent = finding.constraintName;
+    card.appendChild(constraintName);
+  }
+
+  // Cascade path, or one table per line for a group
+  if (finding.tables ?? false) {
+    if (finding.tableName) {
+      const path = document.createElement('div');
+      path.className = "column";
+      path.textContent = finding.tables.join(' -> ');
+      card.appendChild(path);
+    } else {
+      finding.tables.forEach(table => {
+        const member = document.createElement('div');
+        member.className = "column"; // columnName's css for bold font.
+        member.textContent = table;
+        card.appendChild(member);
+      });
+    }
   }
 
   // Description...
   const description = document.createElement('div');
   description.className = "description";
-  description.textContent = problem.issueDescription;
+  description.textContent = finding.message;
   card.appendChild(description);
 
+  // Correction proposée, à cocher quand elle a des requêtes
+  if (finding.fix ?? false) {
+    if (finding.fix.rename ?? false) {
+      const rename = document.createElement('div');
+      rename.className = 'fixRename';
+      rename.textContent = `${translations.rename} : ${finding.fix.rename}`;
+      card.appendChild(rename);
+    }
+    if (finding.fix.statements ?? false) {
+      const label = document.createElement('label');
+      label.className = 'fixSelect';
+      const checkbox = document.createElement('input');
+      checkbox.type = 'checkbox';
+      checkbox.checked = selected.has(finding.id);
+      checkbox.addEventListener('change', () => {
+        if (checkbox.checked) selected.add(finding.id);
+        else selected.delete(finding.id);
+        updateSelection(translations);
+      });
+      label.append(checkbox, ` ${translations.fix}`);
+      card.appendChild(label);
+
+      const statements = document.createElement('pre');
+      statements.className = 'fixStatements';
+      statements.textContent = finding.fix.statements.join(';\n') + ';';
+      card.appendChild(statements);
+    }
+    [finding.fix.note, ...safeMap(finding.fix.warnings)].filter(Boolean).forEach(text => {
+      const note = document.createElement('div');
+      note.className = 'fixNote';
+      note.textContent = text;
+      card.appendChild(note);
+    });
+  }
+
   return card;
 }
 
@@ -168,14 +315,49 @@ export async function getTranslations(msg = null) {
   return {
     pageTitle: 'Intégrité du schema',
     verificationsFilter: 'Vérification',
+    severityFilter: 'Sévérité',
     tableFilter: 'Table',
+    allRules: 'Toutes les règles',
+    allSeverities: 'Toutes',
     allTables: 'Toutes les tables',
-    missingPrimaryKeys: 'Clés primaire manquantes',
-    nullableColumns: 'NOT NULL = false',
-    missingUniqueIndexes: 'Indexs manquants',
-    foreignKeyIssues: 'Problèmes de foreign key',
-    redundantIndexes: 'Indexs redondants',
-    sccs: 'Relations circulaires',
-    SCC: 'SCC group'
+    disabled: 'désactivée',
+    error: 'Erreur',
+    warning: 'Avertissement',
+    info: 'Info',
+    findings: 'constat(s)',
+    suppressed: 'écarté(s) par la configuration',
+    configPath: 'Configuration',
+    tablesGroup: 'Groupe de tables',
+    missingPrimaryKey: 'Clés primaire manquantes',
+    nullableColumn: 'NOT NULL = false',
+    uniqueWithoutIndex: 'Indexs manquants',
+    missingForeignKeyTable: 'Tables référencées introuvables',
+    unindexedForeignKey: 'Foreign keys sans index',
+    redundantIndex: 'Indexs redondants',
+    circularRelations: 'Relations circulaires',
+    setNullOnNotNull: 'SET NULL sur colonne NOT NULL',
+    cascadeDepth: 'Cascades trop profondes',
+    namingCase: 'Casse des noms',
+    namingSingularTable: 'Tables au pluriel',
+    namingForeignKeyColumn: 'Colonnes de foreign key mal nommées',
+    namingPrefix: 'Préfixes ix_ / uq_ / fk_',
+    namingReservedKeyword: 'Mots-clés réservés',
+    keysCategory: 'Clés',
+    columnsCategory: 'Colonnes',
+    indexesCategory: 'Index',
+    relationsCategory: 'Relations',
+    namingCategory: 'Nommage',
+    rename: 'Renommer en',
+    fix: 'Corriger',
+    previewFixes: 'Prévisualiser le script',
+    dryRunFixes: 'Essai (dry run)',
+    applyFixes: 'Appliquer',
+    noSelection: 'Aucun constat coché, le script corrige tout',
+    selectedFindings: 'constat(s) coché(s)',
+    confirmApply: 'Appliquer les corrections de %d constat(s) à la base ?',
+    conflicts: 'Tables reconstruites par plusieurs corrections, à appliquer une à une',
+    noStatement: 'Aucune requête',
+    dryRunDone: 'Essai réussi, la transaction a été annulée',
+    applyDone: 'Corrections appliquées, métadonnées rechargées',
   };
-}
\ No newline at end of file
+}
@@ -1,4 +1,6 @@
+import { ExportSnapshot, ListSessions } from '../../../wailsjs/go/main/App';
 import { pagesKeys, loadPage } from '../../main';
+import { getSessionId, setSessionId } from '../../utils/utils';
 import './styles.css';
 
 export const html = ``;
@@ -27,10 +29,15 @@ export const init = async (data) => {
       page: pagesKeys.restapi,
       name: pagesKeys.restapi,
     },
+    {
+      text: 'Schema diff',
+      page: pagesKeys.diff,
+      name: pagesKeys.diff,
+    },
     {
       text: 'SQL Generator',
-      page: pagesKeys.graph,
-      name: null,
+      page: pagesKeys.sqlgen,
+      name: pagesKeys.sqlgen,
     },
   ];
 
@@ -43,4 +50,42 @@ export const init = async (data) => {
     div.onclick = () => loadPage(item.page);
     navDiv.appendChild(div);
   });
+
+  // Export du schéma de la session pour le rouvrir hors ligne
+  if (pageName !== pagesKeys.connection) {
+    const exportDiv = document.createElement('div');
+    exportDiv.className = 'item';
+    exportDiv.textContent = 'Exporter l\'instantané';
+    exportDiv.onclick = async () => {
+      try {
+        const link = document.createElement('a');
+        link.href = URL.createObjectURL(new Blob([await ExportSnapshot(getSessionId())], { type: 'application/json' }));
+        link.download = `${getSessionId()}-snapshot.json`;
+        link.click();
+        URL.revokeObjectURL(link.href);
+      } catch (err) {
+        alert(err);
+      }
+    };
+    navDiv.appendChild(exportDiv);
+  }
+
+  // Sélecteur de session : recharge la page courante sur l'autre base
+  const sessions = await ListSessions() ?? [];
+  if (sessions.length > 1) {
+    const select = document.createElement('select');
+    select.className = 'session';
+    sessions.forEach(session => {
+      const option = document.createElement('option');
+      option.value = session;
+      option.textContent = session;
+      option.selected = session === getSessionId();
+      select.appendChild(option);
+    });
+    select.onchange = () => {
+      setSessionId(select.value);
+      loadPage(pageName);
+    };
+    navDiv.appendChild(select);
+  }
 }
\ No newline at end of file
@@ -0,0 +1,122 @@
+import { ExportDDL } from '../../../wailsjs/go/main/App';
+import './styles.css'
+import { getSessionId } from '../../utils/utils';
+
+export const html = `
+<div id="sqlgen">
+  <h1>string:pageTitle;</h1>
+  <div id="result" class="result"></div>
+
+  <div class="filterBar">
+    <label for="dialect">string:dialect; :</label>
+    <select id="dialect" class="filterInput">
+      <option value="">string:sessionDialect;</option>
+      <option value="postgres">PostgreSQL</option>
+      <option value="mysql">MySQL</option>
+      <option value="sqlite">SQLite</option>
+      <option value="sqlserver">SQL Server</option>
+    </select>
+    <button type="button" id="generate" class="btn-green">string:generate;</button>
+    <button type="button" id="download" disabled>string:download;</button>
+  </div>
+
+  <section class="ddl">
+    <div class="ddlScript">
+      <ul id="warnings" class="ddlWarnings"></ul>
+      <pre id="sql"></pre>
+    </div>
+    <div id="conversions" class="ddlConversions" hidden>
+      <h2>string:conversions;</h2>
+      <table>
+        <thead>
+          <tr>
+            <th>string:table;</th>
+            <th>string:object;</th>
+            <th>string:from;</th>
+            <th>string:to;</th>
+            <th>string:note;</th>
+          </tr>
+        </thead>
+        <tbody id="conversionRows"></tbody>
+      </table>
+    </div>
+  </section>
+</div>
+`
+
+export async function init() {
+  const translations = await getTranslations();
+  const resultDiv = document.getElementById('result');
+  const dialectSelect = document.getElementById('dialect');
+
+  // Le DDL de la session, traduit quand un autre dialecte est choisi
+  let ddl = null;
+  const generate = async () => {
+    resultDiv.style.display = 'none';
+    try {
+      ddl = JSON.parse(await ExportDDL(getSessionId(), dialectSelect.value));
+      renderDDL(ddl, translations);
+    } catch (err) {
+      resultDiv.style.display = 'flex';
+      resultDiv.innerText = err;
+    }
+  };
+  document.getElementById('generate').addEventListener('click', generate);
+  document.getElementById('download').addEventListener('click', () => {
+    const link = document.createElement('a');
+    link.href = URL.createObjectURL(new Blob([ddl.sql], { type: 'application/sql' }));
+    link.download = `${getSessionId()}-${ddl.dialect}.sql`;
+    link.click();
+    URL.revokeObjectURL(link.href);
+  });
+
+  await generate();
+}
+
+const safeMap = supposedArray => supposedArray ?? [];
+
+function renderDDL(ddl, translations) {
+  document.getElementById('sql').textContent = ddl.sql || translations.noStatement;
+  document.getElementById('download').disabled = !ddl.sql;
+
+  const list = document.getElementById('warnings');
+  list.innerHTML = '';
+  safeMap(ddl.warnings).forEach(warning => {
+    const item = document.createElement('li');
+    item.textContent = warning;
+    list.appendChild(item);
+  });
+
+  // Une ligne par type, valeur par défaut ou expression qui change en passant d'un dialecte à l'autre
+  const conversions = safeMap(ddl.conversions);
+  document.getElementById('conversions').hidden = conversions.length === 0;
+  const tbody = document.getElementById('conversionRows');
+  tbody.innerHTML = '';
+  conversions.forEach(conversion => {
+    const row = document.createElement('tr');
+    [conversion.table, conversion.object, conversion.from, conversion.to, conversion.note].forEach(value => {
+      const cell = document.createElement('td');
+      cell.textContent = value ?? '';
+      row.appendChild(cell);
+    });
+    tbody.appendChild(row);
+  });
+}
+
+// As of now, simply returns the key/val pairs
+export async function getTranslations(msg = null) {
+  return {
+    pageTitle: 'Générateur SQL',
+    dialect: 'Dialecte',
+    sessionDialect: 'Celui de la session',
+    generate: 'Générer le DDL',
+    download: 'Télécharger',
+    conversions: 'Conversions',
+    table: 'Table',
+    object: 'Objet',
+    from: 'Source',
+    to: 'Cible',
+    note: 'Note',
+    noStatement: '-- Aucune instruction',
+  };
+}
@@ -1,3 +1,8 @@
+// Session affichée par les pages, choisie à la connexion ou dans la navigation
+let sessionId = 'default';
+export const getSessionId = () => sessionId;
+export const setSessionId = id => { sessionId = id; };
+
 export const mergeArraysSafe = (...arrays) => {
   // Filter arrays
   const filteredArrays = arrays.map(arr => Array.isArray(arr) ? arr : []);
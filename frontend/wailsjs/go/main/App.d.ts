@@ -2,13 +2,71 @@
 // This file is automatically generated. DO NOT EDIT
 import {api} from '../models';
 import {dbstructs} from '../models';
+import {registry} from '../models';
+import {profiles} from '../models';
 
-export function ConfigureGorm(arg1:string,arg2:string,arg3:string,arg4:string,arg5:string,arg6:string):Promise<string>;
+export function ApplyFixes(arg1:string,arg2:Array<string>,arg3:boolean):Promise<string>;
 
-export function GenerateOpenApi(arg1:api.APIConfig):Promise<string>;
+export function CancelConfigureGorm(arg1:string):Promise<void>;
 
-export function GetTablesList():Promise<Array<dbstructs.TableMetadata>>;
+export function CloseSession(arg1:string):Promise<void>;
 
-export function GraphTransform():Promise<string>;
+export function CompareSessions(arg1:string,arg2:string):Promise<string>;
 
-export function PerformAllVerifications():Promise<string>;
+export function ConfigureGorm(arg1:string,arg2:string,arg3:string,arg4:string,arg5:string,arg6:string,arg7:string,arg8:string,arg9:registry.Options):Promise<string>;
+
+export function ConnectProfile(arg1:string,arg2:string):Promise<string>;
+
+export function DeleteProfile(arg1:string):Promise<void>;
+
+export function ExportDDL(arg1:string,arg2:string):Promise<string>;
+
+export function ExportProfiles():Promise<string>;
+
+export function ExportSnapshot(arg1:string):Promise<string>;
+
+export function GenerateMigration(arg1:string,arg2:string,arg3:string):Promise<string>;
+
+export function GenerateOpenApi(arg1:string,arg2:api.APIConfig):Promise<string>;
+
+export function GetConnectors():Promise<Array<registry.Driver>>;
+
+export function GetProfile(arg1:string):Promise<profiles.Profile>;
+
+export function GetRoutinesList(arg1:string):Promise<Array<dbstructs.RoutineMetadata>>;
+
+export function GetSequencesList(arg1:string):Promise<Array<dbstructs.SequenceMetadata>>;
+
+export function GetTablesList(arg1:string):Promise<Array<dbstructs.TableMetadata>>;
+
+export function GetTriggersList(arg1:string):Promise<Array<dbstructs.TriggerMetadata>>;
+
+export function GetTypesList(arg1:string):Promise<Array<dbstructs.TypeMetadata>>;
+
+export function GetViewsList(arg1:string):Promise<Array<dbstructs.ViewMetadata>>;
+
+export function GraphTransform(arg1:string,arg2:boolean):Promise<string>;
+
+export function ImportProfiles(arg1:string):Promise<number>;
+
+export function ListProfiles():Promise<Array<profiles.Profile>>;
+
+export function ListSessions():Promise<Array<string>>;
+
+export function LockProfiles():Promise<void>;
+
+export function OpenDDL(arg1:string,arg2:string,arg3:string):Promise<string>;
+
+export function OpenSnapshot(arg1:string,arg2:string):Promise<string>;
+
+export function PerformAllVerifications(arg1:string):Promise<string>;
+
+export function PreviewFixes(arg1:string,arg2:Array<string>):Promise<string>;
+
+export function ProfilesUnlocked():Promise<boolean>;
+
+export function SaveProfile(arg1:profiles.Profile):Promise<void>;
+
+export function TestProfile(arg1:string):Promise<void>;
+
+export function UnlockProfiles(arg1:string):Promise<void>;
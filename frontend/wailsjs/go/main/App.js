@@ -2,22 +2,134 @@
 // Cynhyrchwyd y ffeil hon yn awtomatig. PEIDIWCH Â MODIWL
 // This file is automatically generated. DO NOT EDIT
 
-export function ConfigureGorm(arg1, arg2, arg3, arg4, arg5, arg6) {
-  return window['go']['main']['App']['ConfigureGorm'](arg1, arg2, arg3, arg4, arg5, arg6);
+export function ApplyFixes(arg1, arg2, arg3) {
+  return window['go']['main']['App']['ApplyFixes'](arg1, arg2, arg3);
 }
 
-export function GenerateOpenApi(arg1) {
-  return window['go']['main']['App']['GenerateOpenApi'](arg1);
+export function CancelConfigureGorm(arg1) {
+  return window['go']['main']['App']['CancelConfigureGorm'](arg1);
 }
 
-export function GetTablesList() {
-  return window['go']['main']['App']['GetTablesList']();
+export function CloseSession(arg1) {
+  return window['go']['main']['App']['CloseSession'](arg1);
 }
 
-export function GraphTransform() {
-  return window['go']['main']['App']['GraphTransform']();
+export function CompareSessions(arg1, arg2) {
+  return window['go']['main']['App']['CompareSessions'](arg1, arg2);
 }
 
-export function PerformAllVerifications() {
-  return window['go']['main']['App']['PerformAllVerifications']();
+export function ConfigureGorm(arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8, arg9) {
+  return window['go']['main']['App']['ConfigureGorm'](arg1, arg2, arg3, arg4, arg5, arg6, arg7, arg8, arg9);
+}
+
+export function ConnectProfile(arg1, arg2) {
+  return window['go']['main']['App']['ConnectProfile'](arg1, arg2);
+}
+
+export function DeleteProfile(arg1) {
+  return window['go']['main']['App']['DeleteProfile'](arg1);
+}
+
+export function ExportDDL(arg1, arg2) {
+  return window['go']['main']['App']['ExportDDL'](arg1, arg2);
+}
+
+export function ExportProfiles() {
+  return window['go']['main']['App']['ExportProfiles']();
+}
+
+export function ExportSnapshot(arg1) {
+  return window['go']['main']['App']['ExportSnapshot'](arg1);
+}
+
+export function GenerateMigration(arg1, arg2, arg3) {
+  return window['go']['main']['App']['GenerateMigration'](arg1, arg2, arg3);
+}
+
+export function GenerateOpenApi(arg1, arg2) {
+  return window['go']['main']['App']['GenerateOpenApi'](arg1, arg2);
+}
+
+export function GetConnectors() {
+  return window['go']['main']['App']['GetConnectors']();
+}
+
+export function GetProfile(arg1) {
+  return window['go']['main']['App']['GetProfile'](arg1);
+}
+
+export function GetRoutinesList(arg1) {
+  return window['go']['main']['App']['GetRoutinesList'](arg1);
+}
+
+export function GetSequencesList(arg1) {
+  return window['go']['main']['App']['GetSequencesList'](arg1);
+}
+
+export function GetTablesList(arg1) {
+  return window['go']['main']['App']['GetTablesList'](arg1);
+}
+
+export function GetTriggersList(arg1) {
+  return window['go']['main']['App']['GetTriggersList'](arg1);
+}
+
+export function GetTypesList(arg1) {
+  return window['go']['main']['App']['GetTypesList'](arg1);
+}
+
+export function GetViewsList(arg1) {
+  return window['go']['main']['App']['GetViewsList'](arg1);
+}
+
+export function GraphTransform(arg1, arg2) {
+  return window['go']['main']['App']['GraphTransform'](arg1, arg2);
+}
+
+export function ImportProfiles(arg1) {
+  return window['go']['main']['App']['ImportProfiles'](arg1);
+}
+
+export function ListProfiles() {
+  return window['go']['main']['App']['ListProfiles']();
+}
+
+export function ListSessions() {
+  return window['go']['main']['App']['ListSessions']();
+}
+
+export function LockProfiles() {
+  return window['go']['main']['App']['LockProfiles']();
+}
+
+export function OpenDDL(arg1, arg2, arg3) {
+  return window['go']['main']['App']['OpenDDL'](arg1, arg2, arg3);
+}
+
+export function OpenSnapshot(arg1, arg2) {
+  return window['go']['main']['App']['OpenSnapshot'](arg1, arg2);
+}
+
+export function PerformAllVerifications(arg1) {
+  return window['go']['main']['App']['PerformAllVerifications'](arg1);
+}
+
+export function PreviewFixes(arg1, arg2) {
+  return window['go']['main']['App']['PreviewFixes'](arg1, arg2);
+}
+
+export function ProfilesUnlocked() {
+  return window['go']['main']['App']['ProfilesUnlocked']();
+}
+
+export function SaveProfile(arg1) {
+  return window['go']['main']['App']['SaveProfile'](arg1);
+}
+
+export function TestProfile(arg1) {
+  return window['go']['main']['App']['TestProfile'](arg1);
+}
+
+export function UnlockProfiles(arg1) {
+  return window['go']['main']['App']['UnlockProfiles'](arg1);
 }
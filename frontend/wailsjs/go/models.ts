@@ -1,8 +1,46 @@
 export namespace dbstructs {
 	
+	export class CheckConstraint {
+	    name: string;
+	    columns: string[];
+	    expression: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new CheckConstraint(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.columns = source["columns"];
+	        this.expression = source["expression"];
+	    }
+	}
+	export class IndexKey {
+	    column?: string;
+	    expression?: string;
+	    descending?: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new IndexKey(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.column = source["column"];
+	        this.expression = source["expression"];
+	        this.descending = source["descending"];
+	    }
+	}
 	export class Index {
 	    name: string;
 	    columns: string[];
+	    keys?: IndexKey[];
+	    include?: string[];
+	    unique?: boolean;
+	    primary?: boolean;
+	    method?: string;
+	    predicate?: string;
 	
 	    static createFrom(source: any = {}) {
 	        return new Index(source);
@@ -12,13 +50,46 @@ export namespace dbstructs {
 	        if ('string' === typeof source) source = JSON.parse(source);
 	        this.name = source["name"];
 	        this.columns = source["columns"];
+	        this.keys = this.convertValues(source["keys"], IndexKey);
+	        this.include = source["include"];
+	        this.unique = source["unique"];
+	        this.primary = source["primary"];
+	        this.method = source["method"];
+	        this.predicate = source["predicate"];
 	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
 	}
 	export class Column {
 	    columnName: string;
 	    data_type: string;
 	    not_null: boolean;
 	    unique: boolean;
+	    default?: string;
+	    auto_increment?: boolean;
+	    generated?: string;
+	    character_length?: number;
+	    numeric_precision?: number;
+	    numeric_scale?: number;
+	    ordinal_position?: number;
+	    user_type?: string;
+	    enum_values?: string[];
 	
 	    static createFrom(source: any = {}) {
 	        return new Column(source);
@@ -30,14 +101,167 @@ export namespace dbstructs {
 	        this.data_type = source["data_type"];
 	        this.not_null = source["not_null"];
 	        this.unique = source["unique"];
+	        this.default = source["default"];
+	        this.auto_increment = source["auto_increment"];
+	        this.generated = source["generated"];
+	        this.character_length = source["character_length"];
+	        this.numeric_precision = source["numeric_precision"];
+	        this.numeric_scale = source["numeric_scale"];
+	        this.ordinal_position = source["ordinal_position"];
+	        this.user_type = source["user_type"];
+	        this.enum_values = source["enum_values"];
+	    }
+	}
+	export class Partition {
+	    name: string;
+	    bound?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Partition(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.bound = source["bound"];
+	    }
+	}
+	export class Partitioning {
+	    strategy: string;
+	    key: string;
+	    partitions: Partition[];
+	
+	    static createFrom(source: any = {}) {
+	        return new Partitioning(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.strategy = source["strategy"];
+	        this.key = source["key"];
+	        this.partitions = this.convertValues(source["partitions"], Partition);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class RoutineArgument {
+	    name: string;
+	    data_type: string;
+	    mode: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new RoutineArgument(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.data_type = source["data_type"];
+	        this.mode = source["mode"];
+	    }
+	}
+	export class RoutineMetadata {
+	    schema?: string;
+	    routineName: string;
+	    kind: string;
+	    arguments: RoutineArgument[];
+	    return_type?: string;
+	    language: string;
+	    body: string;
+	    references: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new RoutineMetadata(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.schema = source["schema"];
+	        this.routineName = source["routineName"];
+	        this.kind = source["kind"];
+	        this.arguments = this.convertValues(source["arguments"], RoutineArgument);
+	        this.return_type = source["return_type"];
+	        this.language = source["language"];
+	        this.body = source["body"];
+	        this.references = source["references"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class SequenceMetadata {
+	    schema?: string;
+	    sequenceName: string;
+	    data_type: string;
+	    start: number;
+	    increment: number;
+	    min_value: number;
+	    max_value: number;
+	    cycle: boolean;
+	    owner_table?: string;
+	    owner_column?: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new SequenceMetadata(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.schema = source["schema"];
+	        this.sequenceName = source["sequenceName"];
+	        this.data_type = source["data_type"];
+	        this.start = source["start"];
+	        this.increment = source["increment"];
+	        this.min_value = source["min_value"];
+	        this.max_value = source["max_value"];
+	        this.cycle = source["cycle"];
+	        this.owner_table = source["owner_table"];
+	        this.owner_column = source["owner_column"];
 	    }
 	}
 	export class TableMetadata {
+	    schema?: string;
 	    tableName: string;
 	    columns: Column[];
 	    primary_key: string[];
 	    indexes: Index[];
 	    relationships: RelationshipMetadata[];
+	    check_constraints?: CheckConstraint[];
+	    partitioning?: Partitioning;
+	    partition_of?: string;
+	    partition_bound?: string;
+	    inherits?: string[];
 	
 	    static createFrom(source: any = {}) {
 	        return new TableMetadata(source);
@@ -45,11 +269,17 @@ export namespace dbstructs {
 	
 	    constructor(source: any = {}) {
 	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.schema = source["schema"];
 	        this.tableName = source["tableName"];
 	        this.columns = this.convertValues(source["columns"], Column);
 	        this.primary_key = source["primary_key"];
 	        this.indexes = this.convertValues(source["indexes"], Index);
 	        this.relationships = this.convertValues(source["relationships"], RelationshipMetadata);
+	        this.check_constraints = this.convertValues(source["check_constraints"], CheckConstraint);
+	        this.partitioning = this.convertValues(source["partitioning"], Partitioning);
+	        this.partition_of = source["partition_of"];
+	        this.partition_bound = source["partition_bound"];
+	        this.inherits = source["inherits"];
 	    }
 	
 		convertValues(a: any, classs: any, asMap: boolean = false): any {
@@ -70,6 +300,338 @@ export namespace dbstructs {
 		    return a;
 		}
 	}
+	export class TriggerMetadata {
+	    triggerName: string;
+	    table_schema?: string;
+	    tableName: string;
+	    timing: string;
+	    events: string[];
+	    orientation: string;
+	    body: string;
+	    references: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new TriggerMetadata(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.triggerName = source["triggerName"];
+	        this.table_schema = source["table_schema"];
+	        this.tableName = source["tableName"];
+	        this.timing = source["timing"];
+	        this.events = source["events"];
+	        this.orientation = source["orientation"];
+	        this.body = source["body"];
+	        this.references = source["references"];
+	    }
+	}
+	export class TypeMetadata {
+	    schema?: string;
+	    typeName: string;
+	    kind: string;
+	    values?: string[];
+	    base_type?: string;
+	    not_null?: boolean;
+	    default?: string;
+	    checks?: string[];
+	    attributes?: Column[];
+	
+	    static createFrom(source: any = {}) {
+	        return new TypeMetadata(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.schema = source["schema"];
+	        this.typeName = source["typeName"];
+	        this.kind = source["kind"];
+	        this.values = source["values"];
+	        this.base_type = source["base_type"];
+	        this.not_null = source["not_null"];
+	        this.default = source["default"];
+	        this.checks = source["checks"];
+	        this.attributes = this.convertValues(source["attributes"], Column);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+	export class ViewMetadata {
+	    schema?: string;
+	    viewName: string;
+	    materialized: boolean;
+	    columns: Column[];
+	    definition: string;
+	    dependencies: string[];
+	
+	    static createFrom(source: any = {}) {
+	        return new ViewMetadata(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.schema = source["schema"];
+	        this.viewName = source["viewName"];
+	        this.materialized = source["materialized"];
+	        this.columns = this.convertValues(source["columns"], Column);
+	        this.definition = source["definition"];
+	        this.dependencies = source["dependencies"];
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+
+}
+
+
+export namespace profiles {
+	
+	export class Profile {
+	    name: string;
+	    dbType: string;
+	    host: string;
+	    port: string;
+	    database: string;
+	    user: string;
+	    password: string;
+	    schemas: string;
+	    options: registry.Options;
+	
+	    static createFrom(source: any = {}) {
+	        return new Profile(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.dbType = source["dbType"];
+	        this.host = source["host"];
+	        this.port = source["port"];
+	        this.database = source["database"];
+	        this.user = source["user"];
+	        this.password = source["password"];
+	        this.schemas = source["schemas"];
+	        this.options = this.convertValues(source["options"], registry.Options);
+	    }
+
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
 
 }
 
+export namespace registry {
+	
+	export class Field {
+	    name: string;
+	    label: string;
+	    type: string;
+	    options?: string[];
+	    default?: string;
+	    placeholder?: string;
+	    required?: boolean;
+	    advanced?: boolean;
+	
+	    static createFrom(source: any = {}) {
+	        return new Field(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.label = source["label"];
+	        this.type = source["type"];
+	        this.options = source["options"];
+	        this.default = source["default"];
+	        this.placeholder = source["placeholder"];
+	        this.required = source["required"];
+	        this.advanced = source["advanced"];
+	    }
+	}
+	export class Driver {
+	    name: string;
+	    label: string;
+	    fields: Field[];
+	
+	    static createFrom(source: any = {}) {
+	        return new Driver(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.name = source["name"];
+	        this.label = source["label"];
+	        this.fields = this.convertValues(source["fields"], Field);
+	    }
+	
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+
+	export class Options {
+	    dsn: string;
+	    tlsMode: string;
+	    caFile: string;
+	    certFile: string;
+	    keyFile: string;
+	    connectTimeout: number;
+	    applicationName: string;
+	    ssh: sshtunnel.Config;
+	
+	    static createFrom(source: any = {}) {
+	        return new Options(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.dsn = source["dsn"];
+	        this.tlsMode = source["tlsMode"];
+	        this.caFile = source["caFile"];
+	        this.certFile = source["certFile"];
+	        this.keyFile = source["keyFile"];
+	        this.connectTimeout = source["connectTimeout"];
+	        this.applicationName = source["applicationName"];
+	        this.ssh = this.convertValues(source["ssh"], sshtunnel.Config);
+	    }
+
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+
+}
+
+export namespace sshtunnel {
+	
+	export class Hop {
+	    host: string;
+	    port: string;
+	    user: string;
+	    password: string;
+	    keyFile: string;
+	    keyPassphrase: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Hop(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.host = source["host"];
+	        this.port = source["port"];
+	        this.user = source["user"];
+	        this.password = source["password"];
+	        this.keyFile = source["keyFile"];
+	        this.keyPassphrase = source["keyPassphrase"];
+	    }
+	}
+	export class Config {
+	    hops: Hop[];
+	    knownHostsFile: string;
+	
+	    static createFrom(source: any = {}) {
+	        return new Config(source);
+	    }
+	
+	    constructor(source: any = {}) {
+	        if ('string' === typeof source) source = JSON.parse(source);
+	        this.hops = this.convertValues(source["hops"], Hop);
+	        this.knownHostsFile = source["knownHostsFile"];
+	    }
+
+		convertValues(a: any, classs: any, asMap: boolean = false): any {
+		    if (!a) {
+		        return a;
+		    }
+		    if (a.slice) {
+		        return (a as any[]).map(elem => this.convertValues(elem, classs));
+		    } else if ("object" === typeof a) {
+		        if (asMap) {
+		            for (const key of Object.keys(a)) {
+		                a[key] = new classs(a[key]);
+		            }
+		            return a;
+		        }
+		        return new classs(a);
+		    }
+		    return a;
+		}
+	}
+
+}
@@ -0,0 +1,229 @@
+package integrity
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+)
+
+// Config sets the rules up, by rule ID. Rules left out run with their
+// defaults, for instance:
+//
+//	rules:
+//	  nullable-column:
+//	    enabled: false
+//	  cascade-depth:
+//	    severity: error
+//	    thresholds:
+//	      max_depth: 5
+//	  naming-case:
+//	    options:
+//	      columns: camelCase
+//	  missing-primary-key:
+//	    suppress:
+//	      - table: "audit_*"
+//	  unindexed-foreign-key:
+//	    suppress:
+//	      - table: public.orders
+//	        column: created_by
+type Config struct {
+	Rules map[string]*RuleConfig `yaml:"rules"`
+}
+
+// RuleConfig overrides the defaults of a rule.
+type RuleConfig struct {
+	Enabled    *bool             `yaml:"enabled"`
+	Severity   Severity          `yaml:"severity"`
+	Thresholds map[string]int    `yaml:"thresholds"`
+	Options    map[string]string `yaml:"options"`
+	Suppress   []Suppression     `yaml:"suppress"`
+}
+
+// Suppression leaves out the findings on a table, a column, or a column of a
+// table. Both are path.Match patterns, a table without schema matching the
+// table in any schema.
+type Suppression struct {
+	Table  string `yaml:"table"`
+	Column string `yaml:"column"`
+}
+
+// DefaultConfigPath is integrity.yaml in the db_meta directory of the user
+// config.
+func DefaultConfigPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "db_meta", "integrity.yaml"), nil
+}
+
+// LoadConfig reads the configuration file at path. A missing file, or an empty
+// path, is the default configuration.
+func LoadConfig(path string) (*Config, error) {
+	if path == "" {
+		return &Config{}, nil
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	config, err := ParseConfig(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return config, nil
+}
+
+// ParseConfig reads a YAML configuration, rejecting unknown keys.
+func ParseConfig(data []byte) (*Config, error) {
+	config := &Config{}
+	if err := yaml.UnmarshalStrict(data, config); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+func (c *Config) validate(rules []Rule) error {
+	known := make(map[string]Rule, len(rules))
+	for _, rule := range rules {
+		known[rule.ID()] = rule
+	}
+	for id, settings := range c.Rules {
+		rule, ok := known[id]
+		if !ok {
+			return fmt.Errorf("unknown integrity rule %q", id)
+		}
+		if settings == nil {
+			continue
+		}
+		switch settings.Severity {
+		case "", SeverityInfo, SeverityWarning, SeverityError:
+		default:
+			return fmt.Errorf("rule %s: unknown severity %q", id, settings.Severity)
+		}
+		defaults := thresholds(rule)
+		for name := range settings.Thresholds {
+			if _, ok := defaults[name]; !ok {
+				return fmt.Errorf("rule %s: unknown threshold %q", id, name)
+			}
+		}
+		if len(settings.Options) > 0 {
+			defaults := options(rule)
+			for name := range settings.Options {
+				if _, ok := defaults[name]; !ok {
+					return fmt.Errorf("rule %s: unknown option %q", id, name)
+				}
+			}
+			if err := rule.(OptionRule).ValidateOptions(c.info(rule).Options); err != nil {
+				return fmt.Errorf("rule %s: %w", id, err)
+			}
+		}
+		for _, suppression := range settings.Suppress {
+			for _, pattern := range []string{suppression.Table, suppression.Column} {
+				if _, err := path.Match(pattern, ""); err != nil {
+					return fmt.Errorf("rule %s: bad suppression pattern %q", id, pattern)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// info applies the configuration to the defaults of rule.
+func (c *Config) info(rule Rule) *RuleInfo {
+	info := &RuleInfo{ID: rule.ID(), Category: rule.Category(), Severity: rule.Severity(), Enabled: true}
+	if defaults := thresholds(rule); len(defaults) > 0 {
+		info.Thresholds = make(map[string]int, len(defaults))
+		for name, value := range defaults {
+			info.Thresholds[name] = value
+		}
+	}
+	if defaults := options(rule); len(defaults) > 0 {
+		info.Options = make(map[string]string, len(defaults))
+		for name, value := range defaults {
+			info.Options[name] = value
+		}
+	}
+
+	settings := c.Rules[rule.ID()]
+	if settings == nil {
+		return info
+	}
+	if settings.Enabled != nil {
+		info.Enabled = *settings.Enabled
+	}
+	if settings.Severity != "" {
+		info.Severity = settings.Severity
+	}
+	for name, value := range settings.Thresholds {
+		info.Thresholds[name] = value
+	}
+	for name, value := range settings.Options {
+		info.Options[name] = value
+	}
+	return info
+}
+
+func thresholds(rule Rule) map[string]int {
+	if rule, ok := rule.(ThresholdRule); ok {
+		return rule.Thresholds()
+	}
+	return nil
+}
+
+func options(rule Rule) map[string]string {
+	if rule, ok := rule.(OptionRule); ok {
+		return rule.Options()
+	}
+	return nil
+}
+
+// suppressed tells whether a suppression of rule id covers finding. A finding
+// without table, on a group of tables, is covered by a suppression of any of
+// them.
+func (c *Config) suppressed(id string, finding *Finding) bool {
+	settings := c.Rules[id]
+	if settings == nil {
+		return false
+	}
+	tables := finding.Tables
+	if finding.TableName != "" {
+		tables = []string{finding.TableName}
+	}
+	for _, suppression := range settings.Suppress {
+		if suppression.Table != "" && !matchAny(suppression.Table, tables, true) {
+			continue
+		}
+		if suppression.Column != "" && !matchAny(suppression.Column, finding.Columns, false) {
+			continue
+		}
+		if suppression.Table != "" || suppression.Column != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// matchAny tells whether pattern matches one of names. Table patterns without
+// schema match the table name of qualified names.
+func matchAny(pattern string, names []string, table bool) bool {
+	for _, name := range names {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+		if dot := strings.LastIndex(name, "."); table && dot >= 0 && !strings.Contains(pattern, ".") {
+			if ok, _ := path.Match(pattern, name[dot+1:]); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,373 @@
+package integrity
+
+import (
+	"db_meta/dbstructs"
+	"db_meta/schemadiff"
+	"db_meta/sqlgen"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Fix is the remediation suggested for a finding: the statements to run, if
+// any, and what to look at before running them.
+type Fix struct {
+	Statements []string `json:"statements,omitempty"`
+	Note       string   `json:"note,omitempty"`
+	Warnings   []string `json:"warnings,omitempty"` // of the SQL writer
+	Rebuilds   string   `json:"rebuilds,omitempty"` // SQLite table rebuilt by the statements
+	Rename     string   `json:"rename,omitempty"`   // suggested name
+	Renames    string   `json:"renames,omitempty"`  // table renamed by the statements
+}
+
+// FixScript gathers the fixes of findings.
+type FixScript struct {
+	Dialect    string   `json:"dialect"`
+	Statements []string `json:"statements"`
+	Notes      []string `json:"notes"` // by finding ID
+	// Conflicts are the tables rebuilt, or renamed, by several fixes, each fix
+	// starting from the table as loaded: they must be applied one at a time.
+	Conflicts []string `json:"conflicts,omitempty"`
+}
+
+// SQL returns the statements as a script.
+func (s *FixScript) SQL() string {
+	return sqlgen.Script(s.Dialect, s.Statements)
+}
+
+// Fixes gathers the fixes of the findings of ids, all of them when ids is
+// empty. A fix suggested by several findings is kept once, the tables are
+// renamed last as the other statements use their current names.
+func (r *Report) Fixes(ids []string) (*FixScript, error) {
+	byID := make(map[string]*Finding, len(r.Findings))
+	for _, finding := range r.Findings {
+		byID[finding.ID] = finding
+	}
+	selected := r.Findings
+	if len(ids) > 0 {
+		selected = nil
+		for _, id := range ids {
+			finding, ok := byID[id]
+			if !ok {
+				return nil, fmt.Errorf("unknown finding %q", id)
+			}
+			selected = append(selected, finding)
+		}
+	}
+
+	script := &FixScript{Dialect: r.Dialect}
+	seen := make(map[string]bool)
+	rebuilt := make(map[string]int)
+	renamed := make(map[string]int)
+	var renames []string
+	for _, finding := range selected {
+		if finding.Fix == nil {
+			continue
+		}
+		if table := finding.Fix.Rebuilds; table != "" {
+			if rebuilt[table]++; rebuilt[table] == 2 {
+				script.Conflicts = append(script.Conflicts, table)
+			}
+		}
+		if key := strings.Join(finding.Fix.Statements, ";\n"); !seen[key] {
+			seen[key] = true
+			if table := finding.Fix.Renames; table != "" {
+				if renamed[table]++; renamed[table] == 2 {
+					script.Conflicts = append(script.Conflicts, table)
+				}
+				renames = append(renames, finding.Fix.Statements...)
+			} else {
+				script.Statements = append(script.Statements, finding.Fix.Statements...)
+			}
+		}
+		if finding.Fix.Note != "" {
+			script.Notes = append(script.Notes, finding.ID+": "+finding.Fix.Note)
+		}
+		for _, warning := range finding.Fix.Warnings {
+			script.Notes = append(script.Notes, finding.ID+": "+warning)
+		}
+	}
+	script.Statements = append(script.Statements, renames...)
+	return script, nil
+}
+
+// Transactional rewrites statements to run in a transaction: PostgreSQL only
+// builds and drops indexes CONCURRENTLY outside of one.
+func Transactional(statements []string) []string {
+	rewritten := make([]string, len(statements))
+	for i, statement := range statements {
+		rewritten[i] = strings.Replace(statement, " INDEX CONCURRENTLY ", " INDEX ", 1)
+	}
+	return rewritten
+}
+
+// errDryRun rolls the transaction of a dry run back
+var errDryRun = errors.New("dry run")
+
+// Apply runs statements of dialect on db in a transaction, rolled back after
+// the last statement when dryRun is set. MySQL commits each DDL statement, its
+// fixes can't be tried. SQLite runs them with its foreign keys off, as tables
+// are rebuilt, and checks them before committing.
+func Apply(db *gorm.DB, dialect string, statements []string, dryRun bool) error {
+	if dialect == sqlgen.MySQL && dryRun {
+		return errors.New("MySQL commits each DDL statement, the fixes can't be tried in a transaction")
+	}
+
+	return db.Connection(func(conn *gorm.DB) error {
+		if dialect == sqlgen.SQLite {
+			var foreignKeys int
+			if err := conn.Raw("PRAGMA foreign_keys").Scan(&foreignKeys).Error; err != nil {
+				return err
+			}
+			if foreignKeys == 1 {
+				if err := conn.Exec("PRAGMA foreign_keys = OFF").Error; err != nil {
+					return err
+				}
+				defer conn.Exec("PRAGMA foreign_keys = ON")
+			}
+		}
+
+		err := conn.Transaction(func(tx *gorm.DB) error {
+			for _, statement := range Transactional(statements) {
+				if err := tx.Exec(statement).Error; err != nil {
+					return fmt.Errorf("%s: %w", statement, err)
+				}
+			}
+			if dialect == sqlgen.SQLite {
+				var violations []map[string]interface{}
+				if err := tx.Raw("PRAGMA foreign_key_check").Scan(&violations).Error; err != nil {
+					return err
+				}
+				if len(violations) > 0 {
+					return fmt.Errorf("%d rows break a foreign key after the fixes", len(violations))
+				}
+			}
+			if dryRun {
+				return errDryRun
+			}
+			return nil
+		})
+		if errors.Is(err, errDryRun) {
+			return nil
+		}
+		return err
+	})
+}
+
+// Fixes of the built-in rules
+
+// fixPrimaryKey adds a primary key on the id column, or on the NOT NULL columns
+// of a unique index.
+func fixPrimaryKey(w *sqlgen.Writer, schema *Schema, finding *Finding) *Fix {
+	table := schema.Table(finding.TableName)
+	columns := candidateKey(table)
+	if columns == nil {
+		return &Fix{Note: "No candidate key, add an identity column as primary key"}
+	}
+
+	target := copyTable(table)
+	target.PrimaryKey = columns
+	fix := &Fix{Note: fmt.Sprintf("The primary key is set on (%s), check that it identifies the rows", strings.Join(columns, ", "))}
+	if w.Dialect() == sqlgen.SQLite {
+		fix.Statements, fix.Rebuilds = w.RebuildTable(table, target), table.QualifiedName()
+		return fix
+	}
+	for _, column := range target.Columns {
+		if contains(columns, column.ColumnName) && !column.NotNull {
+			fix.Statements = append(fix.Statements, setNotNull(w, table, column)...)
+		}
+	}
+	fix.Statements = append(fix.Statements, w.AddPrimaryKey(target))
+	return fix
+}
+
+// candidateKey returns the id column, or the columns of a unique index which
+// are all NOT NULL.
+func candidateKey(table *dbstructs.TableMetadata) []string {
+	if findColumnByName(table, "id") != nil {
+		return []string{"id"}
+	}
+	for _, index := range table.Indexes {
+		if !index.Unique || index.Predicate != "" || len(index.Columns) == 0 || len(index.Keys) > 0 && len(index.Keys) != len(index.Columns) {
+			continue
+		}
+		notNull := true
+		for _, columnName := range index.Columns {
+			if column := findColumnByName(table, columnName); column == nil || !column.NotNull {
+				notNull = false
+			}
+		}
+		if notNull {
+			return index.Columns
+		}
+	}
+	return nil
+}
+
+func fixNullableColumn(w *sqlgen.Writer, schema *Schema, finding *Finding) *Fix {
+	table := schema.Table(finding.TableName)
+	column := findColumnByName(table, finding.Columns[0])
+	fix := &Fix{
+		Statements: setNotNull(w, table, column),
+		Note:       fmt.Sprintf("Fails while %s holds NULL values, fill them first", column.ColumnName),
+	}
+	if w.Dialect() == sqlgen.SQLite {
+		fix.Rebuilds = table.QualifiedName()
+	}
+	return fix
+}
+
+// setNotNull writes the statements adding NOT NULL to column, SQLite rebuilding
+// the table.
+func setNotNull(w *sqlgen.Writer, table *dbstructs.TableMetadata, column *dbstructs.Column) []string {
+	target := copyTable(table)
+	for i, targetColumn := range target.Columns {
+		if targetColumn.ColumnName == column.ColumnName {
+			notNull := *targetColumn
+			notNull.NotNull = true
+			target.Columns[i] = &notNull
+			if w.Dialect() != sqlgen.SQLite {
+				return w.AlterColumn(table, column, &notNull, []string{schemadiff.ChangeNullability})
+			}
+		}
+	}
+	return w.RebuildTable(table, target)
+}
+
+func fixUniqueIndex(w *sqlgen.Writer, schema *Schema, finding *Finding) *Fix {
+	table := schema.Table(finding.TableName)
+	index := &dbstructs.Index{Name: indexName(table, finding.Columns, "key"), Columns: finding.Columns, Unique: true}
+	return &Fix{
+		Statements: []string{createIndex(w, table, index)},
+		Note:       "Fails while the column holds duplicate values",
+	}
+}
+
+func fixForeignKeyTable(w *sqlgen.Writer, schema *Schema, finding *Finding) *Fix {
+	note := fmt.Sprintf("Load the schema of %s if it exists, otherwise drop the foreign key", finding.RelatedTable)
+	table := schema.Table(finding.TableName)
+	relationship := findRelationship(table, finding.ConstraintName)
+	if relationship == nil || relationship.Conname == "" {
+		return &Fix{Note: note}
+	}
+	if w.Dialect() == sqlgen.SQLite {
+		target := copyTable(table)
+		target.Relationships = nil
+		for _, other := range table.Relationships {
+			if other != relationship {
+				target.Relationships = append(target.Relationships, other)
+			}
+		}
+		return &Fix{Statements: w.RebuildTable(table, target), Note: note, Rebuilds: table.QualifiedName()}
+	}
+	return &Fix{Statements: []string{w.DropForeignKey(table, relationship)}, Note: note}
+}
+
+func fixForeignKeyIndex(w *sqlgen.Writer, schema *Schema, finding *Finding) *Fix {
+	if len(finding.Columns) == 0 {
+		return &Fix{Note: "The connector did not report the columns of the foreign key"}
+	}
+	table := schema.Table(finding.TableName)
+	index := &dbstructs.Index{Name: indexName(table, finding.Columns, "idx"), Columns: finding.Columns}
+	return &Fix{Statements: []string{createIndex(w, table, index)}}
+}
+
+func fixRedundantIndex(w *sqlgen.Writer, schema *Schema, finding *Finding) *Fix {
+	table := schema.Table(finding.TableName)
+	for _, index := range table.Indexes {
+		if index.Name != finding.IndexName {
+			continue
+		}
+		if index.Unique {
+			return &Fix{Statements: w.DropIndex(table, index), Note: "The index is unique, its constraint is dropped with it"}
+		}
+		statements := w.DropIndex(table, index)
+		if w.Dialect() == sqlgen.PostgreSQL && table.Partitioning == nil {
+			statements[0] = strings.Replace(statements[0], "DROP INDEX ", "DROP INDEX CONCURRENTLY ", 1)
+		}
+		return &Fix{Statements: statements}
+	}
+	return nil
+}
+
+func fixCircularRelations(w *sqlgen.Writer, schema *Schema, finding *Finding) *Fix {
+	return &Fix{Note: "Rows of these tables can only be inserted with a nullable or deferrable foreign key, check that one of them is"}
+}
+
+// fixSetNullAction turns the SET NULL actions of the foreign key into NO ACTION
+func fixSetNullAction(w *sqlgen.Writer, schema *Schema, finding *Finding) *Fix {
+	table := schema.Table(finding.TableName)
+	relationship := findRelationship(table, finding.ConstraintName)
+	if relationship == nil || relationship.Conname == "" {
+		return &Fix{Note: "Make the column nullable or change the action of the foreign key"}
+	}
+
+	changed := *relationship
+	for _, action := range []*string{&changed.OnDelete, &changed.OnUpdate} {
+		if *action == dbstructs.ReferentialActionSetNull {
+			*action = dbstructs.ReferentialActionNoAction
+		}
+	}
+	fix := &Fix{Note: fmt.Sprintf("The SET NULL action becomes NO ACTION, make %s nullable instead if its rows outlive the referenced ones", strings.Join(finding.Columns, ", "))}
+	if w.Dialect() == sqlgen.SQLite {
+		target := copyTable(table)
+		for i, other := range target.Relationships {
+			if other == relationship {
+				target.Relationships[i] = &changed
+			}
+		}
+		fix.Statements, fix.Rebuilds = w.RebuildTable(table, target), table.QualifiedName()
+		return fix
+	}
+	fix.Statements = []string{w.DropForeignKey(table, relationship), w.AddForeignKey(table, &changed)}
+	return fix
+}
+
+func fixCascadeDepth(w *sqlgen.Writer, schema *Schema, finding *Finding) *Fix {
+	return &Fix{Note: "A delete on this table removes rows down the whole chain, replace a CASCADE action by RESTRICT to stop it"}
+}
+
+// Toolbox functions
+
+// createIndex writes the CREATE INDEX of a fix, CONCURRENTLY on PostgreSQL,
+// which partitioned tables don't support.
+func createIndex(w *sqlgen.Writer, table *dbstructs.TableMetadata, index *dbstructs.Index) string {
+	statement := w.CreateIndex(table, index)
+	if w.Dialect() == sqlgen.PostgreSQL && table.Partitioning == nil {
+		statement = strings.Replace(statement, "INDEX ", "INDEX CONCURRENTLY ", 1)
+	}
+	return statement
+}
+
+// indexName names an index as PostgreSQL does: <table>_<columns>_<suffix>.
+func indexName(table *dbstructs.TableMetadata, columns []string, suffix string) string {
+	return table.TableName + "_" + strings.Join(columns, "_") + "_" + suffix
+}
+
+func findRelationship(table *dbstructs.TableMetadata, name string) *dbstructs.RelationshipMetadata {
+	for _, relationship := range table.Relationships {
+		if relationship.Conname == name {
+			return relationship
+		}
+	}
+	return nil
+}
+
+// copyTable copies table and its lists, to be changed into the target of a fix.
+func copyTable(table *dbstructs.TableMetadata) *dbstructs.TableMetadata {
+	target := *table
+	target.Columns = append([]*dbstructs.Column{}, table.Columns...)
+	target.Relationships = append([]*dbstructs.RelationshipMetadata{}, table.Relationships...)
+	return &target
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,128 @@
+package integrity
+
+import (
+	"db_meta/dbstructs"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+// shopTables are the tables of shopDDL, as the connectors report them
+func shopTables(schema string) []*dbstructs.TableMetadata {
+	return []*dbstructs.TableMetadata{
+		{
+			Schema: schema, TableName: "customers",
+			Columns: []*dbstructs.Column{{ColumnName: "id", DataType: "bigint", NotNull: true, Unique: true}, {ColumnName: "email", DataType: "text", NotNull: true, Unique: true}},
+		},
+		{
+			Schema: schema, TableName: "orders", PrimaryKey: []string{"id"},
+			Columns: []*dbstructs.Column{{ColumnName: "id", DataType: "bigint", NotNull: true}, {ColumnName: "customer_id", DataType: "bigint", NotNull: true}, {ColumnName: "note", DataType: "text"}},
+			Indexes: []*dbstructs.Index{
+				{Name: "orders_pkey", Columns: []string{"id"}, Unique: true, Primary: true},
+				{Name: "orders_note_idx", Columns: []string{"note"}},
+				{Name: "orders_note_id_idx", Columns: []string{"note", "id"}},
+			},
+			Relationships: []*dbstructs.RelationshipMetadata{{
+				Conname: "orders_customer_id_fkey", SourceSchema: schema, SourceTableName: "orders", RelatedSchema: schema, RelatedTableName: "customers",
+				SourceColumns: []string{"customer_id"}, TargetColumns: []string{"id"}, OnDelete: dbstructs.ReferentialActionSetNull,
+			}},
+		},
+	}
+}
+
+var shopDDL = []string{
+	"CREATE TABLE customers (id bigint NOT NULL UNIQUE, email text NOT NULL)",
+	"CREATE TABLE orders (id bigint NOT NULL PRIMARY KEY, customer_id bigint NOT NULL REFERENCES customers (id) ON DELETE SET NULL, note text)",
+	"CREATE INDEX orders_note_idx ON orders (note)",
+	"CREATE INDEX orders_note_id_idx ON orders (note, id)",
+}
+
+func TestReport_Fixes(t *testing.T) {
+	fixes := func(dialect, id string) []string {
+		report, err := Run(Rules(), &Schema{Tables: shopTables("public"), Dialect: dialect}, nil)
+		assert.NoError(t, err)
+		script, err := report.Fixes([]string{id})
+		assert.NoError(t, err)
+		return script.Statements
+	}
+
+	// indexes are built CONCURRENTLY on PostgreSQL
+	foreignKey := "unindexed-foreign-key:public.orders:customer_id:orders_customer_id_fkey"
+	assert.Equal(t, []string{`CREATE INDEX CONCURRENTLY "orders_customer_id_idx" ON "public"."orders" ("customer_id")`}, fixes("postgres", foreignKey))
+	assert.Equal(t, []string{"CREATE INDEX `orders_customer_id_idx` ON `orders` (`customer_id`)"}, fixes("mysql", foreignKey))
+	assert.Equal(t, []string{`CREATE INDEX [orders_customer_id_idx] ON [public].[orders] ([customer_id])`}, fixes("sqlserver", foreignKey))
+	assert.Equal(t, []string{`CREATE INDEX "orders_customer_id_idx" ON "orders" ("customer_id")`}, fixes("sqlite", foreignKey))
+	assert.Equal(t, []string{`CREATE INDEX "orders_customer_id_idx" ON "public"."orders" ("customer_id")`}, Transactional(fixes("postgres", foreignKey)))
+
+	assert.Equal(t, []string{`ALTER TABLE "public"."customers" ADD CONSTRAINT "customers_pkey" PRIMARY KEY ("id")`}, fixes("postgres", "missing-primary-key:public.customers"))
+	assert.Equal(t, []string{"ALTER TABLE `orders` MODIFY COLUMN `note` text NOT NULL"}, fixes("mysql", "nullable-column:public.orders:note"))
+	assert.Equal(t, []string{
+		`ALTER TABLE [public].[orders] DROP CONSTRAINT [orders_customer_id_fkey]`,
+		`ALTER TABLE [public].[orders] ADD CONSTRAINT [orders_customer_id_fkey] FOREIGN KEY ([customer_id]) REFERENCES [public].[customers] ([id])`,
+	}, fixes("sqlserver", "set-null-on-not-null:public.orders:customer_id:orders_customer_id_fkey"))
+
+	// a whole script, notes by finding
+	report, err := Run(Rules(), &Schema{Tables: shopTables(""), Dialect: "sqlite"}, nil)
+	assert.NoError(t, err)
+	script, err := report.Fixes(nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "sqlite", script.Dialect)
+	assert.Contains(t, script.Notes, "missing-primary-key:customers: The primary key is set on (id), check that it identifies the rows")
+	assert.Equal(t, []string{"orders"}, script.Conflicts)
+	assert.Equal(t, 2, strings.Count(script.SQL(), `ALTER TABLE "_new_orders" RENAME TO "orders";`))
+	assert.Contains(t, script.SQL(), `DROP INDEX "orders_note_idx";`)
+
+	_, err = report.Fixes([]string{"nullable-column:orders:status"})
+	assert.EqualError(t, err, `unknown finding "nullable-column:orders:status"`)
+
+	// no fixes without dialect
+	report, err = Run(Rules(), &Schema{Tables: shopTables("")}, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, report.Dialect)
+	for _, finding := range report.Findings {
+		assert.Nil(t, finding.Fix)
+	}
+}
+
+func TestApply(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "shop.db")), &gorm.Config{})
+	assert.NoError(t, err)
+	for _, statement := range append(shopDDL, "PRAGMA foreign_keys = ON", "INSERT INTO customers VALUES (1, 'ada@example.com')", "INSERT INTO orders VALUES (1, 1, 'gift')") {
+		assert.NoError(t, db.Exec(statement).Error)
+	}
+	report, err := Run(Rules(), &Schema{Tables: shopTables(""), Dialect: "sqlite"}, nil)
+	assert.NoError(t, err)
+	script, err := report.Fixes([]string{"nullable-column:orders:note", "unindexed-foreign-key:orders:customer_id:orders_customer_id_fkey", "missing-primary-key:customers"})
+	assert.NoError(t, err)
+	assert.Empty(t, script.Conflicts)
+
+	indexes := func() []string {
+		var names []string
+		assert.NoError(t, db.Raw("SELECT name FROM sqlite_master WHERE type = 'index' AND tbl_name = 'orders' AND sql IS NOT NULL ORDER BY name").Scan(&names).Error)
+		return names
+	}
+
+	// a dry run leaves the database as it was
+	assert.NoError(t, Apply(db, "sqlite", script.Statements, true))
+	assert.Equal(t, []string{"orders_note_id_idx", "orders_note_idx"}, indexes())
+	assert.NoError(t, db.Exec("INSERT INTO orders VALUES (2, 1, NULL)").Error)
+
+	// the NOT NULL fix fails on the NULL note, nothing is applied
+	err = Apply(db, "sqlite", script.Statements, false)
+	assert.ErrorContains(t, err, "NOT NULL constraint failed")
+	assert.Equal(t, []string{"orders_note_id_idx", "orders_note_idx"}, indexes())
+
+	assert.NoError(t, db.Exec("UPDATE orders SET note = '' WHERE note IS NULL").Error)
+	assert.NoError(t, Apply(db, "sqlite", script.Statements, false))
+	assert.Equal(t, []string{"orders_customer_id_idx", "orders_note_id_idx", "orders_note_idx"}, indexes())
+	assert.Error(t, db.Exec("INSERT INTO orders VALUES (3, 1, NULL)").Error)
+	var count int
+	assert.NoError(t, db.Raw("SELECT count(*) FROM orders").Scan(&count).Error)
+	assert.Equal(t, 2, count)
+
+	assert.EqualError(t, Apply(db, "mysql", script.Statements, true), "MySQL commits each DDL statement, the fixes can't be tried in a transaction")
+}
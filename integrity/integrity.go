@@ -0,0 +1,294 @@
+// Package integrity checks a schema against a set of rules. The built-in rules
+// register themselves from this package, other packages add theirs with
+// Register from their init function:
+//
+//	func init() { integrity.Register(integrity.NewRule("audit-columns", "columns", integrity.SeverityInfo, checkAuditColumns)) }
+package integrity
+
+import (
+	"db_meta/dbstructs"
+	"db_meta/sqlgen"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Severity is how serious a finding is.
+type Severity string
+
+const (
+	SeverityInfo    Severity = "info"
+	SeverityWarning Severity = "warning"
+	SeverityError   Severity = "error"
+)
+
+// Categories of the built-in rules
+const (
+	CategoryKeys      = "keys"
+	CategoryColumns   = "columns"
+	CategoryIndexes   = "indexes"
+	CategoryRelations = "relations"
+	CategoryNaming    = "naming"
+)
+
+// Finding is an issue reported by a rule. Rules fill in what they found, Run
+// sets the ID, rule, category, severity and fix.
+type Finding struct {
+	ID             string   `json:"id"` // stable across runs of a same schema
+	RuleID         string   `json:"ruleId"`
+	Category       string   `json:"category"`
+	Severity       Severity `json:"severity"`
+	TableName      string   `json:"tableName,omitempty"` // qualified name
+	Columns        []string `json:"columns,omitempty"`
+	IndexName      string   `json:"indexName,omitempty"`
+	ConstraintName string   `json:"constraintName,omitempty"`
+	RelatedTable   string   `json:"relatedTableName,omitempty"`
+	Tables         []string `json:"tables,omitempty"` // a cascade path or a group of tables
+	Message        string   `json:"message"`
+	Fix            *Fix     `json:"fix,omitempty"`
+}
+
+// Rule is a check of the schema. Its ID is stable, configurations and
+// suppressions refer to it. Severity is the default severity of its findings.
+type Rule interface {
+	ID() string
+	Category() string
+	Severity() Severity
+	Check(schema *Schema) []*Finding
+}
+
+// ThresholdRule is a rule taking thresholds, by name with their default value.
+// Check reads them with Schema.Threshold.
+type ThresholdRule interface {
+	Rule
+	Thresholds() map[string]int
+}
+
+// OptionRule is a rule taking string options, by name with their default
+// value. ValidateOptions checks the configured values, Check reads them with
+// Schema.Option.
+type OptionRule interface {
+	Rule
+	Options() map[string]string
+	ValidateOptions(options map[string]string) error
+}
+
+// FixRule is a rule suggesting how to fix its findings, with statements written
+// by w in the dialect of the schema.
+type FixRule interface {
+	Rule
+	Fix(w *sqlgen.Writer, schema *Schema, finding *Finding) *Fix
+}
+
+// NewRule returns a rule running check.
+func NewRule(id, category string, severity Severity, check func(schema *Schema) []*Finding) Rule {
+	return &rule{id: id, category: category, severity: severity, check: check}
+}
+
+type rule struct {
+	id         string
+	category   string
+	severity   Severity
+	thresholds map[string]int
+	options    map[string]string
+	validate   func(options map[string]string) error
+	check      func(schema *Schema) []*Finding
+	fix        func(w *sqlgen.Writer, schema *Schema, finding *Finding) *Fix
+}
+
+func (r *rule) ID() string                      { return r.id }
+func (r *rule) Category() string                { return r.category }
+func (r *rule) Severity() Severity              { return r.severity }
+func (r *rule) Check(schema *Schema) []*Finding { return r.check(schema) }
+func (r *rule) Thresholds() map[string]int      { return r.thresholds }
+func (r *rule) Options() map[string]string      { return r.options }
+
+func (r *rule) ValidateOptions(options map[string]string) error {
+	if r.validate == nil {
+		return nil
+	}
+	return r.validate(options)
+}
+
+func (r *rule) Fix(w *sqlgen.Writer, schema *Schema, finding *Finding) *Fix {
+	if r.fix == nil {
+		return nil
+	}
+	return r.fix(w, schema, finding)
+}
+
+var (
+	mu    sync.RWMutex
+	rules = make(map[string]Rule)
+)
+
+// Register makes a rule available to Rules. It panics if the ID is empty or
+// already registered.
+func Register(rule Rule) {
+	mu.Lock()
+	defer mu.Unlock()
+	if rule.ID() == "" {
+		panic("integrity: Register needs a rule ID")
+	}
+	if _, dup := rules[rule.ID()]; dup {
+		panic(fmt.Sprintf("integrity: Register called twice for rule %q", rule.ID()))
+	}
+	rules[rule.ID()] = rule
+}
+
+// Lookup returns the rule registered under id.
+func Lookup(id string) (Rule, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	rule, ok := rules[id]
+	return rule, ok
+}
+
+// Rules returns the registered rules, sorted by ID.
+func Rules() []Rule {
+	mu.RLock()
+	defer mu.RUnlock()
+	list := make([]Rule, 0, len(rules))
+	for _, rule := range rules {
+		list = append(list, rule)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].ID() < list[j].ID() })
+	return list
+}
+
+// Schema is what the rules check: the tables and their graph. The fixes are
+// written in Dialect, the registry name of the connector, when sqlgen knows it.
+type Schema struct {
+	Tables  []*dbstructs.TableMetadata
+	Graph   *dbstructs.GraphResponse
+	Dialect string
+
+	thresholds map[string]int // of the running rule
+	options    map[string]string
+}
+
+// VerifiedTables leaves partitions out, they share the definition of their
+// parent which is verified instead.
+func (s *Schema) VerifiedTables() []*dbstructs.TableMetadata {
+	var tables []*dbstructs.TableMetadata
+	for _, table := range s.Tables {
+		if table.PartitionOf == "" {
+			tables = append(tables, table)
+		}
+	}
+	return tables
+}
+
+// Table looks a table up by its qualified name.
+func (s *Schema) Table(name string) *dbstructs.TableMetadata {
+	for _, table := range s.Tables {
+		if table.QualifiedName() == name {
+			return table
+		}
+	}
+	return nil
+}
+
+// Threshold returns the configured value of a threshold of the running rule.
+func (s *Schema) Threshold(name string) int {
+	return s.thresholds[name]
+}
+
+// Option returns the configured value of an option of the running rule.
+func (s *Schema) Option(name string) string {
+	return s.options[name]
+}
+
+// RuleInfo is a rule as configured for a run.
+type RuleInfo struct {
+	ID         string            `json:"id"`
+	Category   string            `json:"category"`
+	Severity   Severity          `json:"severity"`
+	Enabled    bool              `json:"enabled"`
+	Thresholds map[string]int    `json:"thresholds,omitempty"`
+	Options    map[string]string `json:"options,omitempty"`
+}
+
+// Report lists the rules of a run and their findings, in rule order.
+type Report struct {
+	Dialect    string      `json:"dialect,omitempty"` // of the fixes
+	Rules      []*RuleInfo `json:"rules"`
+	Findings   []*Finding  `json:"findings"`
+	Suppressed int         `json:"suppressed"` // findings left out by the configuration
+}
+
+// Run checks schema with the enabled rules, concurrently, as configured by
+// config which may be nil. It fails on a configuration naming an unknown rule
+// or threshold.
+func Run(rules []Rule, schema *Schema, config *Config) (*Report, error) {
+	if config == nil {
+		config = &Config{}
+	}
+	if err := config.validate(rules); err != nil {
+		return nil, err
+	}
+
+	report := &Report{}
+	if _, err := sqlgen.NewWriter(schema.Dialect); err == nil {
+		report.Dialect = schema.Dialect
+	}
+	results := make([][]*Finding, len(rules))
+	views := make([]*Schema, len(rules))
+	var wg sync.WaitGroup
+	for i, rule := range rules {
+		info := config.info(rule)
+		report.Rules = append(report.Rules, info)
+		if !info.Enabled {
+			continue
+		}
+
+		view := *schema
+		view.thresholds, view.options = info.Thresholds, info.Options
+		views[i] = &view
+		wg.Add(1)
+		go func(i int, rule Rule, view *Schema) {
+			defer wg.Done()
+			results[i] = rule.Check(view)
+		}(i, rule, &view)
+	}
+	wg.Wait()
+
+	ids := make(map[string]int)
+	for i, findings := range results {
+		info := report.Rules[i]
+		for _, finding := range findings {
+			if config.suppressed(info.ID, finding) {
+				report.Suppressed++
+				continue
+			}
+			finding.RuleID, finding.Category, finding.Severity = info.ID, info.Category, info.Severity
+			finding.ID = findingID(finding)
+			if ids[finding.ID]++; ids[finding.ID] > 1 {
+				finding.ID += fmt.Sprintf("#%d", ids[finding.ID])
+			}
+			if rule, ok := rules[i].(FixRule); ok && report.Dialect != "" {
+				w, _ := sqlgen.NewWriter(report.Dialect)
+				if finding.Fix = rule.Fix(w, views[i], finding); finding.Fix != nil {
+					finding.Fix.Warnings = w.Warnings()
+				}
+			}
+			report.Findings = append(report.Findings, finding)
+		}
+	}
+	return report, nil
+}
+
+// findingID names a finding after its rule and what it is about.
+func findingID(finding *Finding) string {
+	parts := []string{finding.RuleID, finding.TableName}
+	if finding.TableName == "" {
+		parts[1] = strings.Join(finding.Tables, ",")
+	}
+	for _, part := range []string{strings.Join(finding.Columns, ","), finding.IndexName, finding.ConstraintName} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ":")
+}
@@ -0,0 +1,117 @@
+package integrity
+
+import (
+	"db_meta/dbstructs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// auditColumns is a rule of a team, outside the registry
+var auditColumns = NewRule("audit-columns", CategoryColumns, SeverityInfo, func(schema *Schema) []*Finding {
+	var findings []*Finding
+	for _, table := range schema.VerifiedTables() {
+		for _, column := range table.Columns {
+			if column.ColumnName == "created_by" {
+				findings = append(findings, &Finding{TableName: table.QualifiedName(), Columns: []string{column.ColumnName}, Message: "Audit column"})
+			}
+		}
+	}
+	return findings
+})
+
+func TestRules_registry(t *testing.T) {
+	rules := Rules()
+	assert.Len(t, rules, 14)
+	for i := 1; i < len(rules); i++ {
+		assert.Less(t, rules[i-1].ID(), rules[i].ID())
+	}
+
+	rule, ok := Lookup(RuleCascadeDepth)
+	assert.True(t, ok)
+	assert.Equal(t, map[string]int{"max_depth": 3}, rule.(ThresholdRule).Thresholds())
+	_, ok = Lookup("audit-columns")
+	assert.False(t, ok)
+
+	assert.Panics(t, func() { Register(NewRule(RuleCascadeDepth, CategoryRelations, SeverityInfo, nil)) })
+}
+
+func TestRun_config(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "integrity.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(`
+rules:
+  nullable-column:
+    enabled: false
+  missing-primary-key:
+    severity: warning
+    suppress:
+      - table: "audit_*"
+  cascade-depth:
+    thresholds:
+      max_depth: 0
+  audit-columns:
+    suppress:
+      - table: orders
+        column: created_by
+`), 0o600))
+	config, err := LoadConfig(path)
+	assert.NoError(t, err)
+
+	schema := &Schema{Tables: []*dbstructs.TableMetadata{
+		{Schema: "sales", TableName: "orders", Columns: []*dbstructs.Column{{ColumnName: "created_by"}}},
+		{Schema: "sales", TableName: "invoices", Columns: []*dbstructs.Column{{ColumnName: "created_by"}}},
+		{Schema: "sales", TableName: "audit_log"},
+	}}
+	rules := []Rule{auditColumns}
+	for _, id := range []string{RuleMissingPrimaryKey, RuleNullableColumn, RuleCascadeDepth} {
+		rule, _ := Lookup(id)
+		rules = append(rules, rule)
+	}
+	report, err := Run(rules, schema, config)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []*RuleInfo{
+		{ID: "audit-columns", Category: CategoryColumns, Severity: SeverityInfo, Enabled: true},
+		{ID: RuleMissingPrimaryKey, Category: CategoryKeys, Severity: SeverityWarning, Enabled: true},
+		{ID: RuleNullableColumn, Category: CategoryColumns, Severity: SeverityInfo, Enabled: false},
+		{ID: RuleCascadeDepth, Category: CategoryRelations, Severity: SeverityWarning, Enabled: true, Thresholds: map[string]int{"max_depth": 0}},
+	}, report.Rules)
+
+	var found []string
+	for _, finding := range report.Findings {
+		found = append(found, finding.RuleID+" "+finding.TableName+" "+string(finding.Severity))
+	}
+	assert.Equal(t, []string{
+		"audit-columns sales.invoices info",
+		"missing-primary-key sales.orders warning",
+		"missing-primary-key sales.invoices warning",
+	}, found)
+	assert.Equal(t, 2, report.Suppressed)
+
+	// the defaults of a missing file
+	config, err = LoadConfig(filepath.Join(t.TempDir(), "missing.yaml"))
+	assert.NoError(t, err)
+	report, err = Run(rules, schema, config)
+	assert.NoError(t, err)
+	assert.Len(t, report.Findings, 7)
+	assert.Zero(t, report.Suppressed)
+}
+
+func TestRun_badConfig(t *testing.T) {
+	for config, message := range map[string]string{
+		"rules:\n  unknown-rule:\n    enabled: false\n":                         `unknown integrity rule "unknown-rule"`,
+		"rules:\n  missing-primary-key:\n    severity: fatal\n":                 `rule missing-primary-key: unknown severity "fatal"`,
+		"rules:\n  cascade-depth:\n    thresholds:\n      depth: 2\n":           `rule cascade-depth: unknown threshold "depth"`,
+		"rules:\n  missing-primary-key:\n    suppress:\n      - table: \"[\"\n": `rule missing-primary-key: bad suppression pattern "["`,
+	} {
+		parsed, err := ParseConfig([]byte(config))
+		assert.NoError(t, err)
+		_, err = Run(Rules(), &Schema{}, parsed)
+		assert.EqualError(t, err, message)
+	}
+
+	_, err := ParseConfig([]byte("rules:\n  missing-primary-key:\n    enable: false\n"))
+	assert.ErrorContains(t, err, "field enable not found")
+}
@@ -0,0 +1,491 @@
+package integrity
+
+import (
+	"db_meta/dbstructs"
+	"db_meta/sqlgen"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// IDs of the naming rules
+const (
+	RuleNamingCase           = "naming-case"
+	RuleSingularTable        = "naming-singular-table"
+	RuleForeignKeyColumnName = "naming-foreign-key-column"
+	RuleNamePrefix           = "naming-prefix"
+	RuleReservedKeyword      = "naming-reserved-keyword"
+)
+
+func init() {
+	for _, rule := range []*rule{
+		{
+			id: RuleNamingCase, category: CategoryNaming, severity: SeverityWarning,
+			options:  map[string]string{"tables": "snake_case", "columns": "snake_case"},
+			validate: validateNamingCase,
+			check:    checkNamingCase, fix: fixNamingCase,
+		},
+		{id: RuleSingularTable, category: CategoryNaming, severity: SeverityInfo, check: checkSingularTables, fix: fixSingularTable},
+		{
+			id: RuleForeignKeyColumnName, category: CategoryNaming, severity: SeverityInfo,
+			options:  map[string]string{"pattern": "{table}_{column}"},
+			validate: validateForeignKeyColumnName,
+			check:    checkForeignKeyColumnNames, fix: fixForeignKeyColumnName,
+		},
+		{
+			id: RuleNamePrefix, category: CategoryNaming, severity: SeverityInfo,
+			options: map[string]string{"index": "ix_", "unique": "uq_", "foreign_key": "fk_"},
+			check:   checkNamePrefixes, fix: fixNamePrefix,
+		},
+		{
+			id: RuleReservedKeyword, category: CategoryNaming, severity: SeverityWarning,
+			options:  map[string]string{"dialects": "all"},
+			validate: validateReservedKeyword,
+			check:    checkReservedKeywords, fix: fixReservedKeyword,
+		},
+	} {
+		Register(rule)
+	}
+}
+
+const renameNote = "Renaming breaks the queries, views and code using the old name"
+
+// convention is a naming convention: a preset, which names can be converted
+// to, or a regular expression.
+type convention struct {
+	name    string
+	pattern *regexp.Regexp
+	convert func(words []string) string
+}
+
+var presets = map[string]*convention{
+	"snake_case": {
+		pattern: regexp.MustCompile(`^[a-z][a-z0-9]*(_[a-z0-9]+)*$`),
+		convert: func(words []string) string { return strings.ToLower(strings.Join(words, "_")) },
+	},
+	"UPPER_SNAKE_CASE": {
+		pattern: regexp.MustCompile(`^[A-Z][A-Z0-9]*(_[A-Z0-9]+)*$`),
+		convert: func(words []string) string { return strings.ToUpper(strings.Join(words, "_")) },
+	},
+	"camelCase": {
+		pattern: regexp.MustCompile(`^[a-z][a-zA-Z0-9]*$`),
+		convert: func(words []string) string {
+			if len(words) == 0 {
+				return ""
+			}
+			return strings.ToLower(words[0]) + titleCase(words[1:])
+		},
+	},
+	"PascalCase": {
+		pattern: regexp.MustCompile(`^[A-Z][a-zA-Z0-9]*$`),
+		convert: titleCase,
+	},
+}
+
+// parseConvention returns the preset named value, or the convention of the
+// regular expression value, which must match whole names.
+func parseConvention(value string) (*convention, error) {
+	if preset, ok := presets[value]; ok {
+		named := *preset
+		named.name = value
+		return &named, nil
+	}
+	pattern, err := regexp.Compile("^(?:" + value + ")$")
+	if err != nil {
+		return nil, fmt.Errorf("%q is neither a naming preset nor a regular expression", value)
+	}
+	return &convention{name: value, pattern: pattern}, nil
+}
+
+// rename returns name following c, or "" when c is a regular expression.
+func (c *convention) rename(name string) string {
+	if c.convert == nil {
+		return ""
+	}
+	return c.convert(words(name))
+}
+
+// words splits a name at underscores, dashes, spaces and case changes:
+// HTTPServerLog is HTTP, Server and Log.
+func words(name string) []string {
+	var list []string
+	var current []rune
+	flush := func() {
+		if len(current) > 0 {
+			list = append(list, string(current))
+			current = nil
+		}
+	}
+	runes := []rune(name)
+	for i, r := range runes {
+		if r == '_' || r == '-' || r == ' ' {
+			flush()
+			continue
+		}
+		if unicode.IsUpper(r) && len(current) > 0 {
+			previous := runes[i-1]
+			beforeLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(previous) || unicode.IsDigit(previous) || unicode.IsUpper(previous) && beforeLower {
+				flush()
+			}
+		}
+		current = append(current, r)
+	}
+	flush()
+	return list
+}
+
+func titleCase(words []string) string {
+	var b strings.Builder
+	for _, word := range words {
+		runes := []rune(strings.ToLower(word))
+		runes[0] = unicode.ToUpper(runes[0])
+		b.WriteString(string(runes))
+	}
+	return b.String()
+}
+
+func validateNamingCase(options map[string]string) error {
+	for _, name := range []string{"tables", "columns"} {
+		if _, err := parseConvention(options[name]); err != nil {
+			return fmt.Errorf("option %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+func checkNamingCase(schema *Schema) []*Finding {
+	tables, _ := parseConvention(schema.Option("tables"))
+	columns, _ := parseConvention(schema.Option("columns"))
+	var findings []*Finding
+	for _, table := range schema.VerifiedTables() {
+		if !tables.pattern.MatchString(table.TableName) {
+			findings = append(findings, &Finding{
+				TableName: table.QualifiedName(),
+				Message:   fmt.Sprintf("Table name does not follow %s", tables.name),
+			})
+		}
+		for _, column := range table.Columns {
+			if !columns.pattern.MatchString(column.ColumnName) {
+				findings = append(findings, &Finding{
+					TableName: table.QualifiedName(),
+					Columns:   []string{column.ColumnName},
+					Message:   fmt.Sprintf("Column name does not follow %s", columns.name),
+				})
+			}
+		}
+	}
+	return findings
+}
+
+func fixNamingCase(w *sqlgen.Writer, schema *Schema, finding *Finding) *Fix {
+	table := schema.Table(finding.TableName)
+	if len(finding.Columns) == 0 {
+		tables, _ := parseConvention(schema.Option("tables"))
+		return renameTable(w, table, tables.rename(table.TableName))
+	}
+	columns, _ := parseConvention(schema.Option("columns"))
+	return renameColumn(w, table, finding.Columns[0], columns.rename(finding.Columns[0]))
+}
+
+// irregular plurals, and words which are their own plural
+var (
+	irregularPlurals = map[string]string{"people": "person", "children": "child", "men": "man", "women": "woman", "mice": "mouse", "geese": "goose"}
+	uncountables     = map[string]bool{"data": true, "metadata": true, "news": true, "series": true, "species": true, "information": true, "equipment": true}
+)
+
+// singulars in -us, whose plural takes -es (statuses but houses), and
+// singulars in -ie (movies but categories)
+var (
+	usSingulars = keywordSet(`
+		APPARATUS BONUS BUS CACTUS CAMPUS CENSUS CHORUS CORPUS FOCUS GENIUS HIATUS NEXUS OCTOPUS
+		PROSPECTUS SINUS STATUS SURPLUS SYLLABUS VIRUS`)
+	ieSingulars = keywordSet(`
+		AUNTIE BIRDIE BROWNIE CALORIE COOKIE CUTIE FREEBIE GENIE GOALIE HIPPIE HOODIE MOVIE NEWBIE
+		PIXIE PRAIRIE ROOKIE SELFIE SMOOTHIE SORTIE VEGGIE ZOMBIE`)
+)
+
+// singular returns the singular of the last word of name, keeping its case.
+func singular(name string) string {
+	list := words(name)
+	if len(list) == 0 {
+		return name
+	}
+	last := strings.ToLower(list[len(list)-1])
+	if uncountables[last] {
+		return name
+	}
+	if single, ok := irregularPlurals[last]; ok {
+		return replaceSuffix(name, len(last), single)
+	}
+	upper := strings.ToUpper(last)
+	switch {
+	case strings.HasSuffix(last, "ies") && ieSingulars[upper[:len(upper)-1]]:
+		return name[:len(name)-1]
+	case strings.HasSuffix(last, "ies") && len(last) > 4:
+		return replaceSuffix(name, 3, "y")
+	case strings.HasSuffix(last, "uses") && usSingulars[upper[:len(upper)-2]]:
+		return name[:len(name)-2]
+	case strings.HasSuffix(last, "sses"), strings.HasSuffix(last, "shes"), strings.HasSuffix(last, "ches"),
+		strings.HasSuffix(last, "xes"), strings.HasSuffix(last, "zzes"):
+		return name[:len(name)-2]
+	case strings.HasSuffix(last, "ss"), strings.HasSuffix(last, "us"), strings.HasSuffix(last, "is"):
+		return name
+	case strings.HasSuffix(last, "s") && len(last) > 1:
+		return name[:len(name)-1]
+	}
+	return name
+}
+
+// replaceSuffix replaces the last n bytes of name by suffix, in upper case if
+// they were.
+func replaceSuffix(name string, n int, suffix string) string {
+	replaced := name[len(name)-n:]
+	if replaced == strings.ToUpper(replaced) {
+		suffix = strings.ToUpper(suffix)
+	}
+	return name[:len(name)-n] + suffix
+}
+
+func checkSingularTables(schema *Schema) []*Finding {
+	var findings []*Finding
+	for _, table := range schema.VerifiedTables() {
+		if single := singular(table.TableName); single != table.TableName {
+			findings = append(findings, &Finding{
+				TableName: table.QualifiedName(),
+				Message:   fmt.Sprintf("Table name should be singular: %s", single),
+			})
+		}
+	}
+	return findings
+}
+
+func fixSingularTable(w *sqlgen.Writer, schema *Schema, finding *Finding) *Fix {
+	table := schema.Table(finding.TableName)
+	return renameTable(w, table, singular(table.TableName))
+}
+
+func validateForeignKeyColumnName(options map[string]string) error {
+	if !strings.Contains(options["pattern"], "{table}") {
+		return errors.New("option pattern: {table} is missing")
+	}
+	return nil
+}
+
+// foreignKeyColumnName is the name pattern gives the column of a single column
+// foreign key: {table} is the singular of the referenced table, {column} the
+// referenced column.
+func foreignKeyColumnName(pattern string, relationship *dbstructs.RelationshipMetadata) string {
+	return strings.NewReplacer("{table}", singular(relationship.RelatedTableName), "{column}", relationship.TargetColumns[0]).Replace(pattern)
+}
+
+// checkForeignKeyColumnNames reports the single column foreign keys whose column
+// is not named after the pattern. A prefix naming the role of the reference,
+// as in billing_customer_id, is fine.
+func checkForeignKeyColumnNames(schema *Schema) []*Finding {
+	var findings []*Finding
+	for _, table := range schema.VerifiedTables() {
+		for _, relationship := range sqlgen.ForeignKeys(table) {
+			if len(relationship.SourceColumns) != 1 || len(relationship.TargetColumns) != 1 {
+				continue
+			}
+			column := strings.ToLower(relationship.SourceColumns[0])
+			expected := foreignKeyColumnName(schema.Option("pattern"), relationship)
+			if column == strings.ToLower(expected) || strings.HasSuffix(column, "_"+strings.ToLower(expected)) {
+				continue
+			}
+			findings = append(findings, &Finding{
+				TableName:      table.QualifiedName(),
+				Columns:        relationship.SourceColumns,
+				ConstraintName: relationship.Conname,
+				RelatedTable:   relationship.QualifiedRelatedName(),
+				Message:        fmt.Sprintf("Foreign key column should be named %s", expected),
+			})
+		}
+	}
+	return findings
+}
+
+func fixForeignKeyColumnName(w *sqlgen.Writer, schema *Schema, finding *Finding) *Fix {
+	table := schema.Table(finding.TableName)
+	for _, relationship := range sqlgen.ForeignKeys(table) {
+		if relationship.Conname == finding.ConstraintName && len(relationship.SourceColumns) == 1 {
+			return renameColumn(w, table, finding.Columns[0], foreignKeyColumnName(schema.Option("pattern"), relationship))
+		}
+	}
+	return nil
+}
+
+// checkNamePrefixes reports the indexes and foreign keys whose name lacks the
+// prefix of their kind. Primary keys and the indexes SQLite names are left
+// out, as are the foreign keys of SQLite which have no name.
+func checkNamePrefixes(schema *Schema) []*Finding {
+	var findings []*Finding
+	for _, table := range schema.VerifiedTables() {
+		for _, index := range table.Indexes {
+			prefix := schema.Option("index")
+			if index.Unique {
+				prefix = schema.Option("unique")
+			}
+			if index.Primary || prefix == "" || strings.HasPrefix(index.Name, prefix) || strings.HasPrefix(index.Name, "sqlite_autoindex_") {
+				continue
+			}
+			findings = append(findings, &Finding{
+				TableName: table.QualifiedName(),
+				Columns:   index.Columns,
+				IndexName: index.Name,
+				Message:   fmt.Sprintf("Index name should start with %s", prefix),
+			})
+		}
+
+		prefix := schema.Option("foreign_key")
+		if prefix == "" || schema.Dialect == sqlgen.SQLite {
+			continue
+		}
+		for _, relationship := range sqlgen.ForeignKeys(table) {
+			if relationship.Conname == "" || strings.HasPrefix(relationship.Conname, prefix) {
+				continue
+			}
+			findings = append(findings, &Finding{
+				TableName:      table.QualifiedName(),
+				Columns:        relationship.SourceColumns,
+				ConstraintName: relationship.Conname,
+				RelatedTable:   relationship.QualifiedRelatedName(),
+				Message:        fmt.Sprintf("Foreign key name should start with %s", prefix),
+			})
+		}
+	}
+	return findings
+}
+
+var identifier = regexp.MustCompile(`^\w+$`)
+
+// prefixedName names an index or a constraint <prefix><table>_<columns>, or
+// prefixes its current name when a key is an expression.
+func prefixedName(prefix string, table *dbstructs.TableMetadata, columns []string, current string) string {
+	for _, column := range columns {
+		if !identifier.MatchString(column) {
+			return prefix + current
+		}
+	}
+	return prefix + table.TableName + "_" + strings.Join(columns, "_")
+}
+
+func fixNamePrefix(w *sqlgen.Writer, schema *Schema, finding *Finding) *Fix {
+	table := schema.Table(finding.TableName)
+	taken := func(name string) bool {
+		for _, index := range table.Indexes {
+			if index.Name == name {
+				return true
+			}
+		}
+		return findRelationship(table, name) != nil
+	}
+
+	if finding.IndexName != "" {
+		for _, index := range table.Indexes {
+			if index.Name != finding.IndexName {
+				continue
+			}
+			prefix := schema.Option("index")
+			if index.Unique {
+				prefix = schema.Option("unique")
+			}
+			to := prefixedName(prefix, table, index.Columns, index.Name)
+			if taken(to) {
+				return &Fix{Note: fmt.Sprintf("%s is taken, choose another name starting with %s", to, prefix)}
+			}
+			fix := &Fix{Rename: to, Note: "The statements naming the index in hints or DDL scripts use the old name"}
+			if statement := w.RenameIndex(table, index.Name, to); statement != "" {
+				fix.Statements = []string{statement}
+				return fix
+			}
+			renamed := *index
+			renamed.Name = to
+			fix.Statements = append(w.DropIndex(table, index), w.CreateIndex(table, &renamed))
+			return fix
+		}
+		return nil
+	}
+
+	relationship := findRelationship(table, finding.ConstraintName)
+	if relationship == nil {
+		return nil
+	}
+	to := prefixedName(schema.Option("foreign_key"), table, relationship.SourceColumns, relationship.Conname)
+	if taken(to) {
+		return &Fix{Note: fmt.Sprintf("%s is taken, choose another name starting with %s", to, schema.Option("foreign_key"))}
+	}
+	return &Fix{Statements: w.RenameForeignKey(table, relationship, to), Rename: to}
+}
+
+func validateReservedKeyword(options map[string]string) error {
+	if options["dialects"] == "all" {
+		return nil
+	}
+	for _, dialect := range strings.Split(options["dialects"], ",") {
+		if _, err := sqlgen.NewWriter(strings.TrimSpace(dialect)); err != nil {
+			return fmt.Errorf("option dialects: %w", err)
+		}
+	}
+	return nil
+}
+
+// checkReservedKeywords reports the tables and columns named after a keyword
+// reserved by one of the dialects of the dialects option, all of them by
+// default: the schema may be moved to another database.
+func checkReservedKeywords(schema *Schema) []*Finding {
+	dialects := sqlgen.Dialects()
+	if option := schema.Option("dialects"); option != "all" {
+		dialects = nil
+		for _, dialect := range strings.Split(option, ",") {
+			dialects = append(dialects, strings.TrimSpace(dialect))
+		}
+	}
+
+	var findings []*Finding
+	for _, table := range schema.VerifiedTables() {
+		if reserving := reservedIn(table.TableName, dialects); len(reserving) > 0 {
+			findings = append(findings, &Finding{
+				TableName: table.QualifiedName(),
+				Message:   fmt.Sprintf("%s is a reserved keyword in %s", table.TableName, strings.Join(reserving, ", ")),
+			})
+		}
+		for _, column := range table.Columns {
+			if reserving := reservedIn(column.ColumnName, dialects); len(reserving) > 0 {
+				findings = append(findings, &Finding{
+					TableName: table.QualifiedName(),
+					Columns:   []string{column.ColumnName},
+					Message:   fmt.Sprintf("%s is a reserved keyword in %s", column.ColumnName, strings.Join(reserving, ", ")),
+				})
+			}
+		}
+	}
+	return findings
+}
+
+func fixReservedKeyword(w *sqlgen.Writer, schema *Schema, finding *Finding) *Fix {
+	return &Fix{Note: "Every statement has to quote the name, rename it to a word that is no keyword"}
+}
+
+// renameTable suggests renaming table to to.
+func renameTable(w *sqlgen.Writer, table *dbstructs.TableMetadata, to string) *Fix {
+	if to == "" || to == table.TableName {
+		return &Fix{Note: "Rename the table by hand"}
+	}
+	return &Fix{Statements: []string{w.RenameTable(table, to)}, Note: renameNote, Rename: to, Renames: table.QualifiedName()}
+}
+
+// renameColumn suggests renaming the column from of table to to, unless
+// another column has this name.
+func renameColumn(w *sqlgen.Writer, table *dbstructs.TableMetadata, from, to string) *Fix {
+	if to == "" || to == from {
+		return &Fix{Note: "Rename the column by hand"}
+	}
+	if findColumnByName(table, to) != nil {
+		return &Fix{Note: fmt.Sprintf("%s is taken, add a prefix naming the role of the column", to)}
+	}
+	return &Fix{Statements: []string{w.RenameColumn(table, from, to)}, Note: renameNote, Rename: to}
+}
@@ -0,0 +1,164 @@
+package integrity
+
+import (
+	"db_meta/dbstructs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// namingTables break every naming rule
+func namingTables() []*dbstructs.TableMetadata {
+	return []*dbstructs.TableMetadata{
+		{
+			Schema: "shop", TableName: "Categories", PrimaryKey: []string{"id"},
+			Columns: []*dbstructs.Column{{ColumnName: "id"}, {ColumnName: "displayName"}, {ColumnName: "order"}},
+			Indexes: []*dbstructs.Index{
+				{Name: "categories_pkey", Columns: []string{"id"}, Unique: true, Primary: true},
+				{Name: "categories_name_key", Columns: []string{"displayName"}, Unique: true},
+			},
+		},
+		{
+			Schema: "shop", TableName: "product", PrimaryKey: []string{"id"},
+			Columns: []*dbstructs.Column{{ColumnName: "id"}, {ColumnName: "category"}, {ColumnName: "main_category_id"}, {ColumnName: "status"}},
+			Indexes: []*dbstructs.Index{
+				{Name: "product_pkey", Columns: []string{"id"}, Unique: true, Primary: true},
+				{Name: "ix_product_status", Columns: []string{"status"}},
+				{Name: "product_lower_idx", Columns: []string{"lower(status)"}},
+			},
+			Relationships: []*dbstructs.RelationshipMetadata{
+				{
+					Conname: "product_category_fkey", SourceSchema: "shop", SourceTableName: "product", RelatedSchema: "shop", RelatedTableName: "Categories",
+					SourceColumns: []string{"category"}, TargetColumns: []string{"id"},
+				},
+				{
+					Conname: "fk_product_main_category_id", SourceSchema: "shop", SourceTableName: "product", RelatedSchema: "shop", RelatedTableName: "Categories",
+					SourceColumns: []string{"main_category_id"}, TargetColumns: []string{"id"},
+				},
+			},
+		},
+	}
+}
+
+func TestNaming_words(t *testing.T) {
+	assert.Equal(t, []string{"HTTP", "Server", "Log"}, words("HTTPServerLog"))
+	assert.Equal(t, []string{"order", "Line2", "items"}, words("order_Line2-items"))
+
+	for name, expected := range map[string]string{
+		"orders": "order", "categories": "category", "addresses": "address", "boxes": "box", "statuses": "status",
+		"status": "status", "analysis": "analysis", "series": "series", "people": "person", "ORDER_LINES": "ORDER_LINE",
+		"orderItems": "orderItem", "user_data": "user_data",
+		"houses": "house", "warehouses": "warehouse", "Warehouses": "Warehouse", "causes": "cause", "buses": "bus", "bonuses": "bonus",
+		"movies": "movie", "cookies": "cookie", "ZOMBIES": "ZOMBIE", "stock_movies": "stock_movie", "companies": "company",
+	} {
+		assert.Equal(t, expected, singular(name), name)
+	}
+}
+
+func TestNaming_rules(t *testing.T) {
+	findings := func(ruleID string, config *Config) map[string]*Finding {
+		rule, _ := Lookup(ruleID)
+		report, err := Run([]Rule{rule}, &Schema{Tables: namingTables(), Dialect: "postgres"}, config)
+		assert.NoError(t, err)
+		byID := make(map[string]*Finding)
+		for _, finding := range report.Findings {
+			byID[finding.ID] = finding
+		}
+		return byID
+	}
+
+	naming := findings(RuleNamingCase, nil)
+	assert.Len(t, naming, 2)
+	assert.Equal(t, "Table name does not follow snake_case", naming["naming-case:shop.Categories"].Message)
+	assert.Equal(t, []string{`ALTER TABLE "shop"."Categories" RENAME TO "categories"`}, naming["naming-case:shop.Categories"].Fix.Statements)
+	assert.Equal(t, "shop.Categories", naming["naming-case:shop.Categories"].Fix.Renames)
+	assert.Equal(t, "display_name", naming["naming-case:shop.Categories:displayName"].Fix.Rename)
+
+	config, err := ParseConfig([]byte("rules:\n  naming-case:\n    options:\n      tables: PascalCase\n      columns: '[a-z]+'\n"))
+	assert.NoError(t, err)
+	naming = findings(RuleNamingCase, config)
+	assert.Len(t, naming, 3)
+	assert.Equal(t, "Product", naming["naming-case:shop.product"].Fix.Rename)
+	assert.Equal(t, `Column name does not follow [a-z]+`, naming["naming-case:shop.product:main_category_id"].Message)
+	assert.Empty(t, naming["naming-case:shop.product:main_category_id"].Fix.Statements)
+
+	singular := findings(RuleSingularTable, nil)
+	assert.Len(t, singular, 1)
+	assert.Equal(t, "Table name should be singular: Category", singular["naming-singular-table:shop.Categories"].Message)
+
+	// main_category_id names the role of the reference
+	foreignKeys := findings(RuleForeignKeyColumnName, nil)
+	assert.Len(t, foreignKeys, 1)
+	finding := foreignKeys["naming-foreign-key-column:shop.product:category:product_category_fkey"]
+	assert.Equal(t, "Foreign key column should be named Category_id", finding.Message)
+	assert.Equal(t, []string{`ALTER TABLE "shop"."product" RENAME COLUMN "category" TO "Category_id"`}, finding.Fix.Statements)
+
+	prefixes := findings(RuleNamePrefix, nil)
+	assert.Len(t, prefixes, 3)
+	assert.Equal(t, []string{`ALTER INDEX "shop"."categories_name_key" RENAME TO "uq_Categories_displayName"`}, prefixes["naming-prefix:shop.Categories:displayName:categories_name_key"].Fix.Statements)
+	assert.Equal(t, "ix_product_lower_idx", prefixes["naming-prefix:shop.product:lower(status):product_lower_idx"].Fix.Rename)
+	assert.Equal(t, []string{`ALTER TABLE "shop"."product" RENAME CONSTRAINT "product_category_fkey" TO "fk_product_category"`}, prefixes["naming-prefix:shop.product:category:product_category_fkey"].Fix.Statements)
+
+	keywords := findings(RuleReservedKeyword, nil)
+	assert.Len(t, keywords, 1)
+	assert.Equal(t, "order is a reserved keyword in postgres, mysql, sqlite, sqlserver", keywords["naming-reserved-keyword:shop.Categories:order"].Message)
+	config, err = ParseConfig([]byte("rules:\n  naming-reserved-keyword:\n    options:\n      dialects: mysql, sqlserver\n"))
+	assert.NoError(t, err)
+	assert.Equal(t, "order is a reserved keyword in mysql, sqlserver", findings(RuleReservedKeyword, config)["naming-reserved-keyword:shop.Categories:order"].Message)
+
+	// the renames of a table come last
+	report, err := Run(Rules(), &Schema{Tables: namingTables(), Dialect: "postgres"}, nil)
+	assert.NoError(t, err)
+	script, err := report.Fixes([]string{"naming-case:shop.Categories", "naming-prefix:shop.Categories:displayName:categories_name_key"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		`ALTER INDEX "shop"."categories_name_key" RENAME TO "uq_Categories_displayName"`,
+		`ALTER TABLE "shop"."Categories" RENAME TO "categories"`,
+	}, script.Statements)
+	script, err = report.Fixes([]string{"naming-case:shop.Categories", "naming-singular-table:shop.Categories"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"shop.Categories"}, script.Conflicts)
+}
+
+func TestNaming_badOptions(t *testing.T) {
+	for config, message := range map[string]string{
+		"rules:\n  naming-case:\n    options:\n      tables: '('\n":                  `rule naming-case: option tables: "(" is neither a naming preset nor a regular expression`,
+		"rules:\n  naming-case:\n    options:\n      keys: snake_case\n":             `rule naming-case: unknown option "keys"`,
+		"rules:\n  naming-foreign-key-column:\n    options:\n      pattern: id\n":    `rule naming-foreign-key-column: option pattern: {table} is missing`,
+		"rules:\n  naming-reserved-keyword:\n    options:\n      dialects: oracle\n": `rule naming-reserved-keyword: option dialects: unknown SQL dialect "oracle", expected one of postgres, mysql, sqlite, sqlserver`,
+		"rules:\n  cascade-depth:\n    options:\n      depth: '2'\n":                 `rule cascade-depth: unknown option "depth"`,
+	} {
+		parsed, err := ParseConfig([]byte(config))
+		assert.NoError(t, err)
+		_, err = Run(Rules(), &Schema{}, parsed)
+		assert.EqualError(t, err, message)
+	}
+}
+
+func TestNaming_pluralTables(t *testing.T) {
+	for table, single := range map[string]string{"houses": "house", "warehouses": "warehouse", "movies": "movie", "cookies": "cookie", "statuses": "status"} {
+		tables := []*dbstructs.TableMetadata{
+			{TableName: table, PrimaryKey: []string{"id"}, Columns: []*dbstructs.Column{{ColumnName: "id"}}},
+			{
+				TableName: "listing", PrimaryKey: []string{"id"}, Columns: []*dbstructs.Column{{ColumnName: "id"}, {ColumnName: single + "_id"}},
+				Relationships: []*dbstructs.RelationshipMetadata{{
+					Conname: "fk_listing_" + single, SourceTableName: "listing", RelatedTableName: table,
+					SourceColumns: []string{single + "_id"}, TargetColumns: []string{"id"},
+				}},
+			},
+		}
+		report, err := Run(Rules(), &Schema{Tables: tables, Dialect: "postgres"}, nil)
+		assert.NoError(t, err)
+		byID := make(map[string]*Finding)
+		for _, finding := range report.Findings {
+			byID[finding.ID] = finding
+		}
+
+		finding := byID["naming-singular-table:"+table]
+		if assert.NotNil(t, finding, table) {
+			assert.Equal(t, "Table name should be singular: "+single, finding.Message)
+			assert.Equal(t, []string{`ALTER TABLE "` + table + `" RENAME TO "` + single + `"`}, finding.Fix.Statements)
+		}
+		assert.Nil(t, byID["naming-foreign-key-column:listing:"+single+"_id:fk_listing_"+single], table)
+	}
+}
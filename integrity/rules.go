@@ -0,0 +1,370 @@
+package integrity
+
+import (
+	"db_meta/databases"
+	"db_meta/dbstructs"
+	"db_meta/sqlgen"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// IDs of the built-in rules
+const (
+	RuleMissingPrimaryKey      = "missing-primary-key"
+	RuleNullableColumn         = "nullable-column"
+	RuleUniqueWithoutIndex     = "unique-without-index"
+	RuleMissingForeignKeyTable = "missing-foreign-key-table"
+	RuleUnindexedForeignKey    = "unindexed-foreign-key"
+	RuleRedundantIndex         = "redundant-index"
+	RuleCircularRelations      = "circular-relations"
+	RuleSetNullOnNotNull       = "set-null-on-not-null"
+	RuleCascadeDepth           = "cascade-depth"
+)
+
+func init() {
+	for _, rule := range []*rule{
+		{id: RuleMissingPrimaryKey, category: CategoryKeys, severity: SeverityError, check: checkPrimaryKeys, fix: fixPrimaryKey},
+		{id: RuleNullableColumn, category: CategoryColumns, severity: SeverityInfo, check: checkNullableColumns, fix: fixNullableColumn},
+		{id: RuleUniqueWithoutIndex, category: CategoryIndexes, severity: SeverityWarning, check: checkUniqueIndexes, fix: fixUniqueIndex},
+		{id: RuleMissingForeignKeyTable, category: CategoryRelations, severity: SeverityError, check: checkForeignKeyTables, fix: fixForeignKeyTable},
+		{id: RuleUnindexedForeignKey, category: CategoryIndexes, severity: SeverityWarning, check: checkForeignKeyIndexes, fix: fixForeignKeyIndex},
+		{id: RuleRedundantIndex, category: CategoryIndexes, severity: SeverityWarning, check: checkRedundantIndexes, fix: fixRedundantIndex},
+		{id: RuleCircularRelations, category: CategoryRelations, severity: SeverityInfo, check: checkCircularRelations, fix: fixCircularRelations},
+		{id: RuleSetNullOnNotNull, category: CategoryRelations, severity: SeverityError, check: checkSetNullActions, fix: fixSetNullAction},
+		{
+			id: RuleCascadeDepth, category: CategoryRelations, severity: SeverityWarning,
+			thresholds: map[string]int{"max_depth": 3},
+			check:      checkCascadeDepth, fix: fixCascadeDepth,
+		},
+	} {
+		Register(rule)
+	}
+}
+
+func checkPrimaryKeys(schema *Schema) []*Finding {
+	var findings []*Finding
+	for _, table := range schema.VerifiedTables() {
+		if len(table.PrimaryKey) == 0 {
+			findings = append(findings, &Finding{TableName: table.QualifiedName(), Message: "Missing primary key"})
+		}
+	}
+	return findings
+}
+
+// checkNullableColumns reports the columns that should be NOT NULL. Identity
+// and generated columns are filled by the database.
+func checkNullableColumns(schema *Schema) []*Finding {
+	var findings []*Finding
+	for _, table := range schema.VerifiedTables() {
+		for _, column := range table.Columns {
+			if !column.NotNull && column.DataType != "serial" && !column.AutoIncrement && column.Generated == "" {
+				findings = append(findings, &Finding{
+					TableName: table.QualifiedName(),
+					Columns:   []string{column.ColumnName},
+					Message:   "Column should be NOT NULL",
+				})
+			}
+		}
+	}
+	return findings
+}
+
+func checkUniqueIndexes(schema *Schema) []*Finding {
+	var findings []*Finding
+	for _, table := range schema.VerifiedTables() {
+		for _, column := range table.Columns {
+			if column.Unique && !columnHasIndex(table, column.ColumnName) {
+				findings = append(findings, &Finding{
+					TableName: table.QualifiedName(),
+					Columns:   []string{column.ColumnName},
+					Message:   "Missing unique index",
+				})
+			}
+		}
+	}
+	return findings
+}
+
+func checkForeignKeyTables(schema *Schema) []*Finding {
+	var findings []*Finding
+	for _, table := range schema.VerifiedTables() {
+		for _, relationship := range sqlgen.ForeignKeys(table) {
+			if schema.Table(relationship.QualifiedRelatedName()) == nil {
+				findings = append(findings, &Finding{
+					TableName:      table.QualifiedName(),
+					Columns:        relationship.SourceColumns,
+					ConstraintName: relationship.Conname,
+					RelatedTable:   relationship.QualifiedRelatedName(),
+					Message:        fmt.Sprintf("Linked table not found: %s", relationship.QualifiedRelatedName()),
+				})
+			}
+		}
+	}
+	return findings
+}
+
+func checkForeignKeyIndexes(schema *Schema) []*Finding {
+	var findings []*Finding
+	for _, table := range schema.VerifiedTables() {
+		for _, relationship := range sqlgen.ForeignKeys(table) {
+			if schema.Table(relationship.QualifiedRelatedName()) == nil {
+				continue
+			}
+
+			var hasIndex bool
+			if len(relationship.SourceColumns) > 0 {
+				hasIndex = columnsHaveIndex(table, relationship.SourceColumns)
+			} else {
+				hasIndex = columnHasIndex(table, relationship.Conname)
+			}
+			if !hasIndex {
+				findings = append(findings, &Finding{
+					TableName:      table.QualifiedName(),
+					Columns:        relationship.SourceColumns,
+					ConstraintName: relationship.Conname,
+					RelatedTable:   relationship.QualifiedRelatedName(),
+					Message:        "Missing index for foreign key",
+				})
+			}
+		}
+	}
+	return findings
+}
+
+func checkRedundantIndexes(schema *Schema) []*Finding {
+	var findings []*Finding
+	for _, table := range schema.VerifiedTables() {
+		for _, index := range table.Indexes {
+			for _, other := range table.Indexes {
+				if isRedundantIndex(index, other) {
+					findings = append(findings, &Finding{
+						TableName: table.QualifiedName(),
+						IndexName: index.Name,
+						Message:   fmt.Sprintf("Redundant index, covered by %s", other.Name),
+					})
+					break
+				}
+			}
+		}
+	}
+	return findings
+}
+
+// checkCircularRelations reports the groups of tables referencing each other
+// through their foreign keys.
+func checkCircularRelations(schema *Schema) []*Finding {
+	if schema.Graph == nil {
+		return nil
+	}
+	graph := &dbstructs.GraphResponse{Nodes: schema.Graph.Nodes}
+	for _, edge := range schema.Graph.Edges {
+		if edge.Data.Kind == dbstructs.EdgeKindForeignKey {
+			graph.Edges = append(graph.Edges, edge)
+		}
+	}
+
+	var findings []*Finding
+	for _, scc := range databases.FindSCCs(graph) {
+		if len(scc) < 2 {
+			continue
+		}
+		tables := append([]string{}, scc...)
+		sort.Strings(tables)
+		findings = append(findings, &Finding{
+			Tables:  tables,
+			Message: fmt.Sprintf("Circular relations between %d tables", len(tables)),
+		})
+	}
+	return findings
+}
+
+// checkSetNullActions reports SET NULL actions that a NOT NULL column would
+// reject.
+func checkSetNullActions(schema *Schema) []*Finding {
+	var findings []*Finding
+	for _, table := range schema.VerifiedTables() {
+		for _, relationship := range sqlgen.ForeignKeys(table) {
+			for _, action := range []string{relationship.OnDelete, relationship.OnUpdate} {
+				if action != dbstructs.ReferentialActionSetNull {
+					continue
+				}
+				for _, columnName := range relationship.SourceColumns {
+					if column := findColumnByName(table, columnName); column != nil && column.NotNull {
+						findings = append(findings, &Finding{
+							TableName:      table.QualifiedName(),
+							Columns:        []string{columnName},
+							ConstraintName: relationship.Conname,
+							RelatedTable:   relationship.QualifiedRelatedName(),
+							Message:        fmt.Sprintf("SET NULL action on NOT NULL column %s", columnName),
+						})
+					}
+				}
+			}
+		}
+	}
+	return findings
+}
+
+// checkCascadeDepth reports cascading delete chains deeper than the max_depth
+// threshold: the number of ON DELETE CASCADE hops a single delete may trigger.
+func checkCascadeDepth(schema *Schema) []*Finding {
+	maxDepth := schema.Threshold("max_depth")
+
+	// Deleting a row of the related table cascades to the source table
+	cascades := make(map[string][]*dbstructs.RelationshipMetadata)
+	for _, table := range schema.VerifiedTables() {
+		for _, relationship := range sqlgen.ForeignKeys(table) {
+			if relationship.OnDelete == dbstructs.ReferentialActionCascade {
+				cascades[relationship.QualifiedRelatedName()] = append(cascades[relationship.QualifiedRelatedName()], relationship)
+			}
+		}
+	}
+
+	var findings []*Finding
+	for _, table := range schema.VerifiedTables() {
+		if path := cascadePath(table.QualifiedName(), maxDepth+1, cascades, map[string]bool{}); path != nil {
+			findings = append(findings, &Finding{
+				TableName: table.QualifiedName(),
+				Tables:    path,
+				Message:   fmt.Sprintf("ON DELETE CASCADE chain deeper than %d", maxDepth),
+			})
+		}
+	}
+	return findings
+}
+
+// cascadePath returns a chain of depth cascading deletes from tableName, nil
+// when there is none. The walk stops at depth, cycles are cut.
+func cascadePath(tableName string, depth int, cascades map[string][]*dbstructs.RelationshipMetadata, visiting map[string]bool) []string {
+	if depth == 0 {
+		return []string{tableName}
+	}
+	visiting[tableName] = true
+	defer delete(visiting, tableName)
+
+	for _, relationship := range cascades[tableName] {
+		if visiting[relationship.QualifiedSourceName()] {
+			continue
+		}
+		if path := cascadePath(relationship.QualifiedSourceName(), depth-1, cascades, visiting); path != nil {
+			return append([]string{tableName}, path...)
+		}
+	}
+	return nil
+}
+
+// Toolbox functions
+
+func findColumnByName(table *dbstructs.TableMetadata, columnName string) *dbstructs.Column {
+	for _, column := range table.Columns {
+		if column.ColumnName == columnName {
+			return column
+		}
+	}
+	return nil
+}
+
+func columnHasIndex(table *dbstructs.TableMetadata, columnName string) bool {
+	for _, index := range table.Indexes {
+		for _, idxColumn := range index.Columns {
+			if idxColumn == columnName {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// columnsHaveIndex tells if an index starts with the given columns, in any order,
+// so that it can serve lookups on a (composite) foreign key. Partial indexes do
+// not cover every row and are ignored.
+func columnsHaveIndex(table *dbstructs.TableMetadata, columns []string) bool {
+	for _, index := range table.Indexes {
+		if index.Predicate != "" {
+			continue
+		}
+		if len(index.Columns) >= len(columns) && isSubset(columns, index.Columns[:len(columns)]) {
+			return true
+		}
+	}
+	return false
+}
+
+// isRedundantIndex tells if other makes index useless: same access method and
+// predicate, and the keys of index are a leftmost prefix of the keys of other,
+// in the same order and direction. Unique indexes enforce a constraint, they are
+// only redundant with an identical unique index. Of two identical indexes only
+// one is reported, the primary key or the first by name is kept.
+func isRedundantIndex(index, other *dbstructs.Index) bool {
+	if index == other || index.Primary || indexMethod(index) != indexMethod(other) || index.Predicate != other.Predicate {
+		return false
+	}
+
+	keys, otherKeys := indexKeys(index), indexKeys(other)
+	if len(keys) == 0 || len(keys) > len(otherKeys) {
+		return false
+	}
+	for i := range keys {
+		if keys[i] != otherKeys[i] {
+			return false
+		}
+	}
+
+	sameKeys := len(keys) == len(otherKeys)
+	if !sameKeys && (index.Unique || indexMethod(index) != "btree") {
+		return false // only b-trees serve lookups on a prefix of their keys
+	}
+	if index.Unique && !other.Unique {
+		return false
+	}
+	// an index-only scan on index needs its included columns in other
+	if !isSubset(index.Include, append(append([]string{}, other.Columns...), other.Include...)) {
+		return false
+	}
+
+	identical := sameKeys && index.Unique == other.Unique && isSubset(other.Include, index.Include) && !other.Primary
+	return !identical || index.Name > other.Name
+}
+
+// indexMethod folds the access methods that behave as b-trees
+func indexMethod(index *dbstructs.Index) string {
+	switch method := strings.ToLower(index.Method); method {
+	case "", "btree", "clustered", "nonclustered":
+		return "btree"
+	default:
+		return method
+	}
+}
+
+// indexKeys describes the keys of an index, falling back to its columns when the
+// connector did not report them.
+func indexKeys(index *dbstructs.Index) []string {
+	if len(index.Keys) == 0 {
+		return index.Columns
+	}
+	keys := make([]string, len(index.Keys))
+	for i, key := range index.Keys {
+		keys[i] = key.Column
+		if key.Expression != "" {
+			keys[i] = "(" + key.Expression + ")"
+		}
+		if key.Descending {
+			keys[i] += " DESC"
+		}
+	}
+	return keys
+}
+
+func isSubset(subset, set []string) bool {
+	setMap := make(map[string]struct{})
+	for _, item := range set {
+		setMap[item] = struct{}{}
+	}
+	for _, item := range subset {
+		if _, found := setMap[item]; !found {
+			return false
+		}
+	}
+	return true
+}
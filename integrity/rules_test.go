@@ -0,0 +1,230 @@
+package integrity
+
+import (
+	"db_meta/databases"
+	"db_meta/dbstructs"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// check runs the registered rules on tables with the default configuration
+func check(t *testing.T, tables []*dbstructs.TableMetadata, ruleID string) []*Finding {
+	dbm := &databases.DatabaseManager{Tables: tables}
+	dbm.TransformToGraph()
+	report, err := Run(Rules(), &Schema{Tables: tables, Graph: &dbstructs.GraphResponse{Edges: dbm.Edges, Nodes: dbm.Nodes}}, nil)
+	assert.NoError(t, err)
+
+	var findings []*Finding
+	for _, finding := range report.Findings {
+		if finding.RuleID == ruleID {
+			findings = append(findings, finding)
+		}
+	}
+	return findings
+}
+
+func TestRules_foreignKeyIndexes(t *testing.T) {
+	tables := []*dbstructs.TableMetadata{
+		{
+			TableName:  "region",
+			PrimaryKey: []string{"country", "code"},
+			Indexes:    []*dbstructs.Index{{Name: "region_pkey", Columns: []string{"country", "code"}}},
+		},
+		{
+			TableName:  "shop",
+			PrimaryKey: []string{"id"},
+			Indexes: []*dbstructs.Index{
+				{Name: "shop_pkey", Columns: []string{"id"}},
+				{Name: "shop_region_idx", Columns: []string{"region_code", "region_country", "name"}},
+			},
+			Relationships: []*dbstructs.RelationshipMetadata{{
+				Conname:          "shop_region_fkey",
+				SourceTableName:  "shop",
+				RelatedTableName: "region",
+				SourceColumns:    []string{"region_country", "region_code"},
+				TargetColumns:    []string{"country", "code"},
+			}},
+		},
+		{
+			TableName:  "warehouse",
+			PrimaryKey: []string{"id"},
+			Indexes:    []*dbstructs.Index{{Name: "warehouse_pkey", Columns: []string{"id"}}},
+			Relationships: []*dbstructs.RelationshipMetadata{
+				{
+					Conname:          "warehouse_region_fkey",
+					SourceTableName:  "warehouse",
+					RelatedTableName: "region",
+					SourceColumns:    []string{"region_country", "region_code"},
+					TargetColumns:    []string{"country", "code"},
+				},
+				{
+					Conname:          "warehouse_owner_fkey",
+					SourceTableName:  "warehouse",
+					RelatedTableName: "owner",
+					SourceColumns:    []string{"owner_id"},
+					TargetColumns:    []string{"id"},
+				},
+			},
+		},
+	}
+
+	findings := check(t, tables, RuleUnindexedForeignKey)
+	assert.Len(t, findings, 1)
+	assert.Equal(t, &Finding{
+		ID:             "unindexed-foreign-key:warehouse:region_country,region_code:warehouse_region_fkey",
+		RuleID:         RuleUnindexedForeignKey,
+		Category:       CategoryIndexes,
+		Severity:       SeverityWarning,
+		TableName:      "warehouse",
+		Columns:        []string{"region_country", "region_code"},
+		ConstraintName: "warehouse_region_fkey",
+		RelatedTable:   "region",
+		Message:        "Missing index for foreign key",
+	}, findings[0])
+
+	findings = check(t, tables, RuleMissingForeignKeyTable)
+	assert.Len(t, findings, 1)
+	assert.Equal(t, "Linked table not found: owner", findings[0].Message)
+	assert.Equal(t, SeverityError, findings[0].Severity)
+}
+
+func TestRules_referentialActions(t *testing.T) {
+	cascadeTo := func(source, related string) *dbstructs.RelationshipMetadata {
+		return &dbstructs.RelationshipMetadata{
+			Conname:          source + "_" + related + "_fkey",
+			SourceTableName:  source,
+			RelatedTableName: related,
+			SourceColumns:    []string{related + "_id"},
+			TargetColumns:    []string{"id"},
+			OnDelete:         dbstructs.ReferentialActionCascade,
+		}
+	}
+	tables := []*dbstructs.TableMetadata{
+		{TableName: "a"},
+		{TableName: "b", Relationships: []*dbstructs.RelationshipMetadata{cascadeTo("b", "a")}},
+		{TableName: "c", Relationships: []*dbstructs.RelationshipMetadata{cascadeTo("c", "b")}},
+		{TableName: "d", Relationships: []*dbstructs.RelationshipMetadata{cascadeTo("d", "c")}},
+		{
+			TableName: "e",
+			Columns:   []*dbstructs.Column{{ColumnName: "d_id", NotNull: true}},
+			Relationships: []*dbstructs.RelationshipMetadata{
+				cascadeTo("e", "d"),
+				{
+					Conname:          "e_a_fkey",
+					SourceTableName:  "e",
+					RelatedTableName: "a",
+					SourceColumns:    []string{"d_id"},
+					TargetColumns:    []string{"id"},
+					OnDelete:         dbstructs.ReferentialActionSetNull,
+				},
+			},
+		},
+	}
+
+	findings := check(t, tables, RuleCascadeDepth)
+	assert.Len(t, findings, 1)
+	assert.Equal(t, "a", findings[0].TableName)
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, findings[0].Tables)
+	assert.Equal(t, "ON DELETE CASCADE chain deeper than 3", findings[0].Message)
+
+	// each table of a dense graph cascades from all the previous ones, the
+	// walk stops at max_depth+1
+	var dense []*dbstructs.TableMetadata
+	for i := 0; i < 40; i++ {
+		table := &dbstructs.TableMetadata{TableName: fmt.Sprintf("t%d", i)}
+		for j := 0; j < i; j++ {
+			table.Relationships = append(table.Relationships, cascadeTo(table.TableName, fmt.Sprintf("t%d", j)))
+		}
+		dense = append(dense, table)
+	}
+	findings = check(t, dense, RuleCascadeDepth)
+	assert.Len(t, findings, 36)
+	assert.Equal(t, []string{"t0", "t1", "t2", "t3", "t4"}, findings[0].Tables)
+
+	findings = check(t, tables, RuleSetNullOnNotNull)
+	assert.Len(t, findings, 1)
+	assert.Equal(t, "e", findings[0].TableName)
+	assert.Equal(t, "e_a_fkey", findings[0].ConstraintName)
+	assert.Equal(t, []string{"d_id"}, findings[0].Columns)
+}
+
+func TestRules_redundantIndexes(t *testing.T) {
+	key := func(column string) *dbstructs.IndexKey { return &dbstructs.IndexKey{Column: column} }
+	tables := []*dbstructs.TableMetadata{{
+		TableName:  "orders",
+		PrimaryKey: []string{"id"},
+		Indexes: []*dbstructs.Index{
+			{Name: "orders_pkey", Columns: []string{"id"}, Keys: []*dbstructs.IndexKey{key("id")}, Unique: true, Primary: true},
+			{Name: "orders_id_key", Columns: []string{"id"}, Keys: []*dbstructs.IndexKey{key("id")}, Unique: true},
+			// leftmost prefix of orders_customer_date_idx
+			{Name: "orders_customer_idx", Columns: []string{"customer_id"}, Keys: []*dbstructs.IndexKey{key("customer_id")}},
+			{Name: "orders_customer_date_idx", Columns: []string{"customer_id", "created_at"}, Keys: []*dbstructs.IndexKey{key("customer_id"), key("created_at")}},
+			// same columns, not a prefix
+			{Name: "orders_date_customer_idx", Columns: []string{"created_at", "customer_id"}, Keys: []*dbstructs.IndexKey{key("created_at"), key("customer_id")}},
+			// partial and hash indexes answer other queries
+			{Name: "orders_open_customer_idx", Columns: []string{"customer_id"}, Keys: []*dbstructs.IndexKey{key("customer_id")}, Predicate: "status = 'open'"},
+			{Name: "orders_customer_hash", Columns: []string{"customer_id"}, Keys: []*dbstructs.IndexKey{key("customer_id")}, Method: "hash"},
+			// identical indexes are reported once
+			{Name: "orders_status_a", Columns: []string{"status"}, Keys: []*dbstructs.IndexKey{key("status")}, Method: "btree"},
+			{Name: "orders_status_b", Columns: []string{"status"}, Keys: []*dbstructs.IndexKey{key("status")}, Method: "btree"},
+		},
+	}}
+
+	redundant := make(map[string]string)
+	for _, finding := range check(t, tables, RuleRedundantIndex) {
+		redundant[finding.IndexName] = finding.Message
+	}
+	assert.Equal(t, map[string]string{
+		"orders_id_key":       "Redundant index, covered by orders_pkey",
+		"orders_customer_idx": "Redundant index, covered by orders_customer_date_idx",
+		"orders_status_b":     "Redundant index, covered by orders_status_a",
+	}, redundant)
+}
+
+func TestRules_partitions(t *testing.T) {
+	tables := []*dbstructs.TableMetadata{
+		{
+			Schema:       "public",
+			TableName:    "measurement",
+			Columns:      []*dbstructs.Column{{ColumnName: "logdate", NotNull: true}},
+			Partitioning: &dbstructs.Partitioning{Strategy: "range", Key: "logdate"},
+		},
+		{
+			Schema:         "public",
+			TableName:      "measurement_y2024",
+			Columns:        []*dbstructs.Column{{ColumnName: "logdate", NotNull: true}},
+			PartitionOf:    "public.measurement",
+			PartitionBound: "FOR VALUES FROM ('2024-01-01') TO ('2025-01-01')",
+		},
+	}
+
+	// Only the parent is reported
+	findings := check(t, tables, RuleMissingPrimaryKey)
+	assert.Len(t, findings, 1)
+	assert.Equal(t, "public.measurement", findings[0].TableName)
+}
+
+func TestRules_circularRelations(t *testing.T) {
+	reference := func(source, related string) *dbstructs.RelationshipMetadata {
+		return &dbstructs.RelationshipMetadata{
+			Conname:          source + "_" + related + "_fkey",
+			SourceTableName:  source,
+			RelatedTableName: related,
+			SourceColumns:    []string{related + "_id"},
+			TargetColumns:    []string{"id"},
+		}
+	}
+	tables := []*dbstructs.TableMetadata{
+		{TableName: "employee", Relationships: []*dbstructs.RelationshipMetadata{reference("employee", "department"), reference("employee", "employee")}},
+		{TableName: "department", Relationships: []*dbstructs.RelationshipMetadata{reference("department", "employee")}},
+		{TableName: "badge", Relationships: []*dbstructs.RelationshipMetadata{reference("badge", "employee")}},
+	}
+
+	// a table referencing itself is no cycle worth reporting
+	findings := check(t, tables, RuleCircularRelations)
+	assert.Len(t, findings, 1)
+	assert.Empty(t, findings[0].TableName)
+	assert.Equal(t, []string{"department", "employee"}, findings[0].Tables)
+}
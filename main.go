@@ -4,6 +4,12 @@ import (
 	"embed"
 	"fmt"
 
+	// connectors register themselves with db_meta/databases/registry
+	_ "db_meta/databases/mysql"
+	_ "db_meta/databases/postgres"
+	_ "db_meta/databases/sqlite"
+	_ "db_meta/databases/sqlserver"
+
 	lang "github.com/cloudfoundry/jibber_jabber"
 	"github.com/wailsapp/wails/v2"
 	"github.com/wailsapp/wails/v2/pkg/options"
@@ -0,0 +1,383 @@
+// Package migration writes the scripts turning a schema into another from the
+// schemadiff.Diff between their tables: an up script from the source to the
+// target, and a down script back to the source.
+package migration
+
+import (
+	"db_meta/dbstructs"
+	"db_meta/schemadiff"
+	"db_meta/sqlgen"
+	"fmt"
+	"strings"
+)
+
+// Script holds the statements of a migration, without their trailing
+// semicolons, and the warnings about what they may lose or fail on.
+type Script struct {
+	Dialect      string   `json:"dialect"`
+	Up           []string `json:"up"`
+	Down         []string `json:"down"`
+	UpWarnings   []string `json:"upWarnings"`
+	DownWarnings []string `json:"downWarnings"`
+}
+
+// Generate writes the migration of diff in dialect, one of sqlgen.Dialects.
+//
+// Statements run in an order the foreign keys allow: the foreign keys, indexes,
+// checks and primary keys that go away are dropped first, then the removed
+// tables, referencing tables before the ones they reference. Tables are created
+// and columns added, altered and dropped, then the primary keys, checks, indexes
+// and, last, the foreign keys are added. SQLite, which alters little, rebuilds
+// the tables it can't alter.
+//
+// Rename candidates are not renamed, they are dropped and added again with a
+// warning.
+func Generate(dialect string, diff *schemadiff.Diff) (*Script, error) {
+	up, err := generate(dialect, diff)
+	if err != nil {
+		return nil, err
+	}
+	down, err := generate(dialect, diff.Reverse())
+	if err != nil {
+		return nil, err
+	}
+	return &Script{
+		Dialect:      dialect,
+		Up:           up.statements,
+		Down:         down.statements,
+		UpWarnings:   up.warnings,
+		DownWarnings: down.warnings,
+	}, nil
+}
+
+// UpSQL returns the up script as it can be run, in a transaction when the
+// dialect has transactional DDL.
+func (s *Script) UpSQL() string {
+	return text(s.Dialect, s.Up)
+}
+
+// DownSQL returns the down script as it can be run.
+func (s *Script) DownSQL() string {
+	return text(s.Dialect, s.Down)
+}
+
+func text(dialect string, statements []string) string {
+	if len(statements) == 0 {
+		return ""
+	}
+	switch dialect {
+	case sqlgen.SQLServer:
+		// batches are split by GO, as the DECLARE statements need. An error
+		// rolls the transaction back (XACT_ABORT) and the batches left are
+		// then compiled only (NOEXEC), COMMIT included.
+		const guard = "\nGO\n\nIF @@TRANCOUNT = 0 SET NOEXEC ON\nGO\n\n"
+		return "SET XACT_ABORT ON\nBEGIN TRANSACTION\nGO\n\n" + strings.Join(statements, guard) + guard +
+			"COMMIT\nGO\n\nSET NOEXEC OFF\nGO\n"
+	case sqlgen.MySQL:
+		// DDL commits implicitly on MySQL
+		return strings.Join(statements, ";\n\n") + ";\n"
+	case sqlgen.SQLite:
+		// the foreign keys must be off while the tables are rebuilt, which can't
+		// be done in a transaction
+		return "PRAGMA foreign_keys = OFF;\n\nBEGIN;\n\n" + strings.Join(statements, ";\n\n") +
+			";\n\nPRAGMA foreign_key_check;\n\nCOMMIT;\n\nPRAGMA foreign_keys = ON;\n"
+	}
+	return "BEGIN;\n\n" + strings.Join(statements, ";\n\n") + ";\n\nCOMMIT;\n"
+}
+
+// generator collects the statements of one direction of a migration.
+type generator struct {
+	w          *sqlgen.Writer
+	statements []string
+	warnings   []string
+	rebuilt    map[*schemadiff.TableDiff]bool // SQLite tables rebuilt rather than altered
+}
+
+func generate(dialect string, diff *schemadiff.Diff) (*generator, error) {
+	w, err := sqlgen.NewWriter(dialect)
+	if err != nil {
+		return nil, err
+	}
+	g := &generator{w: w, rebuilt: make(map[*schemadiff.TableDiff]bool)}
+	if dialect == sqlgen.SQLite {
+		for _, table := range diff.ChangedTables {
+			g.rebuilt[table] = g.needsRebuild(table)
+		}
+	}
+	g.renameWarnings(diff)
+
+	removed, cycles := sortTables(diff.RemovedTables)
+	g.dropForeignKeys(diff.ChangedTables, cycles)
+	g.dropConstraints(diff.ChangedTables)
+	for i := len(removed) - 1; i >= 0; i-- {
+		g.warn("the table %s is dropped with its data", removed[i].QualifiedName())
+		g.add(w.DropTable(removed[i]))
+	}
+	added, _ := sortTables(diff.AddedTables)
+	for _, table := range added {
+		g.add(w.CreateTable(table, false))
+		for _, index := range w.Indexes(table) {
+			g.add(w.CreateIndex(table, index))
+		}
+	}
+	for _, table := range diff.ChangedTables {
+		g.alterColumns(table)
+	}
+	g.addConstraints(diff.ChangedTables)
+	g.addForeignKeys(added, diff.ChangedTables)
+
+	g.warnings = append(g.warnings, w.Warnings()...)
+	return g, nil
+}
+
+func (g *generator) add(statements ...string) {
+	for _, statement := range statements {
+		if statement != "" {
+			g.statements = append(g.statements, statement)
+		}
+	}
+}
+
+func (g *generator) warn(format string, args ...interface{}) {
+	g.warnings = append(g.warnings, fmt.Sprintf(format, args...))
+}
+
+func (g *generator) renameWarnings(diff *schemadiff.Diff) {
+	for _, candidate := range diff.RenameCandidates {
+		g.warn("the table %s may have been renamed to %s, it is dropped and created again: rename it by hand to keep its data", candidate.From, candidate.To)
+	}
+	for _, table := range diff.ChangedTables {
+		for _, candidate := range table.RenameCandidates {
+			g.warn("the column %s.%s may have been renamed to %s, it is dropped and added again: rename it by hand to keep its data", candidate.Table, candidate.From, candidate.To)
+		}
+	}
+}
+
+// needsRebuild tells whether SQLite must rebuild the table to apply its
+// changes: all but adding columns it can add and changing the indexes that
+// belong to no constraint.
+func (g *generator) needsRebuild(table *schemadiff.TableDiff) bool {
+	if len(table.RemovedColumns) > 0 || len(table.ChangedColumns) > 0 || table.PrimaryKey != nil ||
+		len(table.AddedForeignKeys) > 0 || len(table.RemovedForeignKeys) > 0 || len(table.ChangedForeignKeys) > 0 ||
+		len(table.AddedChecks) > 0 || len(table.RemovedChecks) > 0 || len(table.ChangedChecks) > 0 {
+		return true
+	}
+	for _, column := range table.AddedColumns {
+		// ADD COLUMN takes neither a primary key nor a NOT NULL column without
+		// a default, nor a stored generated column
+		if column.Generated != "" || column.NotNull && column.Default == nil || containsName(table.Target.PrimaryKey, column.ColumnName) {
+			return true
+		}
+	}
+	for _, index := range table.AddedIndexes {
+		if g.w.IsConstraintIndex(index) {
+			return true
+		}
+	}
+	for _, index := range table.RemovedIndexes {
+		if g.w.IsConstraintIndex(index) {
+			return true
+		}
+	}
+	for _, index := range table.ChangedIndexes {
+		if g.w.IsConstraintIndex(index.Source) || g.w.IsConstraintIndex(index.Target) {
+			return true
+		}
+	}
+	return false
+}
+
+// dropForeignKeys drops the foreign keys that change or go away, and the ones
+// between removed tables that reference each other.
+func (g *generator) dropForeignKeys(tables []*schemadiff.TableDiff, cycles []foreignKey) {
+	if g.w.Dialect() == sqlgen.SQLite {
+		// they go with their table
+		return
+	}
+	for _, fk := range cycles {
+		g.add(g.w.DropForeignKey(fk.table, fk.relationship))
+	}
+	for _, table := range tables {
+		for _, relationship := range table.RemovedForeignKeys {
+			g.add(g.w.DropForeignKey(table.Source, relationship))
+		}
+		for _, relationship := range table.ChangedForeignKeys {
+			g.add(g.w.DropForeignKey(table.Source, relationship.Source))
+		}
+	}
+}
+
+// dropConstraints drops the indexes, checks and primary keys that change or go
+// away.
+func (g *generator) dropConstraints(tables []*schemadiff.TableDiff) {
+	for _, table := range tables {
+		if g.rebuilt[table] {
+			continue
+		}
+		for _, index := range table.RemovedIndexes {
+			g.add(g.w.DropIndex(table.Source, index)...)
+		}
+		for _, index := range table.ChangedIndexes {
+			if !g.renamesIndex(table, index) {
+				g.add(g.w.DropIndex(table.Source, index.Source)...)
+			}
+		}
+		for _, check := range table.RemovedChecks {
+			g.add(g.w.DropCheck(table.Source, check))
+		}
+		for _, check := range table.ChangedChecks {
+			g.add(g.w.DropCheck(table.Source, check.Source))
+		}
+		if table.PrimaryKey != nil && len(table.PrimaryKey.Source) > 0 {
+			g.add(g.w.DropPrimaryKey(table.Source))
+		}
+	}
+}
+
+// renamesIndex tells whether only the name of index changes and the dialect
+// can rename it.
+func (g *generator) renamesIndex(table *schemadiff.TableDiff, index *schemadiff.IndexDiff) bool {
+	return len(index.Changes) == 1 && index.Changes[0] == schemadiff.ChangeName && g.w.RenameIndex(table.Source, index.Source.Name, index.Target.Name) != ""
+}
+
+// alterColumns adds, alters and drops the columns of table, or rebuilds it.
+func (g *generator) alterColumns(table *schemadiff.TableDiff) {
+	name := table.TableName
+	for _, column := range table.AddedColumns {
+		if column.NotNull && column.Default == nil && column.Generated == "" && !column.AutoIncrement {
+			g.warn("the column %s.%s is added NOT NULL without a default, the migration fails if the table has rows", name, column.ColumnName)
+		}
+	}
+	for _, column := range table.ChangedColumns {
+		if containsName(column.Changes, schemadiff.ChangeType) {
+			g.warn("the type of %s.%s changes from %s to %s, values may be truncated or fail to convert", name, column.ColumnName, column.SourceType, column.TargetType)
+		}
+		if containsName(column.Changes, schemadiff.ChangeNullability) && column.Target.NotNull {
+			g.warn("the column %s.%s becomes NOT NULL, the migration fails if it holds NULL values", name, column.ColumnName)
+		}
+	}
+	for _, column := range table.RemovedColumns {
+		g.warn("the column %s.%s is dropped with its data", name, column.ColumnName)
+	}
+
+	if g.rebuilt[table] {
+		g.warn("the table %s is rebuilt, its data is copied into a new table", name)
+		g.add(g.w.RebuildTable(table.Source, table.Target)...)
+		return
+	}
+	for _, column := range table.AddedColumns {
+		g.add(g.w.AddColumn(table.Source, column))
+	}
+	for _, column := range table.ChangedColumns {
+		g.add(g.w.AlterColumn(table.Source, column.Source, column.Target, column.Changes)...)
+	}
+	for _, column := range table.RemovedColumns {
+		g.add(g.w.DropColumn(table.Source, column)...)
+	}
+}
+
+// addConstraints adds the primary keys, checks and indexes that change or
+// appear.
+func (g *generator) addConstraints(tables []*schemadiff.TableDiff) {
+	for _, table := range tables {
+		if g.rebuilt[table] {
+			continue
+		}
+		if table.PrimaryKey != nil && len(table.PrimaryKey.Target) > 0 {
+			g.add(g.w.AddPrimaryKey(table.Target))
+		}
+		for _, check := range table.ChangedChecks {
+			g.add(g.w.AddCheck(table.Target, check.Target))
+		}
+		for _, check := range table.AddedChecks {
+			g.add(g.w.AddCheck(table.Target, check))
+		}
+		for _, index := range table.ChangedIndexes {
+			if g.renamesIndex(table, index) {
+				g.add(g.w.RenameIndex(table.Source, index.Source.Name, index.Target.Name))
+			} else {
+				g.add(g.w.CreateIndex(table.Target, index.Target))
+			}
+		}
+		for _, index := range table.AddedIndexes {
+			g.add(g.w.CreateIndex(table.Target, index))
+		}
+	}
+}
+
+// addForeignKeys adds the foreign keys of the created tables and the ones that
+// change or appear, once all the tables they reference exist.
+func (g *generator) addForeignKeys(added []*dbstructs.TableMetadata, tables []*schemadiff.TableDiff) {
+	if g.w.Dialect() == sqlgen.SQLite {
+		// they are written in CREATE TABLE
+		return
+	}
+	for _, table := range added {
+		for _, relationship := range sqlgen.ForeignKeys(table) {
+			g.add(g.w.AddForeignKey(table, relationship))
+		}
+	}
+	for _, table := range tables {
+		for _, relationship := range table.ChangedForeignKeys {
+			g.add(g.w.AddForeignKey(table.Target, relationship.Target))
+		}
+		for _, relationship := range table.AddedForeignKeys {
+			g.add(g.w.AddForeignKey(table.Target, relationship))
+		}
+	}
+}
+
+// foreignKey is a foreign key and the table holding it.
+type foreignKey struct {
+	table        *dbstructs.TableMetadata
+	relationship *dbstructs.RelationshipMetadata
+}
+
+// sortTables orders tables so that the ones a table references come before it,
+// and returns the foreign keys of the reference cycles that prevent it.
+func sortTables(tables []*dbstructs.TableMetadata) ([]*dbstructs.TableMetadata, []foreignKey) {
+	byName := make(map[string]*dbstructs.TableMetadata, len(tables))
+	for _, table := range tables {
+		byName[strings.ToLower(table.QualifiedName())] = table
+	}
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := make(map[*dbstructs.TableMetadata]int, len(tables))
+	var sorted []*dbstructs.TableMetadata
+	var cycles []foreignKey
+	var visit func(table *dbstructs.TableMetadata)
+	visit = func(table *dbstructs.TableMetadata) {
+		state[table] = visiting
+		for _, relationship := range sqlgen.ForeignKeys(table) {
+			related, ok := byName[strings.ToLower(relationship.QualifiedRelatedName())]
+			if !ok || related == table {
+				continue
+			}
+			switch state[related] {
+			case visiting:
+				cycles = append(cycles, foreignKey{table, relationship})
+			case 0:
+				visit(related)
+			}
+		}
+		state[table] = visited
+		sorted = append(sorted, table)
+	}
+	for _, table := range tables {
+		if state[table] == 0 {
+			visit(table)
+		}
+	}
+	return sorted, cycles
+}
+
+func containsName(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
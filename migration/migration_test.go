@@ -0,0 +1,133 @@
+package migration
+
+import (
+	"db_meta/dbstructs"
+	"db_meta/schemadiff"
+	"db_meta/sqlgen"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func intPointer(value int) *int {
+	return &value
+}
+
+func customers() *dbstructs.TableMetadata {
+	return &dbstructs.TableMetadata{
+		Schema:    "public",
+		TableName: "customers",
+		Columns: []*dbstructs.Column{
+			{ColumnName: "id", DataType: "integer", NotNull: true},
+			{ColumnName: "email", DataType: "character varying", CharacterLength: intPointer(100)},
+			{ColumnName: "fax", DataType: "text"},
+		},
+		PrimaryKey: []string{"id"},
+		Indexes:    []*dbstructs.Index{{Name: "customers_pkey", Columns: []string{"id"}, Unique: true, Primary: true}},
+	}
+}
+
+func orders() *dbstructs.TableMetadata {
+	return &dbstructs.TableMetadata{
+		Schema:    "public",
+		TableName: "orders",
+		Columns: []*dbstructs.Column{
+			{ColumnName: "id", DataType: "integer", NotNull: true},
+			{ColumnName: "customer_id", DataType: "integer", NotNull: true},
+		},
+		PrimaryKey: []string{"id"},
+		Indexes:    []*dbstructs.Index{{Name: "orders_pkey", Columns: []string{"id"}, Unique: true, Primary: true}},
+		Relationships: []*dbstructs.RelationshipMetadata{{
+			Conname: "orders_customer_id_fkey", SourceSchema: "public", SourceTableName: "orders",
+			RelatedSchema: "public", RelatedTableName: "customers",
+			SourceColumns: []string{"customer_id"}, TargetColumns: []string{"id"},
+		}},
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	staging := customers()
+	staging.Columns[1].CharacterLength = intPointer(50)
+	staging.Columns[1].NotNull = true
+	staging.Columns = staging.Columns[:2]
+	staging.Indexes = append(staging.Indexes, &dbstructs.Index{Name: "customers_email_key", Columns: []string{"email"}, Unique: true})
+	diff := schemadiff.Compare(
+		[]*dbstructs.TableMetadata{customers()},
+		[]*dbstructs.TableMetadata{staging, orders()},
+	)
+
+	script, err := Generate(sqlgen.PostgreSQL, diff)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		`CREATE TABLE "public"."orders" (
+  "id" integer NOT NULL,
+  "customer_id" integer NOT NULL,
+  CONSTRAINT "orders_pkey" PRIMARY KEY ("id")
+)`,
+		`ALTER TABLE "public"."customers" ALTER COLUMN "email" TYPE character varying(50) USING "email"::character varying(50)`,
+		`ALTER TABLE "public"."customers" ALTER COLUMN "email" SET NOT NULL`,
+		`ALTER TABLE "public"."customers" DROP COLUMN "fax"`,
+		`CREATE UNIQUE INDEX "customers_email_key" ON "public"."customers" ("email")`,
+		`ALTER TABLE "public"."orders" ADD CONSTRAINT "orders_customer_id_fkey" FOREIGN KEY ("customer_id") REFERENCES "public"."customers" ("id")`,
+	}, script.Up)
+	assert.Equal(t, []string{
+		"the type of public.customers.email changes from character varying(100) to character varying(50), values may be truncated or fail to convert",
+		"the column public.customers.email becomes NOT NULL, the migration fails if it holds NULL values",
+		"the column public.customers.fax is dropped with its data",
+	}, script.UpWarnings)
+
+	assert.Equal(t, []string{
+		`ALTER TABLE "public"."customers" DROP CONSTRAINT IF EXISTS "customers_email_key"`,
+		`DROP INDEX IF EXISTS "public"."customers_email_key"`,
+		`DROP TABLE "public"."orders"`,
+		`ALTER TABLE "public"."customers" ADD COLUMN "fax" text`,
+		`ALTER TABLE "public"."customers" ALTER COLUMN "email" TYPE character varying(100) USING "email"::character varying(100)`,
+		`ALTER TABLE "public"."customers" ALTER COLUMN "email" DROP NOT NULL`,
+	}, script.Down)
+	assert.Contains(t, script.DownWarnings, "the table public.orders is dropped with its data")
+
+	assert.True(t, strings.HasPrefix(script.UpSQL(), "BEGIN;\n\n"+script.Up[0]+";\n\n"))
+	assert.True(t, strings.HasSuffix(script.UpSQL(), script.Up[5]+";\n\nCOMMIT;\n"))
+	assert.Empty(t, (&Script{Dialect: sqlgen.PostgreSQL}).UpSQL())
+
+	_, err = Generate("oracle", diff)
+	assert.Error(t, err)
+}
+
+func TestGenerate_cycles(t *testing.T) {
+	// removed tables referencing each other
+	a := orders()
+	b := customers()
+	b.Relationships = []*dbstructs.RelationshipMetadata{{
+		Conname: "customers_last_order_fkey", SourceSchema: "public", SourceTableName: "customers",
+		RelatedSchema: "public", RelatedTableName: "orders",
+		SourceColumns: []string{"fax"}, TargetColumns: []string{"id"},
+	}}
+	diff := schemadiff.Compare([]*dbstructs.TableMetadata{a, b}, nil)
+
+	script, err := Generate(sqlgen.MySQL, diff)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"ALTER TABLE `customers` DROP FOREIGN KEY `customers_last_order_fkey`",
+		"DROP TABLE `orders`",
+		"DROP TABLE `customers`",
+	}, script.Up)
+	assert.Contains(t, script.Down[2:], "ALTER TABLE `customers` ADD CONSTRAINT `customers_last_order_fkey` FOREIGN KEY (`fax`) REFERENCES `orders` (`id`)")
+}
+
+func TestGenerate_renameCandidates(t *testing.T) {
+	staging := customers()
+	staging.Columns[2].ColumnName = "fax_no"
+	diff := schemadiff.Compare([]*dbstructs.TableMetadata{customers()}, []*dbstructs.TableMetadata{staging})
+
+	script, err := Generate(sqlgen.SQLServer, diff)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"ALTER TABLE [public].[customers] ADD [fax_no] text",
+		"ALTER TABLE [public].[customers] DROP COLUMN [fax]",
+	}, script.Up)
+	assert.Contains(t, script.UpWarnings[0], "the column public.customers.fax may have been renamed to fax_no")
+	guard := "\nGO\n\nIF @@TRANCOUNT = 0 SET NOEXEC ON\nGO\n\n"
+	assert.Equal(t, "SET XACT_ABORT ON\nBEGIN TRANSACTION\nGO\n\n"+script.Up[0]+guard+script.Up[1]+guard+"COMMIT\nGO\n\nSET NOEXEC OFF\nGO\n", script.UpSQL())
+}
@@ -0,0 +1,419 @@
+// Package profiles stores named connection profiles in the config directory of
+// the user. The secrets of a profile, its password, its DSN which may hold
+// credentials, and the SSH passwords and key passphrases, are encrypted with
+// AES-GCM under a key derived from a master passphrase with Argon2id, bound to
+// the profile name. The rest of the profile stays readable, listing the
+// profiles does not need the passphrase.
+package profiles
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"db_meta/databases/registry"
+	"db_meta/databases/sshtunnel"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+)
+
+var (
+	// ErrLocked is returned when secrets are read or written before Unlock.
+	ErrLocked = errors.New("the profiles are locked, enter the master passphrase")
+	// ErrWrongPassphrase is returned by Unlock for another passphrase than the
+	// one the secrets were encrypted with.
+	ErrWrongPassphrase = errors.New("wrong master passphrase")
+	// ErrUnknownProfile is returned for a name without profile.
+	ErrUnknownProfile = errors.New("unknown profile")
+)
+
+// Profile is a saved connection, the arguments of App.ConfigureGorm.
+type Profile struct {
+	Name     string           `json:"name"`
+	DBType   string           `json:"dbType"`
+	Host     string           `json:"host"`
+	Port     string           `json:"port"`
+	Database string           `json:"database"`
+	User     string           `json:"user"`
+	Password string           `json:"password"` // secret
+	Schemas  string           `json:"schemas"`  // comma separated
+	Options  registry.Options `json:"options"`  // DSN, SSH passwords and passphrases are secrets
+}
+
+// secrets are the encrypted fields of a profile, the SSH ones by hop.
+type secrets struct {
+	Password       string   `json:"password"`
+	DSN            string   `json:"dsn,omitempty"`
+	SSHPasswords   []string `json:"sshPasswords"`
+	SSHPassphrases []string `json:"sshPassphrases"`
+}
+
+// split moves the secrets out of the profile.
+func (p Profile) split() (Profile, secrets) {
+	s := secrets{Password: p.Password, DSN: p.Options.DSN}
+	p.Password, p.Options.DSN = "", ""
+	hops := append([]sshtunnel.Hop(nil), p.Options.SSH.Hops...)
+	for i := range hops {
+		s.SSHPasswords = append(s.SSHPasswords, hops[i].Password)
+		s.SSHPassphrases = append(s.SSHPassphrases, hops[i].KeyPassphrase)
+		hops[i].Password, hops[i].KeyPassphrase = "", ""
+	}
+	p.Options.SSH.Hops = hops
+	return p, s
+}
+
+// join puts the secrets back into the profile.
+func (p Profile) join(s secrets) Profile {
+	p.Password, p.Options.DSN = s.Password, s.DSN
+	hops := append([]sshtunnel.Hop(nil), p.Options.SSH.Hops...)
+	for i := range hops {
+		if i < len(s.SSHPasswords) {
+			hops[i].Password = s.SSHPasswords[i]
+		}
+		if i < len(s.SSHPassphrases) {
+			hops[i].KeyPassphrase = s.SSHPassphrases[i]
+		}
+	}
+	p.Options.SSH.Hops = hops
+	return p
+}
+
+func (s secrets) empty() bool {
+	for _, secret := range append(append([]string{s.Password, s.DSN}, s.SSHPasswords...), s.SSHPassphrases...) {
+		if secret != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// kdf are the Argon2id parameters of the key, kept in the file so that they can
+// be raised without losing the existing secrets.
+type kdf struct {
+	Salt    []byte `json:"salt"`
+	Time    uint32 `json:"time"`
+	Memory  uint32 `json:"memory"` // KiB
+	Threads uint8  `json:"threads"`
+}
+
+func newKDF() (kdf, error) {
+	salt := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return kdf{}, err
+	}
+	return kdf{Salt: salt, Time: 1, Memory: 64 * 1024, Threads: 4}, nil
+}
+
+func (k kdf) key(passphrase string) []byte {
+	return argon2.IDKey([]byte(passphrase), k.Salt, k.Time, k.Memory, k.Threads, 32)
+}
+
+// storedProfile is a profile on disk, without its secrets in clear.
+type storedProfile struct {
+	Profile
+	Secrets []byte `json:"secrets,omitempty"` // nonce and AES-GCM sealed secrets
+}
+
+// file is the content of the store.
+type file struct {
+	KDF *kdf `json:"kdf,omitempty"`
+	// Check is a sealed constant, telling a wrong passphrase even without
+	// secrets to open.
+	Check    []byte          `json:"check,omitempty"`
+	Profiles []storedProfile `json:"profiles"`
+}
+
+// checkValue is sealed in file.Check.
+const checkValue = "db_meta profiles"
+
+// Store is the profiles file, safe for concurrent use.
+type Store struct {
+	path string
+
+	mu  sync.Mutex
+	key []byte // set by Unlock
+}
+
+// DefaultPath is profiles.json in the db_meta directory of the user config.
+func DefaultPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "db_meta", "profiles.json"), nil
+}
+
+// NewStore returns the store of the file at path, created on the first save.
+func NewStore(path string) *Store {
+	return &Store{path: path}
+}
+
+func (s *Store) load() (*file, error) {
+	data, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &file{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	f := &file{}
+	if err := json.Unmarshal(data, f); err != nil {
+		return nil, fmt.Errorf("%s: %w", s.path, err)
+	}
+	return f, nil
+}
+
+// write replaces the file through a temporary file, readable by the user only.
+func (s *Store) write(f *file) error {
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// Unlock derives the key of passphrase, the first passphrase given becomes the
+// master passphrase of the store.
+func (s *Store) Unlock(passphrase string) error {
+	if passphrase == "" {
+		return errors.New("the master passphrase can't be empty")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := s.load()
+	if err != nil {
+		return err
+	}
+	if f.KDF == nil {
+		params, err := newKDF()
+		if err != nil {
+			return err
+		}
+		key := params.key(passphrase)
+		check, err := seal(key, []byte(checkValue), nil)
+		if err != nil {
+			return err
+		}
+		f.KDF, f.Check = &params, check
+		if err := s.write(f); err != nil {
+			return err
+		}
+		s.key = key
+		return nil
+	}
+	key := f.KDF.key(passphrase)
+	if _, err := open(key, f.Check, nil); err != nil {
+		return ErrWrongPassphrase
+	}
+	s.key = key
+	return nil
+}
+
+// Lock forgets the key, until the next Unlock.
+func (s *Store) Lock() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.key = nil
+}
+
+// Unlocked tells whether the secrets can be read and written.
+func (s *Store) Unlocked() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.key != nil
+}
+
+// List returns the profiles sorted by name, without their secrets.
+func (s *Store) List() ([]Profile, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+	profiles := make([]Profile, 0, len(f.Profiles))
+	for _, stored := range f.Profiles {
+		profiles = append(profiles, stored.Profile)
+	}
+	return profiles, nil
+}
+
+// Get returns the profile name along with its secrets, ErrLocked if it has some
+// and the store is locked.
+func (s *Store) Get(name string) (Profile, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := s.load()
+	if err != nil {
+		return Profile{}, err
+	}
+	i := index(f.Profiles, name)
+	if i < 0 {
+		return Profile{}, fmt.Errorf("%w %q", ErrUnknownProfile, name)
+	}
+	stored := f.Profiles[i]
+	if stored.Secrets == nil {
+		return stored.Profile, nil
+	}
+	if s.key == nil {
+		return Profile{}, ErrLocked
+	}
+	data, err := open(s.key, stored.Secrets, []byte(name))
+	if err != nil {
+		return Profile{}, fmt.Errorf("profile %q: %w", name, err)
+	}
+	var secret secrets
+	if err := json.Unmarshal(data, &secret); err != nil {
+		return Profile{}, err
+	}
+	return stored.Profile.join(secret), nil
+}
+
+// Save adds profile or replaces the one of the same name. Profiles with secrets
+// need the store to be unlocked.
+func (s *Store) Save(profile Profile) error {
+	if profile.Name == "" {
+		return errors.New("the profile needs a name")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := s.load()
+	if err != nil {
+		return err
+	}
+	public, secret := profile.split()
+	stored := storedProfile{Profile: public}
+	if !secret.empty() {
+		if s.key == nil {
+			return ErrLocked
+		}
+		data, err := json.Marshal(secret)
+		if err != nil {
+			return err
+		}
+		if stored.Secrets, err = seal(s.key, data, []byte(profile.Name)); err != nil {
+			return err
+		}
+	}
+	f.put(stored)
+	return s.write(f)
+}
+
+// Delete removes the profile name.
+func (s *Store) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := s.load()
+	if err != nil {
+		return err
+	}
+	i := index(f.Profiles, name)
+	if i < 0 {
+		return fmt.Errorf("%w %q", ErrUnknownProfile, name)
+	}
+	f.Profiles = append(f.Profiles[:i], f.Profiles[i+1:]...)
+	return s.write(f)
+}
+
+// Export returns the profiles as JSON, without their secrets.
+func (s *Store) Export() ([]byte, error) {
+	profiles, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(profiles, "", "  ")
+}
+
+// Import adds the profiles of an Export, replacing the ones of the same name.
+// Secrets in data are ignored, the replaced profiles lose theirs. It returns the
+// number of profiles imported.
+func (s *Store) Import(data []byte) (int, error) {
+	var profiles []Profile
+	if err := json.Unmarshal(data, &profiles); err != nil {
+		return 0, fmt.Errorf("invalid profiles export: %w", err)
+	}
+	for _, profile := range profiles {
+		if profile.Name == "" {
+			return 0, errors.New("invalid profiles export: a profile has no name")
+		}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := s.load()
+	if err != nil {
+		return 0, err
+	}
+	for _, profile := range profiles {
+		public, _ := profile.split()
+		f.put(storedProfile{Profile: public})
+	}
+	return len(profiles), s.write(f)
+}
+
+// put adds or replaces a profile, keeping them sorted by name.
+func (f *file) put(stored storedProfile) {
+	if i := index(f.Profiles, stored.Name); i >= 0 {
+		f.Profiles[i] = stored
+		return
+	}
+	f.Profiles = append(f.Profiles, stored)
+	sort.Slice(f.Profiles, func(i, j int) bool { return f.Profiles[i].Name < f.Profiles[j].Name })
+}
+
+func index(profiles []storedProfile, name string) int {
+	for i, profile := range profiles {
+		if profile.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// seal encrypts plaintext with AES-GCM, the random nonce first, authenticating
+// additionalData along: open needs the same to decrypt it.
+func seal(key, plaintext, additionalData []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// open decrypts the output of seal.
+func open(key, sealed, additionalData []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < aead.NonceSize() {
+		return nil, errors.New("truncated secrets")
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
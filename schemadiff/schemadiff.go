@@ -0,0 +1,698 @@
+// Package schemadiff compares the tables of two schemas, such as staging and
+// production or a database and one of its snapshots, and reports what turns the
+// source tables into the target tables.
+package schemadiff
+
+import (
+	"db_meta/dbstructs"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Diff lists what changes from the source tables to the target tables.
+type Diff struct {
+	AddedTables   []*dbstructs.TableMetadata `json:"addedTables"`   // in the target only
+	RemovedTables []*dbstructs.TableMetadata `json:"removedTables"` // in the source only
+	ChangedTables []*TableDiff               `json:"changedTables"`
+	// RenameCandidates pair removed and added tables that look alike, they are
+	// still listed among the removed and added tables.
+	RenameCandidates []*RenameCandidate `json:"renameCandidates"`
+}
+
+// Empty tells whether both sets of tables are the same.
+func (d *Diff) Empty() bool {
+	return len(d.AddedTables) == 0 && len(d.RemovedTables) == 0 && len(d.ChangedTables) == 0
+}
+
+// Reverse returns the diff from the target tables back to the source tables.
+func (d *Diff) Reverse() *Diff {
+	reversed := &Diff{AddedTables: d.RemovedTables, RemovedTables: d.AddedTables}
+	for _, table := range d.ChangedTables {
+		reversed.ChangedTables = append(reversed.ChangedTables, table.Reverse())
+	}
+	reversed.RenameCandidates = reverseCandidates(d.RenameCandidates)
+	return reversed
+}
+
+// TableDiff lists the changes of a table found in both sets.
+type TableDiff struct {
+	TableName string                   `json:"tableName"` // qualified name in the target
+	Source    *dbstructs.TableMetadata `json:"-"`
+	Target    *dbstructs.TableMetadata `json:"-"`
+
+	AddedColumns   []*dbstructs.Column `json:"addedColumns,omitempty"`
+	RemovedColumns []*dbstructs.Column `json:"removedColumns,omitempty"`
+	ChangedColumns []*ColumnDiff       `json:"changedColumns,omitempty"`
+	PrimaryKey     *KeyChange          `json:"primaryKey,omitempty"`
+
+	AddedIndexes   []*dbstructs.Index `json:"addedIndexes,omitempty"`
+	RemovedIndexes []*dbstructs.Index `json:"removedIndexes,omitempty"`
+	ChangedIndexes []*IndexDiff       `json:"changedIndexes,omitempty"`
+
+	AddedForeignKeys   []*dbstructs.RelationshipMetadata `json:"addedForeignKeys,omitempty"`
+	RemovedForeignKeys []*dbstructs.RelationshipMetadata `json:"removedForeignKeys,omitempty"`
+	ChangedForeignKeys []*ForeignKeyDiff                 `json:"changedForeignKeys,omitempty"`
+
+	AddedChecks   []*dbstructs.CheckConstraint `json:"addedChecks,omitempty"`
+	RemovedChecks []*dbstructs.CheckConstraint `json:"removedChecks,omitempty"`
+	ChangedChecks []*CheckDiff                 `json:"changedChecks,omitempty"`
+
+	// RenameCandidates pair removed and added columns that look alike
+	RenameCandidates []*RenameCandidate `json:"renameCandidates,omitempty"`
+}
+
+// Reverse returns the changes from the target table back to the source table.
+func (d *TableDiff) Reverse() *TableDiff {
+	reversed := &TableDiff{
+		TableName:          d.Source.QualifiedName(),
+		Source:             d.Target,
+		Target:             d.Source,
+		AddedColumns:       d.RemovedColumns,
+		RemovedColumns:     d.AddedColumns,
+		AddedIndexes:       d.RemovedIndexes,
+		RemovedIndexes:     d.AddedIndexes,
+		AddedForeignKeys:   d.RemovedForeignKeys,
+		RemovedForeignKeys: d.AddedForeignKeys,
+		AddedChecks:        d.RemovedChecks,
+		RemovedChecks:      d.AddedChecks,
+		RenameCandidates:   reverseCandidates(d.RenameCandidates),
+	}
+	for _, column := range d.ChangedColumns {
+		reversed.ChangedColumns = append(reversed.ChangedColumns, &ColumnDiff{
+			ColumnName: column.Source.ColumnName,
+			Source:     column.Target,
+			Target:     column.Source,
+			SourceType: column.TargetType,
+			TargetType: column.SourceType,
+			Changes:    column.Changes,
+		})
+	}
+	if d.PrimaryKey != nil {
+		reversed.PrimaryKey = &KeyChange{Source: d.PrimaryKey.Target, Target: d.PrimaryKey.Source}
+	}
+	for _, index := range d.ChangedIndexes {
+		reversed.ChangedIndexes = append(reversed.ChangedIndexes, &IndexDiff{Name: index.Source.Name, Source: index.Target, Target: index.Source, Changes: index.Changes})
+	}
+	for _, fk := range d.ChangedForeignKeys {
+		reversed.ChangedForeignKeys = append(reversed.ChangedForeignKeys, &ForeignKeyDiff{Name: fk.Source.Conname, Source: fk.Target, Target: fk.Source, Changes: fk.Changes})
+	}
+	for _, check := range d.ChangedChecks {
+		reversed.ChangedChecks = append(reversed.ChangedChecks, &CheckDiff{Name: check.Source.Name, Source: check.Target, Target: check.Source, Changes: check.Changes})
+	}
+	return reversed
+}
+
+func reverseCandidates(candidates []*RenameCandidate) []*RenameCandidate {
+	var reversed []*RenameCandidate
+	for _, candidate := range candidates {
+		reversed = append(reversed, &RenameCandidate{Table: candidate.Table, From: candidate.To, To: candidate.From, Similarity: candidate.Similarity})
+	}
+	return reversed
+}
+
+func (d *TableDiff) empty() bool {
+	return len(d.AddedColumns) == 0 && len(d.RemovedColumns) == 0 && len(d.ChangedColumns) == 0 && d.PrimaryKey == nil &&
+		len(d.AddedIndexes) == 0 && len(d.RemovedIndexes) == 0 && len(d.ChangedIndexes) == 0 &&
+		len(d.AddedForeignKeys) == 0 && len(d.RemovedForeignKeys) == 0 && len(d.ChangedForeignKeys) == 0 &&
+		len(d.AddedChecks) == 0 && len(d.RemovedChecks) == 0 && len(d.ChangedChecks) == 0
+}
+
+// What changed in a column, an index, a foreign key or a check
+const (
+	ChangeType          = "type" // data type, length, precision, scale or enum values
+	ChangeNullability   = "nullability"
+	ChangeDefault       = "default"
+	ChangeAutoIncrement = "auto_increment"
+	ChangeGenerated     = "generated"
+
+	ChangeName       = "name" // matched by definition, under another name
+	ChangeColumns    = "columns"
+	ChangeUnique     = "unique"
+	ChangeMethod     = "method"
+	ChangePredicate  = "predicate"
+	ChangeInclude    = "include"
+	ChangeTarget     = "target" // referenced table or columns
+	ChangeOnDelete   = "on_delete"
+	ChangeOnUpdate   = "on_update"
+	ChangeDeferrable = "deferrable"
+	ChangeExpression = "expression"
+)
+
+// ColumnDiff is a column found in both tables, Changes holds the Change* values.
+type ColumnDiff struct {
+	ColumnName string            `json:"columnName"`
+	Source     *dbstructs.Column `json:"source"`
+	Target     *dbstructs.Column `json:"target"`
+	SourceType string            `json:"sourceType"` // as spelled by ColumnType
+	TargetType string            `json:"targetType"`
+	Changes    []string          `json:"changes"`
+}
+
+// KeyChange is a primary key whose columns changed, an empty list when the
+// table has none.
+type KeyChange struct {
+	Source []string `json:"source"`
+	Target []string `json:"target"`
+}
+
+type IndexDiff struct {
+	Name    string           `json:"name"` // in the target
+	Source  *dbstructs.Index `json:"source"`
+	Target  *dbstructs.Index `json:"target"`
+	Changes []string         `json:"changes"`
+}
+
+type ForeignKeyDiff struct {
+	Name    string                          `json:"name"` // in the target
+	Source  *dbstructs.RelationshipMetadata `json:"source"`
+	Target  *dbstructs.RelationshipMetadata `json:"target"`
+	Changes []string                        `json:"changes"`
+}
+
+type CheckDiff struct {
+	Name    string                     `json:"name"` // in the target
+	Source  *dbstructs.CheckConstraint `json:"source"`
+	Target  *dbstructs.CheckConstraint `json:"target"`
+	Changes []string                   `json:"changes"`
+}
+
+// RenameCandidate is a removed table or column that could have been renamed to
+// an added one.
+type RenameCandidate struct {
+	Table      string  `json:"table,omitempty"` // table of the columns, empty for tables
+	From       string  `json:"from"`
+	To         string  `json:"to"`
+	Similarity float64 `json:"similarity"` // from RenameThreshold to 1
+}
+
+// RenameThreshold is the similarity from which a removed and an added table or
+// column are reported as a rename candidate.
+const RenameThreshold = 0.6
+
+// Compare reports the changes from the source tables to the target tables.
+// Tables are matched by qualified name, then by name alone between schemas that
+// differ; columns, indexes, foreign keys and checks by name, ignoring case, and
+// the indexes, foreign keys and checks left over by definition.
+func Compare(source, target []*dbstructs.TableMetadata) *Diff {
+	diff := &Diff{}
+	pairs := matchTables(source, target)
+	matchedSource := make(map[*dbstructs.TableMetadata]bool)
+	for _, targetTable := range target {
+		sourceTable, ok := pairs[targetTable]
+		if !ok {
+			diff.AddedTables = append(diff.AddedTables, targetTable)
+			continue
+		}
+		matchedSource[sourceTable] = true
+		if tableDiff := compareTables(sourceTable, targetTable); !tableDiff.empty() {
+			diff.ChangedTables = append(diff.ChangedTables, tableDiff)
+		}
+	}
+	for _, sourceTable := range source {
+		if !matchedSource[sourceTable] {
+			diff.RemovedTables = append(diff.RemovedTables, sourceTable)
+		}
+	}
+
+	diff.RenameCandidates = renameCandidates(len(diff.RemovedTables), len(diff.AddedTables), func(i, j int) (string, string, float64) {
+		from, to := diff.RemovedTables[i], diff.AddedTables[j]
+		similarity := 0.4*nameSimilarity(from.TableName, to.TableName) + 0.6*setSimilarity(from.ColumnNames(), to.ColumnNames())
+		return from.QualifiedName(), to.QualifiedName(), similarity
+	})
+	return diff
+}
+
+// matchTables returns the source table of each target table found in both.
+func matchTables(source, target []*dbstructs.TableMetadata) map[*dbstructs.TableMetadata]*dbstructs.TableMetadata {
+	pairs := make(map[*dbstructs.TableMetadata]*dbstructs.TableMetadata)
+	byName := make(map[string]*dbstructs.TableMetadata)
+	for _, table := range source {
+		byName[strings.ToLower(table.QualifiedName())] = table
+	}
+	matched := make(map[*dbstructs.TableMetadata]bool)
+	for _, table := range target {
+		if sourceTable, ok := byName[strings.ToLower(table.QualifiedName())]; ok {
+			pairs[table] = sourceTable
+			matched[sourceTable] = true
+		}
+	}
+
+	// public.orders and orders, or staging.orders and prod.orders: the names
+	// match when a single table of each side has it
+	count := func(tables []*dbstructs.TableMetadata, skip func(*dbstructs.TableMetadata) bool) map[string][]*dbstructs.TableMetadata {
+		tablesByName := make(map[string][]*dbstructs.TableMetadata)
+		for _, table := range tables {
+			if !skip(table) {
+				name := strings.ToLower(table.TableName)
+				tablesByName[name] = append(tablesByName[name], table)
+			}
+		}
+		return tablesByName
+	}
+	leftSource := count(source, func(table *dbstructs.TableMetadata) bool { return matched[table] })
+	leftTarget := count(target, func(table *dbstructs.TableMetadata) bool { _, ok := pairs[table]; return ok })
+	for name, targetTables := range leftTarget {
+		if sourceTables := leftSource[name]; len(sourceTables) == 1 && len(targetTables) == 1 {
+			pairs[targetTables[0]] = sourceTables[0]
+		}
+	}
+	return pairs
+}
+
+func compareTables(source, target *dbstructs.TableMetadata) *TableDiff {
+	diff := &TableDiff{TableName: target.QualifiedName(), Source: source, Target: target}
+	compareColumns(diff)
+	if !sameNames(source.PrimaryKey, target.PrimaryKey) {
+		diff.PrimaryKey = &KeyChange{Source: nonNil(source.PrimaryKey), Target: nonNil(target.PrimaryKey)}
+	}
+	compareIndexes(diff)
+	compareForeignKeys(diff)
+	compareChecks(diff)
+	return diff
+}
+
+func compareColumns(diff *TableDiff) {
+	sourceColumns := make(map[string]*dbstructs.Column)
+	for _, column := range diff.Source.Columns {
+		sourceColumns[strings.ToLower(column.ColumnName)] = column
+	}
+	matched := make(map[*dbstructs.Column]bool)
+	for _, target := range diff.Target.Columns {
+		source, ok := sourceColumns[strings.ToLower(target.ColumnName)]
+		if !ok {
+			diff.AddedColumns = append(diff.AddedColumns, target)
+			continue
+		}
+		matched[source] = true
+		if changes := columnChanges(source, target); len(changes) > 0 {
+			diff.ChangedColumns = append(diff.ChangedColumns, &ColumnDiff{
+				ColumnName: target.ColumnName,
+				Source:     source,
+				Target:     target,
+				SourceType: ColumnType(source),
+				TargetType: ColumnType(target),
+				Changes:    changes,
+			})
+		}
+	}
+	for _, source := range diff.Source.Columns {
+		if !matched[source] {
+			diff.RemovedColumns = append(diff.RemovedColumns, source)
+		}
+	}
+
+	diff.RenameCandidates = renameCandidates(len(diff.RemovedColumns), len(diff.AddedColumns), func(i, j int) (string, string, float64) {
+		from, to := diff.RemovedColumns[i], diff.AddedColumns[j]
+		similarity := 0.6 * nameSimilarity(from.ColumnName, to.ColumnName)
+		if sameType(from, to) {
+			similarity += 0.3
+		}
+		if from.OrdinalPosition != 0 && from.OrdinalPosition == to.OrdinalPosition {
+			similarity += 0.1
+		}
+		return from.ColumnName, to.ColumnName, similarity
+	})
+	for _, candidate := range diff.RenameCandidates {
+		candidate.Table = diff.TableName
+	}
+}
+
+func columnChanges(source, target *dbstructs.Column) []string {
+	var changes []string
+	if !sameType(source, target) {
+		changes = append(changes, ChangeType)
+	}
+	if source.NotNull != target.NotNull {
+		changes = append(changes, ChangeNullability)
+	}
+	if !sameExpression(source.Default, target.Default) {
+		changes = append(changes, ChangeDefault)
+	}
+	if source.AutoIncrement != target.AutoIncrement {
+		changes = append(changes, ChangeAutoIncrement)
+	}
+	if normalize(source.Generated) != normalize(target.Generated) {
+		changes = append(changes, ChangeGenerated)
+	}
+	return changes
+}
+
+func sameType(source, target *dbstructs.Column) bool {
+	return strings.EqualFold(source.DataType, target.DataType) &&
+		sameInt(source.CharacterLength, target.CharacterLength) &&
+		sameInt(source.NumericPrecision, target.NumericPrecision) &&
+		sameInt(source.NumericScale, target.NumericScale) &&
+		strings.EqualFold(source.UserType, target.UserType) &&
+		sameValues(source.EnumValues, target.EnumValues)
+}
+
+// ColumnType spells the type of column with its length, or precision and scale:
+// "character varying(255)", "numeric(10,2)", "enum('a','b')".
+func ColumnType(column *dbstructs.Column) string {
+	dataType := column.DataType
+	if column.UserType != "" && (dataType == "" || dataType == "USER-DEFINED") {
+		return column.UserType
+	}
+	if strings.Contains(dataType, "(") {
+		// SQLite keeps the declared type
+		return dataType
+	}
+	lowerType := strings.ToLower(dataType)
+	switch {
+	case len(column.EnumValues) > 0:
+		return fmt.Sprintf("%s('%s')", dataType, strings.Join(column.EnumValues, "','"))
+	case column.CharacterLength != nil && *column.CharacterLength < 0:
+		return dataType + "(max)"
+	case column.CharacterLength != nil:
+		return fmt.Sprintf("%s(%d)", dataType, *column.CharacterLength)
+	case column.NumericPrecision != nil && (strings.Contains(lowerType, "numeric") || strings.Contains(lowerType, "decimal")):
+		if column.NumericScale != nil {
+			return fmt.Sprintf("%s(%d,%d)", dataType, *column.NumericPrecision, *column.NumericScale)
+		}
+		return fmt.Sprintf("%s(%d)", dataType, *column.NumericPrecision)
+	}
+	return dataType
+}
+
+// compareIndexes compares the indexes but for the primary key one, the primary
+// key columns are compared apart.
+func compareIndexes(diff *TableDiff) {
+	var source, target []*dbstructs.Index
+	for _, index := range diff.Source.Indexes {
+		if !index.Primary {
+			source = append(source, index)
+		}
+	}
+	for _, index := range diff.Target.Indexes {
+		if !index.Primary {
+			target = append(target, index)
+		}
+	}
+	match(len(source), len(target),
+		func(i, j int) bool { return strings.EqualFold(source[i].Name, target[j].Name) },
+		func(i, j int) bool { return len(indexChanges(source[i], target[j])) == 0 },
+		func(i, j int) {
+			changes := indexChanges(source[i], target[j])
+			if !strings.EqualFold(source[i].Name, target[j].Name) {
+				changes = append([]string{ChangeName}, changes...)
+			}
+			if len(changes) > 0 {
+				diff.ChangedIndexes = append(diff.ChangedIndexes, &IndexDiff{Name: target[j].Name, Source: source[i], Target: target[j], Changes: changes})
+			}
+		},
+		func(i int) { diff.RemovedIndexes = append(diff.RemovedIndexes, source[i]) },
+		func(j int) { diff.AddedIndexes = append(diff.AddedIndexes, target[j]) },
+	)
+}
+
+func indexChanges(source, target *dbstructs.Index) []string {
+	var changes []string
+	if !sameNames(source.Columns, target.Columns) || !sameDirections(source.Keys, target.Keys) {
+		changes = append(changes, ChangeColumns)
+	}
+	if source.Unique != target.Unique {
+		changes = append(changes, ChangeUnique)
+	}
+	// connectors that don't report the method leave it empty
+	if source.Method != "" && target.Method != "" && !strings.EqualFold(source.Method, target.Method) {
+		changes = append(changes, ChangeMethod)
+	}
+	if normalize(source.Predicate) != normalize(target.Predicate) {
+		changes = append(changes, ChangePredicate)
+	}
+	if !sameNames(source.Include, target.Include) {
+		changes = append(changes, ChangeInclude)
+	}
+	return changes
+}
+
+func sameDirections(source, target []*dbstructs.IndexKey) bool {
+	if len(source) != len(target) {
+		// a connector without keys, the columns were compared
+		return len(source) == 0 || len(target) == 0
+	}
+	for i := range source {
+		if source[i].Descending != target[i].Descending {
+			return false
+		}
+	}
+	return true
+}
+
+// compareForeignKeys compares the foreign keys the tables hold, not the ones
+// some connectors also list on the referenced table.
+func compareForeignKeys(diff *TableDiff) {
+	source := ownForeignKeys(diff.Source)
+	target := ownForeignKeys(diff.Target)
+	match(len(source), len(target),
+		func(i, j int) bool {
+			return source[i].Conname != "" && strings.EqualFold(source[i].Conname, target[j].Conname)
+		},
+		func(i, j int) bool { return len(foreignKeyChanges(source[i], target[j])) == 0 },
+		func(i, j int) {
+			changes := foreignKeyChanges(source[i], target[j])
+			if !strings.EqualFold(source[i].Conname, target[j].Conname) {
+				changes = append([]string{ChangeName}, changes...)
+			}
+			if len(changes) > 0 {
+				diff.ChangedForeignKeys = append(diff.ChangedForeignKeys, &ForeignKeyDiff{Name: target[j].Conname, Source: source[i], Target: target[j], Changes: changes})
+			}
+		},
+		func(i int) { diff.RemovedForeignKeys = append(diff.RemovedForeignKeys, source[i]) },
+		func(j int) { diff.AddedForeignKeys = append(diff.AddedForeignKeys, target[j]) },
+	)
+}
+
+func ownForeignKeys(table *dbstructs.TableMetadata) []*dbstructs.RelationshipMetadata {
+	var relationships []*dbstructs.RelationshipMetadata
+	for _, relationship := range table.Relationships {
+		if relationship.SourceTableName == "" || strings.EqualFold(relationship.SourceTableName, table.TableName) {
+			relationships = append(relationships, relationship)
+		}
+	}
+	return relationships
+}
+
+func foreignKeyChanges(source, target *dbstructs.RelationshipMetadata) []string {
+	var changes []string
+	if !sameNames(source.SourceColumns, target.SourceColumns) {
+		changes = append(changes, ChangeColumns)
+	}
+	// the schemas are compared when both sides have some
+	sameSchema := source.RelatedSchema == "" || target.RelatedSchema == "" || strings.EqualFold(source.RelatedSchema, target.RelatedSchema)
+	if !sameSchema || !strings.EqualFold(source.RelatedTableName, target.RelatedTableName) || !sameNames(source.TargetColumns, target.TargetColumns) {
+		changes = append(changes, ChangeTarget)
+	}
+	if referentialAction(source.OnDelete) != referentialAction(target.OnDelete) {
+		changes = append(changes, ChangeOnDelete)
+	}
+	if referentialAction(source.OnUpdate) != referentialAction(target.OnUpdate) {
+		changes = append(changes, ChangeOnUpdate)
+	}
+	if source.Deferrable != target.Deferrable || source.InitiallyDeferred != target.InitiallyDeferred {
+		changes = append(changes, ChangeDeferrable)
+	}
+	return changes
+}
+
+func referentialAction(action string) string {
+	if action == "" {
+		return dbstructs.ReferentialActionNoAction
+	}
+	return strings.ToUpper(action)
+}
+
+func compareChecks(diff *TableDiff) {
+	source, target := diff.Source.CheckConstraints, diff.Target.CheckConstraints
+	sameExpression := func(i, j int) bool { return normalize(source[i].Expression) == normalize(target[j].Expression) }
+	match(len(source), len(target),
+		func(i, j int) bool { return strings.EqualFold(source[i].Name, target[j].Name) },
+		sameExpression,
+		func(i, j int) {
+			var changes []string
+			if !strings.EqualFold(source[i].Name, target[j].Name) {
+				changes = append(changes, ChangeName)
+			}
+			if !sameExpression(i, j) {
+				changes = append(changes, ChangeExpression)
+			}
+			if len(changes) > 0 {
+				diff.ChangedChecks = append(diff.ChangedChecks, &CheckDiff{Name: target[j].Name, Source: source[i], Target: target[j], Changes: changes})
+			}
+		},
+		func(i int) { diff.RemovedChecks = append(diff.RemovedChecks, source[i]) },
+		func(j int) { diff.AddedChecks = append(diff.AddedChecks, target[j]) },
+	)
+}
+
+// match pairs the source and target items by name, then the ones left by
+// definition, and calls pair, removed or added for each of them in order.
+func match(sourceCount, targetCount int, sameName, sameDefinition func(i, j int) bool, pair func(i, j int), removed func(i int), added func(j int)) {
+	sourceOf := make([]int, targetCount)
+	matched := make([]bool, sourceCount)
+	for j := range sourceOf {
+		sourceOf[j] = -1
+	}
+	for _, same := range []func(i, j int) bool{sameName, sameDefinition} {
+		for j := 0; j < targetCount; j++ {
+			for i := 0; i < sourceCount && sourceOf[j] < 0; i++ {
+				if !matched[i] && same(i, j) {
+					sourceOf[j], matched[i] = i, true
+				}
+			}
+		}
+	}
+	for j, i := range sourceOf {
+		if i < 0 {
+			added(j)
+		} else {
+			pair(i, j)
+		}
+	}
+	for i := range matched {
+		if !matched[i] {
+			removed(i)
+		}
+	}
+}
+
+// renameCandidates pairs removed and added items whose similarity reaches
+// RenameThreshold, the most similar first, each item in a single pair.
+func renameCandidates(removedCount, addedCount int, similarity func(i, j int) (string, string, float64)) []*RenameCandidate {
+	type scored struct {
+		i, j      int
+		candidate *RenameCandidate
+	}
+	var pairs []scored
+	for i := 0; i < removedCount; i++ {
+		for j := 0; j < addedCount; j++ {
+			from, to, value := similarity(i, j)
+			if value >= RenameThreshold {
+				pairs = append(pairs, scored{i, j, &RenameCandidate{From: from, To: to, Similarity: round(value)}})
+			}
+		}
+	}
+	sort.SliceStable(pairs, func(a, b int) bool { return pairs[a].candidate.Similarity > pairs[b].candidate.Similarity })
+
+	var candidates []*RenameCandidate
+	usedRemoved, usedAdded := make(map[int]bool), make(map[int]bool)
+	for _, pair := range pairs {
+		if !usedRemoved[pair.i] && !usedAdded[pair.j] {
+			usedRemoved[pair.i], usedAdded[pair.j] = true, true
+			candidates = append(candidates, pair.candidate)
+		}
+	}
+	return candidates
+}
+
+// nameSimilarity is the Dice coefficient of the character pairs of both names,
+// ignoring case: 1 for the same names, 0 for names without common pair.
+func nameSimilarity(a, b string) float64 {
+	a, b = strings.ToLower(a), strings.ToLower(b)
+	if a == b {
+		return 1
+	}
+	pairs := func(s string) map[string]int {
+		counts := make(map[string]int)
+		runes := []rune(s)
+		for i := 0; i+1 < len(runes); i++ {
+			counts[string(runes[i:i+2])]++
+		}
+		return counts
+	}
+	aPairs, bPairs := pairs(a), pairs(b)
+	total, common := 0, 0
+	for pair, count := range aPairs {
+		total += count
+		if other := bPairs[pair]; other < count {
+			common += other
+		} else {
+			common += count
+		}
+	}
+	for _, count := range bPairs {
+		total += count
+	}
+	if total == 0 {
+		return 0
+	}
+	return 2 * float64(common) / float64(total)
+}
+
+// setSimilarity is the Jaccard index of both sets of names, ignoring case.
+func setSimilarity(a, b []string) float64 {
+	set := make(map[string]bool)
+	for _, name := range a {
+		set[strings.ToLower(name)] = true
+	}
+	union, common := len(set), 0
+	seen := make(map[string]bool)
+	for _, name := range b {
+		name = strings.ToLower(name)
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		if set[name] {
+			common++
+		} else {
+			union++
+		}
+	}
+	if union == 0 {
+		return 0
+	}
+	return float64(common) / float64(union)
+}
+
+func round(value float64) float64 {
+	return float64(int(value*100+0.5)) / 100
+}
+
+func sameNames(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !strings.EqualFold(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func sameValues(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func sameInt(a, b *int) bool {
+	return a == nil && b == nil || a != nil && b != nil && *a == *b
+}
+
+func sameExpression(a, b *string) bool {
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return normalize(*a) == normalize(*b)
+}
+
+// normalize collapses the whitespace of an expression.
+func normalize(expression string) string {
+	return strings.Join(strings.Fields(expression), " ")
+}
+
+func nonNil(names []string) []string {
+	if names == nil {
+		return []string{}
+	}
+	return names
+}
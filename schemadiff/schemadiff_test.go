@@ -0,0 +1,205 @@
+package schemadiff
+
+import (
+	"db_meta/dbstructs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func intPointer(value int) *int {
+	return &value
+}
+
+func stringPointer(value string) *string {
+	return &value
+}
+
+func productionTables() []*dbstructs.TableMetadata {
+	return []*dbstructs.TableMetadata{
+		{
+			Schema:    "public",
+			TableName: "customers",
+			Columns: []*dbstructs.Column{
+				{ColumnName: "id", DataType: "integer", NotNull: true, OrdinalPosition: 1},
+				{ColumnName: "email", DataType: "character varying", CharacterLength: intPointer(100), OrdinalPosition: 2},
+				{ColumnName: "fax", DataType: "text", OrdinalPosition: 3},
+			},
+			PrimaryKey: []string{"id"},
+			Indexes: []*dbstructs.Index{
+				{Name: "customers_pkey", Columns: []string{"id"}, Unique: true, Primary: true, Method: "btree"},
+				{Name: "customers_email_key", Columns: []string{"email"}, Unique: true, Method: "btree"},
+			},
+		},
+		{
+			Schema:    "public",
+			TableName: "orders",
+			Columns: []*dbstructs.Column{
+				{ColumnName: "id", DataType: "integer", NotNull: true, OrdinalPosition: 1},
+				{ColumnName: "customer_id", DataType: "integer", OrdinalPosition: 2},
+				{ColumnName: "total", DataType: "numeric", NumericPrecision: intPointer(10), NumericScale: intPointer(2), OrdinalPosition: 3},
+				{ColumnName: "status", DataType: "text", Default: stringPointer("'new'::text"), OrdinalPosition: 4},
+			},
+			PrimaryKey: []string{"id"},
+			Indexes: []*dbstructs.Index{
+				{Name: "orders_pkey", Columns: []string{"id"}, Unique: true, Primary: true, Method: "btree"},
+				{Name: "orders_customer", Columns: []string{"customer_id"}, Method: "btree"},
+			},
+			Relationships: []*dbstructs.RelationshipMetadata{{
+				Conname: "orders_customer_id_fkey", SourceSchema: "public", SourceTableName: "orders",
+				RelatedSchema: "public", RelatedTableName: "customers",
+				SourceColumns: []string{"customer_id"}, TargetColumns: []string{"id"},
+				OnDelete: dbstructs.ReferentialActionNoAction, OnUpdate: dbstructs.ReferentialActionNoAction,
+			}},
+			CheckConstraints: []*dbstructs.CheckConstraint{{Name: "orders_total_check", Columns: []string{"total"}, Expression: "(total >= 0)"}},
+		},
+		{
+			Schema:    "public",
+			TableName: "audit",
+			Columns: []*dbstructs.Column{
+				{ColumnName: "id", DataType: "integer", NotNull: true, OrdinalPosition: 1},
+				{ColumnName: "payload", DataType: "jsonb", OrdinalPosition: 2},
+			},
+		},
+	}
+}
+
+func TestCompare_same(t *testing.T) {
+	diff := Compare(productionTables(), productionTables())
+	assert.True(t, diff.Empty())
+	assert.Empty(t, diff.RenameCandidates)
+}
+
+func TestCompare(t *testing.T) {
+	production := productionTables()
+	staging := productionTables()
+	customers, orders := staging[0], staging[1]
+
+	// customers: email widened and NOT NULL, fax dropped, phone added
+	customers.Columns[1].CharacterLength = intPointer(255)
+	customers.Columns[1].NotNull = true
+	customers.Columns = append(customers.Columns[:2], &dbstructs.Column{ColumnName: "phone", DataType: "text", OrdinalPosition: 3})
+
+	// orders: composite key, the index renamed, the foreign key cascades and the
+	// default and check changed
+	orders.PrimaryKey = []string{"id", "customer_id"}
+	orders.Indexes[1].Name = "ix_orders_customer_id"
+	orders.Indexes = append(orders.Indexes, &dbstructs.Index{Name: "orders_status", Columns: []string{"status"}, Method: "btree"})
+	orders.Relationships[0].OnDelete = dbstructs.ReferentialActionCascade
+	orders.Columns[3].Default = stringPointer("'pending'::text")
+	orders.CheckConstraints[0].Expression = "(total > 0)"
+
+	// audit renamed to audit_log, events added
+	staging[2] = &dbstructs.TableMetadata{Schema: "public", TableName: "audit_log", Columns: staging[2].Columns}
+	staging = append(staging, &dbstructs.TableMetadata{Schema: "public", TableName: "events", Columns: []*dbstructs.Column{
+		{ColumnName: "name", DataType: "text", OrdinalPosition: 1},
+	}})
+
+	diff := Compare(production, staging)
+	assert.False(t, diff.Empty())
+	if assert.Len(t, diff.AddedTables, 2) {
+		assert.Equal(t, "audit_log", diff.AddedTables[0].TableName)
+		assert.Equal(t, "events", diff.AddedTables[1].TableName)
+	}
+	if assert.Len(t, diff.RemovedTables, 1) {
+		assert.Equal(t, "audit", diff.RemovedTables[0].TableName)
+	}
+	assert.Equal(t, []*RenameCandidate{{From: "public.audit", To: "public.audit_log", Similarity: 0.87}}, diff.RenameCandidates)
+
+	if !assert.Len(t, diff.ChangedTables, 2) {
+		return
+	}
+	customersDiff := diff.ChangedTables[0]
+	assert.Equal(t, "public.customers", customersDiff.TableName)
+	if assert.Len(t, customersDiff.ChangedColumns, 1) {
+		column := customersDiff.ChangedColumns[0]
+		assert.Equal(t, "email", column.ColumnName)
+		assert.Equal(t, []string{ChangeType, ChangeNullability}, column.Changes)
+		assert.Equal(t, "character varying(100)", column.SourceType)
+		assert.Equal(t, "character varying(255)", column.TargetType)
+	}
+	assert.Equal(t, "phone", customersDiff.AddedColumns[0].ColumnName)
+	assert.Equal(t, "fax", customersDiff.RemovedColumns[0].ColumnName)
+	// same type and position, but names too far apart
+	assert.Empty(t, customersDiff.RenameCandidates)
+	assert.Nil(t, customersDiff.PrimaryKey)
+
+	ordersDiff := diff.ChangedTables[1]
+	assert.Equal(t, &KeyChange{Source: []string{"id"}, Target: []string{"id", "customer_id"}}, ordersDiff.PrimaryKey)
+	if assert.Len(t, ordersDiff.ChangedColumns, 1) {
+		assert.Equal(t, []string{ChangeDefault}, ordersDiff.ChangedColumns[0].Changes)
+	}
+	if assert.Len(t, ordersDiff.ChangedIndexes, 1) {
+		assert.Equal(t, "ix_orders_customer_id", ordersDiff.ChangedIndexes[0].Name)
+		assert.Equal(t, []string{ChangeName}, ordersDiff.ChangedIndexes[0].Changes)
+	}
+	if assert.Len(t, ordersDiff.AddedIndexes, 1) {
+		assert.Equal(t, "orders_status", ordersDiff.AddedIndexes[0].Name)
+	}
+	assert.Empty(t, ordersDiff.RemovedIndexes)
+	if assert.Len(t, ordersDiff.ChangedForeignKeys, 1) {
+		assert.Equal(t, []string{ChangeOnDelete}, ordersDiff.ChangedForeignKeys[0].Changes)
+	}
+	if assert.Len(t, ordersDiff.ChangedChecks, 1) {
+		assert.Equal(t, []string{ChangeExpression}, ordersDiff.ChangedChecks[0].Changes)
+	}
+}
+
+func TestDiff_Reverse(t *testing.T) {
+	production := productionTables()
+	staging := productionTables()
+	staging[0].Columns[1].CharacterLength = intPointer(255)
+	staging[1].Indexes[1].Name = "ix_orders_customer_id"
+	staging = staging[:2]
+
+	reversed := Compare(production, staging).Reverse()
+	expected := Compare(staging, production)
+	assert.Equal(t, expected.AddedTables, reversed.AddedTables)
+	assert.Equal(t, expected.RemovedTables, reversed.RemovedTables)
+	if assert.Len(t, reversed.ChangedTables, 2) {
+		assert.Equal(t, expected.ChangedTables[0].ChangedColumns, reversed.ChangedTables[0].ChangedColumns)
+		assert.Equal(t, expected.ChangedTables[1].ChangedIndexes, reversed.ChangedTables[1].ChangedIndexes)
+	}
+}
+
+func TestCompare_columnRenames(t *testing.T) {
+	source := []*dbstructs.TableMetadata{{TableName: "users", Columns: []*dbstructs.Column{
+		{ColumnName: "id", DataType: "INTEGER", OrdinalPosition: 1},
+		{ColumnName: "mail", DataType: "TEXT", OrdinalPosition: 2},
+		{ColumnName: "created", DataType: "TEXT", OrdinalPosition: 3},
+	}}}
+	target := []*dbstructs.TableMetadata{{TableName: "users", Columns: []*dbstructs.Column{
+		{ColumnName: "id", DataType: "INTEGER", OrdinalPosition: 1},
+		{ColumnName: "email", DataType: "TEXT", OrdinalPosition: 2},
+		{ColumnName: "created_at", DataType: "TEXT", OrdinalPosition: 3},
+	}}}
+	diff := Compare(source, target)
+	if assert.Len(t, diff.ChangedTables, 1) {
+		assert.Equal(t, []*RenameCandidate{
+			{Table: "users", From: "mail", To: "email", Similarity: 0.91},
+			{Table: "users", From: "created", To: "created_at", Similarity: 0.88},
+		}, diff.ChangedTables[0].RenameCandidates)
+	}
+}
+
+func TestCompare_schemas(t *testing.T) {
+	// a schema against a SQLite copy without schema
+	source := productionTables()
+	target := productionTables()
+	for _, table := range target {
+		table.Schema = ""
+		for _, relationship := range table.Relationships {
+			relationship.SourceSchema, relationship.RelatedSchema = "", ""
+		}
+	}
+	assert.True(t, Compare(source, target).Empty())
+}
+
+func TestColumnType(t *testing.T) {
+	assert.Equal(t, "numeric(10,2)", ColumnType(&dbstructs.Column{DataType: "numeric", NumericPrecision: intPointer(10), NumericScale: intPointer(2)}))
+	assert.Equal(t, "integer", ColumnType(&dbstructs.Column{DataType: "integer", NumericPrecision: intPointer(32), NumericScale: intPointer(0)}))
+	assert.Equal(t, "nvarchar(max)", ColumnType(&dbstructs.Column{DataType: "nvarchar", CharacterLength: intPointer(-1)}))
+	assert.Equal(t, "enum('a','b')", ColumnType(&dbstructs.Column{DataType: "enum", EnumValues: []string{"a", "b"}}))
+	assert.Equal(t, "public.mood", ColumnType(&dbstructs.Column{DataType: "USER-DEFINED", UserType: "public.mood"}))
+	assert.Equal(t, "VARCHAR(20)", ColumnType(&dbstructs.Column{DataType: "VARCHAR(20)", CharacterLength: intPointer(20)}))
+}
@@ -0,0 +1,56 @@
+// Package snapshot saves the metadata loaded from a database as a versioned
+// JSON document, so that the schema can be looked at without the database.
+package snapshot
+
+import (
+	"db_meta/dbstructs"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// FormatVersion is the version of the snapshots written by this build. It is
+// raised when a change of the format can't be read by older builds.
+const FormatVersion = 1
+
+// Snapshot is the metadata of a database at CapturedAt.
+type Snapshot struct {
+	FormatVersion int       `json:"formatVersion"`
+	DBType        string    `json:"dbType"`        // registry name of the connector
+	ServerVersion string    `json:"serverVersion"` // as reported by the server
+	CapturedAt    time.Time `json:"capturedAt"`
+	Schemas       []string  `json:"schemas,omitempty"` // schemas loaded, connector default when empty
+
+	Tables    []*dbstructs.TableMetadata    `json:"tables"`
+	Views     []*dbstructs.ViewMetadata     `json:"views,omitempty"`
+	Types     []*dbstructs.TypeMetadata     `json:"types,omitempty"`
+	Triggers  []*dbstructs.TriggerMetadata  `json:"triggers,omitempty"`
+	Routines  []*dbstructs.RoutineMetadata  `json:"routines,omitempty"`
+	Sequences []*dbstructs.SequenceMetadata `json:"sequences,omitempty"`
+}
+
+// Write encodes the snapshot to w, stamped with FormatVersion.
+func (s *Snapshot) Write(w io.Writer) error {
+	s.FormatVersion = FormatVersion
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(s)
+}
+
+// Read decodes a snapshot, rejecting documents without format version and the
+// ones written by a newer format.
+func Read(r io.Reader) (*Snapshot, error) {
+	s := &Snapshot{}
+	if err := json.NewDecoder(r).Decode(s); err != nil {
+		return nil, fmt.Errorf("invalid snapshot: %w", err)
+	}
+	switch {
+	case s.FormatVersion <= 0:
+		return nil, errors.New("invalid snapshot: no format version")
+	case s.FormatVersion > FormatVersion:
+		return nil, fmt.Errorf("the snapshot format %d is newer than this version of db_meta (%d)", s.FormatVersion, FormatVersion)
+	}
+	return s, nil
+}
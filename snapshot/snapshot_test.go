@@ -0,0 +1,46 @@
+package snapshot
+
+import (
+	"bytes"
+	"db_meta/dbstructs"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshot_WriteRead(t *testing.T) {
+	capturedAt := time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC)
+	s := &Snapshot{
+		DBType:        "postgres",
+		ServerVersion: "16.2",
+		CapturedAt:    capturedAt,
+		Schemas:       []string{"public"},
+		Tables: []*dbstructs.TableMetadata{{
+			Schema:     "public",
+			TableName:  "customers",
+			Columns:    []*dbstructs.Column{{ColumnName: "id", DataType: "integer", NotNull: true}},
+			PrimaryKey: []string{"id"},
+		}},
+	}
+	var data bytes.Buffer
+	assert.NoError(t, s.Write(&data))
+	assert.Contains(t, data.String(), `"formatVersion": 1`)
+
+	read, err := Read(&data)
+	assert.NoError(t, err)
+	assert.Equal(t, "postgres", read.DBType)
+	assert.Equal(t, "16.2", read.ServerVersion)
+	assert.True(t, capturedAt.Equal(read.CapturedAt))
+	assert.Equal(t, s.Tables, read.Tables)
+}
+
+func TestRead_versions(t *testing.T) {
+	_, err := Read(strings.NewReader(`{"tables": []}`))
+	assert.EqualError(t, err, "invalid snapshot: no format version")
+	_, err = Read(strings.NewReader(`{"formatVersion": 2, "tables": []}`))
+	assert.ErrorContains(t, err, "newer than this version")
+	_, err = Read(strings.NewReader(`[`))
+	assert.ErrorContains(t, err, "invalid snapshot")
+}
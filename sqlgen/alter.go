@@ -0,0 +1,238 @@
+package sqlgen
+
+import (
+	"db_meta/dbstructs"
+	"db_meta/schemadiff"
+	"strings"
+)
+
+// AddColumn writes the statement adding column to table.
+func (w *Writer) AddColumn(table *dbstructs.TableMetadata, column *dbstructs.Column) string {
+	if w.dialect == SQLServer {
+		return w.alterTable(table, "ADD "+w.ColumnDefinition(column))
+	}
+	return w.alterTable(table, "ADD COLUMN "+w.ColumnDefinition(column))
+}
+
+// DropColumn writes the statements dropping column from table, SQL Server
+// dropping its default constraint first.
+func (w *Writer) DropColumn(table *dbstructs.TableMetadata, column *dbstructs.Column) []string {
+	var statements []string
+	if w.dialect == SQLServer && column.Default != nil {
+		statements = append(statements, w.dropDefaultConstraint(table, column))
+	}
+	return append(statements, w.alterTable(table, "DROP COLUMN "+w.Quote(column.ColumnName)))
+}
+
+// AlterColumn writes the statements turning the column source of table into
+// target, changes being the schemadiff.Change* values between them. Generated
+// columns, and identities on SQL Server, can't be altered: the column is
+// dropped and added again. SQLite alters no column, it returns nil.
+func (w *Writer) AlterColumn(table *dbstructs.TableMetadata, source, target *dbstructs.Column, changes []string) []string {
+	if w.dialect == SQLite {
+		return nil
+	}
+	if contains(changes, schemadiff.ChangeGenerated) || w.dialect == SQLServer && contains(changes, schemadiff.ChangeAutoIncrement) {
+		w.warn("%s.%s can't be altered, it is dropped and added again: its data is lost", table.QualifiedName(), target.ColumnName)
+		return append(w.DropColumn(table, source), w.AddColumn(table, target))
+	}
+
+	name := w.Quote(target.ColumnName)
+	var statements []string
+	switch w.dialect {
+	case MySQL:
+		statements = append(statements, w.alterTable(table, "MODIFY COLUMN "+w.ColumnDefinition(target)))
+	case SQLServer:
+		if contains(changes, schemadiff.ChangeType) || contains(changes, schemadiff.ChangeNullability) {
+			nullability := " NULL"
+			if target.NotNull {
+				nullability = " NOT NULL"
+			}
+			statements = append(statements, w.alterTable(table, "ALTER COLUMN "+name+" "+w.ColumnType(target)+nullability))
+		}
+		if contains(changes, schemadiff.ChangeDefault) {
+			if source.Default != nil {
+				statements = append(statements, w.dropDefaultConstraint(table, source))
+			}
+			if target.Default != nil {
+				statements = append(statements, w.alterTable(table, "ADD DEFAULT "+w.defaultValue(target)+" FOR "+name))
+			}
+		}
+	case PostgreSQL:
+		alter := func(action string) {
+			statements = append(statements, w.alterTable(table, "ALTER COLUMN "+name+" "+action))
+		}
+		identityChanged := contains(changes, schemadiff.ChangeAutoIncrement)
+		if identityChanged && source.AutoIncrement && !isSequenceDefault(source) {
+			alter("DROP IDENTITY IF EXISTS")
+		}
+		if contains(changes, schemadiff.ChangeType) {
+			dataType := w.ColumnType(target)
+			alter("TYPE " + dataType + " USING " + name + "::" + dataType)
+		}
+		if contains(changes, schemadiff.ChangeDefault) {
+			if target.Default == nil {
+				alter("DROP DEFAULT")
+			} else {
+				alter("SET DEFAULT " + w.defaultValue(target))
+			}
+		}
+		if contains(changes, schemadiff.ChangeNullability) {
+			if target.NotNull {
+				alter("SET NOT NULL")
+			} else {
+				alter("DROP NOT NULL")
+			}
+		}
+		if identityChanged && target.AutoIncrement && !isSequenceDefault(target) {
+			alter("ADD GENERATED BY DEFAULT AS IDENTITY")
+		}
+	}
+	return statements
+}
+
+// dropDefaultConstraint writes the T-SQL dropping the default constraint of
+// column, whose name the metadata does not hold.
+func (w *Writer) dropDefaultConstraint(table *dbstructs.TableMetadata, column *dbstructs.Column) string {
+	object := String(w.TableName(table))
+	return "DECLARE @default sysname = (SELECT name FROM sys.default_constraints WHERE parent_object_id = OBJECT_ID(" + object + ")" +
+		" AND parent_column_id = COLUMNPROPERTY(OBJECT_ID(" + object + "), " + String(column.ColumnName) + ", 'ColumnId'));\n" +
+		"IF @default IS NOT NULL EXEC('" + strings.ReplaceAll(w.alterTable(table, "DROP CONSTRAINT "), "'", "''") + "' + QUOTENAME(@default))"
+}
+
+// AddPrimaryKey writes the statement adding the primary key of table.
+func (w *Writer) AddPrimaryKey(table *dbstructs.TableMetadata) string {
+	return w.alterTable(table, "ADD "+w.constraintName(w.primaryKeyName(table))+"PRIMARY KEY "+w.columnList(table.PrimaryKey))
+}
+
+// DropPrimaryKey writes the statement dropping the primary key of table.
+func (w *Writer) DropPrimaryKey(table *dbstructs.TableMetadata) string {
+	switch w.dialect {
+	case MySQL:
+		return w.alterTable(table, "DROP PRIMARY KEY")
+	case SQLServer:
+		// the metadata does not hold the name of the constraint
+		object := String(w.TableName(table))
+		return "DECLARE @primary_key sysname = (SELECT name FROM sys.key_constraints WHERE type = 'PK' AND parent_object_id = OBJECT_ID(" + object + "));\n" +
+			"EXEC('" + strings.ReplaceAll(w.alterTable(table, "DROP CONSTRAINT "), "'", "''") + "' + QUOTENAME(@primary_key))"
+	}
+	return w.alterTable(table, "DROP CONSTRAINT "+w.Quote(w.primaryKeyName(table)))
+}
+
+// AddForeignKey writes the statement adding relationship to table.
+func (w *Writer) AddForeignKey(table *dbstructs.TableMetadata, relationship *dbstructs.RelationshipMetadata) string {
+	return w.alterTable(table, "ADD "+w.foreignKeyClause(relationship))
+}
+
+// DropForeignKey writes the statement dropping relationship from table, or ""
+// when it has no name.
+func (w *Writer) DropForeignKey(table *dbstructs.TableMetadata, relationship *dbstructs.RelationshipMetadata) string {
+	if relationship.Conname == "" {
+		w.warn("the foreign key of %s to %s has no name, drop it by hand", table.QualifiedName(), relationship.QualifiedRelatedName())
+		return ""
+	}
+	if w.dialect == MySQL {
+		return w.alterTable(table, "DROP FOREIGN KEY "+w.Quote(relationship.Conname))
+	}
+	return w.alterTable(table, "DROP CONSTRAINT "+w.Quote(relationship.Conname))
+}
+
+// AddCheck writes the statement adding check to table.
+func (w *Writer) AddCheck(table *dbstructs.TableMetadata, check *dbstructs.CheckConstraint) string {
+	return w.alterTable(table, "ADD "+w.checkClause(check))
+}
+
+// DropCheck writes the statement dropping check from table.
+func (w *Writer) DropCheck(table *dbstructs.TableMetadata, check *dbstructs.CheckConstraint) string {
+	if w.dialect == MySQL {
+		return w.alterTable(table, "DROP CHECK "+w.Quote(check.Name))
+	}
+	return w.alterTable(table, "DROP CONSTRAINT "+w.Quote(check.Name))
+}
+
+// RenameTable writes the statement renaming table to to, in its schema.
+func (w *Writer) RenameTable(table *dbstructs.TableMetadata, to string) string {
+	switch w.dialect {
+	case MySQL:
+		return "RENAME TABLE " + w.TableName(table) + " TO " + w.Quote(to)
+	case SQLServer:
+		return "EXEC sp_rename " + String(w.TableName(table)) + ", " + String(to)
+	}
+	return w.alterTable(table, "RENAME TO "+w.Quote(to))
+}
+
+// RenameColumn writes the statement renaming the column from of table to to,
+// MySQL 8.0 and SQLite 3.25 being the first versions to support it.
+func (w *Writer) RenameColumn(table *dbstructs.TableMetadata, from, to string) string {
+	if w.dialect == SQLServer {
+		return "EXEC sp_rename " + String(w.TableName(table)+"."+w.Quote(from)) + ", " + String(to) + ", 'COLUMN'"
+	}
+	return w.alterTable(table, "RENAME COLUMN "+w.Quote(from)+" TO "+w.Quote(to))
+}
+
+// RenameForeignKey writes the statements renaming relationship of table to to:
+// MySQL drops and adds it again, SQLite can't and returns nil.
+func (w *Writer) RenameForeignKey(table *dbstructs.TableMetadata, relationship *dbstructs.RelationshipMetadata, to string) []string {
+	switch w.dialect {
+	case SQLite:
+		return nil
+	case MySQL:
+		renamed := *relationship
+		renamed.Conname = to
+		return []string{w.DropForeignKey(table, relationship), w.AddForeignKey(table, &renamed)}
+	case SQLServer:
+		return []string{"EXEC sp_rename " + String(w.Name(table.Schema, relationship.Conname)) + ", " + String(to) + ", 'OBJECT'"}
+	}
+	return []string{w.alterTable(table, "RENAME CONSTRAINT "+w.Quote(relationship.Conname)+" TO "+w.Quote(to))}
+}
+
+// RebuildTable writes the statements SQLite needs to turn the table source into
+// target: target is created under a temporary name, the data of the columns of
+// both is copied, source is dropped and target renamed, then its indexes are
+// created. Foreign keys must be off while they run.
+func (w *Writer) RebuildTable(source, target *dbstructs.TableMetadata) []string {
+	temporary := *target
+	temporary.TableName = "_new_" + target.TableName
+	temporary.Relationships = nil
+	for _, relationship := range ForeignKeys(target) {
+		copied := *relationship
+		copied.SourceTableName = temporary.TableName
+		temporary.Relationships = append(temporary.Relationships, &copied)
+	}
+
+	var columns []string
+	for _, column := range target.Columns {
+		if column.Generated != "" {
+			continue
+		}
+		for _, sourceColumn := range source.Columns {
+			if strings.EqualFold(sourceColumn.ColumnName, column.ColumnName) && sourceColumn.Generated == "" {
+				columns = append(columns, w.Quote(column.ColumnName))
+				break
+			}
+		}
+	}
+
+	statements := []string{w.CreateTable(&temporary, true)}
+	if len(columns) > 0 {
+		list := strings.Join(columns, ", ")
+		statements = append(statements, "INSERT INTO "+w.TableName(&temporary)+" ("+list+") SELECT "+list+" FROM "+w.TableName(source))
+	}
+	statements = append(statements,
+		w.DropTable(source),
+		"ALTER TABLE "+w.TableName(&temporary)+" RENAME TO "+w.TableName(target),
+	)
+	for _, index := range w.Indexes(target) {
+		statements = append(statements, w.CreateIndex(target, index))
+	}
+	return statements
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
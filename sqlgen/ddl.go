@@ -0,0 +1,72 @@
+package sqlgen
+
+import (
+	"db_meta/dbstructs"
+	"strings"
+)
+
+// CreateSchema writes the statements creating tables: each table with its
+// primary key and checks, its indexes, then the foreign keys once all the
+// tables exist. SQLite writes the foreign keys in CREATE TABLE.
+func (w *Writer) CreateSchema(tables []*dbstructs.TableMetadata) []string {
+	var statements []string
+	for _, table := range tables {
+		statements = append(statements, w.CreateTable(table, false))
+		for _, index := range w.Indexes(table) {
+			statements = append(statements, w.CreateIndex(table, index))
+		}
+	}
+	if w.dialect == SQLite {
+		return statements
+	}
+	for _, table := range tables {
+		for _, relationship := range ForeignKeys(table) {
+			statements = append(statements, w.AddForeignKey(table, relationship))
+		}
+	}
+	return statements
+}
+
+// DDL is the script creating a schema in a dialect.
+type DDL struct {
+	Dialect     string        `json:"dialect"`
+	Statements  []string      `json:"statements"`
+	Conversions []*Conversion `json:"conversions"` // from the dialect the schema was read in
+	Warnings    []string      `json:"warnings"`
+}
+
+// GenerateDDL writes the DDL creating tables, read by the connector of dialect
+// from, in dialect to. The tables are translated first when the dialects
+// differ, types giving the values of the PostgreSQL enums.
+func GenerateDDL(from, to string, tables []*dbstructs.TableMetadata, types []*dbstructs.TypeMetadata) (*DDL, error) {
+	w, err := NewWriter(to)
+	if err != nil {
+		return nil, err
+	}
+	var conversions []*Conversion
+	if from != to {
+		tables, conversions, err = Translate(from, to, tables, types)
+		if err != nil {
+			return nil, err
+		}
+	}
+	statements := w.CreateSchema(tables)
+	return &DDL{Dialect: to, Statements: statements, Conversions: conversions, Warnings: w.Warnings()}, nil
+}
+
+// SQL returns the statements as a script.
+func (d *DDL) SQL() string {
+	return Script(d.Dialect, d.Statements)
+}
+
+// Script joins statements of dialect in a script, split in batches by GO on
+// SQL Server.
+func Script(dialect string, statements []string) string {
+	if len(statements) == 0 {
+		return ""
+	}
+	if dialect == SQLServer {
+		return strings.Join(statements, "\nGO\n\n") + "\nGO\n"
+	}
+	return strings.Join(statements, ";\n\n") + ";\n"
+}
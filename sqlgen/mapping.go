@@ -0,0 +1,423 @@
+package sqlgen
+
+import (
+	"db_meta/dbstructs"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Canonical types, the common ground a column type goes through from a dialect
+// to another
+const (
+	TypeBoolean     = "boolean"
+	TypeSmallInt    = "smallint"
+	TypeInteger     = "integer"
+	TypeBigInt      = "bigint"
+	TypeDecimal     = "decimal"
+	TypeReal        = "real"   // 4 bytes floating point
+	TypeDouble      = "double" // 8 bytes floating point
+	TypeChar        = "char"
+	TypeVarchar     = "varchar"
+	TypeText        = "text"
+	TypeBinary      = "binary"
+	TypeVarbinary   = "varbinary"
+	TypeBlob        = "blob"
+	TypeDate        = "date"
+	TypeTime        = "time"
+	TypeTimestamp   = "timestamp"
+	TypeTimestampTZ = "timestamptz"
+	TypeInterval    = "interval"
+	TypeUUID        = "uuid"
+	TypeJSON        = "json"
+	TypeXML         = "xml"
+	TypeEnum        = "enum"
+	TypeSet         = "set"
+	TypeOther       = "other" // no equivalent, such as geometries or arrays
+)
+
+// CanonicalType is the type of a column whatever its dialect.
+type CanonicalType struct {
+	Kind      string   `json:"kind"` // one of the Type* values
+	Length    *int     `json:"length,omitempty"`
+	Precision *int     `json:"precision,omitempty"`
+	Scale     *int     `json:"scale,omitempty"`
+	Values    []string `json:"values,omitempty"` // of enums and sets
+	Name      string   `json:"name"`             // in the source dialect
+}
+
+// canonicalNames are the kinds of the type names of the dialects.
+var canonicalNames = map[string]string{
+	"boolean": TypeBoolean, "bool": TypeBoolean,
+	"tinyint": TypeSmallInt, "smallint": TypeSmallInt, "int2": TypeSmallInt, "smallserial": TypeSmallInt, "year": TypeSmallInt,
+	"integer": TypeInteger, "int": TypeInteger, "int4": TypeInteger, "mediumint": TypeInteger, "serial": TypeInteger,
+	"bigint": TypeBigInt, "int8": TypeBigInt, "bigserial": TypeBigInt,
+	"numeric": TypeDecimal, "decimal": TypeDecimal, "dec": TypeDecimal, "money": TypeDecimal, "smallmoney": TypeDecimal,
+	"real": TypeReal, "float4": TypeReal,
+	"double precision": TypeDouble, "double": TypeDouble, "float8": TypeDouble, "float": TypeDouble,
+	"character": TypeChar, "char": TypeChar, "nchar": TypeChar, "bpchar": TypeChar,
+	"character varying": TypeVarchar, "varchar": TypeVarchar, "nvarchar": TypeVarchar,
+	"text": TypeText, "tinytext": TypeText, "mediumtext": TypeText, "longtext": TypeText, "ntext": TypeText, "clob": TypeText, "citext": TypeText,
+	"binary": TypeBinary, "varbinary": TypeVarbinary,
+	"bytea": TypeBlob, "blob": TypeBlob, "tinyblob": TypeBlob, "mediumblob": TypeBlob, "longblob": TypeBlob, "image": TypeBlob,
+	"date": TypeDate,
+	"time": TypeTime, "time without time zone": TypeTime,
+	"timestamp": TypeTimestamp, "timestamp without time zone": TypeTimestamp, "datetime": TypeTimestamp, "datetime2": TypeTimestamp, "smalldatetime": TypeTimestamp,
+	"timestamp with time zone": TypeTimestampTZ, "timestamptz": TypeTimestampTZ, "datetimeoffset": TypeTimestampTZ,
+	"interval": TypeInterval,
+	"uuid":     TypeUUID, "uniqueidentifier": TypeUUID,
+	"json": TypeJSON, "jsonb": TypeJSON,
+	"xml":  TypeXML,
+	"enum": TypeEnum, "set": TypeSet,
+}
+
+// Canonical returns the canonical type of column, as reported by the connector
+// of dialect. enums are the PostgreSQL enum types, by qualified name, the
+// columns of which are reported as USER-DEFINED.
+func Canonical(dialect string, column *dbstructs.Column, enums map[string][]string) CanonicalType {
+	canonical := CanonicalType{
+		Name:      (&Writer{dialect: dialect}).ColumnType(column),
+		Length:    column.CharacterLength,
+		Precision: column.NumericPrecision,
+		Scale:     column.NumericScale,
+		Values:    column.EnumValues,
+	}
+	name, args := splitType(column.DataType)
+	kind, known := canonicalNames[name]
+	switch {
+	case dialect == SQLite && !known:
+		kind = sqliteAffinity(name)
+	case name == "user-defined" && enums[strings.ToLower(column.UserType)] != nil:
+		kind, canonical.Values = TypeEnum, enums[strings.ToLower(column.UserType)]
+	case name == "bit":
+		// a single bit is the boolean of SQL Server and MySQL
+		kind = TypeBoolean
+		if length := argument(args, 0, column.CharacterLength); length != nil && *length > 1 || dialect == MySQL && column.NumericPrecision != nil && *column.NumericPrecision > 1 {
+			kind = TypeOther
+		}
+	case !known:
+		kind = TypeOther
+	case dialect == SQLite && kind == TypeInteger:
+		// integers of SQLite take 8 bytes, as its floating point numbers
+		kind = TypeBigInt
+	case dialect == SQLite && kind == TypeReal:
+		kind = TypeDouble
+	case dialect == MySQL && name == "float":
+		kind = TypeReal
+	}
+	canonical.Kind = kind
+
+	switch kind {
+	case TypeChar, TypeVarchar, TypeBinary, TypeVarbinary:
+		canonical.Precision, canonical.Scale = nil, nil
+		canonical.Length = argument(args, 0, canonical.Length)
+		if canonical.Length == nil && dialect == SQLServer && kind != TypeChar && kind != TypeBinary {
+			// SQL Server reports no length for (max)
+			canonical.Length = intPointer(-1)
+		}
+		if canonical.Length != nil && *canonical.Length < 0 {
+			canonical.Kind, canonical.Length = map[string]string{TypeVarchar: TypeText, TypeVarbinary: TypeBlob}[kind], nil
+		}
+	case TypeDecimal:
+		canonical.Length = nil
+		canonical.Precision = argument(args, 0, canonical.Precision)
+		canonical.Scale = argument(args, 1, canonical.Scale)
+		if name == "money" || name == "smallmoney" {
+			canonical.Precision, canonical.Scale = intPointer(19), intPointer(4)
+		}
+	case TypeEnum, TypeSet:
+		canonical.Length, canonical.Precision, canonical.Scale = nil, nil, nil
+		if len(canonical.Values) == 0 {
+			canonical.Values = enumValues(column.DataType)
+		}
+	default:
+		canonical.Length, canonical.Precision, canonical.Scale = nil, nil, nil
+	}
+	return canonical
+}
+
+// sqliteAffinity is the kind of a type SQLite does not name, after the rules of
+// its type affinity.
+func sqliteAffinity(name string) string {
+	switch {
+	case strings.Contains(name, "int"):
+		return TypeBigInt
+	case strings.Contains(name, "char"), strings.Contains(name, "clob"), strings.Contains(name, "text"):
+		return TypeText
+	case name == "", strings.Contains(name, "blob"):
+		return TypeBlob
+	case strings.Contains(name, "real"), strings.Contains(name, "floa"), strings.Contains(name, "doub"):
+		return TypeDouble
+	}
+	return TypeDecimal
+}
+
+// splitType splits a declared type such as "varchar(20)" or "decimal(10, 2)
+// unsigned" into its lower case name and its arguments.
+func splitType(dataType string) (string, []string) {
+	dataType = strings.ToLower(strings.TrimSpace(dataType))
+	open := strings.Index(dataType, "(")
+	if open < 0 {
+		return strings.TrimSpace(strings.TrimSuffix(dataType, " unsigned")), nil
+	}
+	var args []string
+	if end := strings.LastIndex(dataType, ")"); end > open {
+		for _, arg := range strings.Split(dataType[open+1:end], ",") {
+			args = append(args, strings.TrimSpace(arg))
+		}
+	}
+	return strings.TrimSpace(dataType[:open]), args
+}
+
+// argument returns the numeric argument i of a declared type, -1 for max, or
+// reported when it has none.
+func argument(args []string, i int, reported *int) *int {
+	if i >= len(args) {
+		return reported
+	}
+	if args[i] == "max" {
+		return intPointer(-1)
+	}
+	if value, err := strconv.Atoi(args[i]); err == nil {
+		return &value
+	}
+	return reported
+}
+
+// enumValues reads the values of a declared enum('a','b') type.
+func enumValues(dataType string) []string {
+	open, end := strings.Index(dataType, "("), strings.LastIndex(dataType, ")")
+	if open < 0 || end < open {
+		return nil
+	}
+	var values []string
+	list := dataType[open+1 : end]
+	for len(list) > 0 {
+		start := strings.Index(list, "'")
+		if start < 0 {
+			break
+		}
+		value, i := strings.Builder{}, start+1
+		for ; i < len(list); i++ {
+			if list[i] == '\'' {
+				if i+1 < len(list) && list[i+1] == '\'' {
+					value.WriteByte('\'')
+					i++
+					continue
+				}
+				break
+			}
+			value.WriteByte(list[i])
+		}
+		values = append(values, value.String())
+		if i >= len(list) {
+			break
+		}
+		list = list[i+1:]
+	}
+	return values
+}
+
+func intPointer(value int) *int {
+	return &value
+}
+
+// RenderType spells the canonical type in the dialect of w, with a note when it
+// loses something on the way.
+func (w *Writer) RenderType(canonical CanonicalType) (string, string) {
+	length := func(name string, max int) (string, string) {
+		switch {
+		case canonical.Length == nil:
+			return name, ""
+		case *canonical.Length > max:
+			return fmt.Sprintf("%s(%d)", name, max), fmt.Sprintf("the length %d is cut to %d", *canonical.Length, max)
+		}
+		return fmt.Sprintf("%s(%d)", name, *canonical.Length), ""
+	}
+	decimal := func(name string, maxPrecision int) (string, string) {
+		switch {
+		case canonical.Precision == nil && w.dialect == PostgreSQL:
+			return name, ""
+		case canonical.Precision == nil:
+			return fmt.Sprintf("%s(%d,%d)", name, maxPrecision, 10), fmt.Sprintf("the precision is unbounded, it is set to %d with 10 decimals", maxPrecision)
+		case *canonical.Precision > maxPrecision:
+			return fmt.Sprintf("%s(%d,%d)", name, maxPrecision, scale(canonical.Scale)), fmt.Sprintf("the precision %d is cut to %d", *canonical.Precision, maxPrecision)
+		}
+		return fmt.Sprintf("%s(%d,%d)", name, *canonical.Precision, scale(canonical.Scale)), ""
+	}
+	values := func() string {
+		quoted := make([]string, 0, len(canonical.Values))
+		for _, value := range canonical.Values {
+			quoted = append(quoted, String(value))
+		}
+		return strings.Join(quoted, ",")
+	}
+	noEquivalent := fmt.Sprintf("%s has no equivalent", canonical.Name)
+
+	switch w.dialect {
+	case PostgreSQL:
+		switch canonical.Kind {
+		case TypeBoolean, TypeSmallInt, TypeInteger, TypeBigInt, TypeReal, TypeDate, TypeInterval, TypeUUID, TypeXML, TypeText:
+			return canonical.Kind, ""
+		case TypeDecimal:
+			return decimal("numeric", 1000)
+		case TypeDouble:
+			return "double precision", ""
+		case TypeChar:
+			return length("character", 10485760)
+		case TypeVarchar:
+			return length("character varying", 10485760)
+		case TypeBinary, TypeVarbinary, TypeBlob:
+			return "bytea", ""
+		case TypeTime:
+			return "time without time zone", ""
+		case TypeTimestamp:
+			return "timestamp without time zone", ""
+		case TypeTimestampTZ:
+			return "timestamp with time zone", ""
+		case TypeJSON:
+			return "jsonb", ""
+		case TypeEnum:
+			return "text", "" // the values go to a check
+		case TypeSet:
+			return "text", "the values of the set are not checked"
+		}
+		return "text", noEquivalent
+	case MySQL:
+		switch canonical.Kind {
+		case TypeBoolean:
+			return "tinyint(1)", ""
+		case TypeSmallInt, TypeBigInt, TypeDate, TypeJSON:
+			return canonical.Kind, ""
+		case TypeInteger:
+			return "int", ""
+		case TypeDecimal:
+			return decimal("decimal", 65)
+		case TypeReal:
+			return "float", ""
+		case TypeDouble:
+			return "double", ""
+		case TypeChar:
+			return length("char", 255)
+		case TypeVarchar:
+			if canonical.Length == nil {
+				return "longtext", ""
+			}
+			return length("varchar", 16383)
+		case TypeText, TypeXML:
+			return "longtext", ""
+		case TypeBinary:
+			return length("binary", 255)
+		case TypeVarbinary:
+			if canonical.Length == nil {
+				return "longblob", ""
+			}
+			return length("varbinary", 65535)
+		case TypeBlob:
+			return "longblob", ""
+		case TypeTime:
+			return "time(6)", ""
+		case TypeTimestamp:
+			return "datetime(6)", ""
+		case TypeTimestampTZ:
+			return "datetime(6)", "the time zone is dropped"
+		case TypeInterval:
+			return "varchar(255)", "intervals are stored as text"
+		case TypeUUID:
+			return "char(36)", ""
+		case TypeEnum, TypeSet:
+			if len(canonical.Values) == 0 {
+				return "varchar(255)", "the values of the enum are unknown"
+			}
+			return canonical.Kind + "(" + values() + ")", ""
+		}
+		return "longtext", noEquivalent
+	case SQLServer:
+		switch canonical.Kind {
+		case TypeBoolean:
+			return "bit", ""
+		case TypeSmallInt, TypeBigInt, TypeDate, TypeReal, TypeXML:
+			return canonical.Kind, ""
+		case TypeInteger:
+			return "int", ""
+		case TypeDecimal:
+			return decimal("decimal", 38)
+		case TypeDouble:
+			return "float", ""
+		case TypeChar:
+			return length("nchar", 4000)
+		case TypeVarchar:
+			if canonical.Length == nil || *canonical.Length > 4000 {
+				return "nvarchar(max)", ""
+			}
+			return length("nvarchar", 4000)
+		case TypeText:
+			return "nvarchar(max)", ""
+		case TypeBinary:
+			return length("binary", 8000)
+		case TypeVarbinary:
+			if canonical.Length == nil || *canonical.Length > 8000 {
+				return "varbinary(max)", ""
+			}
+			return length("varbinary", 8000)
+		case TypeBlob:
+			return "varbinary(max)", ""
+		case TypeTime:
+			return "time", ""
+		case TypeTimestamp:
+			return "datetime2", ""
+		case TypeTimestampTZ:
+			return "datetimeoffset", ""
+		case TypeInterval:
+			return "nvarchar(255)", "intervals are stored as text"
+		case TypeUUID:
+			return "uniqueidentifier", ""
+		case TypeJSON:
+			return "nvarchar(max)", "JSON is stored as text"
+		case TypeEnum:
+			return "nvarchar(255)", ""
+		case TypeSet:
+			return "nvarchar(max)", "the values of the set are not checked"
+		}
+		return "nvarchar(max)", noEquivalent
+	}
+
+	// SQLite keeps the declared types, their affinity being what counts
+	switch canonical.Kind {
+	case TypeBoolean:
+		return "BOOLEAN", ""
+	case TypeSmallInt, TypeInteger, TypeBigInt:
+		return "INTEGER", ""
+	case TypeDecimal:
+		return "NUMERIC", "decimals may be stored as floating point numbers"
+	case TypeReal, TypeDouble:
+		return "REAL", ""
+	case TypeChar, TypeVarchar:
+		if canonical.Length == nil {
+			return strings.ToUpper(canonical.Kind), ""
+		}
+		return fmt.Sprintf("%s(%d)", strings.ToUpper(canonical.Kind), *canonical.Length), ""
+	case TypeBinary, TypeVarbinary, TypeBlob:
+		return "BLOB", ""
+	case TypeDate, TypeTime:
+		return strings.ToUpper(canonical.Kind), ""
+	case TypeTimestamp:
+		return "DATETIME", ""
+	case TypeTimestampTZ:
+		return "DATETIME", "the time zone is dropped"
+	case TypeInterval:
+		return "TEXT", "intervals are stored as text"
+	case TypeEnum, TypeText, TypeUUID, TypeJSON, TypeXML:
+		return "TEXT", ""
+	case TypeSet:
+		return "TEXT", "the values of the set are not checked"
+	}
+	return "TEXT", noEquivalent
+}
+
+func scale(value *int) int {
+	if value == nil {
+		return 0
+	}
+	return *value
+}
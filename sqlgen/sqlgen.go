@@ -0,0 +1,157 @@
+// Package sqlgen writes the DDL statements of the dbstructs metadata in the
+// dialect of a connector: CREATE TABLE and CREATE INDEX, and the ALTER TABLE
+// statements of each kind of change. Statements have no trailing semicolon.
+package sqlgen
+
+import (
+	"db_meta/dbstructs"
+	"fmt"
+	"strings"
+)
+
+// Dialects, named after the connectors of the registry
+const (
+	PostgreSQL = "postgres"
+	MySQL      = "mysql"
+	SQLite     = "sqlite"
+	SQLServer  = "sqlserver"
+)
+
+// Dialects returns the names of the dialects a Writer writes.
+func Dialects() []string {
+	return []string{PostgreSQL, MySQL, SQLite, SQLServer}
+}
+
+// Writer writes the statements of a dialect, noting what the dialect can't
+// write as the metadata says.
+type Writer struct {
+	dialect  string
+	warnings []string
+}
+
+// NewWriter returns the Writer of dialect, one of Dialects.
+func NewWriter(dialect string) (*Writer, error) {
+	for _, name := range Dialects() {
+		if name == dialect {
+			return &Writer{dialect: dialect}, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown SQL dialect %q, expected one of %s", dialect, strings.Join(Dialects(), ", "))
+}
+
+// Dialect returns the dialect of w.
+func (w *Writer) Dialect() string {
+	return w.dialect
+}
+
+// Warnings returns the notes taken while writing the statements so far.
+func (w *Writer) Warnings() []string {
+	return w.warnings
+}
+
+func (w *Writer) warn(format string, args ...interface{}) {
+	warning := fmt.Sprintf(format, args...)
+	for _, existing := range w.warnings {
+		if existing == warning {
+			return
+		}
+	}
+	w.warnings = append(w.warnings, warning)
+}
+
+// Quote quotes an identifier.
+func (w *Writer) Quote(name string) string {
+	switch w.dialect {
+	case MySQL:
+		return "`" + strings.ReplaceAll(name, "`", "``") + "`"
+	case SQLServer:
+		return "[" + strings.ReplaceAll(name, "]", "]]") + "]"
+	}
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+// Name quotes the name of an object of schema, MySQL and SQLite have no
+// schemas and drop it.
+func (w *Writer) Name(schema, name string) string {
+	if schema == "" || w.dialect == MySQL || w.dialect == SQLite {
+		return w.Quote(name)
+	}
+	return w.Quote(schema) + "." + w.Quote(name)
+}
+
+// TableName quotes the qualified name of table.
+func (w *Writer) TableName(table *dbstructs.TableMetadata) string {
+	return w.Name(table.Schema, table.TableName)
+}
+
+// qualifiedName quotes a "schema.name" qualified name.
+func (w *Writer) qualifiedName(name string) string {
+	if dot := strings.LastIndex(name, "."); dot > 0 {
+		return w.Name(name[:dot], name[dot+1:])
+	}
+	return w.Quote(name)
+}
+
+// columnList quotes the names and lists them between parentheses.
+func (w *Writer) columnList(names []string) string {
+	quoted := make([]string, 0, len(names))
+	for _, name := range names {
+		quoted = append(quoted, w.Quote(name))
+	}
+	return "(" + strings.Join(quoted, ", ") + ")"
+}
+
+// String quotes a string literal.
+func String(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// parenthesized wraps an expression between parentheses, unless the whole of it
+// already is.
+func parenthesized(expression string) string {
+	expression = strings.TrimSpace(expression)
+	if strings.HasPrefix(expression, "(") && closing(expression) == len(expression)-1 {
+		return expression
+	}
+	return "(" + expression + ")"
+}
+
+// closing returns the index of the parenthesis closing the one expression
+// starts with, skipping quoted text, or -1.
+func closing(expression string) int {
+	depth := 0
+	var quote rune
+	for i, char := range expression {
+		switch {
+		case quote != 0:
+			if char == quote {
+				quote = 0
+			}
+		case char == '\'' || char == '"' || char == '`':
+			quote = char
+		case char == '(':
+			depth++
+		case char == ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// ForeignKeys returns the foreign keys table holds, leaving out the ones some
+// connectors also list on the referenced table. Tables of the same name in
+// other schemas are told apart, a foreign key without source schema being in
+// the schema of table.
+func ForeignKeys(table *dbstructs.TableMetadata) []*dbstructs.RelationshipMetadata {
+	var relationships []*dbstructs.RelationshipMetadata
+	for _, relationship := range table.Relationships {
+		if relationship.SourceTableName == "" || (strings.EqualFold(relationship.SourceTableName, table.TableName) &&
+			(relationship.SourceSchema == "" || strings.EqualFold(relationship.SourceSchema, table.Schema))) {
+			relationships = append(relationships, relationship)
+		}
+	}
+	return relationships
+}
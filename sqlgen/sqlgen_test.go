@@ -0,0 +1,204 @@
+package sqlgen
+
+import (
+	"db_meta/dbstructs"
+	"db_meta/schemadiff"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func stringPointer(value string) *string {
+	return &value
+}
+
+func ordersTable() *dbstructs.TableMetadata {
+	return &dbstructs.TableMetadata{
+		Schema:    "shop",
+		TableName: "orders",
+		Columns: []*dbstructs.Column{
+			{ColumnName: "id", DataType: "integer", NotNull: true, AutoIncrement: true},
+			{ColumnName: "customer_id", DataType: "integer", NotNull: true},
+			{ColumnName: "status", DataType: "character varying", CharacterLength: intPointer(20), Default: stringPointer("new")},
+			{ColumnName: "total", DataType: "numeric", NumericPrecision: intPointer(10), NumericScale: intPointer(2)},
+		},
+		PrimaryKey: []string{"id"},
+		Indexes: []*dbstructs.Index{
+			{Name: "orders_pkey", Columns: []string{"id"}, Unique: true, Primary: true},
+			{Name: "orders_recent", Columns: []string{"customer_id", "id"}, Keys: []*dbstructs.IndexKey{{Column: "customer_id"}, {Column: "id", Descending: true}}, Predicate: "total > 0"},
+		},
+		Relationships: []*dbstructs.RelationshipMetadata{{
+			Conname: "orders_customer_fk", SourceTableName: "orders", RelatedSchema: "shop", RelatedTableName: "customers",
+			SourceColumns: []string{"customer_id"}, TargetColumns: []string{"id"},
+			OnDelete: dbstructs.ReferentialActionCascade, OnUpdate: dbstructs.ReferentialActionNoAction,
+		}},
+		CheckConstraints: []*dbstructs.CheckConstraint{{Name: "orders_total_check", Columns: []string{"total"}, Expression: "total >= 0"}},
+	}
+}
+
+func TestForeignKeys(t *testing.T) {
+	// PostgreSQL lists a foreign key under both its tables
+	foreignKey := func(source, related string) *dbstructs.RelationshipMetadata {
+		return &dbstructs.RelationshipMetadata{
+			Conname: source + "_fkey", SourceSchema: source, SourceTableName: "orders", RelatedSchema: related, RelatedTableName: "orders",
+			SourceColumns: []string{"parent_id"}, TargetColumns: []string{"id"},
+		}
+	}
+	fromA, fromB := foreignKey("a", "b"), foreignKey("b", "a")
+	tableA := &dbstructs.TableMetadata{Schema: "a", TableName: "orders", Relationships: []*dbstructs.RelationshipMetadata{fromA, fromB}}
+	tableB := &dbstructs.TableMetadata{Schema: "b", TableName: "orders", Relationships: []*dbstructs.RelationshipMetadata{fromB, fromA}}
+	assert.Equal(t, []*dbstructs.RelationshipMetadata{fromA}, ForeignKeys(tableA))
+	assert.Equal(t, []*dbstructs.RelationshipMetadata{fromB}, ForeignKeys(tableB))
+
+	// without source schema
+	assert.Len(t, ForeignKeys(ordersTable()), 1)
+}
+
+func TestNewWriter(t *testing.T) {
+	w, err := NewWriter(MySQL)
+	assert.NoError(t, err)
+	assert.Equal(t, MySQL, w.Dialect())
+	_, err = NewWriter("oracle")
+	assert.Error(t, err)
+}
+
+func TestWriter_Name(t *testing.T) {
+	for dialect, expected := range map[string]string{
+		PostgreSQL: `"shop"."a""b"`,
+		MySQL:      "`a\"b`",
+		SQLite:     `"a""b"`,
+		SQLServer:  `[shop].[a"b]`,
+	} {
+		w, _ := NewWriter(dialect)
+		assert.Equal(t, expected, w.Name("shop", `a"b`), dialect)
+	}
+	mysql, _ := NewWriter(MySQL)
+	assert.Equal(t, "`a``b`", mysql.Quote("a`b"))
+	sqlserver, _ := NewWriter(SQLServer)
+	assert.Equal(t, "[a]]b]", sqlserver.Quote("a]b"))
+}
+
+func TestWriter_CreateTable(t *testing.T) {
+	postgres, _ := NewWriter(PostgreSQL)
+	assert.Equal(t, `CREATE TABLE "shop"."orders" (
+  "id" integer NOT NULL GENERATED BY DEFAULT AS IDENTITY,
+  "customer_id" integer NOT NULL,
+  "status" character varying(20) DEFAULT new,
+  "total" numeric(10,2),
+  CONSTRAINT "orders_pkey" PRIMARY KEY ("id"),
+  CONSTRAINT "orders_total_check" CHECK (total >= 0)
+)`, postgres.CreateTable(ordersTable(), false))
+
+	mysql, _ := NewWriter(MySQL)
+	assert.Equal(t, "CREATE TABLE `orders` (\n"+
+		"  `id` integer NOT NULL AUTO_INCREMENT,\n"+
+		"  `customer_id` integer NOT NULL,\n"+
+		"  `status` character varying(20) DEFAULT 'new',\n"+
+		"  `total` numeric(10,2),\n"+
+		"  PRIMARY KEY (`id`),\n"+
+		"  CONSTRAINT `orders_total_check` CHECK (total >= 0),\n"+
+		"  CONSTRAINT `orders_customer_fk` FOREIGN KEY (`customer_id`) REFERENCES `customers` (`id`) ON DELETE CASCADE\n"+
+		")", mysql.CreateTable(ordersTable(), true))
+
+	sqlserver, _ := NewWriter(SQLServer)
+	assert.Contains(t, sqlserver.CreateTable(ordersTable(), false), "[id] integer IDENTITY(1,1) NOT NULL,")
+
+	sqlite, _ := NewWriter(SQLite)
+	table := ordersTable()
+	table.Columns[0].DataType = "INTEGER"
+	assert.Equal(t, `CREATE TABLE "orders" (
+  "id" INTEGER NOT NULL PRIMARY KEY,
+  "customer_id" integer NOT NULL,
+  "status" character varying DEFAULT new,
+  "total" numeric,
+  CONSTRAINT "orders_total_check" CHECK (total >= 0),
+  FOREIGN KEY ("customer_id") REFERENCES "customers" ("id") ON DELETE CASCADE
+)`, sqlite.CreateTable(table, false))
+}
+
+func TestWriter_CreateIndex(t *testing.T) {
+	index := ordersTable().Indexes[1]
+	postgres, _ := NewWriter(PostgreSQL)
+	assert.Equal(t, `CREATE INDEX "orders_recent" ON "shop"."orders" ("customer_id", "id" DESC) WHERE total > 0`, postgres.CreateIndex(ordersTable(), index))
+	assert.Equal(t, []string{`DROP INDEX "shop"."orders_recent"`}, postgres.DropIndex(ordersTable(), index))
+
+	mysql, _ := NewWriter(MySQL)
+	assert.Equal(t, "CREATE INDEX `orders_recent` ON `orders` (`customer_id`, `id` DESC)", mysql.CreateIndex(ordersTable(), index))
+	assert.Len(t, mysql.Warnings(), 1)
+}
+
+func TestWriter_AlterColumn(t *testing.T) {
+	source := &dbstructs.Column{ColumnName: "status", DataType: "text", Default: stringPointer("'new'")}
+	target := &dbstructs.Column{ColumnName: "status", DataType: "character varying", CharacterLength: intPointer(20), NotNull: true}
+	changes := []string{schemadiff.ChangeType, schemadiff.ChangeNullability, schemadiff.ChangeDefault}
+
+	postgres, _ := NewWriter(PostgreSQL)
+	assert.Equal(t, []string{
+		`ALTER TABLE "shop"."orders" ALTER COLUMN "status" TYPE character varying(20) USING "status"::character varying(20)`,
+		`ALTER TABLE "shop"."orders" ALTER COLUMN "status" DROP DEFAULT`,
+		`ALTER TABLE "shop"."orders" ALTER COLUMN "status" SET NOT NULL`,
+	}, postgres.AlterColumn(ordersTable(), source, target, changes))
+
+	mysql, _ := NewWriter(MySQL)
+	assert.Equal(t, []string{"ALTER TABLE `orders` MODIFY COLUMN `status` character varying(20) NOT NULL"}, mysql.AlterColumn(ordersTable(), source, target, changes))
+
+	sqlserver, _ := NewWriter(SQLServer)
+	statements := sqlserver.AlterColumn(ordersTable(), source, target, changes)
+	if assert.Len(t, statements, 2) {
+		assert.Equal(t, "ALTER TABLE [shop].[orders] ALTER COLUMN [status] character varying(20) NOT NULL", statements[0])
+		assert.Contains(t, statements[1], "EXEC('ALTER TABLE [shop].[orders] DROP CONSTRAINT ' + QUOTENAME(@default))")
+	}
+
+	sqlite, _ := NewWriter(SQLite)
+	assert.Nil(t, sqlite.AlterColumn(ordersTable(), source, target, changes))
+}
+
+func TestWriter_renames(t *testing.T) {
+	relationship := ordersTable().Relationships[0]
+	for dialect, expected := range map[string][]string{
+		PostgreSQL: {
+			`ALTER TABLE "shop"."orders" RENAME TO "order"`,
+			`ALTER TABLE "shop"."orders" RENAME COLUMN "total" TO "amount"`,
+			`ALTER TABLE "shop"."orders" RENAME CONSTRAINT "orders_customer_fk" TO "fk_order_customer"`,
+		},
+		MySQL: {
+			"RENAME TABLE `orders` TO `order`",
+			"ALTER TABLE `orders` RENAME COLUMN `total` TO `amount`",
+			"ALTER TABLE `orders` DROP FOREIGN KEY `orders_customer_fk`",
+			"ALTER TABLE `orders` ADD CONSTRAINT `fk_order_customer` FOREIGN KEY (`customer_id`) REFERENCES `customers` (`id`) ON DELETE CASCADE",
+		},
+		SQLite: {
+			`ALTER TABLE "orders" RENAME TO "order"`,
+			`ALTER TABLE "orders" RENAME COLUMN "total" TO "amount"`,
+		},
+		SQLServer: {
+			`EXEC sp_rename '[shop].[orders]', 'order'`,
+			`EXEC sp_rename '[shop].[orders].[total]', 'amount', 'COLUMN'`,
+			`EXEC sp_rename '[shop].[orders_customer_fk]', 'fk_order_customer', 'OBJECT'`,
+		},
+	} {
+		w, _ := NewWriter(dialect)
+		statements := []string{w.RenameTable(ordersTable(), "order"), w.RenameColumn(ordersTable(), "total", "amount")}
+		statements = append(statements, w.RenameForeignKey(ordersTable(), relationship, "fk_order_customer")...)
+		assert.Equal(t, expected, statements, dialect)
+	}
+}
+
+func TestWriter_RebuildTable(t *testing.T) {
+	source := ordersTable()
+	source.Schema = ""
+	source.Columns = source.Columns[:3]
+	target := ordersTable()
+	target.Schema = ""
+
+	sqlite, _ := NewWriter(SQLite)
+	statements := sqlite.RebuildTable(source, target)
+	if assert.Len(t, statements, 5) {
+		assert.Contains(t, statements[0], `CREATE TABLE "_new_orders"`)
+		assert.Contains(t, statements[0], `FOREIGN KEY ("customer_id") REFERENCES "customers"`)
+		assert.Equal(t, `INSERT INTO "_new_orders" ("id", "customer_id", "status") SELECT "id", "customer_id", "status" FROM "orders"`, statements[1])
+		assert.Equal(t, `DROP TABLE "orders"`, statements[2])
+		assert.Equal(t, `ALTER TABLE "_new_orders" RENAME TO "orders"`, statements[3])
+		assert.Equal(t, `CREATE INDEX "orders_recent" ON "orders" ("customer_id", "id" DESC) WHERE total > 0`, statements[4])
+	}
+}
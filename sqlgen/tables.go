@@ -0,0 +1,295 @@
+package sqlgen
+
+import (
+	"db_meta/dbstructs"
+	"strings"
+)
+
+// sqliteAutoindexPrefix starts the names of the indexes SQLite creates for the
+// PRIMARY KEY and UNIQUE constraints of a table, they are written as such.
+const sqliteAutoindexPrefix = "sqlite_autoindex_"
+
+// ColumnDefinition writes the definition of column in a CREATE TABLE or an ADD
+// COLUMN: its name, type, generation, nullability, default and identity.
+func (w *Writer) ColumnDefinition(column *dbstructs.Column) string {
+	parts := []string{w.Quote(column.ColumnName)}
+	dataType := w.ColumnType(column)
+	sequenceDefault := w.dialect == PostgreSQL && column.AutoIncrement && isSequenceDefault(column)
+	if serial, ok := serialTypes[strings.ToLower(dataType)]; ok && sequenceDefault {
+		dataType = serial
+	}
+
+	switch {
+	case column.Generated != "" && w.dialect == SQLServer:
+		// computed columns have no type
+		parts = append(parts, "AS "+parenthesized(column.Generated))
+	case column.Generated != "":
+		parts = append(parts, dataType, "GENERATED ALWAYS AS "+parenthesized(column.Generated))
+		if w.dialect == PostgreSQL {
+			parts = append(parts, "STORED")
+		}
+	case dataType != "":
+		parts = append(parts, dataType)
+	}
+
+	if column.AutoIncrement && w.dialect == SQLServer && column.Generated == "" {
+		parts = append(parts, "IDENTITY(1,1)")
+	}
+	if column.NotNull && column.Generated == "" {
+		parts = append(parts, "NOT NULL")
+	}
+	if column.Default != nil && column.Generated == "" && !sequenceDefault {
+		parts = append(parts, "DEFAULT "+w.defaultValue(column))
+	}
+	if column.AutoIncrement && column.Generated == "" {
+		switch {
+		case w.dialect == PostgreSQL && !sequenceDefault:
+			parts = append(parts, "GENERATED BY DEFAULT AS IDENTITY")
+		case w.dialect == MySQL:
+			parts = append(parts, "AUTO_INCREMENT")
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// rowidColumn returns the INTEGER PRIMARY KEY column of a SQLite table, an alias
+// of its rowid, or nil.
+func (w *Writer) rowidColumn(table *dbstructs.TableMetadata) *dbstructs.Column {
+	if w.dialect != SQLite || len(table.PrimaryKey) != 1 {
+		return nil
+	}
+	for _, column := range table.Columns {
+		if strings.EqualFold(column.ColumnName, table.PrimaryKey[0]) && strings.EqualFold(strings.TrimSpace(column.DataType), "INTEGER") {
+			return column
+		}
+	}
+	return nil
+}
+
+// CreateTable writes the CREATE TABLE statement of table with its columns,
+// primary key and checks. Its foreign keys are written when foreignKeys is set,
+// and always on SQLite which can't add them later. The indexes are left to
+// CreateIndex, but for the UNIQUE constraints of SQLite.
+func (w *Writer) CreateTable(table *dbstructs.TableMetadata, foreignKeys bool) string {
+	var definitions []string
+	rowid := w.rowidColumn(table)
+	for _, column := range table.Columns {
+		definition := w.ColumnDefinition(column)
+		if column == rowid {
+			definition += " PRIMARY KEY"
+		}
+		definitions = append(definitions, definition)
+	}
+	if len(table.PrimaryKey) > 0 && rowid == nil {
+		definitions = append(definitions, w.constraintName(w.primaryKeyName(table))+"PRIMARY KEY "+w.columnList(table.PrimaryKey))
+	}
+	if w.dialect == SQLite {
+		for _, index := range table.Indexes {
+			if index.Unique && !index.Primary && strings.HasPrefix(index.Name, sqliteAutoindexPrefix) {
+				definitions = append(definitions, "UNIQUE "+w.columnList(index.Columns))
+			}
+		}
+	}
+	for _, check := range table.CheckConstraints {
+		definitions = append(definitions, w.checkClause(check))
+	}
+	if foreignKeys || w.dialect == SQLite {
+		for _, relationship := range ForeignKeys(table) {
+			definitions = append(definitions, w.foreignKeyClause(relationship))
+		}
+	}
+	return "CREATE TABLE " + w.TableName(table) + " (\n  " + strings.Join(definitions, ",\n  ") + "\n)"
+}
+
+// DropTable writes the DROP TABLE statement of table.
+func (w *Writer) DropTable(table *dbstructs.TableMetadata) string {
+	return "DROP TABLE " + w.TableName(table)
+}
+
+// Indexes returns the indexes of table CreateIndex writes: all of them but the
+// primary key one, and on SQLite the ones of the UNIQUE constraints.
+func (w *Writer) Indexes(table *dbstructs.TableMetadata) []*dbstructs.Index {
+	var indexes []*dbstructs.Index
+	for _, index := range table.Indexes {
+		if !index.Primary && !w.IsConstraintIndex(index) {
+			indexes = append(indexes, index)
+		}
+	}
+	return indexes
+}
+
+// IsConstraintIndex tells whether index belongs to a SQLite constraint, which
+// only a new table can change.
+func (w *Writer) IsConstraintIndex(index *dbstructs.Index) bool {
+	return w.dialect == SQLite && strings.HasPrefix(index.Name, sqliteAutoindexPrefix)
+}
+
+// CreateIndex writes the CREATE INDEX statement of index on table.
+func (w *Writer) CreateIndex(table *dbstructs.TableMetadata, index *dbstructs.Index) string {
+	var b strings.Builder
+	b.WriteString("CREATE ")
+	method := strings.ToLower(index.Method)
+	if index.Unique {
+		b.WriteString("UNIQUE ")
+	}
+	switch {
+	case w.dialect == MySQL && (method == "fulltext" || method == "spatial"):
+		b.WriteString(strings.ToUpper(method) + " ")
+	case w.dialect == SQLServer && method == "clustered":
+		b.WriteString("CLUSTERED ")
+	}
+	b.WriteString("INDEX " + w.Quote(index.Name) + " ON " + w.TableName(table))
+	if w.dialect == PostgreSQL && method != "" && method != "btree" {
+		b.WriteString(" USING " + method)
+	}
+	b.WriteString(" (" + strings.Join(w.indexKeys(index), ", ") + ")")
+	if len(index.Include) > 0 && (w.dialect == PostgreSQL || w.dialect == SQLServer) {
+		b.WriteString(" INCLUDE " + w.columnList(index.Include))
+	}
+	if index.Predicate != "" {
+		if w.dialect == MySQL {
+			w.warn("MySQL has no partial indexes, the WHERE clause of %s is left out", index.Name)
+		} else {
+			b.WriteString(" WHERE " + index.Predicate)
+		}
+	}
+	return b.String()
+}
+
+func (w *Writer) indexKeys(index *dbstructs.Index) []string {
+	var keys []string
+	if len(index.Keys) == 0 {
+		for _, column := range index.Columns {
+			keys = append(keys, w.Quote(column))
+		}
+		return keys
+	}
+	for _, key := range index.Keys {
+		text := w.Quote(key.Column)
+		if key.Column == "" {
+			text = parenthesized(key.Expression)
+		}
+		if key.Descending {
+			text += " DESC"
+		}
+		keys = append(keys, text)
+	}
+	return keys
+}
+
+// DropIndex writes the statements dropping index from table. The unique
+// indexes of PostgreSQL and SQL Server may belong to a UNIQUE constraint, which
+// is dropped first when it exists.
+func (w *Writer) DropIndex(table *dbstructs.TableMetadata, index *dbstructs.Index) []string {
+	switch w.dialect {
+	case PostgreSQL:
+		drop := "DROP INDEX " + w.Name(table.Schema, index.Name)
+		if index.Unique {
+			return []string{w.alterTable(table, "DROP CONSTRAINT IF EXISTS "+w.Quote(index.Name)), "DROP INDEX IF EXISTS " + w.Name(table.Schema, index.Name)}
+		}
+		return []string{drop}
+	case SQLServer:
+		drop := "DROP INDEX " + w.Quote(index.Name) + " ON " + w.TableName(table)
+		if index.Unique {
+			return []string{w.alterTable(table, "DROP CONSTRAINT IF EXISTS "+w.Quote(index.Name)), "DROP INDEX IF EXISTS " + w.Quote(index.Name) + " ON " + w.TableName(table)}
+		}
+		return []string{drop}
+	case MySQL:
+		return []string{"DROP INDEX " + w.Quote(index.Name) + " ON " + w.TableName(table)}
+	}
+	return []string{"DROP INDEX " + w.Quote(index.Name)}
+}
+
+// RenameIndex writes the statement renaming the index from of table to to, or
+// "" on SQLite which can't.
+func (w *Writer) RenameIndex(table *dbstructs.TableMetadata, from, to string) string {
+	switch w.dialect {
+	case PostgreSQL:
+		return "ALTER INDEX " + w.Name(table.Schema, from) + " RENAME TO " + w.Quote(to)
+	case MySQL:
+		return w.alterTable(table, "RENAME INDEX "+w.Quote(from)+" TO "+w.Quote(to))
+	case SQLServer:
+		return "EXEC sp_rename " + String(w.TableName(table)+"."+w.Quote(from)) + ", " + String(to) + ", 'INDEX'"
+	}
+	return ""
+}
+
+func (w *Writer) alterTable(table *dbstructs.TableMetadata, action string) string {
+	return "ALTER TABLE " + w.TableName(table) + " " + action
+}
+
+func (w *Writer) constraintName(name string) string {
+	if name == "" {
+		return ""
+	}
+	return "CONSTRAINT " + w.Quote(name) + " "
+}
+
+// primaryKeyName is the name of the primary key constraint of table: the one
+// of its primary key index, or the default of the dialect.
+func (w *Writer) primaryKeyName(table *dbstructs.TableMetadata) string {
+	switch w.dialect {
+	case MySQL, SQLite:
+		// the primary key of MySQL is always PRIMARY, SQLite does not keep it
+		return ""
+	}
+	for _, index := range table.Indexes {
+		if index.Primary {
+			return index.Name
+		}
+	}
+	if w.dialect == SQLServer {
+		return "PK_" + table.TableName
+	}
+	return table.TableName + "_pkey"
+}
+
+func (w *Writer) checkClause(check *dbstructs.CheckConstraint) string {
+	return w.constraintName(check.Name) + "CHECK " + parenthesized(check.Expression)
+}
+
+// foreignKeyClause writes the FOREIGN KEY table constraint of relationship.
+// SQLite does not keep the names of the foreign keys, they are left out.
+func (w *Writer) foreignKeyClause(relationship *dbstructs.RelationshipMetadata) string {
+	var b strings.Builder
+	if w.dialect != SQLite {
+		b.WriteString(w.constraintName(relationship.Conname))
+	}
+	b.WriteString("FOREIGN KEY " + w.columnList(relationship.SourceColumns))
+	b.WriteString(" REFERENCES " + w.Name(relationship.RelatedSchema, relationship.RelatedTableName))
+	if len(relationship.TargetColumns) > 0 {
+		b.WriteString(" " + w.columnList(relationship.TargetColumns))
+	}
+	if w.dialect == PostgreSQL && strings.EqualFold(relationship.Match, "FULL") {
+		b.WriteString(" MATCH FULL")
+	}
+	for _, action := range []struct{ clause, action string }{{"ON DELETE", relationship.OnDelete}, {"ON UPDATE", relationship.OnUpdate}} {
+		if text := w.referentialAction(relationship, action.action); text != "" {
+			b.WriteString(" " + action.clause + " " + text)
+		}
+	}
+	if relationship.Deferrable && (w.dialect == PostgreSQL || w.dialect == SQLite) {
+		b.WriteString(" DEFERRABLE")
+		if relationship.InitiallyDeferred {
+			b.WriteString(" INITIALLY DEFERRED")
+		}
+	}
+	return b.String()
+}
+
+// referentialAction spells an ON DELETE or ON UPDATE action, "" for the
+// default NO ACTION.
+func (w *Writer) referentialAction(relationship *dbstructs.RelationshipMetadata, action string) string {
+	action = strings.ToUpper(strings.TrimSpace(action))
+	switch {
+	case action == "" || action == dbstructs.ReferentialActionNoAction:
+		return ""
+	case action == dbstructs.ReferentialActionRestrict && w.dialect == SQLServer:
+		// SQL Server checks at once, as RESTRICT does
+		return ""
+	case action == dbstructs.ReferentialActionSetDefault && w.dialect == MySQL:
+		w.warn("MySQL does not support SET DEFAULT, the foreign key %s uses NO ACTION", relationship.Conname)
+		return ""
+	}
+	return action
+}
@@ -0,0 +1,384 @@
+package sqlgen
+
+import (
+	"db_meta/dbstructs"
+	"fmt"
+	"strings"
+)
+
+// Conversion is a part of a schema that is not written the same in the target
+// dialect: a type that loses something, a default or an expression copied as
+// written, a clause the dialect lacks.
+type Conversion struct {
+	Table  string `json:"table"`
+	Object string `json:"object,omitempty"` // column, index or constraint
+	From   string `json:"from"`
+	To     string `json:"to"`
+	Note   string `json:"note"`
+}
+
+// translator writes the tables of a dialect as tables of the dialect of w.
+type translator struct {
+	from        string
+	w           *Writer
+	enums       map[string][]string // values of the PostgreSQL enum types, by lower case qualified name
+	indexNames  map[string]bool     // taken, index names being unique by schema on PostgreSQL and SQLite
+	conversions []*Conversion
+}
+
+// Translate writes tables, read by the connector of dialect from, as tables of
+// dialect to, along with the conversions to review. Column types go through
+// their CanonicalType, defaults and expressions are adapted when they are
+// known and copied otherwise. types are the user-defined types of the schema,
+// giving the values of the enums. tables are left untouched.
+func Translate(from, to string, tables []*dbstructs.TableMetadata, types []*dbstructs.TypeMetadata) ([]*dbstructs.TableMetadata, []*Conversion, error) {
+	w, err := NewWriter(to)
+	if err != nil {
+		return nil, nil, err
+	}
+	t := &translator{from: from, w: w, enums: make(map[string][]string), indexNames: make(map[string]bool)}
+	for _, typ := range types {
+		if typ.Kind == dbstructs.TypeKindEnum {
+			t.enums[strings.ToLower(typ.QualifiedName())] = typ.Values
+		}
+	}
+	translated := make([]*dbstructs.TableMetadata, 0, len(tables))
+	for _, table := range tables {
+		translated = append(translated, t.table(table))
+	}
+	return translated, t.conversions, nil
+}
+
+func (t *translator) convert(table *dbstructs.TableMetadata, object, from, to, format string, args ...interface{}) {
+	t.conversions = append(t.conversions, &Conversion{
+		Table:  table.QualifiedName(),
+		Object: object,
+		From:   from,
+		To:     to,
+		Note:   fmt.Sprintf(format, args...),
+	})
+}
+
+// schema maps the schemas of the source dialect to the ones of the target.
+func (t *translator) schema(schema string) string {
+	switch {
+	case t.from == MySQL || t.from == SQLite || t.w.dialect == MySQL || t.w.dialect == SQLite:
+		// the database name, or main, on one side or the other
+		return ""
+	case t.from == PostgreSQL && t.w.dialect == SQLServer && schema == "public":
+		return "dbo"
+	case t.from == SQLServer && t.w.dialect == PostgreSQL && schema == "dbo":
+		return "public"
+	}
+	return schema
+}
+
+func (t *translator) table(source *dbstructs.TableMetadata) *dbstructs.TableMetadata {
+	table := *source
+	table.Schema = t.schema(source.Schema)
+	table.Columns = nil
+	table.Indexes = nil
+	table.Relationships = nil
+	table.CheckConstraints = nil
+
+	for _, check := range source.CheckConstraints {
+		translated := *check
+		translated.Expression = t.expression(check.Expression)
+		t.convert(&table, check.Name, check.Expression, translated.Expression, "the check expression is copied, review its syntax")
+		table.CheckConstraints = append(table.CheckConstraints, &translated)
+	}
+	for _, column := range source.Columns {
+		table.Columns = append(table.Columns, t.column(&table, column))
+	}
+	for _, index := range source.Indexes {
+		table.Indexes = append(table.Indexes, t.index(&table, index))
+	}
+	for _, relationship := range ForeignKeys(source) {
+		table.Relationships = append(table.Relationships, t.foreignKey(&table, relationship))
+	}
+	return &table
+}
+
+func (t *translator) column(table *dbstructs.TableMetadata, source *dbstructs.Column) *dbstructs.Column {
+	canonical := Canonical(t.from, source, t.enums)
+	dataType, note := t.w.RenderType(canonical)
+	if note != "" {
+		t.convert(table, source.ColumnName, canonical.Name, dataType, "%s", note)
+	}
+
+	column := *source
+	column.DataType = dataType
+	column.CharacterLength, column.NumericPrecision, column.NumericScale = nil, nil, nil
+	column.UserType, column.EnumValues = "", nil
+
+	if canonical.Kind == TypeEnum && t.w.dialect != MySQL {
+		if len(canonical.Values) == 0 {
+			t.convert(table, source.ColumnName, canonical.Name, dataType, "the values of the enum are unknown")
+		} else {
+			// the values are checked instead
+			values := make([]string, 0, len(canonical.Values))
+			for _, value := range canonical.Values {
+				values = append(values, String(value))
+			}
+			table.CheckConstraints = append(table.CheckConstraints, &dbstructs.CheckConstraint{
+				Name:       table.TableName + "_" + source.ColumnName + "_check",
+				Columns:    []string{source.ColumnName},
+				Expression: t.w.Quote(source.ColumnName) + " IN (" + strings.Join(values, ", ") + ")",
+			})
+		}
+	}
+	if source.Generated != "" {
+		column.Generated = t.expression(source.Generated)
+		t.convert(table, source.ColumnName, source.Generated, column.Generated, "the generation expression is copied, review its syntax")
+	}
+	if source.Default != nil {
+		column.Default = t.defaultValue(table, canonical.Kind, source)
+	}
+	if source.AutoIncrement && t.w.dialect == SQLite &&
+		(len(table.PrimaryKey) != 1 || !strings.EqualFold(table.PrimaryKey[0], source.ColumnName) || dataType != "INTEGER") {
+		column.AutoIncrement = false
+		t.convert(table, source.ColumnName, canonical.Name, dataType, "SQLite only numbers INTEGER PRIMARY KEY columns, the column is no longer auto-incremented")
+	}
+	return &column
+}
+
+// Defaults known by all the dialects, by the lower case spellings of each
+var (
+	timestampDefaults = map[string]bool{
+		"current_timestamp": true, "current_timestamp()": true, "current_timestamp(6)": true, "now()": true, "now(6)": true,
+		"localtimestamp": true, "transaction_timestamp()": true, "statement_timestamp()": true, "clock_timestamp()": true,
+		"getdate()": true, "sysdatetime()": true, "datetime('now')": true,
+	}
+	dateDefaults = map[string]bool{"current_date": true, "curdate()": true, "date('now')": true, "cast(getdate() as date)": true}
+	uuidDefaults = map[string]bool{"gen_random_uuid()": true, "uuid_generate_v4()": true, "uuid()": true, "newid()": true, "newsequentialid()": true}
+)
+
+// defaultValue writes the default of column in the target dialect, kind being
+// the canonical kind of the column.
+func (t *translator) defaultValue(table *dbstructs.TableMetadata, kind string, column *dbstructs.Column) *string {
+	value := strings.TrimSpace(*column.Default)
+	if t.from == MySQL {
+		// MySQL reports string defaults without quotes
+		value = (&Writer{dialect: MySQL}).defaultValue(column)
+	}
+	value = unwrap(value)
+	if t.from == PostgreSQL {
+		value = uncast(value)
+	}
+	if strings.HasPrefix(value, "N'") || strings.HasPrefix(value, "n'") {
+		value = value[1:]
+	}
+
+	lower := strings.ToLower(value)
+	result := func(text string) *string {
+		return &text
+	}
+	switch {
+	case strings.HasPrefix(lower, "nextval("):
+		if !column.AutoIncrement {
+			t.convert(table, column.ColumnName, value, "", "the sequence default is dropped")
+		}
+		return nil
+	case kind == TypeBoolean && (lower == "true" || lower == "1" || lower == "b'1'" || lower == "'1'"):
+		return result(t.boolean(true))
+	case kind == TypeBoolean && (lower == "false" || lower == "0" || lower == "b'0'" || lower == "'0'"):
+		return result(t.boolean(false))
+	case lower == "null", isNumber(value), strings.HasPrefix(value, "'") && closingQuote(value) == len(value)-1:
+		return &value
+	case timestampDefaults[lower]:
+		if t.w.dialect == MySQL && (kind == TypeTimestamp || kind == TypeTimestampTZ) {
+			// of the precision of datetime(6)
+			return result("CURRENT_TIMESTAMP(6)")
+		}
+		return result("CURRENT_TIMESTAMP")
+	case dateDefaults[lower]:
+		switch t.w.dialect {
+		case MySQL:
+			return result("(CURRENT_DATE)")
+		case SQLServer:
+			return result("CAST(GETDATE() AS date)")
+		}
+		return result("CURRENT_DATE")
+	case uuidDefaults[lower]:
+		switch t.w.dialect {
+		case PostgreSQL:
+			return result("gen_random_uuid()")
+		case MySQL:
+			return result("uuid()")
+		case SQLServer:
+			return result("newid()")
+		}
+		t.convert(table, column.ColumnName, value, "", "SQLite has no UUID function, the default is dropped")
+		return nil
+	}
+	translated := t.expression(value)
+	t.convert(table, column.ColumnName, value, translated, "the default is copied, review its syntax")
+	return &translated
+}
+
+// boolean spells a boolean literal, the dialects other than PostgreSQL storing
+// booleans as numbers.
+func (t *translator) boolean(value bool) string {
+	switch {
+	case t.w.dialect == PostgreSQL && value:
+		return "true"
+	case t.w.dialect == PostgreSQL:
+		return "false"
+	case value:
+		return "1"
+	}
+	return "0"
+}
+
+// unwrap removes the parentheses wrapping the whole of an expression, as SQL
+// Server reports its defaults.
+func unwrap(expression string) string {
+	expression = strings.TrimSpace(expression)
+	for strings.HasPrefix(expression, "(") && closing(expression) == len(expression)-1 {
+		expression = strings.TrimSpace(expression[1 : len(expression)-1])
+	}
+	return expression
+}
+
+// uncast removes the PostgreSQL cast of a literal, as in 'new'::text.
+func uncast(expression string) string {
+	end := -1
+	switch {
+	case strings.HasPrefix(expression, "'"):
+		end = closingQuote(expression)
+	case strings.HasPrefix(expression, "("):
+		end = closing(expression)
+	}
+	if end > 0 && strings.HasPrefix(expression[end+1:], "::") {
+		return unwrap(expression[:end+1])
+	}
+	return expression
+}
+
+// closingQuote returns the index of the quote closing the string literal
+// expression starts with, or -1.
+func closingQuote(expression string) int {
+	for i := 1; i < len(expression); i++ {
+		if expression[i] == '\'' {
+			if i+1 < len(expression) && expression[i+1] == '\'' {
+				i++
+				continue
+			}
+			return i
+		}
+	}
+	return -1
+}
+
+// expression quotes again the identifiers of an expression of the source
+// dialect, string literals left as they are.
+func (t *translator) expression(expression string) string {
+	var b strings.Builder
+	for i := 0; i < len(expression); i++ {
+		char := expression[i]
+		var end byte
+		switch {
+		case char == '\'':
+			if length := closingQuote(expression[i:]); length > 0 {
+				b.WriteString(expression[i : i+length+1])
+				i += length
+				continue
+			}
+		case char == '`' && t.from == MySQL:
+			end = '`'
+		case char == '[' && t.from == SQLServer:
+			end = ']'
+		case char == '"' && t.from != MySQL:
+			end = '"'
+		}
+		if end == 0 {
+			b.WriteByte(char)
+			continue
+		}
+		length := strings.IndexByte(expression[i+1:], end)
+		if length < 0 {
+			b.WriteString(expression[i:])
+			break
+		}
+		b.WriteString(t.w.Quote(expression[i+1 : i+1+length]))
+		i += length + 1
+	}
+	return b.String()
+}
+
+// indexMethods are the methods each dialect writes, the others being dropped.
+var indexMethods = map[string][]string{
+	PostgreSQL: {"btree", "hash", "gin", "gist", "brin", "spgist"},
+	MySQL:      {"btree", "fulltext", "spatial"},
+	SQLServer:  {"clustered", "nonclustered"},
+}
+
+func (t *translator) index(table *dbstructs.TableMetadata, source *dbstructs.Index) *dbstructs.Index {
+	index := *source
+	if index.Primary {
+		if t.from == MySQL {
+			// always PRIMARY
+			index.Name = ""
+		}
+		return &index
+	}
+	if t.from == SQLite && strings.HasPrefix(index.Name, sqliteAutoindexPrefix) {
+		index.Name = table.TableName + "_" + strings.Join(index.Columns, "_") + "_key"
+	}
+	if t.w.dialect == PostgreSQL || t.w.dialect == SQLite {
+		key := strings.ToLower(table.Schema + "." + index.Name)
+		if t.indexNames[key] {
+			index.Name = table.TableName + "_" + index.Name
+			t.convert(table, source.Name, source.Name, index.Name, "index names are unique by schema, the index is renamed")
+			key = strings.ToLower(table.Schema + "." + index.Name)
+		}
+		t.indexNames[key] = true
+	}
+
+	method := strings.ToLower(index.Method)
+	if method != "" && !contains(indexMethods[t.w.dialect], method) {
+		index.Method = ""
+		switch method {
+		case "btree", "hash", "clustered", "nonclustered":
+		default:
+			t.convert(table, index.Name, method, "", "the %s index method is dropped", method)
+		}
+	}
+	if len(index.Include) > 0 && (t.w.dialect == MySQL || t.w.dialect == SQLite) {
+		t.convert(table, index.Name, strings.Join(index.Include, ", "), "", "the included columns are dropped")
+		index.Include = nil
+	}
+	if len(index.Keys) > 0 {
+		index.Keys = make([]*dbstructs.IndexKey, 0, len(source.Keys))
+		for _, key := range source.Keys {
+			translated := *key
+			if key.Expression != "" && key.Column == "" {
+				translated.Expression = t.expression(key.Expression)
+				t.convert(table, index.Name, key.Expression, translated.Expression, "the index expression is copied, review its syntax")
+			}
+			index.Keys = append(index.Keys, &translated)
+		}
+	}
+	if index.Predicate != "" && t.w.dialect != MySQL {
+		index.Predicate = t.expression(source.Predicate)
+		t.convert(table, index.Name, source.Predicate, index.Predicate, "the index predicate is copied, review its syntax")
+	}
+	return &index
+}
+
+func (t *translator) foreignKey(table *dbstructs.TableMetadata, source *dbstructs.RelationshipMetadata) *dbstructs.RelationshipMetadata {
+	relationship := *source
+	relationship.SourceSchema = t.schema(source.SourceSchema)
+	relationship.RelatedSchema = t.schema(source.RelatedSchema)
+	if t.from == SQLite {
+		// SQLite names the foreign keys after their columns
+		relationship.Conname = table.TableName + "_" + strings.Join(source.SourceColumns, "_") + "_fkey"
+	}
+	if source.Deferrable && (t.w.dialect == MySQL || t.w.dialect == SQLServer) {
+		t.convert(table, relationship.Conname, "DEFERRABLE", "", "the foreign key is checked at once")
+	}
+	if strings.EqualFold(source.Match, "FULL") && t.w.dialect != PostgreSQL {
+		t.convert(table, relationship.Conname, "MATCH FULL", "", "the foreign key is checked as MATCH SIMPLE")
+	}
+	return &relationship
+}
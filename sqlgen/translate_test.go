@@ -0,0 +1,208 @@
+package sqlgen
+
+import (
+	"db_meta/dbstructs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCanonical(t *testing.T) {
+	enums := map[string][]string{"public.mood": {"happy", "sad"}}
+	for _, test := range []struct {
+		dialect  string
+		column   *dbstructs.Column
+		expected CanonicalType
+	}{
+		{MySQL, &dbstructs.Column{DataType: "varchar", CharacterLength: intPointer(20)}, CanonicalType{Kind: TypeVarchar, Length: intPointer(20), Name: "varchar(20)"}},
+		{MySQL, &dbstructs.Column{DataType: "float", NumericPrecision: intPointer(12)}, CanonicalType{Kind: TypeReal, Name: "float"}},
+		{MySQL, &dbstructs.Column{DataType: "enum", EnumValues: []string{"a", "b"}}, CanonicalType{Kind: TypeEnum, Values: []string{"a", "b"}, Name: "enum('a','b')"}},
+		{SQLServer, &dbstructs.Column{DataType: "nvarchar"}, CanonicalType{Kind: TypeText, Name: "nvarchar(max)"}},
+		{SQLServer, &dbstructs.Column{DataType: "float", NumericPrecision: intPointer(53)}, CanonicalType{Kind: TypeDouble, Name: "float"}},
+		{SQLServer, &dbstructs.Column{DataType: "bit"}, CanonicalType{Kind: TypeBoolean, Name: "bit"}},
+		{SQLServer, &dbstructs.Column{DataType: "money", NumericPrecision: intPointer(19)}, CanonicalType{Kind: TypeDecimal, Precision: intPointer(19), Scale: intPointer(4), Name: "money"}},
+		{SQLite, &dbstructs.Column{DataType: "VARCHAR(40)"}, CanonicalType{Kind: TypeVarchar, Length: intPointer(40), Name: "VARCHAR(40)"}},
+		{SQLite, &dbstructs.Column{DataType: "INTEGER"}, CanonicalType{Kind: TypeBigInt, Name: "INTEGER"}},
+		{SQLite, &dbstructs.Column{DataType: "DATETIME"}, CanonicalType{Kind: TypeTimestamp, Name: "DATETIME"}},
+		{SQLite, &dbstructs.Column{DataType: "UNSIGNED BIG INT"}, CanonicalType{Kind: TypeBigInt, Name: "UNSIGNED BIG INT"}},
+		{SQLite, &dbstructs.Column{DataType: "NUMERIC(10, 2)"}, CanonicalType{Kind: TypeDecimal, Precision: intPointer(10), Scale: intPointer(2), Name: "NUMERIC(10, 2)"}},
+		{PostgreSQL, &dbstructs.Column{DataType: "numeric", NumericPrecision: intPointer(10), NumericScale: intPointer(2)}, CanonicalType{Kind: TypeDecimal, Precision: intPointer(10), Scale: intPointer(2), Name: "numeric(10,2)"}},
+		{PostgreSQL, &dbstructs.Column{DataType: "integer", NumericPrecision: intPointer(32), NumericScale: intPointer(0)}, CanonicalType{Kind: TypeInteger, Name: "integer"}},
+		{PostgreSQL, &dbstructs.Column{DataType: "USER-DEFINED", UserType: "public.mood"}, CanonicalType{Kind: TypeEnum, Values: []string{"happy", "sad"}, Name: `"public"."mood"`}},
+		{PostgreSQL, &dbstructs.Column{DataType: "ARRAY"}, CanonicalType{Kind: TypeOther, Name: "text[]"}},
+	} {
+		assert.Equal(t, test.expected, Canonical(test.dialect, test.column, enums), test.column.DataType)
+	}
+}
+
+func TestWriter_RenderType(t *testing.T) {
+	varchar := CanonicalType{Kind: TypeVarchar, Length: intPointer(20000), Name: "character varying(20000)"}
+	for dialect, expected := range map[string][2]string{
+		PostgreSQL: {"character varying(20000)", ""},
+		MySQL:      {"varchar(16383)", "the length 20000 is cut to 16383"},
+		SQLServer:  {"nvarchar(max)", ""},
+		SQLite:     {"VARCHAR(20000)", ""},
+	} {
+		w, _ := NewWriter(dialect)
+		dataType, note := w.RenderType(varchar)
+		assert.Equal(t, expected, [2]string{dataType, note}, dialect)
+	}
+
+	mysql, _ := NewWriter(MySQL)
+	dataType, note := mysql.RenderType(CanonicalType{Kind: TypeDecimal, Name: "numeric"})
+	assert.Equal(t, "decimal(65,10)", dataType)
+	assert.Equal(t, "the precision is unbounded, it is set to 65 with 10 decimals", note)
+	dataType, note = mysql.RenderType(CanonicalType{Kind: TypeOther, Name: "geography"})
+	assert.Equal(t, "longtext", dataType)
+	assert.Equal(t, "geography has no equivalent", note)
+}
+
+func mysqlTables() []*dbstructs.TableMetadata {
+	return []*dbstructs.TableMetadata{{
+		Schema:    "shop",
+		TableName: "orders",
+		Columns: []*dbstructs.Column{
+			{ColumnName: "id", DataType: "int", NotNull: true, AutoIncrement: true},
+			{ColumnName: "customer_id", DataType: "int", NotNull: true},
+			{ColumnName: "status", DataType: "enum", EnumValues: []string{"new", "paid"}, NotNull: true, Default: stringPointer("new")},
+			{ColumnName: "note", DataType: "varchar", CharacterLength: intPointer(200)},
+			{ColumnName: "urgent", DataType: "tinyint", Default: stringPointer("0")},
+			{ColumnName: "created_at", DataType: "datetime", Default: stringPointer("CURRENT_TIMESTAMP")},
+			{ColumnName: "total", DataType: "decimal", NumericPrecision: intPointer(10), NumericScale: intPointer(2)},
+		},
+		PrimaryKey: []string{"id"},
+		Indexes: []*dbstructs.Index{
+			{Name: "PRIMARY", Columns: []string{"id"}, Unique: true, Primary: true, Method: "btree"},
+			{Name: "orders_note", Columns: []string{"note"}, Method: "fulltext"},
+		},
+		Relationships: []*dbstructs.RelationshipMetadata{{
+			Conname: "orders_ibfk_1", SourceSchema: "shop", SourceTableName: "orders", RelatedSchema: "shop", RelatedTableName: "customers",
+			SourceColumns: []string{"customer_id"}, TargetColumns: []string{"id"}, OnDelete: dbstructs.ReferentialActionCascade,
+		}},
+		CheckConstraints: []*dbstructs.CheckConstraint{{Name: "orders_total", Columns: []string{"total"}, Expression: "(`total` >= 0)"}},
+	}}
+}
+
+func TestGenerateDDL(t *testing.T) {
+	tables := mysqlTables()
+	ddl, err := GenerateDDL(MySQL, PostgreSQL, tables, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		`CREATE TABLE "orders" (
+  "id" integer NOT NULL GENERATED BY DEFAULT AS IDENTITY,
+  "customer_id" integer NOT NULL,
+  "status" text NOT NULL DEFAULT 'new',
+  "note" character varying(200),
+  "urgent" smallint DEFAULT 0,
+  "created_at" timestamp without time zone DEFAULT CURRENT_TIMESTAMP,
+  "total" numeric(10,2),
+  PRIMARY KEY ("id"),
+  CONSTRAINT "orders_total" CHECK ("total" >= 0),
+  CONSTRAINT "orders_status_check" CHECK ("status" IN ('new', 'paid'))
+)`,
+		`CREATE INDEX "orders_note" ON "orders" ("note")`,
+		`ALTER TABLE "orders" ADD CONSTRAINT "orders_ibfk_1" FOREIGN KEY ("customer_id") REFERENCES "customers" ("id") ON DELETE CASCADE`,
+	}, ddl.Statements)
+	assert.Equal(t, []*Conversion{
+		{Table: "orders", Object: "orders_total", From: "(`total` >= 0)", To: `("total" >= 0)`, Note: "the check expression is copied, review its syntax"},
+		{Table: "orders", Object: "orders_note", From: "fulltext", Note: "the fulltext index method is dropped"},
+	}, ddl.Conversions)
+	assert.Equal(t, "shop", tables[0].Schema, "the tables are left untouched")
+
+	ddl, err = GenerateDDL(MySQL, MySQL, tables, nil)
+	assert.NoError(t, err)
+	assert.Empty(t, ddl.Conversions)
+	assert.Contains(t, ddl.SQL(), "CREATE FULLTEXT INDEX `orders_note` ON `orders` (`note`);\n\n")
+
+	_, err = GenerateDDL(MySQL, "oracle", tables, nil)
+	assert.Error(t, err)
+}
+
+func TestTranslate(t *testing.T) {
+	tables := []*dbstructs.TableMetadata{{
+		Schema:    "public",
+		TableName: "accounts",
+		Columns: []*dbstructs.Column{
+			{ColumnName: "id", DataType: "integer", NotNull: true, AutoIncrement: true, Default: stringPointer("nextval('accounts_id_seq'::regclass)")},
+			{ColumnName: "token", DataType: "uuid", Default: stringPointer("gen_random_uuid()")},
+			{ColumnName: "active", DataType: "boolean", Default: stringPointer("true")},
+			{ColumnName: "kind", DataType: "character varying", CharacterLength: intPointer(10), Default: stringPointer("'basic'::character varying")},
+			{ColumnName: "settings", DataType: "jsonb", Default: stringPointer("'{}'::jsonb")},
+			{ColumnName: "mood", DataType: "USER-DEFINED", UserType: "public.mood"},
+			{ColumnName: "seen", DataType: "date", Default: stringPointer("CURRENT_DATE")},
+			{ColumnName: "score", DataType: "integer", Default: stringPointer("abs(-1)")},
+		},
+		PrimaryKey: []string{"id"},
+		Indexes: []*dbstructs.Index{
+			{Name: "accounts_pkey", Columns: []string{"id"}, Unique: true, Primary: true},
+			{Name: "accounts_settings", Columns: []string{"settings"}, Method: "gin"},
+			{Name: "accounts_active", Columns: []string{"lower(kind)"}, Keys: []*dbstructs.IndexKey{{Expression: `lower("kind")`}}, Predicate: "active"},
+		},
+	}}
+	types := []*dbstructs.TypeMetadata{{Schema: "public", TypeName: "mood", Kind: dbstructs.TypeKindEnum, Values: []string{"ok"}}}
+
+	translated, conversions, err := Translate(PostgreSQL, SQLServer, tables, types)
+	assert.NoError(t, err)
+	w, _ := NewWriter(SQLServer)
+	assert.Equal(t, `CREATE TABLE [dbo].[accounts] (
+  [id] int IDENTITY(1,1) NOT NULL,
+  [token] uniqueidentifier DEFAULT newid(),
+  [active] bit DEFAULT 1,
+  [kind] nvarchar(10) DEFAULT 'basic',
+  [settings] nvarchar(max) DEFAULT '{}',
+  [mood] nvarchar(255),
+  [seen] date DEFAULT CAST(GETDATE() AS date),
+  [score] int DEFAULT abs(-1),
+  CONSTRAINT [accounts_pkey] PRIMARY KEY ([id]),
+  CONSTRAINT [accounts_mood_check] CHECK ([mood] IN ('ok'))
+)`, w.CreateTable(translated[0], false))
+	assert.Equal(t, `CREATE INDEX [accounts_active] ON [dbo].[accounts] ((lower([kind]))) WHERE active`, w.CreateIndex(translated[0], translated[0].Indexes[2]))
+	var notes []string
+	for _, conversion := range conversions {
+		notes = append(notes, conversion.Object+": "+conversion.Note)
+	}
+	assert.Equal(t, []string{
+		"settings: JSON is stored as text",
+		"score: the default is copied, review its syntax",
+		"accounts_settings: the gin index method is dropped",
+		"accounts_active: the index expression is copied, review its syntax",
+		"accounts_active: the index predicate is copied, review its syntax",
+	}, notes)
+	assert.Equal(t, "public", tables[0].Schema)
+	assert.Equal(t, "USER-DEFINED", tables[0].Columns[5].DataType)
+
+	translated, conversions, err = Translate(PostgreSQL, SQLite, tables, types)
+	assert.NoError(t, err)
+	assert.Equal(t, "INTEGER", translated[0].Columns[0].DataType)
+	assert.True(t, translated[0].Columns[0].AutoIncrement)
+	assert.Nil(t, translated[0].Columns[1].Default)
+	assert.Contains(t, conversions, &Conversion{Table: "accounts", Object: "token", From: "gen_random_uuid()", Note: "SQLite has no UUID function, the default is dropped"})
+}
+
+func TestTranslate_sqliteNames(t *testing.T) {
+	tables := []*dbstructs.TableMetadata{
+		{
+			TableName:  "customers",
+			Columns:    []*dbstructs.Column{{ColumnName: "id", DataType: "INTEGER", AutoIncrement: true}, {ColumnName: "name", DataType: "TEXT", NotNull: true}},
+			PrimaryKey: []string{"id"},
+			Indexes:    []*dbstructs.Index{{Name: "sqlite_autoindex_customers_1", Columns: []string{"name"}, Unique: true}, {Name: "by_name", Columns: []string{"name"}}},
+		},
+		{
+			TableName:  "orders",
+			Columns:    []*dbstructs.Column{{ColumnName: "id", DataType: "INTEGER", AutoIncrement: true}, {ColumnName: "customer_id", DataType: "INT"}},
+			PrimaryKey: []string{"id"},
+			Indexes:    []*dbstructs.Index{{Name: "by_name", Columns: []string{"customer_id"}}},
+			Relationships: []*dbstructs.RelationshipMetadata{{
+				Conname: "customer_id", SourceTableName: "orders", RelatedTableName: "customers",
+				SourceColumns: []string{"customer_id"}, TargetColumns: []string{"id"},
+			}},
+		},
+	}
+	translated, conversions, err := Translate(SQLite, PostgreSQL, tables, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "customers_name_key", translated[0].Indexes[0].Name)
+	assert.Equal(t, "orders_by_name", translated[1].Indexes[0].Name)
+	assert.Equal(t, "orders_customer_id_fkey", translated[1].Relationships[0].Conname)
+	assert.Equal(t, "bigint", translated[1].Columns[1].DataType)
+	assert.Equal(t, []*Conversion{{Table: "orders", Object: "by_name", From: "by_name", To: "orders_by_name", Note: "index names are unique by schema, the index is renamed"}}, conversions)
+}
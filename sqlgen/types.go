@@ -0,0 +1,101 @@
+package sqlgen
+
+import (
+	"db_meta/dbstructs"
+	"fmt"
+	"strings"
+)
+
+// ColumnType spells the type of column, as reported by the connector of the
+// dialect, with its length, precision and scale.
+func (w *Writer) ColumnType(column *dbstructs.Column) string {
+	dataType := column.DataType
+	lowerType := strings.ToLower(dataType)
+	if strings.Contains(dataType, "(") || w.dialect == SQLite {
+		// declared types are kept as written
+		return dataType
+	}
+
+	switch w.dialect {
+	case PostgreSQL:
+		if column.UserType != "" {
+			// enums, composite types and domains, a domain being reported as its
+			// base type
+			return w.qualifiedName(column.UserType)
+		}
+		if dataType == "ARRAY" {
+			w.warn("the element type of the ARRAY column %s is unknown, it is written as text[]", column.ColumnName)
+			return "text[]"
+		}
+	case MySQL:
+		if (lowerType == "enum" || lowerType == "set") && len(column.EnumValues) > 0 {
+			values := make([]string, 0, len(column.EnumValues))
+			for _, value := range column.EnumValues {
+				values = append(values, String(value))
+			}
+			return dataType + "(" + strings.Join(values, ",") + ")"
+		}
+	}
+
+	switch {
+	case column.CharacterLength == nil && w.dialect == SQLServer && (lowerType == "varchar" || lowerType == "nvarchar" || lowerType == "varbinary"):
+		// SQL Server reports no length for (max)
+		return dataType + "(max)"
+	case column.CharacterLength != nil && (strings.Contains(lowerType, "char") || strings.Contains(lowerType, "binary") || strings.HasPrefix(lowerType, "bit")):
+		if *column.CharacterLength < 0 {
+			return dataType + "(max)"
+		}
+		return fmt.Sprintf("%s(%d)", dataType, *column.CharacterLength)
+	case column.NumericPrecision != nil && (lowerType == "numeric" || lowerType == "decimal"):
+		if column.NumericScale != nil {
+			return fmt.Sprintf("%s(%d,%d)", dataType, *column.NumericPrecision, *column.NumericScale)
+		}
+		return fmt.Sprintf("%s(%d)", dataType, *column.NumericPrecision)
+	}
+	return dataType
+}
+
+// serialTypes are the PostgreSQL serial types of the integer types, whose
+// default takes the next value of the sequence they own.
+var serialTypes = map[string]string{"integer": "serial", "bigint": "bigserial", "smallint": "smallserial"}
+
+// isSequenceDefault tells whether the default of column is the nextval of a
+// PostgreSQL sequence, as serial columns have.
+func isSequenceDefault(column *dbstructs.Column) bool {
+	return column.Default != nil && strings.HasPrefix(strings.TrimSpace(*column.Default), "nextval(")
+}
+
+// defaultValue spells the default of column. MySQL reports string defaults
+// without quotes, and expressions without the parentheses they are written in.
+func (w *Writer) defaultValue(column *dbstructs.Column) string {
+	value := strings.TrimSpace(*column.Default)
+	if w.dialect != MySQL {
+		return value
+	}
+	upper := strings.ToUpper(value)
+	switch {
+	case upper == "NULL", strings.HasPrefix(value, "'"), strings.HasPrefix(value, "("), isNumber(value),
+		strings.HasPrefix(upper, "CURRENT_TIMESTAMP"), strings.HasPrefix(upper, "B'"), strings.HasPrefix(upper, "X'"):
+		return value
+	case strings.Contains(value, "(") && strings.HasSuffix(value, ")"):
+		return "(" + value + ")"
+	}
+	return String(value)
+}
+
+func isNumber(value string) bool {
+	if value == "" {
+		return false
+	}
+	digits := 0
+	for i, char := range value {
+		switch {
+		case char >= '0' && char <= '9':
+			digits++
+		case (char == '-' || char == '+') && i == 0, char == '.':
+		default:
+			return false
+		}
+	}
+	return digits > 0
+}